@@ -3,80 +3,197 @@ package main
 import (
 	"log"
 	"os"
-	"path/filepath"
+	"os/exec"
+	"os/signal"
+	"strings"
 	"syscall"
+
+	"github.com/urizennnn/boxify/pkg/container"
+)
+
+var containerEnv = []string{
+	"PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin",
+	"TERM=xterm",
+	"HOME=/root",
+	"HOSTNAME=container",
+}
+
+// workDirEnv and userEnv carry the image's working directory and user from
+// the daemon. They are not passed on to the container's command.
+const (
+	workDirEnv = "BOXIFY_WORKDIR"
+	userEnv    = "BOXIFY_USER"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "pause" {
+		pause(os.Args[2:])
+		return
+	}
+
 	if len(os.Args) < 5 {
-		log.Fatalf("Usage: boxify-init <containerID> <memory> <cpu> <mergedDir>")
+		log.Fatalf("Usage: boxify-init <containerID> <memory> <cpu> <mergedDir> [command...]")
 	}
 
-	containerID := os.Args[1]
 	mergedDir := os.Args[4]
+	command := os.Args[5:]
 
-	if err := pivotRoot(mergedDir); err != nil {
-		log.Fatalf("Error: failed to pivot root: %v\n", err)
+	// Nothing of the container runs until the daemon has moved init into
+	// its cgroup and network.
+	sync := container.InheritedSync()
+	if err := sync.Expect(container.SyncGo); err != nil {
+		log.Fatalf("Error: failed waiting for the daemon: %v\n", err)
 	}
 
-	defer os.RemoveAll("/var/lib/boxify/boxify-container/" + containerID)
-	defer os.RemoveAll("/sys/fs/cgroup/boxify/")
+	path, user, stage, err := setup(mergedDir, command)
+	if err != nil {
+		fail(sync, stage, err)
+	}
+	if err := sync.Send(container.SyncReady); err != nil {
+		log.Fatalf("Error: failed to report to the daemon: %v\n", err)
+	}
 
-	setupMounts()
+	// The container is created; its command runs once it is started.
+	if err := sync.Expect(container.SyncStart); err != nil {
+		log.Fatalf("Error: failed waiting for start: %v\n", err)
+	}
 
+	if len(command) > 0 {
+		if user != "" {
+			if err := switchUser(user); err != nil {
+				fail(sync, "switch to user "+user, err)
+			}
+		}
+		log.Printf("executing %v\n", command)
+		if err := syscall.Exec(path, command, containerEnv); err != nil {
+			fail(sync, "exec "+command[0], err)
+		}
+	}
+
+	sync.Send(container.SyncStarted)
+	sync.Close()
 	log.Println("Container ready, waiting for attach...")
 
-	for {
-		syscall.Pause()
-	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	sig := <-signals
+	log.Printf("Received %v, shutting down\n", sig)
 }
 
-func pivotRoot(newRoot string) error {
-	log.Printf("pivoting root to %s\n", newRoot)
-
-	putOld := filepath.Join(newRoot, ".pivot_root")
-	if err := os.MkdirAll(putOld, 0700); err != nil {
-		return err
+// pause is the infra process of a pod. It holds the namespaces the pod's
+// containers join, sets the pod's hostname and sleeps until it is stopped.
+func pause(args []string) {
+	if len(args) > 0 && args[0] != "" {
+		if err := syscall.Sethostname([]byte(args[0])); err != nil {
+			log.Fatalf("Error: failed to set hostname: %v\n", err)
+		}
 	}
 
-	if err := syscall.Mount(newRoot, newRoot, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
-		return err
-	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	<-signals
+}
 
-	if err := syscall.PivotRoot(newRoot, putOld); err != nil {
-		return err
+// fail reports a setup failure to the daemon and exits.
+func fail(sync *container.Sync, stage string, err error) {
+	log.Printf("Error: failed to %s: %v\n", stage, err)
+	if err := sync.SendError(stage, err); err != nil {
+		log.Printf("Error: failed to report to the daemon: %v\n", err)
 	}
+	os.Exit(1)
+}
 
-	if err := os.Chdir("/"); err != nil {
-		return err
+// setup pivots into the container's root and prepares the command's
+// environment and working directory. It returns the resolved command and
+// the user to run it as, or the stage that failed.
+func setup(mergedDir string, command []string) (path, user, stage string, err error) {
+	if err := container.PivotRoot(mergedDir); err != nil {
+		return "", "", "pivot root", err
 	}
-
-	putOld = "/.pivot_root"
-	if err := syscall.Unmount(putOld, syscall.MNT_DETACH); err != nil {
-		return err
+	if stage, err := setupMounts(); err != nil {
+		return "", "", stage, err
 	}
 
-	if err := os.RemoveAll(putOld); err != nil {
-		return err
+	// The daemon starts boxify-init with the container's environment, which
+	// overrides the defaults.
+	workDir := os.Getenv(workDirEnv)
+	user = os.Getenv(userEnv)
+	for _, env := range os.Environ() {
+		key, value, _ := strings.Cut(env, "=")
+		if key != workDirEnv && key != userEnv {
+			containerEnv = setEnv(containerEnv, key, value)
+		}
+	}
+	if workDir != "" {
+		if err := os.MkdirAll(workDir, 0o755); err != nil {
+			return "", "", "create working directory", err
+		}
+		if err := os.Chdir(workDir); err != nil {
+			return "", "", "change to working directory", err
+		}
 	}
 
-	log.Printf("successfully pivoted root\n")
-	return nil
+	if len(command) == 0 {
+		return "", user, "", nil
+	}
+	os.Clearenv()
+	for _, env := range containerEnv {
+		key, value, _ := strings.Cut(env, "=")
+		os.Setenv(key, value)
+	}
+	path, err = exec.LookPath(command[0])
+	if err != nil {
+		return "", "", "find command " + command[0], err
+	}
+	return path, user, "", nil
 }
 
-func setupMounts() {
+func setupMounts() (string, error) {
 	log.Printf("setting up proc mount\n")
 	if err := syscall.Mount("proc", "/proc", "proc", 0, ""); err != nil {
-		log.Fatalf("Error mounting proc: %v\n", err)
+		return "mount proc", err
 	}
 
 	log.Printf("setting up sys mount\n")
 	if err := syscall.Mount("sysfs", "/sys", "sysfs", 0, ""); err != nil {
-		log.Fatalf("Error mounting sys: %v\n", err)
+		return "mount sys", err
 	}
 
 	log.Printf("setting up dev mount\n")
 	if err := syscall.Mount("tmpfs", "/dev", "tmpfs", 0, ""); err != nil {
-		log.Fatalf("Error mounting dev: %v\n", err)
+		return "mount dev", err
+	}
+	return "", nil
+}
+
+// setEnv replaces the value of key in env or appends it.
+func setEnv(env []string, key, value string) []string {
+	for i, entry := range env {
+		if name, _, _ := strings.Cut(entry, "="); name == key {
+			env[i] = key + "=" + value
+			return env
+		}
+	}
+	return append(env, key+"="+value)
+}
+
+// switchUser changes to spec, "user[:group]" given as names from the
+// container's /etc/passwd and /etc/group or as numeric IDs. Without a group
+// the user's primary and supplementary groups are used.
+func switchUser(spec string) error {
+	u, err := container.ResolveUser("/", spec)
+	if err != nil {
+		return err
+	}
+	if u.Home != "" {
+		containerEnv = setEnv(containerEnv, "HOME", u.Home)
+	}
+
+	if err := syscall.Setgroups(u.Groups); err != nil {
+		return err
+	}
+	if err := syscall.Setgid(u.GID); err != nil {
+		return err
 	}
+	return syscall.Setuid(u.UID)
 }
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/urizennnn/boxify/pkg/oci"
+	"golang.org/x/sys/unix"
+)
+
+func init() {
+	// The container's init sets capabilities and execs from one thread, so
+	// it must never be moved between threads.
+	if len(os.Args) > 1 && os.Args[1] == "init" {
+		runtime.GOMAXPROCS(1)
+		runtime.LockOSThread()
+	}
+}
+
+var (
+	root    string
+	logFile string
+	debug   bool
+)
+
+var rootCmd = &cobra.Command{
+	Use:   "boxify-oci",
+	Short: "Run OCI runtime bundles with boxify",
+	Long: `boxify-oci is an OCI runtime built on boxify's namespace, pivot_root and
+cgroup v2 code. It runs a bundle, a directory with a config.json and the
+root filesystem it names, through the create, start, state, kill and delete
+operations of the OCI runtime specification, so tools that drive runc can
+drive boxify as well.`,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if cmd.Name() == "init" {
+			if !debug {
+				log.SetOutput(io.Discard)
+			}
+			return nil
+		}
+		if logFile != "" {
+			file, err := os.OpenFile(logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+			if err != nil {
+				return err
+			}
+			log.SetOutput(file)
+		}
+		return nil
+	},
+}
+
+var createCmd = &cobra.Command{
+	Use:   "create [flags] CONTAINER",
+	Short: "Create a container from a bundle",
+	Long: `Set up the container's namespaces, root filesystem and cgroup and leave
+its process waiting for start.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		bundle, _ := cmd.Flags().GetString("bundle")
+		pidFile, _ := cmd.Flags().GetString("pid-file")
+		if socket, _ := cmd.Flags().GetString("console-socket"); socket != "" {
+			return fmt.Errorf("--console-socket is not supported, process.terminal must be false")
+		}
+		return runtimeFor().Create(args[0], bundle, pidFile)
+	},
+}
+
+var startCmd = &cobra.Command{
+	Use:   "start CONTAINER",
+	Short: "Start the process of a created container",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runtimeFor().Start(args[0])
+	},
+}
+
+var stateCmd = &cobra.Command{
+	Use:   "state CONTAINER",
+	Short: "Print the state of a container as JSON",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		state, err := runtimeFor().State(args[0])
+		if err != nil {
+			return err
+		}
+		return printJSON(state)
+	},
+}
+
+var killCmd = &cobra.Command{
+	Use:   "kill [flags] CONTAINER [SIGNAL]",
+	Short: "Send a signal to a container's process",
+	Long:  `Send SIGNAL, SIGTERM by default, given by name or number.`,
+	Args:  cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sig := syscall.SIGTERM
+		if len(args) == 2 {
+			var err error
+			if sig, err = parseSignal(args[1]); err != nil {
+				return err
+			}
+		}
+		all, _ := cmd.Flags().GetBool("all")
+		return runtimeFor().Kill(args[0], sig, all)
+	},
+}
+
+var deleteCmd = &cobra.Command{
+	Use:   "delete [flags] CONTAINER",
+	Short: "Delete a stopped container",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		force, _ := cmd.Flags().GetBool("force")
+		return runtimeFor().Delete(args[0], force)
+	},
+}
+
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List containers",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		states, err := runtimeFor().List()
+		if err != nil {
+			return err
+		}
+		if format, _ := cmd.Flags().GetString("format"); format == "json" {
+			if states == nil {
+				states = []*oci.State{}
+			}
+			return printJSON(states)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "ID\tPID\tSTATUS\tBUNDLE")
+		for _, s := range states {
+			fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", s.ID, s.Pid, s.Status, s.Bundle)
+		}
+		return w.Flush()
+	},
+}
+
+var initCmd = &cobra.Command{
+	Use:    "init",
+	Short:  "Set up a container from the inside (internal)",
+	Hidden: true,
+	Args:   cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		// Init reports its errors to the runtime, which prints them.
+		if err := oci.Init(); err != nil {
+			log.Printf("Error: %v", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&root, "root", oci.DefaultRoot, "directory for container state")
+	rootCmd.PersistentFlags().StringVar(&logFile, "log", "", "file to write logs to instead of stderr")
+	rootCmd.PersistentFlags().BoolVar(&debug, "debug", false, "log from inside the container during setup")
+	// Accepted for compatibility with callers written for runc.
+	rootCmd.PersistentFlags().String("log-format", "text", "log format, only text is supported")
+	rootCmd.PersistentFlags().Bool("systemd-cgroup", false, "not supported, cgroupsPath must be a path")
+
+	createCmd.Flags().StringP("bundle", "b", ".", "path to the bundle directory")
+	createCmd.Flags().String("pid-file", "", "file to write the container's PID to")
+	createCmd.Flags().String("console-socket", "", "not supported")
+	killCmd.Flags().BoolP("all", "a", false, "signal every process in the container's cgroup")
+	deleteCmd.Flags().BoolP("force", "f", false, "kill the container if it is still running")
+	listCmd.Flags().StringP("format", "f", "table", "output format, table or json")
+
+	rootCmd.AddCommand(createCmd, startCmd, stateCmd, killCmd, deleteCmd, listCmd, initCmd)
+}
+
+func main() {
+	if err := rootCmd.Execute(); err != nil {
+		if logFile != "" {
+			log.Printf("Error: %v", err)
+		}
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func runtimeFor() *oci.Runtime {
+	return &oci.Runtime{Root: root}
+}
+
+func printJSON(v any) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// parseSignal accepts a signal as a number or a name with or without the
+// SIG prefix.
+func parseSignal(raw string) (syscall.Signal, error) {
+	if n, err := strconv.Atoi(raw); err == nil && n > 0 && n < 65 {
+		return syscall.Signal(n), nil
+	}
+	name := strings.ToUpper(raw)
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	if sig := unix.SignalNum(name); sig != 0 {
+		return sig, nil
+	}
+	return 0, fmt.Errorf("invalid signal %q", raw)
+}
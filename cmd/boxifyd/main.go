@@ -1,10 +1,18 @@
 package main
 
 import (
+	"flag"
+
 	"github.com/urizennnn/boxify/pkg/daemon"
+	"github.com/urizennnn/boxify/pkg/network"
 )
 
 func main() {
-	d := daemon.New()
+	var netOpts network.Options
+	flag.BoolVar(&netOpts.IPv6, "ipv6", false, "Give the default bridge network and its containers IPv6 addresses")
+	flag.StringVar(&netOpts.IPv6Subnet, "ipv6-subnet", "", "IPv6 subnet of the default bridge network (default: the stored one or a generated unique local /64)")
+	flag.Parse()
+
+	d := daemon.New(netOpts)
 	d.Init()
 }
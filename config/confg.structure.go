@@ -1,24 +1,85 @@
 package config
 
-import "github.com/urizennnn/boxify/pkg/daemon/types"
+import (
+	"github.com/urizennnn/boxify/pkg/daemon/types"
+	"gopkg.in/yaml.v3"
+)
 
 type ConfigStructure struct {
-	ImageName string   `yaml:"image_name" json:"image_name"`
-	Settings  Settings `yaml:"settings" json:"settings"`
+	Name        string            `yaml:"name" json:"name"`
+	ImageName   string            `yaml:"image_name" json:"image_name"`
+	Image       string            `yaml:"image" json:"image"`
+	Command     []string          `yaml:"command" json:"command"`
+	Labels      map[string]string `yaml:"labels" json:"labels"`
+	Annotations map[string]string `yaml:"annotations" json:"annotations"`
+	Volumes     []string          `yaml:"volumes" json:"volumes"`
+	Restart     string            `yaml:"restart" json:"restart"`
+	NetworkMode string            `yaml:"network_mode" json:"network_mode"`
+	HealthCheck *HealthCheck      `yaml:"healthcheck" json:"healthcheck"`
+	Settings    Settings          `yaml:"settings" json:"settings"`
+}
+
+type HealthCheck struct {
+	Test               HealthTest `yaml:"test" json:"test"`
+	Interval           string     `yaml:"interval" json:"interval"`
+	Timeout            string     `yaml:"timeout" json:"timeout"`
+	Retries            int        `yaml:"retries" json:"retries"`
+	StartPeriod        string     `yaml:"start_period" json:"start_period"`
+	RestartOnUnhealthy bool       `yaml:"restart_on_unhealthy" json:"restart_on_unhealthy"`
+}
+
+// HealthTest is either a list such as ["CMD", "curl", "-f", "localhost"] or
+// a plain string, which is run through the shell like ["CMD-SHELL", ...].
+type HealthTest []string
+
+func (t *HealthTest) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*t = HealthTest{"CMD-SHELL", value.Value}
+		return nil
+	}
+
+	var list []string
+	if err := value.Decode(&list); err != nil {
+		return err
+	}
+	*t = list
+	return nil
 }
 
 type Settings struct {
-	MemoryLimit string `yaml:"memory_limit" json:"memory_limit"`
-	CpuLimit    string `yaml:"cpu_limit" json:"cpu_limit"`
+	MemoryLimit       string   `yaml:"memory_limit" json:"memory_limit"`
+	MemoryReservation string   `yaml:"memory_reservation" json:"memory_reservation"`
+	MemorySwap        string   `yaml:"memory_swap" json:"memory_swap"`
+	CpuLimit          string   `yaml:"cpu_limit" json:"cpu_limit"`
+	Cpus              string   `yaml:"cpus" json:"cpus"`
+	CpuShares         int      `yaml:"cpu_shares" json:"cpu_shares"`
+	CpuPeriod         int      `yaml:"cpu_period" json:"cpu_period"`
+	CpuQuota          int      `yaml:"cpu_quota" json:"cpu_quota"`
+	CpusetCpus        string   `yaml:"cpuset_cpus" json:"cpuset_cpus"`
+	CpusetMems        string   `yaml:"cpuset_mems" json:"cpuset_mems"`
+	IOWeight          int      `yaml:"io_weight" json:"io_weight"`
+	DeviceReadBps     []string `yaml:"device_read_bps" json:"device_read_bps"`
+	DeviceWriteBps    []string `yaml:"device_write_bps" json:"device_write_bps"`
+	DeviceReadIOps    []string `yaml:"device_read_iops" json:"device_read_iops"`
+	DeviceWriteIOps   []string `yaml:"device_write_iops" json:"device_write_iops"`
+	PidsLimit         int      `yaml:"pids_limit" json:"pids_limit"`
 }
 
+// NetworkStorage is a network as stored in /var/lib/boxify/networks. The
+// default network is a bridge; macvlan and ipvlan networks have no bridge
+// and put their containers on the Parent interface's segment instead.
 type NetworkStorage struct {
-	Id         string             `yaml:"id" json:"id"`
-	Name       string             `yaml:"name" json:"name"`
-	CreatedAt  string             `yaml:"created_at" json:"created_at"`
-	Bridge     NetworkBridge      `yaml:"bridge" json:"bridge"`
-	Ipam       NetworkIpam        `yaml:"ipam" json:"ipam"`
-	Containers []*types.Container `yaml:"containers" json:"containers"`
+	Id          string             `yaml:"id" json:"id"`
+	Name        string             `yaml:"name" json:"name"`
+	Driver      string             `yaml:"driver" json:"driver"`
+	Parent      string             `yaml:"parent,omitempty" json:"parent,omitempty"`
+	Mode        string             `yaml:"mode,omitempty" json:"mode,omitempty"`
+	CreatedAt   string             `yaml:"created_at" json:"created_at"`
+	Labels      map[string]string  `yaml:"labels" json:"labels"`
+	Annotations map[string]string  `yaml:"annotations" json:"annotations"`
+	Bridge      NetworkBridge      `yaml:"bridge" json:"bridge"`
+	Ipam        NetworkIpam        `yaml:"ipam" json:"ipam"`
+	Containers  []*types.Container `yaml:"containers" json:"containers"`
 }
 
 type NetworkBridge struct {
@@ -26,9 +87,15 @@ type NetworkBridge struct {
 	Mtu  int    `yaml:"mtu" json:"mtu"`
 }
 
+// NetworkIpam holds a network's IPv4 addresses and, on dual-stack networks,
+// its IPv6 ones. Subnet6 is empty when the network has no IPv6.
 type NetworkIpam struct {
-	Subnet       string            `yaml:"subnet" json:"subnet"`
-	Gateway      string            `yaml:"gateway" json:"gateway"`
-	NextIP       string            `yaml:"next_ip" json:"next_ip"`
-	AllocatedIPs map[string]string `yaml:"allocated_ips" json:"allocated_ips"`
+	Subnet        string            `yaml:"subnet" json:"subnet"`
+	Gateway       string            `yaml:"gateway" json:"gateway"`
+	NextIP        string            `yaml:"next_ip" json:"next_ip"`
+	AllocatedIPs  map[string]string `yaml:"allocated_ips" json:"allocated_ips"`
+	Subnet6       string            `yaml:"subnet6,omitempty" json:"subnet6,omitempty"`
+	Gateway6      string            `yaml:"gateway6,omitempty" json:"gateway6,omitempty"`
+	NextIP6       string            `yaml:"next_ip6,omitempty" json:"next_ip6,omitempty"`
+	AllocatedIPs6 map[string]string `yaml:"allocated_ips6,omitempty" json:"allocated_ips6,omitempty"`
 }
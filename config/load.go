@@ -0,0 +1,31 @@
+package config
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+var DefaultFiles = []string{"boxify.yaml", "boxify.yml"}
+
+// Load reads the first config file that exists from DefaultFiles. It returns
+// an empty config when none of them exist.
+func Load() (*ConfigStructure, error) {
+	for _, path := range DefaultFiles {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+
+		var fileConfig ConfigStructure
+		if err := yaml.Unmarshal(data, &fileConfig); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		return &fileConfig, nil
+	}
+	return &ConfigStructure{}, nil
+}
@@ -0,0 +1,274 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Conditions a service can wait for in depends_on.
+const (
+	ConditionStarted   = "service_started"
+	ConditionHealthy   = "service_healthy"
+	ConditionCompleted = "service_completed_successfully"
+)
+
+// DefaultNetwork is the network services join when they list none. It is
+// the daemon's bridge network.
+const DefaultNetwork = "default"
+
+var validServiceName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// Project is a boxify.yaml with a services section. Each service becomes one
+// container; the top-level name is the project's name rather than a
+// container's.
+type Project struct {
+	Name     string                     `yaml:"name" json:"name"`
+	Services map[string]*Service        `yaml:"services" json:"services"`
+	Networks map[string]*ProjectNetwork `yaml:"networks" json:"networks"`
+	Volumes  map[string]*ProjectVolume  `yaml:"volumes" json:"volumes"`
+}
+
+// Service has the settings of a single-container boxify.yaml, without the
+// container name, which is derived from the project and service names.
+type Service struct {
+	Image       string            `yaml:"image" json:"image"`
+	Command     []string          `yaml:"command" json:"command"`
+	Labels      map[string]string `yaml:"labels" json:"labels"`
+	Annotations map[string]string `yaml:"annotations" json:"annotations"`
+	Volumes     []string          `yaml:"volumes" json:"volumes"`
+	Restart     string            `yaml:"restart" json:"restart"`
+	NetworkMode string            `yaml:"network_mode" json:"network_mode"`
+	HealthCheck *HealthCheck      `yaml:"healthcheck" json:"healthcheck"`
+	Settings    Settings          `yaml:"settings" json:"settings"`
+	DependsOn   DependsOn         `yaml:"depends_on" json:"depends_on"`
+	Networks    []string          `yaml:"networks" json:"networks"`
+}
+
+// DependsOn maps the services a service needs to the condition they must
+// reach before it is started. It is written either as a list of service
+// names, each waited for until it started, or as a map with a condition per
+// service.
+type DependsOn map[string]Dependency
+
+type Dependency struct {
+	Condition string `yaml:"condition" json:"condition"`
+}
+
+func (d *DependsOn) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.SequenceNode {
+		var names []string
+		if err := value.Decode(&names); err != nil {
+			return err
+		}
+		*d = DependsOn{}
+		for _, name := range names {
+			(*d)[name] = Dependency{Condition: ConditionStarted}
+		}
+		return nil
+	}
+
+	var deps map[string]Dependency
+	if err := value.Decode(&deps); err != nil {
+		return err
+	}
+	for name, dep := range deps {
+		if dep.Condition == "" {
+			dep.Condition = ConditionStarted
+			deps[name] = dep
+		}
+	}
+	*d = deps
+	return nil
+}
+
+// ProjectNetwork declares a network services can join. Only external
+// networks are supported, optionally renamed with name. Each service joins
+// at most one network, the default bridge network when it lists none.
+type ProjectNetwork struct {
+	External bool   `yaml:"external" json:"external"`
+	Name     string `yaml:"name" json:"name"`
+}
+
+// ProjectVolume declares a named volume. Volumes are created as
+// <project>_<volume> unless they are external, in which case name, or the
+// key, must name an existing volume.
+type ProjectVolume struct {
+	External bool              `yaml:"external" json:"external"`
+	Name     string            `yaml:"name" json:"name"`
+	Labels   map[string]string `yaml:"labels" json:"labels"`
+}
+
+// LoadProject reads the project file at path, or the first of DefaultFiles
+// when path is empty, and validates it. It returns the project and the path
+// it was read from.
+func LoadProject(path string) (*Project, string, error) {
+	candidates := DefaultFiles
+	if path != "" {
+		candidates = []string{path}
+	}
+
+	for _, candidate := range candidates {
+		data, err := os.ReadFile(candidate)
+		if err != nil {
+			if os.IsNotExist(err) && path == "" {
+				continue
+			}
+			return nil, "", fmt.Errorf("failed to read %s: %w", candidate, err)
+		}
+
+		var project Project
+		if err := yaml.Unmarshal(data, &project); err != nil {
+			return nil, "", fmt.Errorf("failed to parse %s: %w", candidate, err)
+		}
+		if len(project.Services) == 0 {
+			return nil, "", fmt.Errorf("%s has no services section", candidate)
+		}
+		if err := project.Validate(); err != nil {
+			return nil, "", fmt.Errorf("%s: %w", candidate, err)
+		}
+		return &project, candidate, nil
+	}
+	return nil, "", fmt.Errorf("no project file found, looked for %s", strings.Join(DefaultFiles, " and "))
+}
+
+// Validate checks service names, dependencies and the networks and volumes
+// services refer to.
+func (p *Project) Validate() error {
+	for name, network := range p.Networks {
+		if network == nil {
+			p.Networks[name] = &ProjectNetwork{}
+			network = p.Networks[name]
+		}
+		if !network.External && name != DefaultNetwork {
+			return fmt.Errorf("network %s: only external networks are supported, create it with \"boxify network create\" and mark it external", name)
+		}
+	}
+	for name, v := range p.Volumes {
+		if v == nil {
+			p.Volumes[name] = &ProjectVolume{}
+		}
+	}
+
+	for name, service := range p.Services {
+		if service == nil {
+			return fmt.Errorf("service %s is empty", name)
+		}
+		if !validServiceName.MatchString(name) {
+			return fmt.Errorf("invalid service name %q, only [a-zA-Z0-9][a-zA-Z0-9_.-] are allowed", name)
+		}
+
+		for dep, dependency := range service.DependsOn {
+			if _, ok := p.Services[dep]; !ok {
+				return fmt.Errorf("service %s depends on undefined service %s", name, dep)
+			}
+			switch dependency.Condition {
+			case ConditionStarted, ConditionCompleted:
+			case ConditionHealthy:
+				if p.Services[dep].HealthCheck == nil {
+					return fmt.Errorf("service %s waits for %s to be healthy, but %s has no healthcheck", name, dep, dep)
+				}
+			default:
+				return fmt.Errorf("service %s: invalid depends_on condition %q for %s", name, dependency.Condition, dep)
+			}
+		}
+
+		if service.NetworkMode != "" && len(service.Networks) > 0 {
+			return fmt.Errorf("service %s: network_mode cannot be combined with networks", name)
+		}
+		if len(service.Networks) > 1 {
+			return fmt.Errorf("service %s: containers can only join one network", name)
+		}
+		for _, network := range service.Networks {
+			if _, ok := p.Networks[network]; !ok && network != DefaultNetwork {
+				return fmt.Errorf("service %s uses undefined network %s", name, network)
+			}
+		}
+
+		for _, spec := range service.Volumes {
+			volumeName, _, _ := strings.Cut(spec, ":")
+			if _, ok := p.Volumes[volumeName]; !ok {
+				return fmt.Errorf("service %s uses undefined volume %s", name, volumeName)
+			}
+		}
+	}
+
+	_, err := p.ServiceOrder()
+	return err
+}
+
+// ServiceOrder returns the service names so that every service comes after
+// the services it depends on. Services that do not depend on each other are
+// sorted by name.
+func (p *Project) ServiceOrder() ([]string, error) {
+	names := make([]string, 0, len(p.Services))
+	for name := range p.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := map[string]int{}
+	var order []string
+
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case done:
+			return nil
+		case visiting:
+			return fmt.Errorf("dependency cycle: %s", strings.Join(append(path, name), " -> "))
+		}
+		state[name] = visiting
+
+		deps := make([]string, 0, len(p.Services[name].DependsOn))
+		for dep := range p.Services[name].DependsOn {
+			deps = append(deps, dep)
+		}
+		sort.Strings(deps)
+		for _, dep := range deps {
+			if err := visit(dep, append(path, name)); err != nil {
+				return err
+			}
+		}
+
+		state[name] = done
+		order = append(order, name)
+		return nil
+	}
+
+	for _, name := range names {
+		if err := visit(name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// VolumeName is the name of the project volume key on the daemon.
+func (p *Project) VolumeName(projectName, key string) string {
+	v := p.Volumes[key]
+	switch {
+	case v.Name != "":
+		return v.Name
+	case v.External:
+		return key
+	}
+	return projectName + "_" + key
+}
+
+// NetworkName is the name of the network key on the daemon.
+func (p *Project) NetworkName(key string) string {
+	if n, ok := p.Networks[key]; ok && n.Name != "" {
+		return n.Name
+	}
+	return key
+}
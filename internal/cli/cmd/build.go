@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/urizennnn/boxify/pkg/archive"
+	"github.com/urizennnn/boxify/pkg/build"
+	"github.com/urizennnn/boxify/pkg/image"
+)
+
+var (
+	buildFile    string
+	buildTag     string
+	buildNoCache bool
+	buildQuiet   bool
+)
+
+var buildCmd = &cobra.Command{
+	Use:   "build [flags] CONTEXT",
+	Short: "Build an image from a Boxfile",
+	Long: `Build an image from the Boxfile in a build context directory.
+
+A Boxfile is a Dockerfile subset. It starts with FROM, an image or "scratch",
+followed by RUN, COPY, ADD, ENV, WORKDIR, USER, ENTRYPOINT, CMD, EXPOSE and
+LABEL instructions. RUN commands execute in a throwaway container of the
+image built so far; their changes become a new layer. COPY and ADD take files
+from the build context, and ADD also extracts local tar archives.
+
+Every step is cached by the image it runs on, the instruction and, for COPY
+and ADD, the contents of the copied files. Unchanged steps are reused on the
+next build unless --no-cache is given.
+
+The context directory is sent to the daemon as a tar stream. Paths matching
+the patterns in its .boxifyignore file are left out.`,
+	Example: `  # Build the Boxfile in the current directory
+  boxify build -t myapp:v1 .
+
+  # Use another Boxfile from the context
+  boxify build -f docker/Boxfile.dev -t myapp:dev .
+
+  # Rebuild every step and print only the image ID
+  boxify build --no-cache -q .`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		id, err := buildImage(args[0])
+		if err != nil {
+			exitWithError(err)
+		}
+		if buildQuiet {
+			fmt.Println(image.ShortID(id))
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(buildCmd)
+	buildCmd.Flags().StringVarP(&buildFile, "file", "f", "", "Path to the Boxfile (default CONTEXT/Boxfile)")
+	buildCmd.Flags().StringVarP(&buildTag, "tag", "t", "", "Name and optionally a tag in the name:tag format")
+	buildCmd.Flags().BoolVar(&buildNoCache, "no-cache", false, "Do not use the build cache")
+	buildCmd.Flags().BoolVarP(&buildQuiet, "quiet", "q", false, "Suppress the build output and print the image ID")
+}
+
+// buildImage sends the context to the daemon and prints the build output.
+// It returns the ID of the built image.
+func buildImage(contextDir string) (string, error) {
+	contextDir, err := filepath.Abs(contextDir)
+	if err != nil {
+		return "", err
+	}
+	if info, err := os.Stat(contextDir); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("build context %s is not a directory", contextDir)
+	}
+
+	boxfile := build.DefaultBoxfile
+	if buildFile != "" {
+		abs, err := filepath.Abs(buildFile)
+		if err != nil {
+			return "", err
+		}
+		rel, err := filepath.Rel(contextDir, abs)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			return "", fmt.Errorf("the Boxfile %s must be inside the build context", buildFile)
+		}
+		boxfile = filepath.ToSlash(rel)
+	}
+
+	ignore, err := build.ReadIgnore(contextDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", build.IgnoreFile, err)
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(archive.TarContext(pw, contextDir, func(rel string, info os.FileInfo) bool {
+			// The Boxfile is always sent, even when it is ignored.
+			if rel == boxfile || strings.HasPrefix(boxfile, rel+"/") {
+				return false
+			}
+			if info.IsDir() {
+				return ignore.SkipDir(rel)
+			}
+			return ignore.Ignored(rel)
+		}))
+	}()
+	defer pr.Close()
+
+	query := url.Values{}
+	query.Set("boxfile", path.Clean(boxfile))
+	if buildTag != "" {
+		query.Set("t", buildTag)
+	}
+	if buildNoCache {
+		query.Set("nocache", "true")
+	}
+
+	resp, err := daemonRaw(http.MethodPost, "/build", query, pr, "application/x-tar")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var message struct {
+			Stream string `json:"stream"`
+			Error  string `json:"error"`
+			ID     string `json:"id"`
+		}
+		if err := decoder.Decode(&message); err != nil {
+			if err == io.EOF {
+				return "", errors.New("build ended without a result")
+			}
+			return "", fmt.Errorf("failed to read build output: %w", err)
+		}
+
+		switch {
+		case message.Error != "":
+			return "", errors.New(message.Error)
+		case message.ID != "":
+			return message.ID, nil
+		case !buildQuiet:
+			fmt.Print(message.Stream)
+		}
+	}
+}
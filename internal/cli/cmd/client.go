@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const daemonSocket = "/var/run/boxify.sock"
+
+func newDaemonClient() *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return net.Dial("unix", daemonSocket)
+			},
+		},
+	}
+}
+
+// daemonRequest sends a request to boxifyd and decodes the JSON response into
+// out when out is non-nil.
+func daemonRequest(method, path string, query url.Values, body interface{}, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := newDaemonRequest(method, path, query, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := doDaemonRequest(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// daemonStream performs a GET request and hands back the open response so
+// the caller can stream the body. The caller must close it.
+func daemonStream(path string, query url.Values) (*http.Response, error) {
+	return daemonRaw(http.MethodGet, path, query, nil, "")
+}
+
+// daemonRaw sends body as is rather than JSON encoded and hands back the
+// open response. The caller must close it.
+func daemonRaw(method, path string, query url.Values, body io.Reader, contentType string) (*http.Response, error) {
+	req, err := newDaemonRequest(method, path, query, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	return doDaemonRequest(req)
+}
+
+func newDaemonRequest(method, path string, query url.Values, body io.Reader) (*http.Request, error) {
+	target := "http://unix" + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	return http.NewRequest(method, target, body)
+}
+
+func doDaemonRequest(req *http.Request) (*http.Response, error) {
+	resp, err := newDaemonClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to boxifyd: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		message, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("daemon returned %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	return resp, nil
+}
+
+func daemonGet(path string, query url.Values, out interface{}) error {
+	return daemonRequest(http.MethodGet, path, query, nil, out)
+}
+
+func daemonPost(path string, query url.Values, body interface{}, out interface{}) error {
+	return daemonRequest(http.MethodPost, path, query, body, out)
+}
+
+func daemonDelete(path string, query url.Values) error {
+	return daemonRequest(http.MethodDelete, path, query, nil, nil)
+}
+
+// containerPath builds an endpoint path for a container reference, which may
+// be an ID, ID prefix or name.
+func containerPath(ref, suffix string) string {
+	return "/containers/" + url.PathEscape(ref) + suffix
+}
+
+func exitWithError(err error) {
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	os.Exit(1)
+}
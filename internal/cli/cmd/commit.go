@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/urizennnn/boxify/pkg/daemon/requests"
+	"github.com/urizennnn/boxify/pkg/image"
+)
+
+var (
+	commitMessage string
+	commitAuthor  string
+	commitChanges []string
+	commitPause   bool
+)
+
+var commitCmd = &cobra.Command{
+	Use:   "commit [flags] CONTAINER [REPOSITORY[:TAG]]",
+	Short: "Create a new image from a container's changes",
+	Long: `Create an image from everything a container changed on top of its image.
+
+The container's upper layer becomes a new layer on top of the image's layers.
+Deleted files are recorded as whiteouts, so they stay deleted in the new
+image. Volume mount points are not committed.
+
+The new image keeps the container's command, environment and working
+directory. --change applies Boxfile instructions on top: ENV, LABEL, WORKDIR,
+USER, EXPOSE, ENTRYPOINT and CMD. A running container is paused while its
+files are read unless --pause=false is given.`,
+	Example: `  # Save a container as a new image
+  boxify commit web myapp:v1
+
+  # Record a message and change the default command
+  boxify commit -m "install curl" -c 'CMD ["curl", "--version"]' web myapp:curl
+
+  # Set environment and working directory for containers of the image
+  boxify commit -c "ENV MODE=dev" -c "WORKDIR /app" web myapp:dev`,
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		request := requests.CommitRequest{
+			Comment: commitMessage,
+			Author:  commitAuthor,
+			Changes: commitChanges,
+			Pause:   commitPause,
+		}
+		if len(args) == 2 {
+			request.Reference = args[1]
+		}
+
+		var response struct {
+			ID string `json:"id"`
+		}
+		if err := daemonPost(containerPath(args[0], "/commit"), nil, request, &response); err != nil {
+			exitWithError(err)
+		}
+		fmt.Println(image.ShortID(response.ID))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(commitCmd)
+	commitCmd.Flags().StringVarP(&commitMessage, "message", "m", "", "Commit message")
+	commitCmd.Flags().StringVarP(&commitAuthor, "author", "a", "", `Author (e.g. "Jane Doe <jane@example.com>")`)
+	commitCmd.Flags().StringArrayVarP(&commitChanges, "change", "c", nil, "Apply a Boxfile instruction to the image config")
+	commitCmd.Flags().BoolVarP(&commitPause, "pause", "p", true, "Pause the container while committing")
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/spf13/cobra"
+)
+
+var containerPruneFilters []string
+
+var containerCmd = &cobra.Command{
+	Use:   "container",
+	Short: "Manage containers",
+}
+
+var containerPruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove all stopped containers",
+	Long: `Remove every exited container, optionally narrowed down with label
+filters (label=<key> or label=<key>=<value>).`,
+	Example: `  # Remove all stopped containers of a team
+  boxify container prune --filter label=team=payments`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		query := url.Values{}
+		if err := filterQuery(query, containerPruneFilters); err != nil {
+			exitWithError(err)
+		}
+
+		var result struct {
+			Deleted []string `json:"deleted"`
+		}
+		if err := daemonPost("/containers/prune", query, nil, &result); err != nil {
+			exitWithError(err)
+		}
+
+		for _, id := range result.Deleted {
+			fmt.Println(id)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(containerCmd)
+	containerCmd.AddCommand(containerPruneCmd)
+
+	containerPruneCmd.Flags().StringArrayVarP(&containerPruneFilters, "filter", "f", nil, "Filter containers to prune (label=...)")
+}
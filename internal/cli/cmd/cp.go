@@ -0,0 +1,209 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/urizennnn/boxify/pkg/archive"
+)
+
+var cpCmd = &cobra.Command{
+	Use:   "cp CONTAINER:SRC_PATH DEST_PATH|-\n  boxify cp SRC_PATH|- CONTAINER:DEST_PATH",
+	Short: "Copy files between a container and the local filesystem",
+	Long: `Copy files or directories between a container and the host.
+
+Paths inside the container are resolved within its root filesystem, so
+symlinks cannot point the copy outside of it. The container does not need to
+be running.
+
+If the destination is an existing directory the source is copied into it,
+otherwise it is copied under the destination's name. Use "-" to write a tar
+archive to stdout or to extract one from stdin into a container directory.
+Local paths containing a colon must start with "./" or "/".`,
+	Example: `  # Pull a log file out of a container
+  boxify cp web:/var/log/app.log ./app.log
+
+  # Drop a config file into a container
+  boxify cp ./nginx.conf web:/etc/nginx/nginx.conf
+
+  # Copy a directory out as a tar archive
+  boxify cp web:/etc - > etc.tar`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		srcContainer, srcPath := splitCpArg(args[0])
+		destContainer, destPath := splitCpArg(args[1])
+
+		var err error
+		switch {
+		case srcContainer != "" && destContainer != "":
+			err = errors.New("copying between containers is not supported")
+		case srcContainer != "":
+			err = copyFromContainer(srcContainer, srcPath, destPath)
+		case destContainer != "":
+			err = copyToContainer(srcPath, destContainer, destPath)
+		default:
+			err = errors.New("one of the paths must be CONTAINER:PATH")
+		}
+		if err != nil {
+			exitWithError(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(cpCmd)
+}
+
+// splitCpArg splits CONTAINER:PATH. Absolute and ./ relative paths are always
+// local so that local paths can contain colons.
+func splitCpArg(arg string) (string, string) {
+	if filepath.IsAbs(arg) || strings.HasPrefix(arg, ".") {
+		return "", arg
+	}
+	ref, p, ok := strings.Cut(arg, ":")
+	if !ok {
+		return "", arg
+	}
+	return ref, p
+}
+
+func copyFromContainer(ref, src, dest string) error {
+	query := url.Values{}
+	query.Set("path", src)
+	resp, err := daemonStream(containerPath(ref, "/archive"), query)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if dest == "-" {
+		_, err := io.Copy(os.Stdout, resp.Body)
+		return err
+	}
+
+	srcStat, err := decodePathStat(resp.Header)
+	if err != nil {
+		return err
+	}
+
+	destInfo, err := os.Stat(dest)
+	switch {
+	case err == nil && destInfo.IsDir():
+		return archive.Untar(resp.Body, dest, "/")
+	case err == nil && srcStat.Mode.IsDir():
+		return fmt.Errorf("cannot copy directory %s to file %s", src, dest)
+	case err != nil && !os.IsNotExist(err):
+		return err
+	case err != nil && strings.HasSuffix(dest, "/"):
+		return fmt.Errorf("destination directory %s does not exist", dest)
+	}
+
+	// Copy under the destination's name into its parent directory.
+	parent := filepath.Dir(dest)
+	if _, err := os.Stat(parent); err != nil {
+		return err
+	}
+	renamed := archive.Rebase(resp.Body, srcStat.Name, filepath.Base(dest))
+	defer renamed.Close()
+	return archive.Untar(renamed, parent, "/")
+}
+
+func copyToContainer(src, ref, dest string) error {
+	if src == "-" {
+		return putArchive(ref, dest, os.Stdin)
+	}
+
+	srcInfo, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+
+	destStat, exists, err := statContainerPath(ref, dest)
+	if err != nil {
+		return err
+	}
+
+	name := filepath.Base(src)
+	destDir := dest
+	switch {
+	case exists && destStat.Mode.IsDir():
+	case exists && srcInfo.IsDir():
+		return fmt.Errorf("cannot copy directory %s to file %s", src, dest)
+	case !exists && strings.HasSuffix(dest, "/"):
+		return fmt.Errorf("destination directory %s does not exist", dest)
+	default:
+		name = path.Base(dest)
+		destDir = path.Dir(dest)
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(archive.Tar(pw, src, name))
+	}()
+	defer pr.Close()
+	return putArchive(ref, destDir, pr)
+}
+
+func putArchive(ref, dir string, body io.Reader) error {
+	query := url.Values{}
+	query.Set("path", dir)
+	resp, err := daemonRaw(http.MethodPut, containerPath(ref, "/archive"), query, body, "application/x-tar")
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// statContainerPath reports whether p exists in the container and what it
+// is.
+func statContainerPath(ref, p string) (archive.PathStat, bool, error) {
+	query := url.Values{}
+	query.Set("path", p)
+	req, err := newDaemonRequest(http.MethodHead, containerPath(ref, "/archive"), query, nil)
+	if err != nil {
+		return archive.PathStat{}, false, err
+	}
+
+	resp, err := newDaemonClient().Do(req)
+	if err != nil {
+		return archive.PathStat{}, false, fmt.Errorf("cannot connect to boxifyd: %w", err)
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		// Either the path or the container is missing, only the latter is
+		// an error.
+		if err := daemonGet(containerPath(ref, "/json"), nil, nil); err != nil {
+			return archive.PathStat{}, false, err
+		}
+		return archive.PathStat{}, false, nil
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return archive.PathStat{}, false, fmt.Errorf("daemon returned %s", resp.Status)
+	}
+
+	stat, err := decodePathStat(resp.Header)
+	return stat, err == nil, err
+}
+
+func decodePathStat(header http.Header) (archive.PathStat, error) {
+	var stat archive.PathStat
+	data, err := base64.StdEncoding.DecodeString(header.Get(archive.PathStatHeader))
+	if err != nil {
+		return stat, fmt.Errorf("invalid path stat from daemon: %w", err)
+	}
+	if err := json.Unmarshal(data, &stat); err != nil {
+		return stat, fmt.Errorf("invalid path stat from daemon: %w", err)
+	}
+	return stat, nil
+}
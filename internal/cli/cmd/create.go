@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var startAttach bool
+
+var createCmd = &cobra.Command{
+	Use:   "create [flags] [COMMAND] [ARG...]",
+	Short: "Create a new container without starting it",
+	Long: `Create a new container and print its ID.
+
+The container's filesystem, network and cgroup are set up and its init
+process waits inside the container's namespaces, but the command does not
+run until "boxify start". Setup failures, such as a command that does not
+exist in the image, are reported here rather than on start.
+
+create accepts the same flags and config file as run, except --detach.`,
+	Example: `  # Prepare a container and start it later
+  boxify create --name web --memory 256m -- httpd -f
+  boxify start web`,
+	Run: func(cmd *cobra.Command, args []string) {
+		request, err := buildCreateRequest(cmd, args)
+		if err != nil {
+			exitWithError(err)
+		}
+
+		var created createResponse
+		if err := daemonPost("/containers/create", nil, request, &created); err != nil {
+			exitWithError(err)
+		}
+		fmt.Println(created.ID)
+	},
+}
+
+var startCmd = &cobra.Command{
+	Use:   "start [flags] CONTAINER [CONTAINER...]",
+	Short: "Start one or more created or stopped containers",
+	Long: `Start containers made with "boxify create", or start stopped containers
+again with a fresh process.`,
+	Example: `  # Start a created container
+  boxify start web
+
+  # Start it and follow its output
+  boxify start -a job`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if startAttach {
+			if len(args) != 1 {
+				exitWithError(fmt.Errorf("--attach needs exactly one container"))
+			}
+			if err := daemonPost(containerPath(args[0], "/start"), nil, nil, nil); err != nil {
+				exitWithError(err)
+			}
+			if err := streamLogs(args[0], true); err != nil {
+				exitWithError(err)
+			}
+			return
+		}
+		postEach(args, "/start")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(createCmd)
+	rootCmd.AddCommand(startCmd)
+
+	addCreateFlags(createCmd)
+	startCmd.Flags().BoolVarP(&startAttach, "attach", "a", false, "Follow the container's output")
+}
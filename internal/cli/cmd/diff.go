@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/urizennnn/boxify/pkg/daemon/types"
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff CONTAINER",
+	Short: "Inspect changes to files or directories on a container's filesystem",
+	Long: `List the paths a container changed compared to its image, read from the
+container's overlay upper layer. Each line starts with the kind of change:
+
+  A  the path was added
+  C  the path was changed
+  D  the path was deleted
+
+Volume mount points are not listed.`,
+	Example: `  # See what a job wrote
+  boxify diff web`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var changes []types.Change
+		if err := daemonGet(containerPath(args[0], "/changes"), nil, &changes); err != nil {
+			exitWithError(err)
+		}
+		for _, change := range changes {
+			fmt.Printf("%s %s\n", change.Kind, change.Path)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+}
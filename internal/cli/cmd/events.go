@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/urizennnn/boxify/pkg/events"
+)
+
+var (
+	eventsSince   string
+	eventsUntil   string
+	eventsFilters []string
+	eventsFormat  string
+)
+
+var eventsCmd = &cobra.Command{
+	Use:   "events",
+	Short: "Stream real-time events from the daemon",
+	Long: `Print container, network and volume events as they happen.
+
+--since replays events the daemon still has buffered, and --until stops the
+stream at the given time. Both accept a Unix timestamp, an RFC 3339 time or a
+duration such as 10m, which is taken relative to now.
+
+Supported filters are type, event, container and label. The --format flag
+accepts "text" (default), "json" or a Go template.`,
+	Example: `  # Stream all events
+  boxify events
+
+  # Show what happened to a container in the last hour
+  boxify events --since 1h --until 0s -f container=web
+
+  # Only die and health events
+  boxify events -f event=die -f event=health_status`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := streamEvents(); err != nil {
+			exitWithError(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(eventsCmd)
+	eventsCmd.Flags().StringVar(&eventsSince, "since", "", "Show events created since timestamp or relative duration")
+	eventsCmd.Flags().StringVar(&eventsUntil, "until", "", "Stream events until timestamp or relative duration")
+	eventsCmd.Flags().StringArrayVarP(&eventsFilters, "filter", "f", nil, "Filter output based on conditions (key=value)")
+	eventsCmd.Flags().StringVar(&eventsFormat, "format", "text", "Output format: text, json or a Go template")
+}
+
+func streamEvents() error {
+	query := url.Values{}
+	if eventsSince != "" {
+		query.Set("since", eventsSince)
+	}
+	if eventsUntil != "" {
+		query.Set("until", eventsUntil)
+	}
+	if err := filterQuery(query, eventsFilters); err != nil {
+		return err
+	}
+
+	printEvent, err := eventPrinter(eventsFormat)
+	if err != nil {
+		return err
+	}
+
+	resp, err := daemonStream("/events", query)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		var e events.Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return fmt.Errorf("invalid event from daemon: %w", err)
+		}
+		if err := printEvent(e, scanner.Bytes()); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func eventPrinter(format string) (func(e events.Event, raw []byte) error, error) {
+	switch format {
+	case "text":
+		return func(e events.Event, _ []byte) error {
+			fmt.Println(formatEvent(e))
+			return nil
+		}, nil
+	case "json":
+		return func(_ events.Event, raw []byte) error {
+			fmt.Println(string(raw))
+			return nil
+		}, nil
+	}
+
+	tmpl, err := template.New("format").Parse(format)
+	if err != nil {
+		return nil, fmt.Errorf("invalid format template: %w", err)
+	}
+	return func(e events.Event, _ []byte) error {
+		if err := tmpl.Execute(os.Stdout, e); err != nil {
+			return err
+		}
+		fmt.Println()
+		return nil
+	}, nil
+}
+
+// formatEvent renders an event as
+// "<time> <type> <action> <id> (key=value, ...)".
+func formatEvent(e events.Event) string {
+	keys := make([]string, 0, len(e.Actor.Attributes))
+	for key := range e.Actor.Attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	attributes := make([]string, 0, len(keys))
+	for _, key := range keys {
+		attributes = append(attributes, key+"="+e.Actor.Attributes[key])
+	}
+
+	timestamp := time.Unix(0, e.TimeNano).Format(time.RFC3339Nano)
+	return fmt.Sprintf("%s %s %s %s (%s)", timestamp, e.Type, e.Action, e.Actor.ID, strings.Join(attributes, ", "))
+}
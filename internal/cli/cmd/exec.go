@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/urizennnn/boxify/pkg/daemon/requests"
+	"golang.org/x/sys/unix"
+)
+
+var execCmd = &cobra.Command{
+	Use:   "exec CONTAINER [COMMAND] [ARG...]",
+	Short: "Run a command in a running container",
+	Long: `Run a command inside a running container.
+
+The daemon starts the command in the container's namespaces and cgroup, with
+the container's environment, user and working directory, so resource limits,
+pause and top apply to it. A pseudo-terminal is allocated when stdin and
+stdout are terminals.
+
+The container can be referenced by ID, unique ID prefix or name. Without a
+command an interactive /bin/sh is started. exec exits with the command's
+exit code.`,
+	Example: `  # Open a shell in a container
+  boxify exec brave_turing
+
+  # Run a single command
+  boxify exec 3f2a ls -l /`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		command := args[1:]
+		if len(command) == 0 {
+			command = []string{"/bin/sh"}
+		}
+		code, err := execInContainer(args[0], command)
+		if err != nil {
+			exitWithError(err)
+		}
+		os.Exit(code)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(execCmd)
+	execCmd.Flags().SetInterspersed(false)
+}
+
+// execInContainer runs command in the container through the daemon's exec
+// endpoint and returns its exit code. The daemon gets this process's stdio,
+// or the slave of a new pty when stdin and stdout are terminals.
+func execInContainer(ref string, command []string) (int, error) {
+	tty := isTerminal(os.Stdin) && isTerminal(os.Stdout)
+
+	conn, err := net.DialUnix("unix", nil, &net.UnixAddr{Name: daemonSocket, Net: "unix"})
+	if err != nil {
+		return 0, fmt.Errorf("cannot connect to boxifyd: %w", err)
+	}
+	defer conn.Close()
+
+	body, err := json.Marshal(requests.ExecRequest{Command: command, Tty: tty})
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	req, err := newDaemonRequest(http.MethodPost, containerPath(ref, "/exec"), nil, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if err := req.Write(conn); err != nil {
+		return 0, fmt.Errorf("cannot connect to boxifyd: %w", err)
+	}
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		return 0, fmt.Errorf("cannot connect to boxifyd: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(resp.Body)
+		return 0, fmt.Errorf("daemon returned %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+
+	if !tty {
+		if err := sendStdio(conn, os.Stdin, os.Stdout, os.Stderr); err != nil {
+			return 0, err
+		}
+		return readExecResult(reader)
+	}
+	return execTerminal(conn, reader)
+}
+
+// execTerminal runs the session on a new pty: its slave goes to the daemon,
+// its master is wired to this terminal, which is in raw mode meanwhile.
+func execTerminal(conn *net.UnixConn, reader *bufio.Reader) (int, error) {
+	master, slave, err := openPty()
+	if err != nil {
+		return 0, err
+	}
+	defer master.Close()
+
+	err = sendStdio(conn, slave, slave, slave)
+	slave.Close()
+	if err != nil {
+		return 0, err
+	}
+
+	state, err := makeRaw(os.Stdin)
+	if err != nil {
+		return 0, err
+	}
+	defer restoreTerminal(os.Stdin, state)
+
+	resizePty(master)
+	winch := make(chan os.Signal, 1)
+	signal.Notify(winch, syscall.SIGWINCH)
+	defer signal.Stop(winch)
+	go func() {
+		for range winch {
+			resizePty(master)
+		}
+	}()
+
+	output := make(chan struct{})
+	go io.Copy(master, os.Stdin)
+	go func() {
+		io.Copy(os.Stdout, master)
+		close(output)
+	}()
+
+	code, err := readExecResult(reader)
+	// The master reads EIO once every process has closed the slave. Don't
+	// wait for processes the command left running in the background.
+	select {
+	case <-output:
+	case <-time.After(time.Second):
+	}
+	return code, err
+}
+
+// sendStdio passes the files to the daemon with SCM_RIGHTS.
+func sendStdio(conn *net.UnixConn, stdin, stdout, stderr *os.File) error {
+	rights := unix.UnixRights(int(stdin.Fd()), int(stdout.Fd()), int(stderr.Fd()))
+	if _, _, err := conn.WriteMsgUnix([]byte{0}, rights, nil); err != nil {
+		return fmt.Errorf("failed to pass stdio to boxifyd: %w", err)
+	}
+	return nil
+}
+
+func readExecResult(reader *bufio.Reader) (int, error) {
+	var result requests.ExecResult
+	if err := json.NewDecoder(reader).Decode(&result); err != nil {
+		return 0, fmt.Errorf("lost connection to boxifyd: %w", err)
+	}
+	if result.Error != "" {
+		return 0, fmt.Errorf("daemon returned: %s", result.Error)
+	}
+	return result.ExitCode, nil
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var exportOutput string
+
+var exportCmd = &cobra.Command{
+	Use:   "export [flags] CONTAINER",
+	Short: "Export a container's filesystem as a tar archive",
+	Long: `Export the filesystem a container sees, its image layers merged with its
+own changes, as a flat tar archive. Device nodes, hard links, ownership and
+extended attributes are kept. The contents of volumes are not included.
+
+The archive can be turned back into an image with "boxify import".`,
+	Example: `  boxify export web > web.tar
+  boxify export -o web.tar web`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if info, err := os.Stdout.Stat(); err == nil && exportOutput == "" && info.Mode()&os.ModeCharDevice != 0 {
+			exitWithError(fmt.Errorf("refusing to write an archive to a terminal, use -o or redirect the output"))
+		}
+
+		resp, err := daemonStream(containerPath(args[0], "/export"), nil)
+		if err != nil {
+			exitWithError(err)
+		}
+		defer resp.Body.Close()
+
+		var out io.Writer = os.Stdout
+		if exportOutput != "" {
+			file, err := os.Create(exportOutput)
+			if err != nil {
+				exitWithError(err)
+			}
+			defer file.Close()
+			out = file
+		}
+		if _, err := io.Copy(out, resp.Body); err != nil {
+			exitWithError(fmt.Errorf("failed to write archive: %w", err))
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+	exportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "Write to a file instead of standard output")
+}
@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// parseFilterFlags turns repeated key=value flags into the filter map sent to
+// the daemon.
+func parseFilterFlags(flags []string) (map[string][]string, error) {
+	filters := map[string][]string{}
+	for _, flag := range flags {
+		key, value, ok := strings.Cut(flag, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid filter %q, expected key=value", flag)
+		}
+		filters[key] = append(filters[key], value)
+	}
+	return filters, nil
+}
+
+// filterQuery encodes filter flags into the "filters" query parameter.
+func filterQuery(query url.Values, flags []string) error {
+	filters, err := parseFilterFlags(flags)
+	if err != nil {
+		return err
+	}
+	if len(filters) == 0 {
+		return nil
+	}
+
+	encoded, err := json.Marshal(filters)
+	if err != nil {
+		return err
+	}
+	query.Set("filters", string(encoded))
+	return nil
+}
@@ -0,0 +1,387 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/urizennnn/boxify/pkg/image"
+)
+
+var (
+	imagesAll     bool
+	imagesQuiet   bool
+	imagesNoTrunc bool
+	rmiForce      bool
+	saveOutput    string
+	saveFormat    string
+	loadInput     string
+	loadQuiet     bool
+	historyQuiet  bool
+	pruneAll      bool
+	importMessage string
+	importChanges []string
+)
+
+var imagesCmd = &cobra.Command{
+	Use:   "images",
+	Short: "List images",
+	Long: `List the images in the local store, newest first. Untagged images that
+other images are built on, such as the steps of a build, are hidden unless
+--all is given. SIZE is the combined size of the image's layers; layers shared
+between images are counted for each of them.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		query := url.Values{}
+		if imagesAll {
+			query.Set("all", "1")
+		}
+
+		var summaries []image.Summary
+		if err := daemonGet("/images", query, &summaries); err != nil {
+			exitWithError(err)
+		}
+
+		if imagesQuiet {
+			for _, s := range summaries {
+				fmt.Println(imageID(s.ID, imagesNoTrunc))
+			}
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "REPOSITORY\tTAG\tIMAGE ID\tCREATED\tSIZE")
+		for _, s := range summaries {
+			tags := s.RepoTags
+			if len(tags) == 0 {
+				tags = []string{"<none>:<none>"}
+			}
+			for _, ref := range tags {
+				separator := strings.LastIndex(ref, ":")
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+					ref[:separator],
+					ref[separator+1:],
+					imageID(s.ID, imagesNoTrunc),
+					formatTimeSince(s.Created),
+					formatSize(s.Size),
+				)
+			}
+		}
+		w.Flush()
+	},
+}
+
+var tagCmd = &cobra.Command{
+	Use:     "tag SOURCE_IMAGE TARGET_IMAGE[:TAG]",
+	Short:   "Create a tag TARGET_IMAGE that refers to SOURCE_IMAGE",
+	Example: `  boxify tag alpine:3.19 base:stable`,
+	Args:    cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		query := url.Values{}
+		query.Set("ref", args[1])
+		if err := daemonPost(imagePath(args[0], "/tag"), query, nil, nil); err != nil {
+			exitWithError(err)
+		}
+	},
+}
+
+var rmiCmd = &cobra.Command{
+	Use:   "rmi IMAGE [IMAGE...]",
+	Short: "Remove one or more images",
+	Long: `Remove images. Naming an image by one of several tags only removes that tag.
+
+An image used by a container is refused unless --force is given, and even
+then only its tags are removed: the image stays for the container until the
+container is removed. Layers are freed by "boxify image prune".`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		query := url.Values{}
+		if rmiForce {
+			query.Set("force", "1")
+		}
+
+		failed := false
+		for _, ref := range args {
+			var deletions []image.Deletion
+			err := daemonRequest(http.MethodDelete, imagePath(ref, ""), query, nil, &deletions)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %s: %v\n", ref, err)
+				failed = true
+				continue
+			}
+			printDeletions(deletions)
+		}
+
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+var saveCmd = &cobra.Command{
+	Use:   "save [flags] IMAGE [IMAGE...]",
+	Short: "Save one or more images to a tar archive",
+	Long: `Save images with their layers to a tar archive, streamed to standard output
+by default. --format selects a docker-archive (the layout "docker save"
+writes) or an OCI image layout. Images named by a tag keep that tag.`,
+	Example: `  boxify save -o alpine.tar alpine:3.19
+  boxify save --format oci myapp:v1 | gzip > myapp-oci.tar.gz`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if info, err := os.Stdout.Stat(); err == nil && saveOutput == "" && info.Mode()&os.ModeCharDevice != 0 {
+			exitWithError(fmt.Errorf("refusing to write an archive to a terminal, use -o or redirect the output"))
+		}
+
+		query := url.Values{"names": args}
+		query.Set("format", saveFormat)
+		resp, err := daemonStream("/images/save", query)
+		if err != nil {
+			exitWithError(err)
+		}
+		defer resp.Body.Close()
+
+		var out io.Writer = os.Stdout
+		if saveOutput != "" {
+			file, err := os.Create(saveOutput)
+			if err != nil {
+				exitWithError(err)
+			}
+			defer file.Close()
+			out = file
+		}
+		if _, err := io.Copy(out, resp.Body); err != nil {
+			exitWithError(fmt.Errorf("failed to write archive: %w", err))
+		}
+	},
+}
+
+var loadCmd = &cobra.Command{
+	Use:   "load [flags]",
+	Short: "Load images from a tar archive",
+	Long: `Load images from a docker-archive or OCI image layout tar archive, read from
+standard input by default. The archive and its layers may be gzip compressed.
+Plain filesystem archives are imported with "boxify import" instead.`,
+	Example: `  boxify load -i alpine.tar
+  gunzip -c myapp-oci.tar.gz | boxify load`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		var in io.Reader = os.Stdin
+		if loadInput != "" {
+			file, err := os.Open(loadInput)
+			if err != nil {
+				exitWithError(err)
+			}
+			defer file.Close()
+			in = file
+		}
+
+		resp, err := daemonRaw(http.MethodPost, "/images/load", nil, in, "application/x-tar")
+		if err != nil {
+			exitWithError(err)
+		}
+		defer resp.Body.Close()
+
+		var result struct {
+			Loaded []string `json:"loaded"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			exitWithError(fmt.Errorf("failed to decode response: %w", err))
+		}
+		for _, name := range result.Loaded {
+			switch {
+			case loadQuiet:
+				fmt.Println(name)
+			case strings.HasPrefix(name, "sha256:"):
+				fmt.Println("Loaded image ID: " + name)
+			default:
+				fmt.Println("Loaded image: " + name)
+			}
+		}
+	},
+}
+
+var importCmd = &cobra.Command{
+	Use:   "import [flags] FILE|- [REPOSITORY[:TAG]]",
+	Short: "Create an image from a root filesystem tarball",
+	Long: `Create a single layer image from a tar archive of a root filesystem, such
+as one written by "boxify export" or a distribution's minimal rootfs tarball.
+The archive may be gzip compressed; "-" reads it from standard input.
+
+The image starts with a default PATH and no command. --change applies Boxfile
+instructions to its config: ENV, LABEL, WORKDIR, USER, EXPOSE, ENTRYPOINT and
+CMD.`,
+	Example: `  boxify import alpine-minirootfs-3.19.0-x86_64.tar.gz alpine:3.19
+  boxify import -c 'CMD ["/bin/bash"]' debian-rootfs.tar debian:bookworm
+  boxify export web | boxify import - web:snapshot`,
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		query := url.Values{"changes": importChanges}
+		query.Set("source", args[0])
+		query.Set("message", importMessage)
+		if len(args) == 2 {
+			query.Set("ref", args[1])
+		}
+
+		var in io.Reader = os.Stdin
+		if args[0] != "-" {
+			file, err := os.Open(args[0])
+			if err != nil {
+				exitWithError(err)
+			}
+			defer file.Close()
+			in = file
+		}
+
+		resp, err := daemonRaw(http.MethodPost, "/images/import", query, in, "application/x-tar")
+		if err != nil {
+			exitWithError(err)
+		}
+		defer resp.Body.Close()
+
+		var result struct {
+			ID string `json:"id"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			exitWithError(fmt.Errorf("failed to decode response: %w", err))
+		}
+		fmt.Println(image.ShortID(result.ID))
+	},
+}
+
+var historyCmd = &cobra.Command{
+	Use:   "history IMAGE",
+	Short: "Show the history of an image",
+	Long: `Show the steps that built an image, newest first. IMAGE is the ID of the
+image a step produced, or <missing> when that image is not in the local store,
+for example for the steps of a loaded image.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var history []image.HistoryEntry
+		if err := daemonGet(imagePath(args[0], "/history"), nil, &history); err != nil {
+			exitWithError(err)
+		}
+
+		if historyQuiet {
+			for _, h := range history {
+				if h.ID != "" {
+					fmt.Println(image.ShortID(h.ID))
+				}
+			}
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "IMAGE\tCREATED\tCREATED BY\tSIZE\tCOMMENT")
+		for _, h := range history {
+			id := "<missing>"
+			if h.ID != "" {
+				id = image.ShortID(h.ID)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+				id,
+				formatTimeSince(h.Created),
+				truncateString(h.CreatedBy, 45),
+				formatSize(h.Size),
+				h.Comment,
+			)
+		}
+		w.Flush()
+	},
+}
+
+var imageCmd = &cobra.Command{
+	Use:   "image",
+	Short: "Manage images",
+	Long: `Manage the local image store under /var/lib/boxify/images. Images are
+listed with "boxify images" and removed with "boxify rmi".`,
+}
+
+var imagePruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove unused images and layers",
+	Long: `Remove untagged images that no container uses and no other image is built
+on, or with --all every image no container uses. Layers that no remaining
+image references are then deleted by digest, together with build cache
+entries for removed images.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		query := url.Values{}
+		if pruneAll {
+			query.Set("all", "1")
+		}
+
+		var report image.PruneReport
+		if err := daemonPost("/images/prune", query, nil, &report); err != nil {
+			exitWithError(err)
+		}
+
+		printDeletions(report.ImagesDeleted)
+		for _, diffID := range report.LayersDeleted {
+			fmt.Println("Deleted layer: " + diffID)
+		}
+		fmt.Printf("Total reclaimed space: %s\n", formatSize(report.SpaceReclaimed))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(imagesCmd, tagCmd, rmiCmd, saveCmd, loadCmd, importCmd, historyCmd, imageCmd)
+	imageCmd.AddCommand(imagePruneCmd)
+
+	imagesCmd.Flags().BoolVarP(&imagesAll, "all", "a", false, "Show all images, including intermediate build steps")
+	imagesCmd.Flags().BoolVarP(&imagesQuiet, "quiet", "q", false, "Only display image IDs")
+	imagesCmd.Flags().BoolVar(&imagesNoTrunc, "no-trunc", false, "Don't truncate image IDs")
+	rmiCmd.Flags().BoolVarP(&rmiForce, "force", "f", false, "Untag images that are in use or named by several tags")
+	saveCmd.Flags().StringVarP(&saveOutput, "output", "o", "", "Write to a file instead of standard output")
+	saveCmd.Flags().StringVar(&saveFormat, "format", image.FormatDocker, "Archive format: docker-archive or oci")
+	loadCmd.Flags().StringVarP(&loadInput, "input", "i", "", "Read from a file instead of standard input")
+	loadCmd.Flags().BoolVarP(&loadQuiet, "quiet", "q", false, "Only print the loaded tags and IDs")
+	importCmd.Flags().StringVarP(&importMessage, "message", "m", "", "Comment for the image history")
+	importCmd.Flags().StringArrayVarP(&importChanges, "change", "c", nil, "Apply a Boxfile instruction to the image config")
+	historyCmd.Flags().BoolVarP(&historyQuiet, "quiet", "q", false, "Only show image IDs")
+	imagePruneCmd.Flags().BoolVarP(&pruneAll, "all", "a", false, "Remove all unused images, not just untagged ones")
+}
+
+// imagePath builds an endpoint path for an image reference, which may be a
+// tag, an ID or an ID prefix.
+func imagePath(ref, suffix string) string {
+	return "/images/" + url.PathEscape(ref) + suffix
+}
+
+func imageID(id string, noTrunc bool) string {
+	if noTrunc {
+		return id
+	}
+	return image.ShortID(id)
+}
+
+func printDeletions(deletions []image.Deletion) {
+	for _, deletion := range deletions {
+		if deletion.Untagged != "" {
+			fmt.Println("Untagged: " + deletion.Untagged)
+		}
+		if deletion.Deleted != "" {
+			fmt.Println("Deleted: " + deletion.Deleted)
+		}
+	}
+}
+
+// formatSize renders a byte count with decimal units, like "7.38MB".
+func formatSize(size int64) string {
+	units := []string{"B", "kB", "MB", "GB", "TB"}
+	value := float64(size)
+	unit := 0
+	for value >= 1000 && unit < len(units)-1 {
+		value /= 1000
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%dB", size)
+	}
+	return fmt.Sprintf("%.3g%s", value, units[unit])
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var inspectCmd = &cobra.Command{
+	Use:   "inspect CONTAINER [CONTAINER...]",
+	Short: "Display detailed information on one or more containers",
+	Long: `Print the daemon's record of one or more containers as a JSON array.
+
+Containers can be referenced by ID, unique ID prefix or name.`,
+	Example: `  # Inspect a container by name
+  boxify inspect web`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		inspectObjects(args, func(ref string) string {
+			return containerPath(ref, "/json")
+		})
+	},
+}
+
+// inspectObjects fetches each reference from the daemon and prints the
+// results as an indented JSON array. Lookup failures are reported on stderr
+// and make the command exit non-zero after printing what was found.
+func inspectObjects(refs []string, pathFor func(ref string) string) {
+	results := []json.RawMessage{}
+	failed := false
+	for _, ref := range refs {
+		var raw json.RawMessage
+		if err := daemonGet(pathFor(ref), nil, &raw); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s: %v\n", ref, err)
+			failed = true
+			continue
+		}
+		results = append(results, raw)
+	}
+
+	output, err := json.MarshalIndent(results, "", "    ")
+	if err != nil {
+		exitWithError(err)
+	}
+	fmt.Println(string(output))
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(inspectCmd)
+}
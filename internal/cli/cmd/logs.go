@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/urizennnn/boxify/pkg/daemon/types"
+)
+
+var (
+	logsFollow  bool
+	logsProject string
+)
+
+var logsCmd = &cobra.Command{
+	Use:   "logs CONTAINER | logs --project[=NAME] [SERVICE...]",
+	Short: "Fetch the logs of a container",
+	Long: `Print the output a container has written to stdout and stderr.
+
+With --follow the output keeps streaming until the container exits.
+
+With --project the logs of a project's services are printed instead, each
+line prefixed with its service. A bare --project means the project in the
+current directory, --project=NAME names another one. Service names narrow
+the output down.`,
+	Example: `  # Print the logs of a container
+  boxify logs web
+
+  # Follow the logs
+  boxify logs -f web
+
+  # Follow the db service of the project in the current directory
+  boxify logs -f --project db`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if logsProject != "" {
+			return nil
+		}
+		return cobra.ExactArgs(1)(cmd, args)
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		if logsProject != "" {
+			if err := projectLogs(logsProject, args, logsFollow); err != nil {
+				exitWithError(err)
+			}
+			return
+		}
+		if err := streamLogs(args[0], logsFollow); err != nil {
+			exitWithError(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(logsCmd)
+	logsCmd.Flags().BoolVarP(&logsFollow, "follow", "f", false, "Follow log output")
+	logsCmd.Flags().StringVar(&logsProject, "project", "", "Show the logs of a project's services")
+	logsCmd.Flags().Lookup("project").NoOptDefVal = currentProject
+	logsCmd.Flags().StringVar(&projectFile, "file", "", "Project file of a bare --project (default boxify.yaml or boxify.yml)")
+}
+
+// projectLogs prints the logs of the project's containers, or of the given
+// services only.
+func projectLogs(ref string, services []string, follow bool) error {
+	name, err := resolveProjectRef(ref, projectFile)
+	if err != nil {
+		return err
+	}
+	existing, err := projectContainers(name)
+	if err != nil {
+		return err
+	}
+
+	var containers []*types.Container
+	if len(services) == 0 {
+		for _, c := range existing {
+			containers = append(containers, c...)
+		}
+	}
+	for _, service := range services {
+		if len(existing[service]) == 0 {
+			return fmt.Errorf("no containers for service %s in project %s", service, name)
+		}
+		containers = append(containers, existing[service]...)
+	}
+	if len(containers) == 0 {
+		return fmt.Errorf("project %s has no containers", name)
+	}
+	return printProjectLogs(containers, follow)
+}
+
+func streamLogs(ref string, follow bool) error {
+	query := url.Values{}
+	if follow {
+		query.Set("follow", "1")
+	}
+
+	resp, err := daemonStream(containerPath(ref, "/logs"), query)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	_, err = io.Copy(os.Stdout, resp.Body)
+	return err
+}
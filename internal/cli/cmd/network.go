@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/urizennnn/boxify/config"
+	"github.com/urizennnn/boxify/pkg/daemon/requests"
+)
+
+var (
+	networkFilters []string
+	networkQuiet   bool
+
+	networkDriver   string
+	networkParent   string
+	networkSubnet   string
+	networkGateway  string
+	networkMode     string
+	networkIPv6     bool
+	networkSubnet6  string
+	networkGateway6 string
+	networkLabels   []string
+)
+
+var networkCmd = &cobra.Command{
+	Use:   "network",
+	Short: "Manage networks",
+}
+
+var networkLsCmd = &cobra.Command{
+	Use:     "ls",
+	Aliases: []string{"list"},
+	Short:   "List networks",
+	Long:    `List networks. Supported filters: id=<id>, name=<name>, driver=<driver> and label=<key>[=<value>].`,
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		query := url.Values{}
+		if err := filterQuery(query, networkFilters); err != nil {
+			exitWithError(err)
+		}
+
+		var networks []*config.NetworkStorage
+		if err := daemonGet("/networks", query, &networks); err != nil {
+			exitWithError(err)
+		}
+
+		if networkQuiet {
+			for _, n := range networks {
+				fmt.Println(truncateString(n.Id, 12))
+			}
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "NETWORK ID\tNAME\tDRIVER\tINTERFACE\tGATEWAY\tCONTAINERS")
+		for _, n := range networks {
+			// Bridge networks hang off their bridge, the others off their
+			// parent interface.
+			iface := n.Bridge.Name
+			if n.Parent != "" {
+				iface = n.Parent
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\n",
+				truncateString(n.Id, 12),
+				n.Name,
+				n.Driver,
+				iface,
+				n.Ipam.Gateway,
+				len(n.Containers),
+			)
+		}
+		w.Flush()
+	},
+}
+
+var networkInspectCmd = &cobra.Command{
+	Use:   "inspect NETWORK [NETWORK...]",
+	Short: "Display detailed information on one or more networks",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		inspectObjects(args, func(ref string) string {
+			return "/networks/" + url.PathEscape(ref)
+		})
+	},
+}
+
+var networkCreateCmd = &cobra.Command{
+	Use:   "create [flags] NETWORK",
+	Short: "Create a macvlan or ipvlan network",
+	Long: `Create a network that puts containers directly on the LAN of a host
+interface, each with its own address from --subnet, instead of behind the
+bridge and NAT.
+
+The macvlan driver gives every container its own MAC address on --parent
+(bridge mode, so containers on the same parent reach each other). The ipvlan
+driver shares the parent's MAC address: in l2 mode (default) containers are
+on the parent's segment, in l3 mode the parent routes their traffic and the
+network takes no gateway. --gateway defaults to the subnet's first address.
+
+--ipv6 makes the network dual-stack: containers also get an IPv6 address
+from --subnet6, a generated unique local /64 by default, and a default route
+via --gateway6, which defaults to that subnet's first address.
+
+Containers join the network with "boxify run --network NETWORK". As with any
+macvlan or ipvlan setup, the host itself cannot reach containers through the
+parent interface.`,
+	Example: `  boxify network create -d macvlan --parent eth1 --subnet 192.168.1.0/24 --gateway 192.168.1.1 lan
+  boxify network create -d ipvlan --mode l3 --parent eth1 --subnet 10.10.0.0/24 routed
+  boxify network create -d macvlan --parent eth2 --subnet 192.168.2.0/24 --ipv6 --subnet6 fd00:2::/64 lan6
+  boxify run -d --network lan -- httpd -f`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		request := requests.CreateNetworkRequest{
+			Name:     args[0],
+			Driver:   networkDriver,
+			Parent:   networkParent,
+			Subnet:   networkSubnet,
+			Gateway:  networkGateway,
+			Mode:     networkMode,
+			IPv6:     networkIPv6,
+			Subnet6:  networkSubnet6,
+			Gateway6: networkGateway6,
+		}
+		var err error
+		if request.Labels, err = parseKeyValueFlags(networkLabels); err != nil {
+			exitWithError(err)
+		}
+
+		var created config.NetworkStorage
+		if err := daemonPost("/networks/create", nil, request, &created); err != nil {
+			exitWithError(err)
+		}
+		fmt.Println(created.Id)
+	},
+}
+
+var networkRmCmd = &cobra.Command{
+	Use:     "rm NETWORK [NETWORK...]",
+	Aliases: []string{"remove"},
+	Short:   "Remove one or more networks",
+	Long:    `Remove networks that no container is attached to. The default bridge network cannot be removed.`,
+	Args:    cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		failed := false
+		for _, ref := range args {
+			if err := daemonDelete("/networks/"+url.PathEscape(ref), nil); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %s: %v\n", ref, err)
+				failed = true
+				continue
+			}
+			fmt.Println(ref)
+		}
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(networkCmd)
+	networkCmd.AddCommand(networkLsCmd, networkInspectCmd, networkCreateCmd, networkRmCmd)
+
+	networkCreateCmd.Flags().StringVarP(&networkDriver, "driver", "d", "", "Network driver: macvlan or ipvlan")
+	networkCreateCmd.Flags().StringVar(&networkParent, "parent", "", "Host interface the network is attached to")
+	networkCreateCmd.Flags().StringVar(&networkSubnet, "subnet", "", "Subnet containers get addresses from, e.g. 192.168.1.0/24")
+	networkCreateCmd.Flags().StringVar(&networkGateway, "gateway", "", "Gateway of the subnet (default: its first address)")
+	networkCreateCmd.Flags().StringVar(&networkMode, "mode", "", "Driver mode: bridge for macvlan, l2 (default) or l3 for ipvlan")
+	networkCreateCmd.Flags().BoolVar(&networkIPv6, "ipv6", false, "Give containers IPv6 addresses as well")
+	networkCreateCmd.Flags().StringVar(&networkSubnet6, "subnet6", "", "IPv6 subnet, implies --ipv6 (default: a generated unique local /64)")
+	networkCreateCmd.Flags().StringVar(&networkGateway6, "gateway6", "", "IPv6 gateway of the subnet (default: its first address)")
+	networkCreateCmd.Flags().StringArrayVarP(&networkLabels, "label", "l", nil, "Set metadata on the network (key=value)")
+
+	networkLsCmd.Flags().StringArrayVarP(&networkFilters, "filter", "f", nil, "Filter output based on conditions provided")
+	networkLsCmd.Flags().BoolVarP(&networkQuiet, "quiet", "q", false, "Only display network IDs")
+}
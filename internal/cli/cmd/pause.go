@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var pauseCmd = &cobra.Command{
+	Use:   "pause CONTAINER [CONTAINER...]",
+	Short: "Pause all processes within one or more containers",
+	Long: `Freeze every process of running containers with the cgroup v2 freezer.
+
+Paused containers keep their memory and state but get no CPU time. exec and
+health checks are refused until the container is unpaused.`,
+	Example: `  # Pause a noisy container
+  boxify pause worker`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		postEach(args, "/pause")
+	},
+}
+
+var unpauseCmd = &cobra.Command{
+	Use:   "unpause CONTAINER [CONTAINER...]",
+	Short: "Unpause all processes within one or more containers",
+	Example: `  # Resume a paused container
+  boxify unpause worker`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		postEach(args, "/unpause")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(pauseCmd)
+	rootCmd.AddCommand(unpauseCmd)
+}
+
+// postEach sends a POST to suffix for every container, printing the ones
+// that succeeded and exiting non-zero if any failed.
+func postEach(refs []string, suffix string) {
+	failed := false
+	for _, ref := range refs {
+		if err := daemonPost(containerPath(ref, suffix), nil, nil, nil); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s: %v\n", ref, err)
+			failed = true
+			continue
+		}
+		fmt.Println(ref)
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,219 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/urizennnn/boxify/pkg/daemon/requests"
+	"github.com/urizennnn/boxify/pkg/daemon/types"
+)
+
+var (
+	podName     string
+	podHostname string
+	podLabels   []string
+	podLimits   requests.Resources
+	podFilters  []string
+	podQuiet    bool
+	podTimeout  int
+	podForce    bool
+)
+
+var podCmd = &cobra.Command{
+	Use:   "pod",
+	Short: "Manage pods",
+	Long: `Manage pods, groups of containers that share a network, IPC and UTS
+namespace.
+
+Each pod has an infra process that holds those namespaces, the pod's IP
+address and a parent cgroup. Containers created with --pod join the pod's
+namespaces instead of getting their own, so they reach each other on
+localhost and share System V IPC and the hostname. The pod's resource limits
+apply to all of its containers together, on top of each container's own.`,
+}
+
+var podCreateCmd = &cobra.Command{
+	Use:   "create [flags]",
+	Short: "Create a pod",
+	Example: `  # A pod for a web server and its log shipper, limited to 1g together
+  boxify pod create --name web --memory 1g
+  boxify run -d --pod web --name app -- httpd -f
+  boxify run -d --pod web --name shipper -- tail -F /var/log/app.log`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		request := requests.CreatePodRequest{
+			Name:     podName,
+			Hostname: podHostname,
+		}
+		applyResourceFlags(cmd, &request.Resources, podLimits)
+
+		var err error
+		if request.Labels, err = parseKeyValueFlags(podLabels); err != nil {
+			exitWithError(err)
+		}
+
+		var created struct {
+			ID string `json:"id"`
+		}
+		if err := daemonPost("/pods/create", nil, request, &created); err != nil {
+			exitWithError(err)
+		}
+		fmt.Println(created.ID)
+	},
+}
+
+var podLsCmd = &cobra.Command{
+	Use:     "ls",
+	Aliases: []string{"list", "ps"},
+	Short:   "List pods",
+	Long:    `List pods. Supported filters: id=<id>, name=<name> and label=<key>[=<value>].`,
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		query := url.Values{}
+		if err := filterQuery(query, podFilters); err != nil {
+			exitWithError(err)
+		}
+
+		var pods []*types.Pod
+		if err := daemonGet("/pods/json", query, &pods); err != nil {
+			exitWithError(err)
+		}
+
+		if podQuiet {
+			for _, p := range pods {
+				fmt.Println(truncateString(p.ID, 12))
+			}
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "POD ID\tNAME\tSTATUS\tIP\tCREATED\tCONTAINERS")
+		for _, p := range pods {
+			ip := ""
+			if p.NetworkInfo != nil {
+				ip = p.NetworkInfo.IP
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\n",
+				truncateString(p.ID, 12),
+				p.Name,
+				p.Status,
+				ip,
+				formatTimeSince(p.CreatedAt),
+				len(p.Containers),
+			)
+		}
+		w.Flush()
+	},
+}
+
+var podInspectCmd = &cobra.Command{
+	Use:   "inspect POD [POD...]",
+	Short: "Display detailed information on one or more pods",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		inspectObjects(args, func(ref string) string {
+			return podPath(ref, "/json")
+		})
+	},
+}
+
+var podStartCmd = &cobra.Command{
+	Use:   "start POD [POD...]",
+	Short: "Start one or more pods and their containers",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		eachPod(args, func(ref string) error {
+			return daemonPost(podPath(ref, "/start"), nil, nil, nil)
+		})
+	},
+}
+
+var podStopCmd = &cobra.Command{
+	Use:   "stop POD [POD...]",
+	Short: "Stop one or more pods and their containers",
+	Long: `Stop every container of the pods like "boxify stop" does, then the pods'
+infra processes.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		query := url.Values{}
+		query.Set("t", strconv.Itoa(podTimeout))
+		eachPod(args, func(ref string) error {
+			return daemonPost(podPath(ref, "/stop"), query, nil, nil)
+		})
+	},
+}
+
+var podUpdateCmd = &cobra.Command{
+	Use:   "update [flags] POD [POD...]",
+	Short: "Update the resource limits of one or more pods",
+	Long: `Change the limits the containers of a pod share. Running pods have their
+cgroup updated in place. Only the flags given are changed.`,
+	Example: `  boxify pod update --memory 2g --cpus 2 web`,
+	Args:    cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		request := requests.UpdatePodRequest{}
+		applyResourceFlags(cmd, &request.Resources, podLimits)
+		eachPod(args, func(ref string) error {
+			return daemonPost(podPath(ref, "/update"), nil, request, nil)
+		})
+	},
+}
+
+var podRmCmd = &cobra.Command{
+	Use:   "rm POD [POD...]",
+	Short: "Remove one or more pods and their containers",
+	Long:  `Remove pods together with their containers. Running pods are refused unless --force is given.`,
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		query := url.Values{}
+		if podForce {
+			query.Set("force", "1")
+		}
+		eachPod(args, func(ref string) error {
+			return daemonDelete(podPath(ref, ""), query)
+		})
+	},
+}
+
+// podPath builds an endpoint path for a pod reference, which may be an ID,
+// ID prefix or name.
+func podPath(ref, suffix string) string {
+	return "/pods/" + url.PathEscape(ref) + suffix
+}
+
+// eachPod runs request for every pod reference, printing the ones that
+// succeeded and exiting non-zero if any failed.
+func eachPod(refs []string, request func(ref string) error) {
+	failed := false
+	for _, ref := range refs {
+		if err := request(ref); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s: %v\n", ref, err)
+			failed = true
+			continue
+		}
+		fmt.Println(ref)
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(podCmd)
+	podCmd.AddCommand(podCreateCmd, podLsCmd, podInspectCmd, podStartCmd, podStopCmd, podUpdateCmd, podRmCmd)
+
+	podCreateCmd.Flags().StringVar(&podName, "name", "", "Assign a name to the pod")
+	podCreateCmd.Flags().StringVar(&podHostname, "hostname", "", "Hostname of the pod's containers, the pod's name by default")
+	podCreateCmd.Flags().StringArrayVarP(&podLabels, "label", "l", nil, "Set metadata on the pod (key=value)")
+	addResourceFlags(podCreateCmd, &podLimits)
+	addResourceFlags(podUpdateCmd, &podLimits)
+	podLsCmd.Flags().StringArrayVarP(&podFilters, "filter", "f", nil, "Filter output based on conditions provided")
+	podLsCmd.Flags().BoolVarP(&podQuiet, "quiet", "q", false, "Only display pod IDs")
+	podStopCmd.Flags().IntVarP(&podTimeout, "time", "t", 10, "Seconds to wait before killing each container")
+	podRmCmd.Flags().BoolVarP(&podForce, "force", "f", false, "Stop and remove running pods")
+}
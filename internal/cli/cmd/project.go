@@ -0,0 +1,288 @@
+package cmd
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/urizennnn/boxify/config"
+	"github.com/urizennnn/boxify/pkg/container"
+	"github.com/urizennnn/boxify/pkg/daemon/requests"
+	"github.com/urizennnn/boxify/pkg/daemon/types"
+)
+
+// Labels that tie containers and volumes to a project. The config hash is
+// the digest of the request a service's container was created from and the
+// ID its image resolved to, so up can tell which services changed.
+const (
+	projectLabel    = "boxify.project"
+	serviceLabel    = "boxify.service"
+	configHashLabel = "boxify.config-hash"
+)
+
+// currentProject is the value of a bare --project flag, naming the project
+// of the file in the current directory.
+const currentProject = "."
+
+var invalidProjectChars = regexp.MustCompile(`[^a-z0-9_.-]`)
+
+// projectName picks the project's name: the --project-name flag, the file's
+// name field or else the directory holding the file, lowercased with
+// characters that are not valid in container names dropped.
+func projectName(flag string, project *config.Project, path string) (string, error) {
+	name := flag
+	if name == "" && project != nil {
+		name = project.Name
+	}
+	if name == "" {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return "", err
+		}
+		name = filepath.Base(filepath.Dir(abs))
+	}
+
+	name = invalidProjectChars.ReplaceAllString(strings.ToLower(name), "")
+	name = strings.TrimLeft(name, "_.-")
+	if name == "" {
+		return "", fmt.Errorf("cannot derive a project name, set one with --project-name")
+	}
+	return name, nil
+}
+
+// resolveProjectRef turns the value of a --project flag into a project name.
+func resolveProjectRef(ref, file string) (string, error) {
+	if ref != currentProject && ref != "" {
+		return projectName(ref, nil, "")
+	}
+	project, path, err := config.LoadProject(file)
+	if err != nil {
+		return "", err
+	}
+	return projectName("", project, path)
+}
+
+func containerName(projectName, service string) string {
+	return projectName + "_" + service
+}
+
+// projectContainers lists every container of the project, by service.
+func projectContainers(projectName string) (map[string][]*types.Container, error) {
+	query := url.Values{}
+	query.Set("all", "1")
+	if err := filterQuery(query, []string{"label=" + projectLabel + "=" + projectName}); err != nil {
+		return nil, err
+	}
+
+	var containers []*types.Container
+	if err := daemonGet("/containers/json", query, &containers); err != nil {
+		return nil, err
+	}
+
+	byService := map[string][]*types.Container{}
+	for _, c := range containers {
+		service := c.Labels[serviceLabel]
+		byService[service] = append(byService[service], c)
+	}
+	return byService, nil
+}
+
+// serviceRequest builds the create request of a service's container and
+// returns it with its config hash, which is also set as a label. The hash
+// covers the ID the image currently resolves to, so a rebuilt or retagged
+// image counts as a change.
+func serviceRequest(project *config.Project, projectName, service string) (*requests.InitContainerRequest, string, error) {
+	s := project.Services[service]
+
+	volumes := make([]string, 0, len(s.Volumes))
+	for _, spec := range s.Volumes {
+		key, rest, _ := strings.Cut(spec, ":")
+		volumes = append(volumes, project.VolumeName(projectName, key)+":"+rest)
+	}
+
+	networkMode := s.NetworkMode
+	if len(s.Networks) == 1 && s.Networks[0] != config.DefaultNetwork {
+		networkMode = project.NetworkName(s.Networks[0])
+	}
+
+	labels := mergeMaps(nil, s.Labels)
+	labels = mergeMaps(labels, map[string]string{
+		projectLabel: projectName,
+		serviceLabel: service,
+	})
+
+	request := &requests.InitContainerRequest{
+		Resources:     requests.Resources(s.Settings),
+		Name:          containerName(projectName, service),
+		Image:         s.Image,
+		Command:       s.Command,
+		Labels:        labels,
+		Annotations:   s.Annotations,
+		Volumes:       volumes,
+		RestartPolicy: s.Restart,
+		HealthCheck:   healthCheckRequest(s.HealthCheck),
+		NetworkMode:   networkMode,
+	}
+
+	imageRef := s.Image
+	if imageRef == "" {
+		imageRef = container.DefaultImage
+	}
+	var img struct {
+		ID string `json:"id"`
+	}
+	if err := daemonGet(imagePath(imageRef, "/json"), nil, &img); err != nil {
+		return nil, "", fmt.Errorf("image %s: %w", imageRef, err)
+	}
+
+	encoded, err := json.Marshal(struct {
+		Request *requests.InitContainerRequest
+		ImageID string
+	}{request, img.ID})
+	if err != nil {
+		return nil, "", err
+	}
+	sum := sha256.Sum256(encoded)
+	hash := hex.EncodeToString(sum[:])
+	request.Labels[configHashLabel] = hash
+	return request, hash, nil
+}
+
+// withDependencies returns the selected services and everything they depend
+// on, in start order. No selection means every service.
+func withDependencies(project *config.Project, order, selected []string) ([]string, error) {
+	if len(selected) == 0 {
+		return order, nil
+	}
+
+	needed := map[string]bool{}
+	var add func(name string)
+	add = func(name string) {
+		if needed[name] {
+			return
+		}
+		needed[name] = true
+		for dep := range project.Services[name].DependsOn {
+			add(dep)
+		}
+	}
+	for _, name := range selected {
+		if _, ok := project.Services[name]; !ok {
+			return nil, fmt.Errorf("no such service: %s", name)
+		}
+		add(name)
+	}
+
+	var services []string
+	for _, name := range order {
+		if needed[name] {
+			services = append(services, name)
+		}
+	}
+	return services, nil
+}
+
+// waitForCondition blocks until the container of service dep reaches the
+// depends_on condition, and fails once it cannot anymore.
+func waitForCondition(dep, id, condition string) error {
+	if condition == config.ConditionStarted {
+		return nil
+	}
+
+	for {
+		var c types.Container
+		if err := daemonGet(containerPath(id, "/json"), nil, &c); err != nil {
+			return err
+		}
+
+		switch condition {
+		case config.ConditionHealthy:
+			if c.Health != nil && c.Health.Status == "healthy" {
+				return nil
+			}
+			if c.Health != nil && c.Health.Status == "unhealthy" {
+				return fmt.Errorf("dependency %s is unhealthy", dep)
+			}
+			if c.Status == "exited" {
+				return fmt.Errorf("dependency %s exited with code %d before it became healthy", dep, c.ExitCode)
+			}
+		case config.ConditionCompleted:
+			if c.Status == "exited" {
+				if c.ExitCode != 0 {
+					return fmt.Errorf("dependency %s exited with code %d", dep, c.ExitCode)
+				}
+				return nil
+			}
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+// printProjectLogs prints the logs of the containers, each line prefixed with
+// its service. Followed logs are interleaved as they arrive.
+func printProjectLogs(containers []*types.Container, follow bool) error {
+	sort.Slice(containers, func(i, j int) bool {
+		return containers[i].Labels[serviceLabel] < containers[j].Labels[serviceLabel]
+	})
+	width := 0
+	for _, c := range containers {
+		width = max(width, len(c.Labels[serviceLabel]))
+	}
+
+	var mu sync.Mutex
+	printLogs := func(c *types.Container) error {
+		query := url.Values{}
+		if follow {
+			query.Set("follow", "1")
+		}
+		resp, err := daemonStream(containerPath(c.ID, "/logs"), query)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		prefix := fmt.Sprintf("%-*s | ", width, c.Labels[serviceLabel])
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			mu.Lock()
+			fmt.Fprintln(os.Stdout, prefix+scanner.Text())
+			mu.Unlock()
+		}
+		return scanner.Err()
+	}
+
+	if !follow {
+		for _, c := range containers {
+			if err := printLogs(c); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(containers))
+	for _, c := range containers {
+		wg.Add(1)
+		go func(c *types.Container) {
+			defer wg.Done()
+			if err := printLogs(c); err != nil {
+				errs <- fmt.Errorf("%s: %w", c.Labels[serviceLabel], err)
+			}
+		}(c)
+	}
+	wg.Wait()
+	close(errs)
+	return <-errs
+}
@@ -1,69 +1,221 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/url"
 	"os"
 	"strings"
 	"text/tabwriter"
+	"text/template"
 	"time"
 
 	"github.com/spf13/cobra"
-	"github.com/urizennnn/boxify/pkg/container"
+	"github.com/urizennnn/boxify/pkg/daemon/types"
 )
 
+var (
+	psAll     bool
+	psQuiet   bool
+	psNoTrunc bool
+	psFormat  string
+	psFilters []string
+	psProject string
+)
+
+// psRow is the display form of a container, used by every output format.
+type psRow struct {
+	ID      string
+	Image   string
+	Command string
+	Created string
+	Status  string
+	Names   string
+}
+
 var psCmd = &cobra.Command{
 	Use:   "ps",
-	Short: "List all running containers",
-	Long: `Display a list of all running Boxify containers with their details.
+	Short: "List containers",
+	Long: `Display a list of Boxify containers with their details.
 
-Shows container ID, image, command, creation time, status, and other information
-in a Docker-like table format.`,
-	Example: `  # List all running containers
-  boxify ps`,
-	Run: func(cmd *cobra.Command, args []string) {
-		containers := container.ListAllContainers()
+Exited containers are hidden unless --all is given. Filters narrow the
+list down and can be repeated; values for the same key are OR'ed and
+different keys are AND'ed.
 
-		if len(containers) == 0 {
-			fmt.Println("No containers running")
-			return
-		}
+Supported filters:
+  • status=<running|paused|restarting|exited>
+  • name=<name>
+  • label=<key> or label=<key>=<value>
+  • network=<network>, or network=host and network=none for containers in
+    those network modes
+  • ancestor=<image>[:tag]
+  • health=<starting|healthy|unhealthy|none>
 
-		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+With --project only the containers of a project started by "boxify up" are
+listed. A bare --project means the project in the current directory,
+--project=NAME names another one.
 
-		fmt.Fprintln(w, "CONTAINER ID\tIMAGE\tCOMMAND\tCREATED\tSTATUS\tPORTS\tNAMES")
+The --format flag accepts "table" (default), "json" or a Go template
+evaluated for each container, e.g. '{{.ID}} {{.Status}}'.`,
+	Example: `  # List containers that have not exited
+  boxify ps
 
-		for _, c := range containers {
-			containerID := truncateString(c.ID, 12)
-			image := c.Image
-			if image == "" {
-				image = "<none>"
-			}
+  # List all containers, including exited ones
+  boxify ps -a
 
-			command := formatCommand(c.Command)
-			created := formatTimeSince(c.CreatedAt)
-			status := c.Status
+  # Only print IDs of exited containers
+  boxify ps -q --filter status=exited
 
-			ports := ""
+  # List the containers of the project in the current directory
+  boxify ps -a --project
 
-			name := containerID
+  # Custom output
+  boxify ps --format '{{.Names}}\t{{.Status}}'`,
+	Run: func(cmd *cobra.Command, args []string) {
+		query, err := psQuery()
+		if err != nil {
+			exitWithError(err)
+		}
 
-			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
-				containerID,
-				image,
-				command,
-				created,
-				status,
-				ports,
-				name,
-			)
+		var containers []*types.Container
+		if err := daemonGet("/containers/json", query, &containers); err != nil {
+			exitWithError(err)
 		}
 
-		w.Flush()
+		if psQuiet {
+			for _, c := range containers {
+				fmt.Println(displayID(c.ID, psNoTrunc))
+			}
+			return
+		}
+
+		rows := make([]psRow, 0, len(containers))
+		for _, c := range containers {
+			rows = append(rows, newPsRow(c, psNoTrunc))
+		}
+
+		if err := printPsRows(rows, psFormat); err != nil {
+			exitWithError(err)
+		}
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(psCmd)
+
+	psCmd.Flags().BoolVarP(&psAll, "all", "a", false, "Show all containers (exited containers are hidden by default)")
+	psCmd.Flags().BoolVarP(&psQuiet, "quiet", "q", false, "Only display container IDs")
+	psCmd.Flags().BoolVar(&psNoTrunc, "no-trunc", false, "Don't truncate output")
+	psCmd.Flags().StringVar(&psFormat, "format", "table", "Output format: table, json or a Go template")
+	psCmd.Flags().StringArrayVarP(&psFilters, "filter", "f", nil, "Filter output based on conditions provided")
+	psCmd.Flags().StringVar(&psProject, "project", "", "Only show the containers of a project")
+	psCmd.Flags().Lookup("project").NoOptDefVal = currentProject
+	psCmd.Flags().StringVar(&projectFile, "file", "", "Project file of a bare --project (default boxify.yaml or boxify.yml)")
+}
+
+func psQuery() (url.Values, error) {
+	query := url.Values{}
+	if psAll {
+		query.Set("all", "1")
+	}
+
+	filters := psFilters
+	if psProject != "" {
+		name, err := resolveProjectRef(psProject, projectFile)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, "label="+projectLabel+"="+name)
+	}
+
+	if err := filterQuery(query, filters); err != nil {
+		return nil, err
+	}
+	return query, nil
+}
+
+func newPsRow(c *types.Container, noTrunc bool) psRow {
+	image := c.Image
+	if image == "" {
+		image = "<none>"
+	}
+
+	command := strings.Join(c.Command, " ")
+	if !noTrunc {
+		command = formatCommand(c.Command)
+	}
+
+	name := c.Name
+	if name == "" {
+		name = displayID(c.ID, false)
+	}
+
+	var notes []string
+	if c.Status == "running" && c.Health != nil {
+		notes = append(notes, c.Health.Status)
+	}
+	if c.OOMKilled {
+		notes = append(notes, "OOMKilled")
+	}
+	status := c.Status
+	if len(notes) > 0 {
+		status += " (" + strings.Join(notes, ", ") + ")"
+	}
+
+	return psRow{
+		ID:      displayID(c.ID, noTrunc),
+		Image:   image,
+		Command: command,
+		Created: formatTimeSince(c.CreatedAt),
+		Status:  status,
+		Names:   name,
+	}
+}
+
+func printPsRows(rows []psRow, format string) error {
+	switch format {
+	case "", "table":
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "CONTAINER ID\tIMAGE\tCOMMAND\tCREATED\tSTATUS\tNAMES")
+		for _, row := range rows {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+				row.ID,
+				row.Image,
+				row.Command,
+				row.Created,
+				row.Status,
+				row.Names,
+			)
+		}
+		return w.Flush()
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		for _, row := range rows {
+			if err := encoder.Encode(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		tmpl, err := template.New("format").Parse(format)
+		if err != nil {
+			return fmt.Errorf("invalid format template: %w", err)
+		}
+		for _, row := range rows {
+			if err := tmpl.Execute(os.Stdout, row); err != nil {
+				return err
+			}
+			fmt.Println()
+		}
+		return nil
+	}
+}
+
+func displayID(id string, noTrunc bool) string {
+	if noTrunc {
+		return id
+	}
+	return truncateString(id, 12)
 }
 
 func truncateString(s string, maxLen int) string {
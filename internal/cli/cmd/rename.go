@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"net/url"
+
+	"github.com/spf13/cobra"
+)
+
+var renameCmd = &cobra.Command{
+	Use:   "rename CONTAINER NEW_NAME",
+	Short: "Rename a container",
+	Long: `Give a container a new name. Names must be unique and may only contain
+[a-zA-Z0-9][a-zA-Z0-9_.-].`,
+	Example: `  boxify rename brave_turing web`,
+	Args:    cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		query := url.Values{}
+		query.Set("name", args[1])
+		if err := daemonPost(containerPath(args[0], "/rename"), query, nil, nil); err != nil {
+			exitWithError(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(renameCmd)
+}
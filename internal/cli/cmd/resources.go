@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/urizennnn/boxify/pkg/daemon/requests"
+)
+
+// addResourceFlags registers the resource limit flags shared by run and
+// update.
+func addResourceFlags(cmd *cobra.Command, limits *requests.Resources) {
+	flags := cmd.Flags()
+	flags.StringVar(&limits.MemoryLimit, "memory", "", "Memory limit (e.g. 100m, 1g)")
+	flags.StringVar(&limits.MemoryReservation, "memory-reservation", "", "Memory soft limit (e.g. 64m)")
+	flags.StringVar(&limits.MemorySwap, "memory-swap", "", "Memory plus swap limit, -1 for unlimited swap")
+	flags.StringVar(&limits.CpuLimit, "cpu", "", "CPU limit as a percentage of one CPU (deprecated, use --cpus)")
+	flags.StringVar(&limits.Cpus, "cpus", "", "Number of CPUs (e.g. 1.5)")
+	flags.IntVar(&limits.CpuShares, "cpu-shares", 0, "Relative CPU weight (2-262144)")
+	flags.IntVar(&limits.CpuPeriod, "cpu-period", 0, "CPU CFS period in microseconds")
+	flags.IntVar(&limits.CpuQuota, "cpu-quota", 0, "CPU CFS quota in microseconds")
+	flags.StringVar(&limits.CpusetCpus, "cpuset-cpus", "", "CPUs in which to allow execution (e.g. 0-3,5)")
+	flags.StringVar(&limits.CpusetMems, "cpuset-mems", "", "Memory nodes in which to allow execution (e.g. 0,1)")
+	flags.IntVar(&limits.IOWeight, "io-weight", 0, "Relative block IO weight (1-10000)")
+	flags.StringArrayVar(&limits.DeviceReadBps, "device-read-bps", nil, "Limit read rate from a device (e.g. /dev/sda:10m)")
+	flags.StringArrayVar(&limits.DeviceWriteBps, "device-write-bps", nil, "Limit write rate to a device (e.g. /dev/sda:10m)")
+	flags.StringArrayVar(&limits.DeviceReadIOps, "device-read-iops", nil, "Limit read operations per second from a device (e.g. /dev/sda:1000)")
+	flags.StringArrayVar(&limits.DeviceWriteIOps, "device-write-iops", nil, "Limit write operations per second to a device (e.g. /dev/sda:1000)")
+	flags.IntVar(&limits.PidsLimit, "pids-limit", 0, "Maximum number of processes, -1 for unlimited")
+}
+
+// applyResourceFlags copies the resource flags that were set on the command
+// line over target.
+func applyResourceFlags(cmd *cobra.Command, target *requests.Resources, limits requests.Resources) {
+	changed := cmd.Flags().Changed
+
+	if changed("memory") {
+		target.MemoryLimit = limits.MemoryLimit
+	}
+	if changed("memory-reservation") {
+		target.MemoryReservation = limits.MemoryReservation
+	}
+	if changed("memory-swap") {
+		target.MemorySwap = limits.MemorySwap
+	}
+	// cpu, cpus and cpu-quota are alternatives, so any of them replaces
+	// whichever one the config file used.
+	if changed("cpu") || changed("cpus") || changed("cpu-quota") {
+		target.CpuLimit = limits.CpuLimit
+		target.Cpus = limits.Cpus
+		target.CpuQuota = limits.CpuQuota
+	}
+	if changed("cpu-shares") {
+		target.CpuShares = limits.CpuShares
+	}
+	if changed("cpu-period") {
+		target.CpuPeriod = limits.CpuPeriod
+	}
+	if changed("cpuset-cpus") {
+		target.CpusetCpus = limits.CpusetCpus
+	}
+	if changed("cpuset-mems") {
+		target.CpusetMems = limits.CpusetMems
+	}
+	if changed("io-weight") {
+		target.IOWeight = limits.IOWeight
+	}
+	if changed("device-read-bps") {
+		target.DeviceReadBps = limits.DeviceReadBps
+	}
+	if changed("device-write-bps") {
+		target.DeviceWriteBps = limits.DeviceWriteBps
+	}
+	if changed("device-read-iops") {
+		target.DeviceReadIOps = limits.DeviceReadIOps
+	}
+	if changed("device-write-iops") {
+		target.DeviceWriteIOps = limits.DeviceWriteIOps
+	}
+	if changed("pids-limit") {
+		target.PidsLimit = limits.PidsLimit
+	}
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var rmForce bool
+
+var rmCmd = &cobra.Command{
+	Use:   "rm CONTAINER [CONTAINER...]",
+	Short: "Remove one or more containers",
+	Long: `Remove containers together with their filesystem, network interface,
+IP address and state.
+
+Running containers are refused unless --force is given, in which case they
+are killed first.`,
+	Example: `  # Remove a stopped container
+  boxify rm web
+
+  # Kill and remove a running container
+  boxify rm -f web`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		query := url.Values{}
+		if rmForce {
+			query.Set("force", "1")
+		}
+
+		failed := false
+		for _, ref := range args {
+			if err := daemonDelete(containerPath(ref, ""), query); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %s: %v\n", ref, err)
+				failed = true
+				continue
+			}
+			fmt.Println(ref)
+		}
+
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(rmCmd)
+	rmCmd.Flags().BoolVarP(&rmForce, "force", "f", false, "Force the removal of a running container")
+}
@@ -27,9 +27,15 @@ Usage:
   boxify [command]
 
 Examples:
+  # Start a container and attach a shell
+  boxify run
+
   # List running containers
   boxify ps
 
+  # Stop a container by name
+  boxify stop web
+
   # Run a container using legacy bare method
   boxify bare
 
@@ -0,0 +1,317 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/urizennnn/boxify/config"
+	"github.com/urizennnn/boxify/pkg/daemon/requests"
+)
+
+var (
+	runName   string
+	runImage  string
+	runDetach bool
+	runLimits requests.Resources
+
+	runLabels      []string
+	runAnnotations []string
+	runVolumes     []string
+	runRestart     string
+	runPod         string
+	runNetwork     string
+	runPid         string
+	runIpc         string
+	runUts         string
+	runIP6         string
+
+	runHealthCmd         string
+	runHealthInterval    string
+	runHealthTimeout     string
+	runHealthRetries     int
+	runHealthStartPeriod string
+	runNoHealthcheck     bool
+	runRestartUnhealthy  bool
+)
+
+// createResponse is returned by the daemon's create endpoint.
+type createResponse struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	PID     int      `json:"pid"`
+	Cmd     string   `json:"cmd"`
+	Command []string `json:"command"`
+}
+
+var runCmd = &cobra.Command{
+	Use:   "run [flags] [COMMAND] [ARG...]",
+	Short: "Create and start a container",
+	Long: `Create and start a new container.
+
+Settings are read from boxify.yaml or boxify.yml in the current directory when
+present; flags take precedence over the file. Containers are created from
+--image, alpine:3.19 by default, and run the image's command unless one is
+given. Without a command the container idles and an interactive shell is
+attached to it. With a command, that command runs as the container's main
+process and its output is streamed until it exits, unless --detach is given.
+
+Containers get a generated name such as "brave_turing" unless --name is set.
+Names must be unique.
+
+Labels and annotations from the config file are merged with --label and
+--annotation flags. Labels can be used to filter ps and prune; annotations
+are free-form metadata shown by inspect.
+
+--restart decides what happens when the main process exits: "no" (default),
+"on-failure[:N]" restarts on a non-zero exit code at most N times,
+"always" restarts unconditionally and "unless-stopped" does the same unless
+the container was stopped with "boxify stop". Restarts back off
+exponentially from 100ms up to one minute.
+
+Resource flags map onto the container's cgroup v2 controls: --memory
+(memory.max), --memory-reservation (memory.low), --memory-swap (memory plus
+swap, -1 for unlimited), --cpus (fractional cores), --cpu-shares
+(cpu.weight), --cpuset-cpus/--cpuset-mems, --io-weight, --device-*-bps and
+--device-*-iops (io.max) and --pids-limit (default 100, -1 for unlimited).
+
+--network, --pid, --ipc and --uts choose where each namespace comes from:
+"private" (default) gives the container its own, "host" uses the host's and
+"container:<name|id>" joins the namespace of another running container.
+--network none gives the container a network namespace with only loopback,
+for jobs that must not reach the network, and --network NAME puts it on a
+macvlan or ipvlan network made with "boxify network create". A container in
+the host's or another container's network namespace, or without networking,
+gets no interface or address of its own. Joined namespaces end with the
+container that owns them; a PID namespace takes the processes of every
+container in it down when its owner stops.
+
+On dual-stack networks, the bridge when boxifyd runs with --ipv6 or networks
+created with --ipv6, containers also get an IPv6 address and default route.
+--ip6 picks the address instead of taking the next free one.`,
+	Example: `  # Start a container and attach a shell
+  boxify run
+
+  # Start a named container in the background
+  boxify run -d --name web --memory 256m -- httpd -f
+
+  # Run a one-off command
+  boxify run echo hello
+
+  # Run a committed image
+  boxify run -d --image myapp:v1
+
+  # Label a container and mount a volume
+  boxify run -d -l team=payments -l env=dev -v data:/data -- sleep 3600
+
+  # Debug a running container from a toolbox in its network and PID namespaces
+  boxify run --image toolbox:latest --network container:web --pid container:web`,
+	Run: func(cmd *cobra.Command, args []string) {
+		request, err := buildCreateRequest(cmd, args)
+		if err != nil {
+			exitWithError(err)
+		}
+
+		var created createResponse
+		if err := daemonPost("/containers/create", nil, request, &created); err != nil {
+			exitWithError(err)
+		}
+		if err := daemonPost(containerPath(created.ID, "/start"), nil, nil, nil); err != nil {
+			exitWithError(err)
+		}
+
+		if runDetach {
+			fmt.Println(created.ID)
+			return
+		}
+
+		if len(created.Command) > 0 {
+			if err := streamLogs(created.ID, true); err != nil {
+				exitWithError(err)
+			}
+			return
+		}
+
+		code, err := execInContainer(created.ID, []string{"/bin/sh"})
+		if err != nil {
+			exitWithError(err)
+		}
+		os.Exit(code)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(runCmd)
+
+	addCreateFlags(runCmd)
+	runCmd.Flags().BoolVarP(&runDetach, "detach", "d", false, "Run container in background and print its ID")
+}
+
+// addCreateFlags registers the flags run and create share.
+func addCreateFlags(c *cobra.Command) {
+	c.Flags().SetInterspersed(false)
+	c.Flags().StringVar(&runName, "name", "", "Assign a name to the container")
+	c.Flags().StringVar(&runImage, "image", "", "Image to create the container from")
+	addResourceFlags(c, &runLimits)
+	c.Flags().StringArrayVarP(&runLabels, "label", "l", nil, "Set metadata on the container (key=value)")
+	c.Flags().StringArrayVar(&runAnnotations, "annotation", nil, "Add an annotation to the container (key=value)")
+	c.Flags().StringArrayVarP(&runVolumes, "volume", "v", nil, "Mount a named volume (name:/path[:ro])")
+	c.Flags().StringVar(&runRestart, "restart", "", "Restart policy: no, on-failure[:N], always or unless-stopped")
+	c.Flags().StringVar(&runPod, "pod", "", "Run the container in a pod, sharing its network, IPC and UTS namespaces")
+	c.Flags().StringVar(&runNetwork, "network", "", "Network mode: private, host, none, container:<name|id> or a network name")
+	c.Flags().StringVar(&runPid, "pid", "", "PID namespace: private, host or container:<name|id>")
+	c.Flags().StringVar(&runIpc, "ipc", "", "IPC namespace: private, host or container:<name|id>")
+	c.Flags().StringVar(&runUts, "uts", "", "UTS namespace: private, host or container:<name|id>")
+	c.Flags().StringVar(&runIP6, "ip6", "", "IPv6 address of the container on a dual-stack network")
+	c.Flags().StringVar(&runHealthCmd, "health-cmd", "", "Command to run to check health")
+	c.Flags().StringVar(&runHealthInterval, "health-interval", "", "Time between running the check (e.g. 30s)")
+	c.Flags().StringVar(&runHealthTimeout, "health-timeout", "", "Maximum time to allow one check to run (e.g. 30s)")
+	c.Flags().IntVar(&runHealthRetries, "health-retries", 0, "Consecutive failures needed to report unhealthy")
+	c.Flags().StringVar(&runHealthStartPeriod, "health-start-period", "", "Start period during which failures are not counted")
+	c.Flags().BoolVar(&runNoHealthcheck, "no-healthcheck", false, "Disable any container-specified healthcheck")
+	c.Flags().BoolVar(&runRestartUnhealthy, "restart-on-unhealthy", false, "Kill the container when it turns unhealthy so its restart policy applies")
+}
+
+// buildCreateRequest merges the config file with the command line flags.
+func buildCreateRequest(cmd *cobra.Command, args []string) (*requests.InitContainerRequest, error) {
+	fileConfig, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	request := &requests.InitContainerRequest{
+		Resources:   requests.Resources(fileConfig.Settings),
+		Name:        fileConfig.Name,
+		Image:       fileConfig.Image,
+		Command:     fileConfig.Command,
+		Labels:      fileConfig.Labels,
+		Annotations: fileConfig.Annotations,
+		Volumes:     fileConfig.Volumes,
+
+		RestartPolicy: fileConfig.Restart,
+		NetworkMode:   fileConfig.NetworkMode,
+	}
+
+	if cmd.Flags().Changed("name") {
+		request.Name = runName
+	}
+	if cmd.Flags().Changed("image") {
+		request.Image = runImage
+	}
+	applyResourceFlags(cmd, &request.Resources, runLimits)
+	if cmd.Flags().Changed("restart") {
+		request.RestartPolicy = runRestart
+	}
+	if cmd.Flags().Changed("pod") {
+		request.Pod = runPod
+	}
+	if cmd.Flags().Changed("network") {
+		request.NetworkMode = runNetwork
+	}
+	request.PidMode = runPid
+	request.IpcMode = runIpc
+	request.UtsMode = runUts
+	request.IPv6Address = runIP6
+	if len(args) > 0 {
+		request.Command = args
+	}
+
+	labels, err := parseKeyValueFlags(runLabels)
+	if err != nil {
+		return nil, err
+	}
+	request.Labels = mergeMaps(request.Labels, labels)
+
+	annotations, err := parseKeyValueFlags(runAnnotations)
+	if err != nil {
+		return nil, err
+	}
+	request.Annotations = mergeMaps(request.Annotations, annotations)
+
+	request.Volumes = append(request.Volumes, runVolumes...)
+	request.HealthCheck = buildHealthCheck(cmd, fileConfig.HealthCheck)
+
+	return request, nil
+}
+
+// buildHealthCheck applies the --health-* flags on top of the config file's
+// healthcheck section.
+func buildHealthCheck(cmd *cobra.Command, fileHealthCheck *config.HealthCheck) *requests.HealthCheck {
+	if runNoHealthcheck {
+		return &requests.HealthCheck{Test: []string{"NONE"}}
+	}
+
+	healthCheck := healthCheckRequest(fileHealthCheck)
+	if healthCheck == nil {
+		healthCheck = &requests.HealthCheck{}
+	}
+
+	if cmd.Flags().Changed("health-cmd") {
+		healthCheck.Test = []string{"CMD-SHELL", runHealthCmd}
+	}
+	if cmd.Flags().Changed("health-interval") {
+		healthCheck.Interval = runHealthInterval
+	}
+	if cmd.Flags().Changed("health-timeout") {
+		healthCheck.Timeout = runHealthTimeout
+	}
+	if cmd.Flags().Changed("health-retries") {
+		healthCheck.Retries = runHealthRetries
+	}
+	if cmd.Flags().Changed("health-start-period") {
+		healthCheck.StartPeriod = runHealthStartPeriod
+	}
+	if cmd.Flags().Changed("restart-on-unhealthy") {
+		healthCheck.RestartOnUnhealthy = runRestartUnhealthy
+	}
+
+	if len(healthCheck.Test) == 0 {
+		return nil
+	}
+	return healthCheck
+}
+
+// healthCheckRequest converts a healthcheck section of a config file.
+func healthCheckRequest(h *config.HealthCheck) *requests.HealthCheck {
+	if h == nil {
+		return nil
+	}
+	return &requests.HealthCheck{
+		Test:               h.Test,
+		Interval:           h.Interval,
+		Timeout:            h.Timeout,
+		Retries:            h.Retries,
+		StartPeriod:        h.StartPeriod,
+		RestartOnUnhealthy: h.RestartOnUnhealthy,
+	}
+}
+
+// parseKeyValueFlags turns repeated key=value flags into a map. A bare key
+// gets an empty value.
+func parseKeyValueFlags(flags []string) (map[string]string, error) {
+	values := make(map[string]string)
+	for _, flag := range flags {
+		key, value, _ := strings.Cut(flag, "=")
+		if key == "" {
+			return nil, fmt.Errorf("invalid value %q, expected key=value", flag)
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+// mergeMaps returns base with overrides applied on top of it.
+func mergeMaps(base, overrides map[string]string) map[string]string {
+	if len(overrides) == 0 {
+		return base
+	}
+	merged := make(map[string]string, len(base)+len(overrides))
+	for key, value := range base {
+		merged[key] = value
+	}
+	for key, value := range overrides {
+		merged[key] = value
+	}
+	return merged
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+var stopTimeout int
+
+var stopCmd = &cobra.Command{
+	Use:   "stop CONTAINER [CONTAINER...]",
+	Short: "Stop one or more running containers",
+	Long: `Stop running containers by sending SIGTERM to their main process and
+SIGKILL once the timeout expires.`,
+	Example: `  # Stop a container, waiting up to 10 seconds
+  boxify stop web
+
+  # Kill immediately
+  boxify stop -t 0 web`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		query := url.Values{}
+		query.Set("t", strconv.Itoa(stopTimeout))
+
+		failed := false
+		for _, ref := range args {
+			if err := daemonPost(containerPath(ref, "/stop"), query, nil, nil); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %s: %v\n", ref, err)
+				failed = true
+				continue
+			}
+			fmt.Println(ref)
+		}
+
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(stopCmd)
+	stopCmd.Flags().IntVarP(&stopTimeout, "time", "t", 10, "Seconds to wait before killing the container")
+}
@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/urizennnn/boxify/pkg/daemon/types"
+)
+
+type topColumn struct {
+	title string
+	value func(p types.Process) string
+}
+
+var topColumns = map[string]topColumn{
+	"pid":  {"PID", func(p types.Process) string { return strconv.Itoa(p.PID) }},
+	"cpid": {"CPID", func(p types.Process) string { return strconv.Itoa(p.ContainerPID) }},
+	"ppid": {"PPID", func(p types.Process) string { return strconv.Itoa(p.PPID) }},
+	"uid":  {"UID", func(p types.Process) string { return strconv.Itoa(p.UID) }},
+	"user": {"USER", func(p types.Process) string { return p.User }},
+	"stat": {"STAT", func(p types.Process) string { return p.State }},
+	"time": {"TIME", func(p types.Process) string { return formatCPUTime(p.CPUTime) }},
+	"rss":  {"RSS", func(p types.Process) string { return strconv.FormatInt(p.RSS/1024, 10) }},
+	"cmd":  {"CMD", func(p types.Process) string { return p.Command }},
+}
+
+// topAliases maps other ps column names onto the ones above.
+var topAliases = map[string]string{
+	"args":    "cmd",
+	"command": "cmd",
+	"state":   "stat",
+	"s":       "stat",
+	"cputime": "time",
+	"rssize":  "rss",
+	"rsz":     "rss",
+}
+
+const defaultTopColumns = "user,pid,cpid,time,rss,cmd"
+
+var topCmd = &cobra.Command{
+	Use:   "top CONTAINER [COLUMNS]",
+	Short: "Display the running processes of a container",
+	Long: `List the processes in a container's cgroup.
+
+COLUMNS is a ps-style comma separated list chosen from pid (host PID), cpid
+(PID inside the container), ppid, uid, user, stat, time (CPU time), rss (KiB)
+and cmd. The default is "` + defaultTopColumns + `".`,
+	Example: `  # Show the processes of a container
+  boxify top web
+
+  # Only PIDs and commands
+  boxify top web pid,cpid,cmd`,
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		spec := defaultTopColumns
+		if len(args) == 2 {
+			spec = args[1]
+		}
+		columns, err := parseTopColumns(spec)
+		if err != nil {
+			exitWithError(err)
+		}
+
+		var processes []types.Process
+		if err := daemonGet(containerPath(args[0], "/top"), nil, &processes); err != nil {
+			exitWithError(err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		titles := make([]string, len(columns))
+		for i, column := range columns {
+			titles[i] = column.title
+		}
+		fmt.Fprintln(w, strings.Join(titles, "\t"))
+
+		for _, p := range processes {
+			values := make([]string, len(columns))
+			for i, column := range columns {
+				values[i] = column.value(p)
+			}
+			fmt.Fprintln(w, strings.Join(values, "\t"))
+		}
+		if err := w.Flush(); err != nil {
+			exitWithError(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(topCmd)
+}
+
+func parseTopColumns(spec string) ([]topColumn, error) {
+	var columns []topColumn
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if alias, ok := topAliases[name]; ok {
+			name = alias
+		}
+		column, ok := topColumns[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown column %q", name)
+		}
+		columns = append(columns, column)
+	}
+	return columns, nil
+}
+
+// formatCPUTime renders CPU time like ps does, as [DD-]HH:MM:SS.
+func formatCPUTime(d time.Duration) string {
+	seconds := int(d.Seconds())
+	days, seconds := seconds/86400, seconds%86400
+	clock := fmt.Sprintf("%02d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+	if days > 0 {
+		return fmt.Sprintf("%d-%s", days, clock)
+	}
+	return clock
+}
@@ -0,0 +1,355 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+
+	"github.com/spf13/cobra"
+	"github.com/urizennnn/boxify/config"
+	"github.com/urizennnn/boxify/pkg/daemon/requests"
+	"github.com/urizennnn/boxify/pkg/daemon/types"
+)
+
+var (
+	projectFile     string
+	projectNameFlag string
+
+	upDetach        bool
+	upRemoveOrphans bool
+	downVolumes     bool
+)
+
+var upCmd = &cobra.Command{
+	Use:   "up [flags] [SERVICE...]",
+	Short: "Create and start the services of a project",
+	Long: `Create and start the containers of a project file, a boxify.yaml with a
+services section:
+
+  name: shop
+  services:
+    db:
+      image: postgres:16
+      volumes: ["data:/var/lib/postgresql/data"]
+      healthcheck:
+        test: pg_isready
+    web:
+      image: shop:latest
+      command: ["./server"]
+      depends_on:
+        db:
+          condition: service_healthy
+  volumes:
+    data: {}
+
+Each service becomes a container named <project>_<service>, labelled with
+boxify.project and boxify.service. Services accept the same settings as a
+single-container boxify.yaml except name. Services start after the services
+they depend on have started, became healthy (service_healthy) or exited
+with code 0 (service_completed_successfully).
+
+Volumes are created as <project>_<volume> unless marked external. Networks
+must be external, made beforehand with "boxify network create".
+
+Running up again reconciles: containers whose configuration or image changed
+are recreated together with the services that depend on them, stopped ones
+are started and the rest are left alone. Given service names, only those
+services and their dependencies are brought up.
+
+Without --detach the services' logs are followed until interrupted, which
+leaves the containers running.`,
+	Example: `  # Bring the project in the current directory up in the background
+  boxify up -d
+
+  # Recreate just the web service (and start what it depends on)
+  boxify up -d web`,
+	Run: func(cmd *cobra.Command, args []string) {
+		project, path, err := config.LoadProject(projectFile)
+		if err != nil {
+			exitWithError(err)
+		}
+		name, err := projectName(projectNameFlag, project, path)
+		if err != nil {
+			exitWithError(err)
+		}
+
+		order, err := project.ServiceOrder()
+		if err != nil {
+			exitWithError(err)
+		}
+		services, err := withDependencies(project, order, args)
+		if err != nil {
+			exitWithError(err)
+		}
+
+		if err := checkProjectNetworks(project); err != nil {
+			exitWithError(err)
+		}
+		if err := createProjectVolumes(project, name); err != nil {
+			exitWithError(err)
+		}
+
+		existing, err := projectContainers(name)
+		if err != nil {
+			exitWithError(err)
+		}
+
+		ids := map[string]string{}
+		replaced := map[string]bool{}
+		for _, service := range services {
+			recreate := false
+			for dep, dependency := range project.Services[service].DependsOn {
+				if err := waitForCondition(dep, ids[dep], dependency.Condition); err != nil {
+					exitWithError(fmt.Errorf("service %s: %w", service, err))
+				}
+				recreate = recreate || replaced[dep]
+			}
+
+			id, created, err := reconcileService(project, name, service, existing[service], recreate)
+			if err != nil {
+				exitWithError(fmt.Errorf("service %s: %w", service, err))
+			}
+			ids[service] = id
+			replaced[service] = created
+		}
+
+		removeOrphans(project, existing)
+
+		if upDetach {
+			return
+		}
+		var containers []*types.Container
+		for _, service := range services {
+			containers = append(containers, &types.Container{
+				ID:     ids[service],
+				Labels: map[string]string{serviceLabel: service},
+			})
+		}
+		if err := printProjectLogs(containers, true); err != nil {
+			exitWithError(err)
+		}
+	},
+}
+
+// reconcileService makes the service's container match the project file
+// and run. With recreate the container is replaced even when it is up to
+// date, because a service it depends on got a new container. It returns the
+// container's ID and whether a new container was created.
+func reconcileService(project *config.Project, projectName, service string, existing []*types.Container, recreate bool) (string, bool, error) {
+	request, hash, err := serviceRequest(project, projectName, service)
+	if err != nil {
+		return "", false, err
+	}
+
+	var current *types.Container
+	for _, c := range existing {
+		if current == nil && !recreate && c.Labels[configHashLabel] == hash {
+			current = c
+			continue
+		}
+		// Outdated or duplicate containers of the service.
+		if err := daemonDelete(containerPath(c.ID, ""), url.Values{"force": {"1"}}); err != nil {
+			return "", false, fmt.Errorf("removing outdated container %s: %w", c.Name, err)
+		}
+	}
+
+	if current != nil {
+		if current.Active() {
+			fmt.Printf("Container %s is up to date\n", current.Name)
+			return current.ID, false, nil
+		}
+		if err := daemonPost(containerPath(current.ID, "/start"), nil, nil, nil); err != nil {
+			return "", false, err
+		}
+		fmt.Printf("Container %s started\n", current.Name)
+		return current.ID, false, nil
+	}
+
+	var created createResponse
+	if err := daemonPost("/containers/create", nil, request, &created); err != nil {
+		return "", false, err
+	}
+	if err := daemonPost(containerPath(created.ID, "/start"), nil, nil, nil); err != nil {
+		return "", false, err
+	}
+	if len(existing) > 0 {
+		fmt.Printf("Container %s recreated\n", created.Name)
+	} else {
+		fmt.Printf("Container %s created\n", created.Name)
+	}
+	return created.ID, true, nil
+}
+
+// removeOrphans handles containers of the project whose service is no
+// longer in the file: they are removed with --remove-orphans and reported
+// otherwise.
+func removeOrphans(project *config.Project, existing map[string][]*types.Container) {
+	var orphans []*types.Container
+	for service, containers := range existing {
+		if _, ok := project.Services[service]; !ok {
+			orphans = append(orphans, containers...)
+		}
+	}
+	sort.Slice(orphans, func(i, j int) bool { return orphans[i].Name < orphans[j].Name })
+
+	for _, c := range orphans {
+		if !upRemoveOrphans {
+			fmt.Fprintf(os.Stderr, "Warning: container %s belongs to service %q, which is not in the project file; use --remove-orphans to remove it\n", c.Name, c.Labels[serviceLabel])
+			continue
+		}
+		if err := daemonDelete(containerPath(c.ID, ""), url.Values{"force": {"1"}}); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s: %v\n", c.Name, err)
+			continue
+		}
+		fmt.Printf("Container %s removed\n", c.Name)
+	}
+}
+
+// checkProjectNetworks makes sure the external networks the project uses
+// exist.
+func checkProjectNetworks(project *config.Project) error {
+	for key := range project.Networks {
+		if key == config.DefaultNetwork && !project.Networks[key].External {
+			continue
+		}
+		var network config.NetworkStorage
+		if err := daemonGet("/networks/"+url.PathEscape(project.NetworkName(key)), nil, &network); err != nil {
+			return fmt.Errorf("network %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// createProjectVolumes creates the project's volumes that do not exist yet
+// and makes sure the external ones do.
+func createProjectVolumes(project *config.Project, projectName string) error {
+	keys := make([]string, 0, len(project.Volumes))
+	for key := range project.Volumes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		v := project.Volumes[key]
+		name := project.VolumeName(projectName, key)
+		if v.External {
+			var existing struct{}
+			if err := daemonGet("/volumes/"+url.PathEscape(name), nil, &existing); err != nil {
+				return fmt.Errorf("external volume %s: %w", name, err)
+			}
+			continue
+		}
+
+		labels := mergeMaps(nil, v.Labels)
+		labels = mergeMaps(labels, map[string]string{projectLabel: projectName})
+		request := requests.CreateVolumeRequest{Name: name, Labels: labels}
+		if err := daemonPost("/volumes/create", nil, request, nil); err != nil {
+			return fmt.Errorf("volume %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+var downCmd = &cobra.Command{
+	Use:   "down [flags]",
+	Short: "Stop and remove the containers of a project",
+	Long: `Stop and remove every container labelled with the project's name, dependents
+before their dependencies. With --volumes the volumes up created for the
+project are removed too; external volumes are kept.
+
+The project is named by --project-name, or by the project file like up does.`,
+	Example: `  boxify down
+  boxify down -v --project-name shop`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		var project *config.Project
+		name := projectNameFlag
+		if name == "" {
+			var path string
+			var err error
+			if project, path, err = config.LoadProject(projectFile); err != nil {
+				exitWithError(err)
+			}
+			if name, err = projectName("", project, path); err != nil {
+				exitWithError(err)
+			}
+		} else if loaded, _, err := config.LoadProject(projectFile); err == nil {
+			project = loaded
+		}
+
+		existing, err := projectContainers(name)
+		if err != nil {
+			exitWithError(err)
+		}
+
+		// Dependents go first, services missing from the file last.
+		var services []string
+		if project != nil {
+			if order, err := project.ServiceOrder(); err == nil {
+				for i := len(order) - 1; i >= 0; i-- {
+					services = append(services, order[i])
+				}
+			}
+		}
+		var rest []string
+		for service := range existing {
+			if project == nil || project.Services[service] == nil {
+				rest = append(rest, service)
+			}
+		}
+		sort.Strings(rest)
+		services = append(services, rest...)
+
+		failed := false
+		for _, service := range services {
+			for _, c := range existing[service] {
+				if err := daemonDelete(containerPath(c.ID, ""), url.Values{"force": {"1"}}); err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %s: %v\n", c.Name, err)
+					failed = true
+					continue
+				}
+				fmt.Printf("Container %s removed\n", c.Name)
+			}
+		}
+
+		if downVolumes {
+			query := url.Values{}
+			if err := filterQuery(query, []string{"label=" + projectLabel + "=" + name}); err != nil {
+				exitWithError(err)
+			}
+			var volumes []*struct{ Name string }
+			if err := daemonGet("/volumes", query, &volumes); err != nil {
+				exitWithError(err)
+			}
+			for _, v := range volumes {
+				if err := daemonDelete("/volumes/"+url.PathEscape(v.Name), nil); err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %s: %v\n", v.Name, err)
+					failed = true
+					continue
+				}
+				fmt.Printf("Volume %s removed\n", v.Name)
+			}
+		}
+
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+// addProjectFlags registers the flags that select a project file and name.
+func addProjectFlags(c *cobra.Command) {
+	c.Flags().StringVar(&projectFile, "file", "", "Project file (default boxify.yaml or boxify.yml)")
+	c.Flags().StringVarP(&projectNameFlag, "project-name", "p", "", "Project name (default: the file's name field or its directory)")
+}
+
+func init() {
+	rootCmd.AddCommand(upCmd, downCmd)
+
+	addProjectFlags(upCmd)
+	addProjectFlags(downCmd)
+	upCmd.Flags().BoolVarP(&upDetach, "detach", "d", false, "Start the services in the background")
+	upCmd.Flags().BoolVar(&upRemoveOrphans, "remove-orphans", false, "Remove containers of services that are not in the project file")
+	downCmd.Flags().BoolVarP(&downVolumes, "volumes", "v", false, "Remove the project's volumes")
+}
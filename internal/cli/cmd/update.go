@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/urizennnn/boxify/pkg/daemon/requests"
+)
+
+var (
+	updateLimits  requests.Resources
+	updateRestart string
+	updateForce   bool
+)
+
+var updateCmd = &cobra.Command{
+	Use:   "update [flags] CONTAINER [CONTAINER...]",
+	Short: "Update the resource limits of one or more containers",
+	Long: `Change the resource limits and restart policy of existing containers.
+
+Running containers have their cgroup updated in place, without a restart.
+Only the flags given are changed; the rest of the spec is kept. The new
+values are saved and used on every later start.
+
+A memory or pids limit below what the container currently uses is refused
+unless --force is given.`,
+	Example: `  # Raise the memory limit of a running container
+  boxify update --memory 1g web
+
+  # Pin a container to two CPUs and give it one and a half cores
+  boxify update --cpuset-cpus 0-1 --cpus 1.5 web
+
+  # Change the restart policy
+  boxify update --restart unless-stopped web worker`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		request := requests.UpdateContainerRequest{Force: updateForce}
+		applyResourceFlags(cmd, &request.Resources, updateLimits)
+		if cmd.Flags().Changed("restart") {
+			request.RestartPolicy = updateRestart
+		}
+
+		failed := false
+		for _, ref := range args {
+			if err := daemonPost(containerPath(ref, "/update"), nil, request, nil); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %s: %v\n", ref, err)
+				failed = true
+				continue
+			}
+			fmt.Println(ref)
+		}
+
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(updateCmd)
+	addResourceFlags(updateCmd, &updateLimits)
+	updateCmd.Flags().StringVar(&updateRestart, "restart", "", "Restart policy: no, on-failure[:N], always or unless-stopped")
+	updateCmd.Flags().BoolVar(&updateForce, "force", false, "Apply limits below the container's current usage")
+}
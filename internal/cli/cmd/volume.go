@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/urizennnn/boxify/pkg/daemon/requests"
+	"github.com/urizennnn/boxify/pkg/volume"
+)
+
+var (
+	volumeLabels      []string
+	volumeAnnotations []string
+	volumeFilters     []string
+	volumeQuiet       bool
+	volumeForce       bool
+)
+
+var volumeCmd = &cobra.Command{
+	Use:   "volume",
+	Short: "Manage volumes",
+	Long: `Manage named volumes. Volumes live under /var/lib/boxify/volumes and are
+mounted into containers with "boxify run -v name:/path".`,
+}
+
+var volumeCreateCmd = &cobra.Command{
+	Use:     "create [NAME]",
+	Short:   "Create a volume",
+	Example: `  boxify volume create --label team=payments data`,
+	Args:    cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		request := requests.CreateVolumeRequest{}
+		if len(args) > 0 {
+			request.Name = args[0]
+		}
+
+		var err error
+		if request.Labels, err = parseKeyValueFlags(volumeLabels); err != nil {
+			exitWithError(err)
+		}
+		if request.Annotations, err = parseKeyValueFlags(volumeAnnotations); err != nil {
+			exitWithError(err)
+		}
+
+		var created volume.Volume
+		if err := daemonPost("/volumes/create", nil, request, &created); err != nil {
+			exitWithError(err)
+		}
+		fmt.Println(created.Name)
+	},
+}
+
+var volumeLsCmd = &cobra.Command{
+	Use:     "ls",
+	Aliases: []string{"list"},
+	Short:   "List volumes",
+	Long: `List volumes. Supported filters: name=<name>, label=<key>[=<value>] and
+dangling=<true|false>.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		query := url.Values{}
+		if err := filterQuery(query, volumeFilters); err != nil {
+			exitWithError(err)
+		}
+
+		var volumes []*volume.Volume
+		if err := daemonGet("/volumes", query, &volumes); err != nil {
+			exitWithError(err)
+		}
+
+		if volumeQuiet {
+			for _, v := range volumes {
+				fmt.Println(v.Name)
+			}
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "DRIVER\tVOLUME NAME\tCREATED")
+		for _, v := range volumes {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", v.Driver, v.Name, formatTimeSince(v.CreatedAt))
+		}
+		w.Flush()
+	},
+}
+
+var volumeInspectCmd = &cobra.Command{
+	Use:   "inspect VOLUME [VOLUME...]",
+	Short: "Display detailed information on one or more volumes",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		inspectObjects(args, func(ref string) string {
+			return "/volumes/" + url.PathEscape(ref)
+		})
+	},
+}
+
+var volumeRmCmd = &cobra.Command{
+	Use:   "rm VOLUME [VOLUME...]",
+	Short: "Remove one or more volumes",
+	Long:  `Remove volumes. Volumes used by a container are refused unless --force is given.`,
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		query := url.Values{}
+		if volumeForce {
+			query.Set("force", "1")
+		}
+
+		failed := false
+		for _, name := range args {
+			if err := daemonDelete("/volumes/"+url.PathEscape(name), query); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %s: %v\n", name, err)
+				failed = true
+				continue
+			}
+			fmt.Println(name)
+		}
+
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+var volumePruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove all unused volumes",
+	Long: `Remove every volume that is not mounted by any container, optionally
+narrowed down with label filters.`,
+	Example: `  boxify volume prune --filter label=env=dev`,
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		query := url.Values{}
+		if err := filterQuery(query, volumeFilters); err != nil {
+			exitWithError(err)
+		}
+
+		var result struct {
+			Deleted []string `json:"deleted"`
+		}
+		if err := daemonPost("/volumes/prune", query, nil, &result); err != nil {
+			exitWithError(err)
+		}
+
+		for _, name := range result.Deleted {
+			fmt.Println(name)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(volumeCmd)
+	volumeCmd.AddCommand(volumeCreateCmd, volumeLsCmd, volumeInspectCmd, volumeRmCmd, volumePruneCmd)
+
+	volumeCreateCmd.Flags().StringArrayVarP(&volumeLabels, "label", "l", nil, "Set metadata on the volume (key=value)")
+	volumeCreateCmd.Flags().StringArrayVar(&volumeAnnotations, "annotation", nil, "Add an annotation to the volume (key=value)")
+	volumeLsCmd.Flags().StringArrayVarP(&volumeFilters, "filter", "f", nil, "Filter output based on conditions provided")
+	volumeLsCmd.Flags().BoolVarP(&volumeQuiet, "quiet", "q", false, "Only display volume names")
+	volumeRmCmd.Flags().BoolVarP(&volumeForce, "force", "f", false, "Remove volumes that are in use")
+	volumePruneCmd.Flags().StringArrayVarP(&volumeFilters, "filter", "f", nil, "Filter volumes to prune (label=...)")
+}
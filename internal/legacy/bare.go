@@ -19,6 +19,7 @@ import (
 )
 
 type httpResult struct {
+	ID  string `json:"id"`
 	PID int    `json:"pid"`
 	Cmd string `json:"cmd"`
 }
@@ -43,10 +44,9 @@ func Run() {
 		return
 	}
 	reqBody := requests.InitContainerRequest{
-		Name:         requestedConfig.ImageName,
+		Name:         requestedConfig.Name,
 		OriginFolder: cwd,
-		MemoryLimit:  requestedConfig.Settings.MemoryLimit,
-		CpuLimit:     requestedConfig.Settings.CpuLimit,
+		Resources:    requests.Resources(requestedConfig.Settings),
 	}
 
 	jsonData, err := json.Marshal(reqBody)
@@ -77,6 +77,15 @@ func Run() {
 	}
 	fmt.Printf("Container created successfully: PID=%d, Cmd=%s\n", result.PID, result.Cmd)
 
+	startResp, err := client.Post("http://unix/containers/"+result.ID+"/start", "application/json", nil)
+	if err != nil {
+		log.Fatalf("Failed to send request: %v", err)
+	}
+	startResp.Body.Close()
+	if startResp.StatusCode != http.StatusNoContent {
+		log.Fatalf("Start failed with status: %d", startResp.StatusCode)
+	}
+
 	containerEnv := []string{
 		"PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin",
 		"TERM=xterm",
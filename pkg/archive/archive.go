@@ -0,0 +1,437 @@
+// Package archive creates and extracts the tar streams used to copy files in
+// and out of containers.
+package archive
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// PathStatHeader carries the base64 encoded JSON PathStat of the path an
+// archive endpoint was asked for.
+const PathStatHeader = "X-Boxify-Container-Path-Stat"
+
+// PathStat describes the path an archive was taken from or will be
+// extracted to.
+type PathStat struct {
+	Name       string      `json:"name"`
+	Size       int64       `json:"size"`
+	Mode       os.FileMode `json:"mode"`
+	Mtime      time.Time   `json:"mtime"`
+	LinkTarget string      `json:"linkTarget"`
+}
+
+// Stat describes path without following a final symlink.
+func Stat(p string) (PathStat, error) {
+	info, err := os.Lstat(p)
+	if err != nil {
+		return PathStat{}, err
+	}
+
+	stat := PathStat{
+		Name:  info.Name(),
+		Size:  info.Size(),
+		Mode:  info.Mode(),
+		Mtime: info.ModTime(),
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		stat.LinkTarget, _ = os.Readlink(p)
+	}
+	return stat, nil
+}
+
+// Tar writes src and, for a directory, everything below it to w. src itself
+// is stored under name. Symlinks are archived as links, not followed.
+func Tar(w io.Writer, src, name string) error {
+	tw := tar.NewWriter(w)
+	hardlinks := map[inode]string{}
+
+	err := filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		entryName := path.Join(name, filepath.ToSlash(rel))
+		return writeEntry(tw, p, entryName, info, hardlinks, nil)
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// TarFilesystem writes the tree below root to w as a flat root filesystem
+// archive, with names relative to root. Device nodes, hard links and
+// extended attributes are kept. Paths in exclude are skipped together with
+// everything below them.
+func TarFilesystem(w io.Writer, root string, exclude []string) error {
+	tw := tar.NewWriter(w)
+	hardlinks := map[inode]string{}
+	skip := map[string]bool{}
+	for _, p := range exclude {
+		skip[path.Clean("/"+p)] = true
+	}
+
+	err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		name := filepath.ToSlash(rel)
+		if skip["/"+name] {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		return writeEntry(tw, p, name, info, hardlinks, nil)
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// TarContext writes the build context in dir to w. Paths for which ignored
+// returns true are left out, for a directory together with everything below
+// it. Every entry is owned by root, the owner of files copied into an image.
+func TarContext(w io.Writer, dir string, ignored func(rel string, info os.FileInfo) bool) error {
+	tw := tar.NewWriter(w)
+	hardlinks := map[inode]string{}
+	rootOwned := func(header *tar.Header) {
+		header.Uid, header.Gid = 0, 0
+		header.Uname, header.Gname = "", ""
+	}
+
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel != "." && ignored(rel, info) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		return writeEntry(tw, p, rel, info, hardlinks, rootOwned)
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// inode identifies a file across the filesystems below an overlay, which
+// report the device of the layer a file comes from.
+type inode struct {
+	dev, ino uint64
+}
+
+// writeEntry adds a single file with its extended attributes to tw. Regular
+// files with more than one link are stored once and as hard links after
+// that. rewrite, when set, may change the header before it is written.
+func writeEntry(tw *tar.Writer, p, name string, info os.FileInfo, hardlinks map[inode]string, rewrite func(*tar.Header)) error {
+	if info.Mode()&os.ModeSocket != 0 {
+		// Sockets cannot be archived.
+		return nil
+	}
+
+	var link string
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(p)
+		if err != nil {
+			return err
+		}
+		link = target
+	}
+
+	header, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	if info.IsDir() {
+		header.Name += "/"
+	}
+
+	if st, ok := info.Sys().(*syscall.Stat_t); ok && info.Mode().IsRegular() && st.Nlink > 1 {
+		key := inode{uint64(st.Dev), st.Ino}
+		if first, seen := hardlinks[key]; seen {
+			header.Typeflag = tar.TypeLink
+			header.Linkname = first
+			header.Size = 0
+		} else {
+			hardlinks[key] = name
+		}
+	}
+
+	if header.Typeflag != tar.TypeLink {
+		if header.PAXRecords, err = readXattrs(p); err != nil {
+			return fmt.Errorf("reading extended attributes of %s: %w", p, err)
+		}
+	}
+
+	if rewrite != nil {
+		rewrite(header)
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	if header.Typeflag != tar.TypeReg {
+		return nil
+	}
+
+	file, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(tw, file)
+	return err
+}
+
+// Untar extracts r into dir. Every entry, link target and symlink on the way
+// is resolved inside root, so a hostile archive cannot write outside of it.
+func Untar(r io.Reader, root, dir string) error {
+	return untar(r, root, dir, false)
+}
+
+// untar implements Untar. With layer set, OCI whiteout entries are turned
+// into overlay whiteouts instead of being extracted as files.
+func untar(r io.Reader, root, dir string, layer bool) error {
+	tr := tar.NewReader(r)
+
+	type dirTimes struct {
+		path  string
+		mtime time.Time
+	}
+	var dirs []dirTimes
+
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		name := path.Clean("/" + header.Name)
+		if name == "/" {
+			continue
+		}
+
+		// Resolve the parent only, the entry itself replaces whatever is
+		// there, including a symlink.
+		parent, err := SecureJoin(root, filepath.Join(dir, path.Dir(name)))
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(parent, 0o755); err != nil {
+			return err
+		}
+		target := filepath.Join(parent, path.Base(name))
+
+		if layer && strings.HasPrefix(path.Base(name), whiteoutPrefix) {
+			if err := applyWhiteout(parent, path.Base(name)); err != nil {
+				return fmt.Errorf("extracting %s: %w", header.Name, err)
+			}
+			continue
+		}
+
+		if err := extractEntry(tr, header, root, dir, target); err != nil {
+			return fmt.Errorf("extracting %s: %w", header.Name, err)
+		}
+		if header.Typeflag == tar.TypeDir {
+			dirs = append(dirs, dirTimes{target, header.ModTime})
+		}
+	}
+
+	// Directory times are set last, creating their contents changed them.
+	for _, d := range dirs {
+		os.Chtimes(d.path, d.mtime, d.mtime)
+	}
+	return nil
+}
+
+func extractEntry(tr *tar.Reader, header *tar.Header, root, dir, target string) error {
+	mode := os.FileMode(header.Mode).Perm()
+	if header.Mode&unix.S_ISUID != 0 {
+		mode |= os.ModeSetuid
+	}
+	if header.Mode&unix.S_ISGID != 0 {
+		mode |= os.ModeSetgid
+	}
+	if header.Mode&unix.S_ISVTX != 0 {
+		mode |= os.ModeSticky
+	}
+
+	if header.Typeflag != tar.TypeDir {
+		if err := removeExisting(target); err != nil {
+			return err
+		}
+	}
+
+	switch header.Typeflag {
+	case tar.TypeDir:
+		if info, err := os.Lstat(target); err == nil && !info.IsDir() {
+			if err := os.Remove(target); err != nil {
+				return err
+			}
+		}
+		if err := os.Mkdir(target, mode); err != nil && !os.IsExist(err) {
+			return err
+		}
+
+	case tar.TypeReg:
+		file, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(file, tr); err != nil {
+			file.Close()
+			return err
+		}
+		if err := file.Close(); err != nil {
+			return err
+		}
+
+	case tar.TypeSymlink:
+		if err := os.Symlink(header.Linkname, target); err != nil {
+			return err
+		}
+		if err := lchown(target, header); err != nil {
+			return err
+		}
+		return applyXattrs(target, header)
+
+	case tar.TypeLink:
+		linkTarget, err := SecureJoin(root, filepath.Join(dir, path.Clean("/"+header.Linkname)))
+		if err != nil {
+			return err
+		}
+		return os.Link(linkTarget, target)
+
+	case tar.TypeChar, tar.TypeBlock, tar.TypeFifo:
+		fileType := uint32(unix.S_IFIFO)
+		switch header.Typeflag {
+		case tar.TypeChar:
+			fileType = unix.S_IFCHR
+		case tar.TypeBlock:
+			fileType = unix.S_IFBLK
+		}
+		dev := unix.Mkdev(uint32(header.Devmajor), uint32(header.Devminor))
+		if err := unix.Mknod(target, fileType|uint32(mode.Perm()), int(dev)); err != nil {
+			return err
+		}
+
+	default:
+		// Extended headers and unknown types carry nothing to extract.
+		return nil
+	}
+
+	if err := lchown(target, header); err != nil {
+		return err
+	}
+	// chown clears the setuid bits and file capabilities, so the mode and
+	// extended attributes are applied afterwards.
+	if err := os.Chmod(target, mode); err != nil {
+		return err
+	}
+	if err := applyXattrs(target, header); err != nil {
+		return err
+	}
+	return os.Chtimes(target, header.AccessTime, header.ModTime)
+}
+
+func removeExisting(target string) error {
+	info, err := os.Lstat(target)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return os.RemoveAll(target)
+	}
+	return os.Remove(target)
+}
+
+// lchown applies the archive's ownership when running as root, otherwise the
+// files keep the extracting user's ownership.
+func lchown(target string, header *tar.Header) error {
+	if os.Geteuid() != 0 {
+		return nil
+	}
+	return os.Lchown(target, header.Uid, header.Gid)
+}
+
+// Rebase rewrites the entries of a tar stream so that oldBase, the top-level
+// entry, becomes newBase. It is used to copy a path under a different name.
+func Rebase(r io.Reader, oldBase, newBase string) io.ReadCloser {
+	pr, pw := io.Pipe()
+
+	rename := func(name string) string {
+		trimmed := strings.TrimPrefix(name, oldBase)
+		if trimmed == name || (trimmed != "" && trimmed[0] != '/') {
+			return name
+		}
+		return newBase + trimmed
+	}
+
+	go func() {
+		tr := tar.NewReader(r)
+		tw := tar.NewWriter(pw)
+		for {
+			header, err := tr.Next()
+			if errors.Is(err, io.EOF) {
+				pw.CloseWithError(tw.Close())
+				return
+			}
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+
+			header.Name = rename(header.Name)
+			if header.Typeflag == tar.TypeLink {
+				header.Linkname = rename(header.Linkname)
+			}
+			if err := tw.WriteHeader(header); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			if _, err := io.Copy(tw, tr); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+	}()
+	return pr
+}
@@ -0,0 +1,198 @@
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+type entry struct {
+	name     string
+	typeflag byte
+	linkname string
+	body     string
+}
+
+func tarball(t *testing.T, entries []entry) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		header := &tar.Header{Name: e.name, Typeflag: e.typeflag, Linkname: e.linkname, Mode: 0o644, Size: int64(len(e.body))}
+		if e.typeflag == tar.TypeDir {
+			header.Mode = 0o755
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func TestUntar(t *testing.T) {
+	tests := []struct {
+		name    string
+		dir     string
+		entries []entry
+		// existing are symlinks made in root before extracting, by name.
+		existing map[string]string
+		// want maps paths under root to the contents they must have.
+		want    map[string]string
+		wantErr string
+	}{
+		{
+			name:    "plain files",
+			dir:     "/",
+			entries: []entry{{name: "a/", typeflag: tar.TypeDir}, {name: "a/b", typeflag: tar.TypeReg, body: "b"}},
+			want:    map[string]string{"a/b": "b"},
+		},
+		{
+			name:    "dot dot names",
+			dir:     "/",
+			entries: []entry{{name: "../escape", typeflag: tar.TypeReg, body: "x"}, {name: "a/../../../deep", typeflag: tar.TypeReg, body: "y"}},
+			want:    map[string]string{"escape": "x", "deep": "y"},
+		},
+		{
+			name:    "dot dot names below dir",
+			dir:     "/sub",
+			entries: []entry{{name: "../../escape", typeflag: tar.TypeReg, body: "x"}},
+			want:    map[string]string{"sub/escape": "x"},
+		},
+		{
+			name: "absolute symlink parent",
+			dir:  "/",
+			entries: []entry{
+				{name: "link", typeflag: tar.TypeSymlink, linkname: "OUTSIDE"},
+				{name: "link/pwned", typeflag: tar.TypeReg, body: "x"},
+			},
+			want: map[string]string{"OUTSIDE/pwned": "x"},
+		},
+		{
+			name: "relative symlink parent",
+			dir:  "/",
+			entries: []entry{
+				{name: "a/", typeflag: tar.TypeDir},
+				{name: "a/link", typeflag: tar.TypeSymlink, linkname: "../../../outside"},
+				{name: "a/link/pwned", typeflag: tar.TypeReg, body: "x"},
+			},
+			want: map[string]string{"outside/pwned": "x"},
+		},
+		{
+			name:     "existing symlink parent",
+			dir:      "/",
+			existing: map[string]string{"link": "OUTSIDE"},
+			entries:  []entry{{name: "link/pwned", typeflag: tar.TypeReg, body: "x"}},
+			want:     map[string]string{"OUTSIDE/pwned": "x"},
+		},
+		{
+			name:     "existing symlink replaced",
+			dir:      "/",
+			existing: map[string]string{"secret": "OUTSIDE/secret"},
+			entries:  []entry{{name: "secret", typeflag: tar.TypeReg, body: "x"}},
+			want:     map[string]string{"secret": "x"},
+		},
+		{
+			name: "hardlink inside root",
+			dir:  "/",
+			entries: []entry{
+				{name: "file", typeflag: tar.TypeReg, body: "x"},
+				{name: "../link", typeflag: tar.TypeLink, linkname: "../../file"},
+			},
+			want: map[string]string{"file": "x", "link": "x"},
+		},
+		{
+			name:    "hardlink target escaping root",
+			dir:     "/",
+			entries: []entry{{name: "link", typeflag: tar.TypeLink, linkname: "../outside/secret"}},
+			wantErr: "no such file",
+		},
+		{
+			name:    "hardlink to an absolute host path",
+			dir:     "/",
+			entries: []entry{{name: "link", typeflag: tar.TypeLink, linkname: "OUTSIDE/secret"}},
+			wantErr: "no such file",
+		},
+		{
+			name: "hardlink through a symlink",
+			dir:  "/",
+			entries: []entry{
+				{name: "dirlink", typeflag: tar.TypeSymlink, linkname: "OUTSIDE"},
+				{name: "link", typeflag: tar.TypeLink, linkname: "dirlink/secret"},
+			},
+			wantErr: "no such file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := t.TempDir()
+			root := filepath.Join(base, "root")
+			outside := filepath.Join(base, "outside")
+			for _, dir := range []string{root, outside, filepath.Join(root, tt.dir)} {
+				if err := os.MkdirAll(dir, 0o755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			secret := filepath.Join(outside, "secret")
+			if err := os.WriteFile(secret, []byte("secret"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			// OUTSIDE stands for the absolute path of the directory next to
+			// root, which is only known once it is created.
+			resolve := func(s string) string { return strings.ReplaceAll(s, "OUTSIDE", outside) }
+			for name, target := range tt.existing {
+				if err := os.Symlink(resolve(target), filepath.Join(root, name)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			entries := make([]entry, len(tt.entries))
+			for i, e := range tt.entries {
+				e.linkname = resolve(e.linkname)
+				entries[i] = e
+			}
+
+			err := Untar(tarball(t, entries), root, tt.dir)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Untar() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("Untar() error = %v", err)
+			}
+
+			for name, want := range tt.want {
+				got, err := os.ReadFile(filepath.Join(root, resolve(name)))
+				if err != nil || string(got) != want {
+					t.Errorf("%s = %q, %v, want %q", name, got, err, want)
+				}
+			}
+
+			// Nothing next to root was touched.
+			files, err := os.ReadDir(outside)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(files) != 1 {
+				t.Errorf("files were created outside root: %v", files)
+			}
+			if data, _ := os.ReadFile(secret); string(data) != "secret" {
+				t.Errorf("the file outside root was changed to %q", data)
+			}
+			var stat syscall.Stat_t
+			if err := syscall.Stat(secret, &stat); err != nil || stat.Nlink != 1 {
+				t.Errorf("the file outside root got a hardlink")
+			}
+		})
+	}
+}
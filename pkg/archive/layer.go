@@ -0,0 +1,122 @@
+package archive
+
+import (
+	"archive/tar"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// whiteoutPrefix marks a deleted file in an OCI layer, ".wh.<name>".
+	whiteoutPrefix = ".wh."
+	// opaqueWhiteout inside a directory hides everything below it in the
+	// lower layers.
+	opaqueWhiteout = whiteoutPrefix + ".wh..opq"
+)
+
+var opaqueXattrs = []string{"trusted.overlay.opaque", "user.overlay.opaque"}
+
+// IsWhiteout reports whether info is an overlay whiteout, a 0/0 character
+// device marking a deleted lower file.
+func IsWhiteout(info os.FileInfo) bool {
+	if info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	return ok && st.Rdev == 0
+}
+
+// IsOpaque reports whether an overlay upper directory hides the lower
+// directory of the same name entirely.
+func IsOpaque(dir string) bool {
+	buf := make([]byte, 1)
+	for _, attr := range opaqueXattrs {
+		if n, err := unix.Lgetxattr(dir, attr, buf); err == nil && n == 1 && buf[0] == 'y' {
+			return true
+		}
+	}
+	return false
+}
+
+// TarLayer writes an overlay upper directory as an OCI layer: whiteout
+// devices become ".wh.<name>" entries and opaque directories get a
+// ".wh..wh..opq" entry. Paths in exclude are skipped with everything below
+// them.
+func TarLayer(w io.Writer, upperDir string, exclude []string) error {
+	tw := tar.NewWriter(w)
+	hardlinks := map[inode]string{}
+	skip := map[string]bool{}
+	for _, p := range exclude {
+		skip[path.Clean("/"+p)] = true
+	}
+
+	err := filepath.Walk(upperDir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(upperDir, p)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		name := filepath.ToSlash(rel)
+
+		if skip["/"+name] {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if IsWhiteout(info) {
+			return tw.WriteHeader(&tar.Header{
+				Typeflag: tar.TypeReg,
+				Name:     path.Join(path.Dir(name), whiteoutPrefix+path.Base(name)),
+				Mode:     0o600,
+				ModTime:  info.ModTime(),
+			})
+		}
+
+		if err := writeEntry(tw, p, name, info, hardlinks, nil); err != nil {
+			return err
+		}
+		if info.IsDir() && IsOpaque(p) {
+			return tw.WriteHeader(&tar.Header{
+				Typeflag: tar.TypeReg,
+				Name:     path.Join(name, opaqueWhiteout),
+				Mode:     0o600,
+				ModTime:  info.ModTime(),
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// UntarLayer extracts an OCI layer into dir so that it can be used as an
+// overlay lower directory, turning whiteout entries into overlay whiteouts.
+func UntarLayer(r io.Reader, dir string) error {
+	return untar(r, dir, "/", true)
+}
+
+func applyWhiteout(parent, base string) error {
+	if base == opaqueWhiteout {
+		return unix.Lsetxattr(parent, opaqueXattrs[0], []byte("y"), 0)
+	}
+
+	target := filepath.Join(parent, base[len(whiteoutPrefix):])
+	if err := removeExisting(target); err != nil {
+		return err
+	}
+	return unix.Mknod(target, unix.S_IFCHR, 0)
+}
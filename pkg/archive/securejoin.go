@@ -0,0 +1,69 @@
+package archive
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const maxSymlinks = 255
+
+var errTooManySymlinks = errors.New("too many levels of symbolic links")
+
+// SecureJoin joins unsafePath onto root and resolves every symlink along the
+// way as if root were "/", so neither ".." nor absolute or relative link
+// targets can lead outside of it. Components that do not exist yet are
+// appended as they are.
+func SecureJoin(root, unsafePath string) (string, error) {
+	root = filepath.Clean(root)
+
+	var resolved string
+	remaining := filepath.ToSlash(unsafePath)
+	links := 0
+
+	for remaining != "" {
+		var component string
+		component, remaining, _ = strings.Cut(remaining, "/")
+
+		switch component {
+		case "", ".":
+			continue
+		case "..":
+			resolved = filepath.Dir(resolved)
+			if resolved == "." || resolved == "/" {
+				resolved = ""
+			}
+			continue
+		}
+
+		next := filepath.Join(resolved, component)
+		info, err := os.Lstat(filepath.Join(root, next))
+		if err != nil {
+			if os.IsNotExist(err) {
+				resolved = next
+				continue
+			}
+			return "", err
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+
+		links++
+		if links > maxSymlinks {
+			return "", errTooManySymlinks
+		}
+		target, err := os.Readlink(filepath.Join(root, next))
+		if err != nil {
+			return "", err
+		}
+		if filepath.IsAbs(target) {
+			resolved = ""
+		}
+		remaining = filepath.ToSlash(target) + "/" + remaining
+	}
+
+	return filepath.Join(root, resolved), nil
+}
@@ -0,0 +1,74 @@
+package archive
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSecureJoin(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"etc", "dir/sub"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	links := map[string]string{
+		"abs":         "/etc",
+		"absup":       "/../../etc",
+		"rel":         "../../..",
+		"dir/up":      "../dir",
+		"dir/sub/out": "../../../../outside",
+		"dir/hop":     "up/sub",
+		"loop":        "loop",
+		"ping":        "pong",
+		"pong":        "ping",
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(root, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{path: "", want: ""},
+		{path: "/", want: ""},
+		{path: "etc/passwd", want: "etc/passwd"},
+		{path: "/etc/passwd", want: "etc/passwd"},
+		{path: "../../etc/passwd", want: "etc/passwd"},
+		{path: "dir/../../../etc", want: "etc"},
+		{path: "missing/../etc", want: "etc"},
+		{path: "abs/passwd", want: "etc/passwd"},
+		{path: "absup/passwd", want: "etc/passwd"},
+		{path: "rel/etc/passwd", want: "etc/passwd"},
+		{path: "rel", want: ""},
+		{path: "dir/up/sub", want: "dir/sub"},
+		{path: "dir/hop/x", want: "dir/sub/x"},
+		{path: "dir/sub/out/file", want: "outside/file"},
+		{path: "dir/sub/out/../etc", want: "etc"},
+		{path: "loop", wantErr: true},
+		{path: "ping/x", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := SecureJoin(root, tt.path)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("SecureJoin(%q) = %s, want an error", tt.path, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SecureJoin(%q) error = %v", tt.path, err)
+			}
+			if want := filepath.Join(root, tt.want); got != want {
+				t.Fatalf("SecureJoin(%q) = %s, want %s", tt.path, got, want)
+			}
+		})
+	}
+}
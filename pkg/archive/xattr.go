@@ -0,0 +1,79 @@
+package archive
+
+import (
+	"archive/tar"
+	"errors"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// paxXattrPrefix is the PAX record prefix GNU tar and Docker use for
+// extended attributes.
+const paxXattrPrefix = "SCHILY.xattr."
+
+// skipXattr reports whether an extended attribute belongs to the host
+// rather than the file: overlay bookkeeping and SELinux labels.
+func skipXattr(name string) bool {
+	return strings.HasPrefix(name, "trusted.overlay.") ||
+		strings.HasPrefix(name, "user.overlay.") ||
+		name == "security.selinux"
+}
+
+// readXattrs returns the extended attributes of p as PAX records.
+func readXattrs(p string) (map[string]string, error) {
+	size, err := unix.Llistxattr(p, nil)
+	if err != nil || size == 0 {
+		if errors.Is(err, unix.ENOTSUP) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	buf := make([]byte, size)
+	if size, err = unix.Llistxattr(p, buf); err != nil {
+		return nil, err
+	}
+
+	records := map[string]string{}
+	for _, name := range strings.Split(string(buf[:size]), "\x00") {
+		if name == "" || skipXattr(name) {
+			continue
+		}
+		value := make([]byte, 256)
+		n, err := unix.Lgetxattr(p, name, value)
+		if errors.Is(err, unix.ERANGE) {
+			if n, err = unix.Lgetxattr(p, name, nil); err == nil {
+				value = make([]byte, n)
+				n, err = unix.Lgetxattr(p, name, value)
+			}
+		}
+		if err != nil {
+			if errors.Is(err, unix.ENODATA) {
+				continue
+			}
+			return nil, err
+		}
+		records[paxXattrPrefix+name] = string(value[:n])
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	return records, nil
+}
+
+// applyXattrs sets the extended attributes recorded in header on target.
+// Attributes the filesystem or the caller's privileges do not allow, such as
+// user attributes on symlinks, are skipped.
+func applyXattrs(target string, header *tar.Header) error {
+	for key, value := range header.PAXRecords {
+		name, ok := strings.CutPrefix(key, paxXattrPrefix)
+		if !ok || skipXattr(name) {
+			continue
+		}
+		err := unix.Lsetxattr(target, name, []byte(value), 0)
+		if err != nil && !errors.Is(err, unix.ENOTSUP) && !errors.Is(err, unix.EPERM) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+// Package build parses Boxfiles, the Dockerfile subset boxify builds images
+// from, and the .boxifyignore file of a build context.
+package build
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DefaultBoxfile is the name looked up in the build context when no other
+// Boxfile is given.
+const DefaultBoxfile = "Boxfile"
+
+// supported lists the instructions a Boxfile may contain.
+var supported = map[string]bool{
+	"FROM":       true,
+	"RUN":        true,
+	"COPY":       true,
+	"ADD":        true,
+	"ENV":        true,
+	"WORKDIR":    true,
+	"USER":       true,
+	"ENTRYPOINT": true,
+	"CMD":        true,
+	"EXPOSE":     true,
+	"LABEL":      true,
+}
+
+// Instruction is one Boxfile instruction with continuation lines joined.
+type Instruction struct {
+	Line    int
+	Command string
+	Flags   []string
+	Value   string
+}
+
+// String is the instruction as it is shown in build output and image
+// history.
+func (i Instruction) String() string {
+	parts := append([]string{i.Command}, i.Flags...)
+	return strings.Join(append(parts, i.Value), " ")
+}
+
+// Parse reads a Boxfile. It must start with a single FROM instruction.
+func Parse(r io.Reader) ([]Instruction, error) {
+	var instructions []Instruction
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var pending strings.Builder
+	start, lineNo := 0, 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "#") || (line == "" && pending.Len() == 0) {
+			continue
+		}
+		if pending.Len() == 0 {
+			start = lineNo
+		}
+
+		if strings.HasSuffix(line, `\`) {
+			pending.WriteString(strings.TrimSuffix(line, `\`))
+			pending.WriteString(" ")
+			continue
+		}
+		pending.WriteString(line)
+
+		instruction, err := parseInstruction(start, pending.String())
+		if err != nil {
+			return nil, err
+		}
+		instructions = append(instructions, instruction)
+		pending.Reset()
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if pending.Len() > 0 {
+		instruction, err := parseInstruction(start, pending.String())
+		if err != nil {
+			return nil, err
+		}
+		instructions = append(instructions, instruction)
+	}
+
+	if len(instructions) == 0 {
+		return nil, fmt.Errorf("the Boxfile has no instructions")
+	}
+	if instructions[0].Command != "FROM" {
+		return nil, fmt.Errorf("line %d: the first instruction must be FROM", instructions[0].Line)
+	}
+	for _, instruction := range instructions[1:] {
+		if instruction.Command == "FROM" {
+			return nil, fmt.Errorf("line %d: multi-stage builds are not supported", instruction.Line)
+		}
+	}
+	return instructions, nil
+}
+
+func parseInstruction(line int, text string) (Instruction, error) {
+	command, rest, _ := strings.Cut(strings.TrimSpace(text), " ")
+	command = strings.ToUpper(command)
+	if !supported[command] {
+		return Instruction{}, fmt.Errorf("line %d: unsupported instruction %s", line, command)
+	}
+
+	instruction := Instruction{Line: line, Command: command}
+	rest = strings.TrimSpace(rest)
+	for strings.HasPrefix(rest, "--") {
+		var flag string
+		flag, rest, _ = strings.Cut(rest, " ")
+		instruction.Flags = append(instruction.Flags, flag)
+		rest = strings.TrimSpace(rest)
+	}
+	instruction.Value = rest
+
+	if instruction.Value == "" {
+		return Instruction{}, fmt.Errorf("line %d: %s requires an argument", line, command)
+	}
+	if len(instruction.Flags) > 0 {
+		return Instruction{}, fmt.Errorf("line %d: %s flags are not supported: %s", line, command, strings.Join(instruction.Flags, " "))
+	}
+	return instruction, nil
+}
+
+// Sources splits the value of a COPY or ADD instruction, in either the JSON
+// or the whitespace separated form, into its sources and destination.
+func (i Instruction) Sources() ([]string, string, error) {
+	var args []string
+	if strings.HasPrefix(i.Value, "[") {
+		if err := json.Unmarshal([]byte(i.Value), &args); err != nil {
+			return nil, "", fmt.Errorf("line %d: invalid JSON array: %w", i.Line, err)
+		}
+	} else {
+		args = strings.Fields(i.Value)
+	}
+
+	if len(args) < 2 {
+		return nil, "", fmt.Errorf("line %d: %s requires at least one source and a destination", i.Line, i.Command)
+	}
+	return args[:len(args)-1], args[len(args)-1], nil
+}
+
+// Expand substitutes $VAR and ${VAR} with values from env, a list of
+// KEY=VALUE entries. Unknown variables expand to nothing.
+func Expand(value string, env []string) string {
+	return expand(value, func(key string) string {
+		for i := len(env) - 1; i >= 0; i-- {
+			if name, v, _ := strings.Cut(env[i], "="); name == key {
+				return v
+			}
+		}
+		return ""
+	})
+}
+
+func expand(value string, lookup func(string) string) string {
+	var out strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+1 < len(value) && value[i+1] == '$' {
+			out.WriteByte('$')
+			i++
+			continue
+		}
+		if value[i] != '$' || i+1 == len(value) {
+			out.WriteByte(value[i])
+			continue
+		}
+
+		if value[i+1] == '{' {
+			end := strings.IndexByte(value[i:], '}')
+			if end < 0 {
+				out.WriteString(value[i:])
+				break
+			}
+			out.WriteString(lookup(value[i+2 : i+end]))
+			i += end
+			continue
+		}
+
+		end := i + 1
+		for end < len(value) && (value[end] == '_' || isAlnum(value[end])) {
+			end++
+		}
+		if end == i+1 {
+			out.WriteByte('$')
+			continue
+		}
+		out.WriteString(lookup(value[i+1 : end]))
+		i = end - 1
+	}
+	return out.String()
+}
+
+func isAlnum(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
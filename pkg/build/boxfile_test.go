@@ -0,0 +1,118 @@
+package build
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		boxfile string
+		want    []Instruction
+		wantErr string
+	}{
+		{
+			name:    "instructions",
+			boxfile: "# comment\nfrom alpine\n\nRUN echo hi\nCMD [\"sh\"]\n",
+			want: []Instruction{
+				{Line: 2, Command: "FROM", Value: "alpine"},
+				{Line: 4, Command: "RUN", Value: "echo hi"},
+				{Line: 5, Command: "CMD", Value: `["sh"]`},
+			},
+		},
+		{
+			name:    "continuation lines",
+			boxfile: "FROM alpine\nRUN apk add \\\n    curl \\\n    git\nUSER app",
+			want: []Instruction{
+				{Line: 1, Command: "FROM", Value: "alpine"},
+				{Line: 2, Command: "RUN", Value: "apk add  curl  git"},
+				{Line: 5, Command: "USER", Value: "app"},
+			},
+		},
+		{
+			name:    "comment inside continuation",
+			boxfile: "FROM alpine\nRUN a \\\n# note\n  b",
+			want: []Instruction{
+				{Line: 1, Command: "FROM", Value: "alpine"},
+				{Line: 2, Command: "RUN", Value: "a  b"},
+			},
+		},
+		{name: "empty", boxfile: "# nothing\n", wantErr: "no instructions"},
+		{name: "missing FROM", boxfile: "RUN true\n", wantErr: "line 1: the first instruction must be FROM"},
+		{name: "multi-stage", boxfile: "FROM a\nFROM b\n", wantErr: "line 2: multi-stage builds"},
+		{name: "unsupported", boxfile: "FROM a\nHEALTHCHECK NONE\n", wantErr: "line 2: unsupported instruction HEALTHCHECK"},
+		{name: "missing argument", boxfile: "FROM a\nWORKDIR\n", wantErr: "line 2: WORKDIR requires an argument"},
+		{name: "flags", boxfile: "FROM a\nCOPY --chown=app . /app\n", wantErr: "COPY flags are not supported: --chown=app"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(strings.NewReader(tt.boxfile))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Parse() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSources(t *testing.T) {
+	tests := []struct {
+		value    string
+		wantSrcs []string
+		wantDest string
+		wantErr  bool
+	}{
+		{value: "a b /dest/", wantSrcs: []string{"a", "b"}, wantDest: "/dest/"},
+		{value: `["my file", "/dest"]`, wantSrcs: []string{"my file"}, wantDest: "/dest"},
+		{value: "onlyone", wantErr: true},
+		{value: `["broken"`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		srcs, dest, err := Instruction{Line: 3, Command: "COPY", Value: tt.value}.Sources()
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Sources(%q) succeeded, want an error", tt.value)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(srcs, tt.wantSrcs) || dest != tt.wantDest {
+			t.Errorf("Sources(%q) = %v, %q, %v, want %v, %q", tt.value, srcs, dest, err, tt.wantSrcs, tt.wantDest)
+		}
+	}
+}
+
+func TestExpand(t *testing.T) {
+	env := []string{"HOME=/root", "APP=one", "APP=two", "EMPTY="}
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"$HOME/bin", "/root/bin"},
+		{"${HOME}bin", "/rootbin"},
+		{"$APP", "two"},
+		{"$MISSING-x", "-x"},
+		{"${EMPTY}x", "x"},
+		{`\$HOME`, "$HOME"},
+		{"cost $5 and $", "cost  and $"},
+		{"$ alone", "$ alone"},
+		{"${UNCLOSED", "${UNCLOSED"},
+	}
+
+	for _, tt := range tests {
+		if got := Expand(tt.value, env); got != tt.want {
+			t.Errorf("Expand(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package build
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Checksum digests the names, modes, link targets and contents of paths and
+// everything below them. Modification times are left out so that touching a
+// file does not invalidate the build cache.
+func Checksum(paths []string) (string, error) {
+	hash := sha256.New()
+	for _, root := range paths {
+		err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(root, p)
+			if err != nil {
+				return err
+			}
+
+			var link string
+			if info.Mode()&os.ModeSymlink != 0 {
+				if link, err = os.Readlink(p); err != nil {
+					return err
+				}
+			}
+			fmt.Fprintf(hash, "%s\x00%s\x00%o\x00%s\x00", filepath.Base(root), rel, info.Mode(), link)
+			if !info.Mode().IsRegular() {
+				return nil
+			}
+
+			file, err := os.Open(p)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			_, err = io.Copy(hash, file)
+			return err
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
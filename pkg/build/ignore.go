@@ -0,0 +1,112 @@
+package build
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// IgnoreFile lists patterns of paths left out of the build context.
+const IgnoreFile = ".boxifyignore"
+
+type ignorePattern struct {
+	pattern   string
+	exclusion bool
+}
+
+// Ignore matches context paths against the patterns of a .boxifyignore file.
+// Patterns use path.Match syntax plus "**" for any number of directories; a
+// leading "!" re-includes paths an earlier pattern excluded. The last
+// matching pattern wins.
+type Ignore struct {
+	patterns      []ignorePattern
+	hasExclusions bool
+}
+
+// ReadIgnore loads the .boxifyignore file of the context directory. A
+// missing file ignores nothing.
+func ReadIgnore(contextDir string) (*Ignore, error) {
+	ignore := &Ignore{}
+
+	file, err := os.Open(filepath.Join(contextDir, IgnoreFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ignore, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		p := ignorePattern{}
+		if strings.HasPrefix(line, "!") {
+			p.exclusion = true
+			ignore.hasExclusions = true
+			line = strings.TrimSpace(line[1:])
+		}
+		p.pattern = path.Clean(strings.TrimPrefix(filepath.ToSlash(line), "/"))
+		if _, err := path.Match(p.pattern, ""); err != nil {
+			return nil, err
+		}
+		ignore.patterns = append(ignore.patterns, p)
+	}
+	return ignore, scanner.Err()
+}
+
+// Ignored reports whether rel, a slash separated path relative to the
+// context, is left out. A path is also left out when one of its parent
+// directories matches.
+func (i *Ignore) Ignored(rel string) bool {
+	ignored := false
+	for _, p := range i.patterns {
+		if matchPath(p.pattern, rel) {
+			ignored = !p.exclusion
+		}
+	}
+	return ignored
+}
+
+// SkipDir reports whether a directory can be left out together with
+// everything below it. With exclusions a file below an ignored directory may
+// still be included, so only the directory entry itself is dropped then.
+func (i *Ignore) SkipDir(rel string) bool {
+	return !i.hasExclusions && i.Ignored(rel)
+}
+
+func matchPath(pattern, rel string) bool {
+	for p := rel; p != "."; p = path.Dir(p) {
+		if matchGlob(strings.Split(pattern, "/"), strings.Split(p, "/")) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchGlob(pattern, parts []string) bool {
+	if len(pattern) == 0 {
+		return len(parts) == 0
+	}
+	if pattern[0] == "**" {
+		for skip := 0; skip <= len(parts); skip++ {
+			if matchGlob(pattern[1:], parts[skip:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(parts) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], parts[0]); !ok {
+		return false
+	}
+	return matchGlob(pattern[1:], parts[1:])
+}
@@ -0,0 +1,98 @@
+package build
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIgnore(t *testing.T) {
+	tests := []struct {
+		name        string
+		patterns    string
+		ignored     []string
+		kept        []string
+		skipDir     []string
+		keepDir     []string
+		wantReadErr bool
+	}{
+		{
+			name:     "no file",
+			patterns: "",
+			kept:     []string{"main.go", "a/b/c"},
+		},
+		{
+			name:     "names and parents",
+			patterns: "# build output\n\n/bin\n*.log\nnode_modules\n",
+			ignored:  []string{"bin", "bin/app", "debug.log", "node_modules/x/y.js"},
+			kept:     []string{"main.go", "cmd/bin", "logs/app.txt", "a/debug.log"},
+			skipDir:  []string{"bin", "node_modules"},
+		},
+		{
+			name:     "double star",
+			patterns: "**/*.tmp\ndocs/**/draft\n",
+			ignored:  []string{"a.tmp", "x/y/z.tmp", "docs/draft", "docs/a/b/draft", "docs/a/draft/file"},
+			kept:     []string{"tmp", "docs/final", "other/draft"},
+		},
+		{
+			name:     "exclusions",
+			patterns: "vendor\n!vendor/keep\n*.md\n!README.md\n",
+			ignored:  []string{"vendor", "vendor/a.go", "CHANGES.md"},
+			kept:     []string{"vendor/keep", "vendor/keep/file.go", "README.md"},
+			keepDir:  []string{"vendor"},
+		},
+		{
+			name:     "last match wins",
+			patterns: "!app.log\n*.log\n",
+			ignored:  []string{"app.log"},
+		},
+		{
+			name:        "invalid pattern",
+			patterns:    "[\n",
+			wantReadErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tt.patterns != "" {
+				if err := os.WriteFile(filepath.Join(dir, IgnoreFile), []byte(tt.patterns), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			ignore, err := ReadIgnore(dir)
+			if tt.wantReadErr {
+				if err == nil {
+					t.Fatalf("ReadIgnore() succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReadIgnore() error = %v", err)
+			}
+
+			for _, rel := range tt.ignored {
+				if !ignore.Ignored(rel) {
+					t.Errorf("%s is not ignored", rel)
+				}
+			}
+			for _, rel := range tt.kept {
+				if ignore.Ignored(rel) {
+					t.Errorf("%s is ignored", rel)
+				}
+			}
+			for _, rel := range tt.skipDir {
+				if !ignore.SkipDir(rel) {
+					t.Errorf("directory %s is not skipped", rel)
+				}
+			}
+			for _, rel := range tt.keepDir {
+				if ignore.SkipDir(rel) {
+					t.Errorf("directory %s is skipped although a file below it may be included", rel)
+				}
+			}
+		})
+	}
+}
@@ -3,46 +3,78 @@ package cgroup
 import (
 	"log"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 )
 
-func SetupCgroupsV2(pid int, mem, cpu string) error {
-	log.Printf("Setting up cgroups v2 with memory: %s, cpu: %s for pid: %d\n", mem, cpu, pid)
-	cgroupPath := "/sys/fs/cgroup/boxify"
+const (
+	cgroupRoot = "/sys/fs/cgroup"
+	BoxifyRoot = cgroupRoot + "/boxify"
+)
 
-	if err := os.MkdirAll(cgroupPath, 0o755); err != nil {
-		return err
+// Path is the cgroup directory of a single container. The cgroups of pod
+// members are nested in their pod's cgroup.
+func Path(containerID string) string {
+	dir := filepath.Join(BoxifyRoot, containerID)
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		if matches, _ := filepath.Glob(filepath.Join(BoxifyRoot, podPrefix+"*", containerID)); len(matches) == 1 {
+			return matches[0]
+		}
 	}
+	return dir
+}
 
-	calculatedMem, err := parseMemory(strings.ToLower(mem))
-	if err != nil {
+// enableControllers delegates the controllers containers need to the
+// children of the boxify cgroup.
+func enableControllers() error {
+	if err := os.MkdirAll(BoxifyRoot, 0o755); err != nil {
 		return err
 	}
-	err = os.WriteFile(cgroupPath+"/memory.max", []byte(strconv.FormatInt(calculatedMem, 10)), 0o644)
-	if err != nil {
-		log.Printf("Error: error setting memory limit %v\n", err)
+
+	for _, dir := range []string{cgroupRoot, BoxifyRoot} {
+		err := os.WriteFile(dir+"/cgroup.subtree_control", []byte(controllers), 0o644)
+		if err != nil {
+			log.Printf("Error: error enabling controllers in %s %v\n", dir, err)
+			return err
+		}
+	}
+	return nil
+}
+
+// SetupCgroupsV2 creates (or reuses) the container's cgroup, applies its
+// limits and moves pid into it. The cgroup of a container in a pod, when
+// podID is set, is created below the pod's cgroup.
+func SetupCgroupsV2(containerID, podID string, pid int, resources Resources) error {
+	log.Printf("Setting up cgroups v2 with %+v for pid: %d\n", resources, pid)
+	cgroupPath := Path(containerID)
+
+	if err := enableControllers(); err != nil {
 		return err
 	}
+	if podID != "" {
+		if err := enablePodControllers(podID); err != nil {
+			return err
+		}
+		cgroupPath = filepath.Join(PodPath(podID), containerID)
+	}
 
-	calculatedCpu, err := strconv.Atoi(cpu)
-	if err != nil {
+	if err := os.MkdirAll(cgroupPath, 0o755); err != nil {
 		return err
 	}
-	calculatedCpu *= 1000
-	err = os.WriteFile(cgroupPath+"/cpu.max", []byte(strconv.Itoa(calculatedCpu)+" 100000"), 0o644)
-	if err != nil {
-		log.Printf("Error: error setting CPU limit %v\n", err)
+
+	// A container killed while paused leaves its cgroup frozen.
+	if err := setFrozen(containerID, false); err != nil {
+		log.Printf("Error: error thawing cgroup %v\n", err)
 		return err
 	}
 
-	err = os.WriteFile(cgroupPath+"/pids.max", []byte("100"), 0o644)
-	if err != nil {
-		log.Printf("Error: error setting PIDs limit %v\n", err)
+	if err := Apply(containerID, resources); err != nil {
+		log.Printf("Error: error applying resource limits %v\n", err)
 		return err
 	}
 
-	err = os.WriteFile(cgroupPath+"/cgroup.procs",
+	err := os.WriteFile(cgroupPath+"/cgroup.procs",
 		[]byte(strconv.Itoa(pid)), 0o644)
 	if err != nil {
 		log.Printf("Error: error adding pid to cgroup %v\n", err)
@@ -51,6 +83,17 @@ func SetupCgroupsV2(pid int, mem, cpu string) error {
 
 	return nil
 }
+
+// RemoveCgroup deletes the container's cgroup. It must not contain any
+// processes anymore.
+func RemoveCgroup(containerID string) error {
+	if err := os.Remove(Path(containerID)); err != nil && !os.IsNotExist(err) {
+		log.Printf("Error: error removing cgroup %v\n", err)
+		return err
+	}
+	return nil
+}
+
 func parseMemory(input string) (int64, error) {
 	if input == "" {
 		return 0, nil
@@ -86,3 +129,24 @@ func parseMemory(input string) (int64, error) {
 	}
 }
 
+// Procs lists the PIDs in the container's cgroup.
+func Procs(containerID string) ([]int, error) {
+	return procs(Path(containerID))
+}
+
+func procs(dir string) ([]int, error) {
+	data, err := os.ReadFile(dir + "/cgroup.procs")
+	if err != nil {
+		return nil, err
+	}
+
+	var pids []int
+	for _, field := range strings.Fields(string(data)) {
+		pid, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, err
+		}
+		pids = append(pids, pid)
+	}
+	return pids, nil
+}
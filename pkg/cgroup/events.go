@@ -0,0 +1,115 @@
+package cgroup
+
+import (
+	"bufio"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// watchPollMillis bounds how long WatchMemoryEvents blocks before asking
+// whether it should stop.
+const watchPollMillis = 1000
+
+// MemoryEvents holds the counters from a cgroup's memory.events file.
+type MemoryEvents struct {
+	Low     uint64
+	High    uint64
+	Max     uint64
+	OOM     uint64
+	OOMKill uint64
+}
+
+// ReadMemoryEvents parses the container's memory.events file.
+func ReadMemoryEvents(containerID string) (MemoryEvents, error) {
+	var events MemoryEvents
+
+	file, err := os.Open(filepath.Join(Path(containerID), "memory.events"))
+	if err != nil {
+		return events, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, raw, ok := strings.Cut(scanner.Text(), " ")
+		if !ok {
+			continue
+		}
+		value, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			return events, err
+		}
+
+		switch key {
+		case "low":
+			events.Low = value
+		case "high":
+			events.High = value
+		case "max":
+			events.Max = value
+		case "oom":
+			events.OOM = value
+		case "oom_kill":
+			events.OOMKill = value
+		}
+	}
+	return events, scanner.Err()
+}
+
+// WatchMemoryEvents calls onChange every time the kernel updates the
+// container's memory.events file. It returns once done reports true or the
+// cgroup is removed.
+func WatchMemoryEvents(containerID string, done func() bool, onChange func()) error {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+
+	path := filepath.Join(Path(containerID), "memory.events")
+	if _, err := unix.InotifyAddWatch(fd, path, unix.IN_MODIFY); err != nil {
+		return err
+	}
+
+	buf := make([]byte, 4096)
+	fds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}
+	for !done() {
+		n, err := unix.Poll(fds, watchPollMillis)
+		if err == unix.EINTR || n == 0 {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		n, err = unix.Read(fd, buf)
+		if err == unix.EAGAIN {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		removed := false
+		for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+			// struct inotify_event: wd int32, mask uint32, cookie uint32, len uint32
+			mask := binary.NativeEndian.Uint32(buf[offset+4:])
+			length := binary.NativeEndian.Uint32(buf[offset+12:])
+			if mask&unix.IN_IGNORED != 0 {
+				removed = true
+			}
+			offset += unix.SizeofInotifyEvent + int(length)
+		}
+
+		onChange()
+		if removed {
+			return nil
+		}
+	}
+	return nil
+}
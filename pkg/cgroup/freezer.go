@@ -0,0 +1,64 @@
+package cgroup
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	freezeTimeout      = 10 * time.Second
+	freezePollInterval = 10 * time.Millisecond
+)
+
+// Freeze stops every process in the container's cgroup and waits until the
+// kernel reports the cgroup as frozen.
+func Freeze(containerID string) error {
+	return setFrozen(containerID, true)
+}
+
+// Thaw resumes a frozen cgroup.
+func Thaw(containerID string) error {
+	return setFrozen(containerID, false)
+}
+
+func setFrozen(containerID string, frozen bool) error {
+	value := "0"
+	if frozen {
+		value = "1"
+	}
+	if err := os.WriteFile(filepath.Join(Path(containerID), "cgroup.freeze"), []byte(value), 0o644); err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(freezeTimeout)
+	for {
+		current, err := Frozen(containerID)
+		if err != nil {
+			return err
+		}
+		if current == frozen {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("cgroup did not report frozen %s within %s", value, freezeTimeout)
+		}
+		time.Sleep(freezePollInterval)
+	}
+}
+
+// Frozen reads the "frozen" key of the container's cgroup.events.
+func Frozen(containerID string) (bool, error) {
+	data, err := os.ReadFile(filepath.Join(Path(containerID), "cgroup.events"))
+	if err != nil {
+		return false, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if key, value, _ := strings.Cut(line, " "); key == "frozen" {
+			return value == "1", nil
+		}
+	}
+	return false, fmt.Errorf("cgroup.events has no frozen key")
+}
@@ -0,0 +1,100 @@
+package cgroup
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// controllers are the controllers boxify delegates to container cgroups.
+const controllers = "+cpu +cpuset +io +memory +pids"
+
+// Resolve maps a cgroup path as written in an OCI bundle onto the cgroup v2
+// mount. Absolute paths are taken from the root of the hierarchy, relative
+// ones are placed below parent.
+func Resolve(cgroupsPath, parent string) (string, error) {
+	if strings.Contains(cgroupsPath, ":") {
+		return "", fmt.Errorf("systemd cgroup path %q is not supported", cgroupsPath)
+	}
+	if filepath.IsAbs(cgroupsPath) {
+		return filepath.Join(cgroupRoot, filepath.Clean(cgroupsPath)), nil
+	}
+	return filepath.Join(cgroupRoot, parent, filepath.Clean("/"+cgroupsPath)), nil
+}
+
+// SetupPath creates the cgroup dir, an absolute path below the cgroup v2
+// mount, delegates the controllers to it from every ancestor, applies r and
+// the raw settings of unified, and moves pid into it. It serves cgroups
+// outside the boxify hierarchy, such as those of OCI bundles.
+func SetupPath(dir string, pid int, r Resources, unified map[string]string) error {
+	rel, err := filepath.Rel(cgroupRoot, dir)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return fmt.Errorf("cgroup %s is not below %s", dir, cgroupRoot)
+	}
+
+	ancestor := cgroupRoot
+	for _, part := range strings.Split(filepath.Dir(rel), string(filepath.Separator)) {
+		if part != "." {
+			ancestor = filepath.Join(ancestor, part)
+		}
+		if err := os.MkdirAll(ancestor, 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(ancestor, "cgroup.subtree_control"), []byte(controllers), 0o644); err != nil {
+			return fmt.Errorf("enabling controllers in %s: %w", ancestor, err)
+		}
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	if err := apply(dir, r); err != nil {
+		return err
+	}
+	for file, value := range unified {
+		if strings.Contains(file, "/") {
+			return fmt.Errorf("invalid cgroup file %q", file)
+		}
+		if err := writeSetting(dir, setting{file, value}); err != nil {
+			return err
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "cgroup.procs"), []byte(strconv.Itoa(pid)), 0o644); err != nil {
+		return fmt.Errorf("adding pid to cgroup %s: %w", dir, err)
+	}
+	return nil
+}
+
+// ProcsPath lists the PIDs in the cgroup dir.
+func ProcsPath(dir string) ([]int, error) {
+	return procs(dir)
+}
+
+// SignalPath sends sig to every process in the cgroup dir.
+func SignalPath(dir string, sig syscall.Signal) error {
+	pids, err := procs(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, pid := range pids {
+		if err := syscall.Kill(pid, sig); err != nil && err != syscall.ESRCH {
+			return err
+		}
+	}
+	return nil
+}
+
+// RemovePath deletes the cgroup dir, which must have no processes left.
+func RemovePath(dir string) error {
+	if err := os.Remove(dir); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
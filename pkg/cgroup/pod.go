@@ -0,0 +1,67 @@
+package cgroup
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// podPrefix keeps pod cgroups apart from container cgroups in BoxifyRoot.
+const podPrefix = "pod-"
+
+// infraCgroup is the leaf of a pod's cgroup that holds its infra process.
+// Processes may only live in leaves once the pod delegates controllers to
+// its members.
+const infraCgroup = "infra"
+
+// PodPath is the parent cgroup of a pod's containers. Its limits apply to
+// the pod as a whole.
+func PodPath(podID string) string {
+	return filepath.Join(BoxifyRoot, podPrefix+podID)
+}
+
+func enablePodControllers(podID string) error {
+	dir := PodPath(podID)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(dir+"/cgroup.subtree_control", []byte(controllers), 0o644)
+}
+
+// SetupPod creates (or reuses) the pod's cgroup, applies the pod's limits
+// and moves the infra process pid into it.
+func SetupPod(podID string, pid int, resources Resources) error {
+	if err := enableControllers(); err != nil {
+		return err
+	}
+	if err := enablePodControllers(podID); err != nil {
+		return err
+	}
+	dir := PodPath(podID)
+	if err := apply(dir, resources); err != nil {
+		return err
+	}
+
+	infra := filepath.Join(dir, infraCgroup)
+	if err := os.MkdirAll(infra, 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(infra+"/cgroup.procs", []byte(strconv.Itoa(pid)), 0o644)
+}
+
+// UpdatePod applies new limits to a pod's cgroup, restoring the previous
+// ones if any write fails.
+func UpdatePod(podID string, r Resources) error {
+	return update(PodPath(podID), r)
+}
+
+// RemovePod deletes the pod's cgroup once its members' cgroups are gone.
+func RemovePod(podID string) error {
+	dir := PodPath(podID)
+	for _, d := range []string{filepath.Join(dir, infraCgroup), dir} {
+		if err := os.Remove(d); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
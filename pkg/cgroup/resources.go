@@ -0,0 +1,449 @@
+package cgroup
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/urizennnn/boxify/pkg/daemon/types"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	defaultCPUPeriod = 100000
+	defaultPidsLimit = 100
+
+	minCPUShares = 2
+	maxCPUShares = 262144
+
+	// The kernel refuses cpu.max quotas below 1ms.
+	minCPUQuota = 1000
+)
+
+// Resources is a validated resource spec in the units the cgroup v2 files
+// expect. Zero values mean unlimited or the kernel default.
+type Resources struct {
+	MemoryMax  int64
+	MemoryLow  int64
+	MemorySwap *int64 // memory.swap.max, nil keeps the default, -1 is unlimited
+	CPUQuota   int64  // microseconds per CPUPeriod, 0 for unlimited
+	CPUPeriod  int64
+	CPUWeight  uint64
+	CpusetCpus string
+	CpusetMems string
+	IOWeight   uint64
+	IOMax      []IOLimit
+	PidsMax    int64 // -1 for unlimited
+}
+
+// IOLimit is one line of io.max. Zero fields are left unlimited.
+type IOLimit struct {
+	Major     uint32
+	Minor     uint32
+	ReadBps   uint64
+	WriteBps  uint64
+	ReadIOps  uint64
+	WriteIOps uint64
+}
+
+// ParseResources validates a container's resource spec and converts it to
+// cgroup v2 values.
+func ParseResources(spec types.Resources) (Resources, error) {
+	r := Resources{CPUPeriod: defaultCPUPeriod, PidsMax: defaultPidsLimit}
+
+	var err error
+	if r.MemoryMax, err = parseMemoryField("memory", spec.MemoryLimit); err != nil {
+		return r, err
+	}
+	if r.MemoryLow, err = parseMemoryField("memory_reservation", spec.MemoryReservation); err != nil {
+		return r, err
+	}
+	if r.MemoryMax > 0 && r.MemoryLow > r.MemoryMax {
+		return r, fmt.Errorf("invalid memory_reservation %q: must not exceed the memory limit %q", spec.MemoryReservation, spec.MemoryLimit)
+	}
+
+	if err := parseSwap(spec, &r); err != nil {
+		return r, err
+	}
+	if err := parseCPU(spec, &r); err != nil {
+		return r, err
+	}
+
+	if spec.CpusetCpus != "" {
+		if err := validateCPUList(spec.CpusetCpus, runtime.NumCPU()); err != nil {
+			return r, fmt.Errorf("invalid cpuset_cpus %q: %w", spec.CpusetCpus, err)
+		}
+		r.CpusetCpus = spec.CpusetCpus
+	}
+	if spec.CpusetMems != "" {
+		if err := validateCPUList(spec.CpusetMems, math.MaxInt32); err != nil {
+			return r, fmt.Errorf("invalid cpuset_mems %q: %w", spec.CpusetMems, err)
+		}
+		r.CpusetMems = spec.CpusetMems
+	}
+
+	if spec.IOWeight != 0 {
+		if spec.IOWeight < 1 || spec.IOWeight > 10000 {
+			return r, fmt.Errorf("invalid io_weight %d: must be between 1 and 10000", spec.IOWeight)
+		}
+		r.IOWeight = uint64(spec.IOWeight)
+	}
+	if r.IOMax, err = parseIOLimits(spec); err != nil {
+		return r, err
+	}
+
+	switch {
+	case spec.PidsLimit == -1:
+		r.PidsMax = -1
+	case spec.PidsLimit < 0:
+		return r, fmt.Errorf("invalid pids_limit %d: must be positive or -1 for unlimited", spec.PidsLimit)
+	case spec.PidsLimit > 0:
+		r.PidsMax = int64(spec.PidsLimit)
+	}
+
+	return r, nil
+}
+
+func parseMemoryField(field, raw string) (int64, error) {
+	if raw == "" {
+		return 0, nil
+	}
+	value, err := parseMemory(strings.ToLower(raw))
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("invalid %s %q: expected a positive size such as 512m or 1g", field, raw)
+	}
+	return value, nil
+}
+
+// parseSwap follows the Docker convention: memory_swap is memory plus swap,
+// so memory.swap.max is the difference, and -1 allows unlimited swap.
+func parseSwap(spec types.Resources, r *Resources) error {
+	switch spec.MemorySwap {
+	case "":
+		return nil
+	case "-1":
+		unlimited := int64(-1)
+		r.MemorySwap = &unlimited
+		return nil
+	}
+
+	if r.MemoryMax == 0 {
+		return fmt.Errorf("invalid memory_swap %q: a memory limit is required as well", spec.MemorySwap)
+	}
+	total, err := parseMemoryField("memory_swap", spec.MemorySwap)
+	if err != nil {
+		return err
+	}
+	if total < r.MemoryMax {
+		return fmt.Errorf("invalid memory_swap %q: must be at least the memory limit %q", spec.MemorySwap, spec.MemoryLimit)
+	}
+	swap := total - r.MemoryMax
+	r.MemorySwap = &swap
+	return nil
+}
+
+func parseCPU(spec types.Resources, r *Resources) error {
+	if spec.CpuPeriod != 0 {
+		if spec.CpuPeriod < 1000 || spec.CpuPeriod > 1000000 {
+			return fmt.Errorf("invalid cpu_period %d: must be between 1000 and 1000000 microseconds", spec.CpuPeriod)
+		}
+		r.CPUPeriod = int64(spec.CpuPeriod)
+	}
+
+	limits := 0
+	for _, set := range []bool{spec.Cpus != "", spec.CpuQuota != 0, spec.CpuLimit != ""} {
+		if set {
+			limits++
+		}
+	}
+	if limits > 1 {
+		return fmt.Errorf("cpus, cpu_quota and cpu_limit are mutually exclusive")
+	}
+
+	switch {
+	case spec.Cpus != "":
+		cpus, err := strconv.ParseFloat(spec.Cpus, 64)
+		if err != nil || cpus <= 0 {
+			return fmt.Errorf("invalid cpus %q: expected a positive number of cores such as 1.5", spec.Cpus)
+		}
+		if available := runtime.NumCPU(); cpus > float64(available) {
+			return fmt.Errorf("invalid cpus %q: only %d CPUs are available", spec.Cpus, available)
+		}
+		r.CPUQuota = int64(cpus * float64(r.CPUPeriod))
+		if r.CPUQuota < minCPUQuota {
+			return fmt.Errorf("invalid cpus %q: gives a quota of %d microseconds per %d, below the minimum of %d; use at least %g", spec.Cpus, r.CPUQuota, r.CPUPeriod, minCPUQuota, float64(minCPUQuota)/float64(r.CPUPeriod))
+		}
+	case spec.CpuQuota != 0:
+		if spec.CpuQuota < minCPUQuota {
+			return fmt.Errorf("invalid cpu_quota %d: must be at least %d microseconds", spec.CpuQuota, minCPUQuota)
+		}
+		r.CPUQuota = int64(spec.CpuQuota)
+	case spec.CpuLimit != "":
+		// cpu_limit predates cpus and is a percentage of one CPU.
+		percent, err := strconv.Atoi(spec.CpuLimit)
+		if err != nil || percent <= 0 {
+			return fmt.Errorf("invalid cpu_limit %q: expected a positive percentage of one CPU", spec.CpuLimit)
+		}
+		r.CPUQuota = int64(percent) * r.CPUPeriod / 100
+		if r.CPUQuota < minCPUQuota {
+			return fmt.Errorf("invalid cpu_limit %q: gives a quota of %d microseconds per %d, below the minimum of %d", spec.CpuLimit, r.CPUQuota, r.CPUPeriod, minCPUQuota)
+		}
+	}
+
+	if spec.CpuShares != 0 {
+		if spec.CpuShares < minCPUShares || spec.CpuShares > maxCPUShares {
+			return fmt.Errorf("invalid cpu_shares %d: must be between %d and %d", spec.CpuShares, minCPUShares, maxCPUShares)
+		}
+		// Map the cgroup v1 shares range [2, 262144] onto cpu.weight's
+		// [1, 10000], the same conversion runc uses.
+		r.CPUWeight = 1 + (uint64(spec.CpuShares)-2)*9999/262142
+	}
+	return nil
+}
+
+// validateCPUList checks a list such as "0-3,6" and that every entry is
+// below limit.
+func validateCPUList(list string, limit int) error {
+	for _, part := range strings.Split(list, ",") {
+		first, last, isRange := strings.Cut(part, "-")
+		start, err := strconv.Atoi(first)
+		if err != nil || start < 0 {
+			return fmt.Errorf("expected a list such as 0-3,6")
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(last); err != nil || end < start {
+				return fmt.Errorf("expected a list such as 0-3,6")
+			}
+		}
+		if end >= limit {
+			return fmt.Errorf("%d is out of range, only 0-%d exist", end, limit-1)
+		}
+	}
+	return nil
+}
+
+func parseIOLimits(spec types.Resources) ([]IOLimit, error) {
+	var limits []IOLimit
+	byDevice := map[[2]uint32]int{}
+
+	add := func(field string, entries []string, size bool, set func(*IOLimit, uint64)) error {
+		for _, entry := range entries {
+			path, raw, ok := strings.Cut(entry, ":")
+			if !ok || path == "" || raw == "" {
+				return fmt.Errorf("invalid %s %q: expected /dev/<device>:<rate>", field, entry)
+			}
+
+			major, minor, err := blockDevice(path)
+			if err != nil {
+				return fmt.Errorf("invalid %s %q: %w", field, entry, err)
+			}
+
+			var rate int64
+			if size {
+				rate, err = parseMemory(strings.ToLower(raw))
+			} else {
+				rate, err = strconv.ParseInt(raw, 10, 64)
+			}
+			if err != nil || rate <= 0 {
+				return fmt.Errorf("invalid %s %q: rate must be a positive number", field, entry)
+			}
+
+			key := [2]uint32{major, minor}
+			index, ok := byDevice[key]
+			if !ok {
+				index = len(limits)
+				byDevice[key] = index
+				limits = append(limits, IOLimit{Major: major, Minor: minor})
+			}
+			set(&limits[index], uint64(rate))
+		}
+		return nil
+	}
+
+	if err := add("device_read_bps", spec.DeviceReadBps, true, func(l *IOLimit, v uint64) { l.ReadBps = v }); err != nil {
+		return nil, err
+	}
+	if err := add("device_write_bps", spec.DeviceWriteBps, true, func(l *IOLimit, v uint64) { l.WriteBps = v }); err != nil {
+		return nil, err
+	}
+	if err := add("device_read_iops", spec.DeviceReadIOps, false, func(l *IOLimit, v uint64) { l.ReadIOps = v }); err != nil {
+		return nil, err
+	}
+	if err := add("device_write_iops", spec.DeviceWriteIOps, false, func(l *IOLimit, v uint64) { l.WriteIOps = v }); err != nil {
+		return nil, err
+	}
+	return limits, nil
+}
+
+func blockDevice(path string) (uint32, uint32, error) {
+	var stat unix.Stat_t
+	if err := unix.Stat(path, &stat); err != nil {
+		return 0, 0, err
+	}
+	if stat.Mode&unix.S_IFMT != unix.S_IFBLK {
+		return 0, 0, fmt.Errorf("%s is not a block device", path)
+	}
+	return unix.Major(stat.Rdev), unix.Minor(stat.Rdev), nil
+}
+
+// setting is a single write to a cgroup control file.
+type setting struct {
+	file  string
+	value string
+}
+
+// settings lists the writes that apply r, in order.
+func (r Resources) settings() []setting {
+	settings := []setting{
+		{"memory.max", limitValue(r.MemoryMax)},
+		{"memory.low", strconv.FormatInt(r.MemoryLow, 10)},
+	}
+	if r.MemorySwap != nil {
+		swap := "max"
+		if *r.MemorySwap >= 0 {
+			swap = strconv.FormatInt(*r.MemorySwap, 10)
+		}
+		settings = append(settings, setting{"memory.swap.max", swap})
+	}
+
+	settings = append(settings, setting{"cpu.max", limitValue(r.CPUQuota) + " " + strconv.FormatInt(r.CPUPeriod, 10)})
+	if r.CPUWeight != 0 {
+		settings = append(settings, setting{"cpu.weight", strconv.FormatUint(r.CPUWeight, 10)})
+	}
+
+	if r.CpusetCpus != "" {
+		settings = append(settings, setting{"cpuset.cpus", r.CpusetCpus})
+	}
+	if r.CpusetMems != "" {
+		settings = append(settings, setting{"cpuset.mems", r.CpusetMems})
+	}
+
+	if r.IOWeight != 0 {
+		settings = append(settings, setting{"io.weight", "default " + strconv.FormatUint(r.IOWeight, 10)})
+	}
+	for _, limit := range r.IOMax {
+		settings = append(settings, setting{"io.max", limit.String()})
+	}
+
+	return append(settings, setting{"pids.max", limitValue(r.PidsMax)})
+}
+
+func writeSetting(dir string, s setting) error {
+	if err := os.WriteFile(filepath.Join(dir, s.file), []byte(s.value), 0o644); err != nil {
+		return fmt.Errorf("setting %s to %q: %w", s.file, s.value, err)
+	}
+	return nil
+}
+
+// Apply writes the resource spec to the container's cgroup.
+func Apply(containerID string, r Resources) error {
+	return apply(Path(containerID), r)
+}
+
+func apply(dir string, r Resources) error {
+	for _, s := range r.settings() {
+		if err := writeSetting(dir, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Update applies a new resource spec to a live cgroup. If any write fails,
+// the files already written are restored so the cgroup is left as it was.
+func Update(containerID string, r Resources) error {
+	return update(Path(containerID), r)
+}
+
+func update(dir string, r Resources) error {
+	var applied []setting
+	for _, s := range r.settings() {
+		previous, err := currentSetting(dir, s)
+		if err != nil {
+			rollback(dir, applied)
+			return err
+		}
+		if err := writeSetting(dir, s); err != nil {
+			rollback(dir, applied)
+			return err
+		}
+		applied = append(applied, previous)
+	}
+	return nil
+}
+
+func rollback(dir string, applied []setting) {
+	for i := len(applied) - 1; i >= 0; i-- {
+		if err := writeSetting(dir, applied[i]); err != nil {
+			log.Printf("Error: error restoring %s in %s %v\n", applied[i].file, dir, err)
+		}
+	}
+}
+
+// currentSetting reads the value s would overwrite, in a form that can be
+// written back.
+func currentSetting(dir string, s setting) (setting, error) {
+	data, err := os.ReadFile(filepath.Join(dir, s.file))
+	if err != nil {
+		return setting{}, err
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+
+	switch s.file {
+	case "io.max":
+		// io.max only lists devices that have a limit.
+		device, _, _ := strings.Cut(s.value, " ")
+		for _, line := range lines {
+			if strings.HasPrefix(line, device+" ") {
+				return setting{s.file, line}, nil
+			}
+		}
+		return setting{s.file, device + " rbps=max wbps=max riops=max wiops=max"}, nil
+	case "io.weight":
+		return setting{s.file, lines[0]}, nil
+	}
+	return setting{s.file, strings.Join(lines, "\n")}, nil
+}
+
+// MemoryCurrent returns the memory currently charged to the container.
+func MemoryCurrent(containerID string) (int64, error) {
+	return readInt(containerID, "memory.current")
+}
+
+// PidsCurrent returns the number of processes in the container.
+func PidsCurrent(containerID string) (int64, error) {
+	return readInt(containerID, "pids.current")
+}
+
+func readInt(containerID, file string) (int64, error) {
+	data, err := os.ReadFile(filepath.Join(Path(containerID), file))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+}
+
+func (l IOLimit) String() string {
+	rate := func(v uint64) string {
+		if v == 0 {
+			return "max"
+		}
+		return strconv.FormatUint(v, 10)
+	}
+	return fmt.Sprintf("%d:%d rbps=%s wbps=%s riops=%s wiops=%s",
+		l.Major, l.Minor, rate(l.ReadBps), rate(l.WriteBps), rate(l.ReadIOps), rate(l.WriteIOps))
+}
+
+// limitValue renders a cgroup limit, where zero and -1 mean "max".
+func limitValue(value int64) string {
+	if value <= 0 {
+		return "max"
+	}
+	return strconv.FormatInt(value, 10)
+}
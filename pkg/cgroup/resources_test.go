@@ -0,0 +1,63 @@
+package cgroup
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/urizennnn/boxify/pkg/daemon/types"
+)
+
+func TestParseResources(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    types.Resources
+		check   func(r Resources) bool
+		wantErr string
+	}{
+		{name: "defaults", spec: types.Resources{}, check: func(r Resources) bool {
+			return r.CPUQuota == 0 && r.CPUPeriod == defaultCPUPeriod && r.PidsMax == defaultPidsLimit
+		}},
+		{name: "cpus", spec: types.Resources{Cpus: "0.5"}, check: func(r Resources) bool { return r.CPUQuota == 50000 }},
+		{name: "smallest cpus", spec: types.Resources{Cpus: "0.01"}, check: func(r Resources) bool { return r.CPUQuota == 1000 }},
+		{name: "cpus below minimum quota", spec: types.Resources{Cpus: "0.005"}, wantErr: "use at least 0.01"},
+		{name: "cpus below minimum with period", spec: types.Resources{Cpus: "0.5", CpuPeriod: 1000}, wantErr: "use at least 1"},
+		{name: "cpus with period", spec: types.Resources{Cpus: "1", CpuPeriod: 1000}, check: func(r Resources) bool {
+			return r.CPUQuota == 1000 && r.CPUPeriod == 1000
+		}},
+		{name: "zero cpus", spec: types.Resources{Cpus: "0"}, wantErr: "positive number of cores"},
+		{name: "too many cpus", spec: types.Resources{Cpus: "100000"}, wantErr: "CPUs are available"},
+		{name: "cpu_quota", spec: types.Resources{CpuQuota: 1000}, check: func(r Resources) bool { return r.CPUQuota == 1000 }},
+		{name: "cpu_quota too small", spec: types.Resources{CpuQuota: 999}, wantErr: "at least 1000"},
+		{name: "cpu_limit", spec: types.Resources{CpuLimit: "50"}, check: func(r Resources) bool { return r.CPUQuota == 50000 }},
+		{name: "cpu_limit below minimum quota", spec: types.Resources{CpuLimit: "50", CpuPeriod: 1000}, wantErr: "below the minimum"},
+		{name: "cpu limits exclusive", spec: types.Resources{Cpus: "1", CpuQuota: 50000}, wantErr: "mutually exclusive"},
+		{name: "cpu_period out of range", spec: types.Resources{CpuPeriod: 999}, wantErr: "invalid cpu_period"},
+		{name: "cpu_shares", spec: types.Resources{CpuShares: 1024}, check: func(r Resources) bool { return r.CPUWeight == 39 }},
+		{name: "memory", spec: types.Resources{MemoryLimit: "512m"}, check: func(r Resources) bool { return r.MemoryMax == 512<<20 }},
+		{name: "reservation above limit", spec: types.Resources{MemoryLimit: "512m", MemoryReservation: "1g"}, wantErr: "must not exceed"},
+		{name: "swap", spec: types.Resources{MemoryLimit: "512m", MemorySwap: "1g"}, check: func(r Resources) bool {
+			return r.MemorySwap != nil && *r.MemorySwap == 512<<20
+		}},
+		{name: "swap without memory", spec: types.Resources{MemorySwap: "1g"}, wantErr: "memory limit is required"},
+		{name: "unlimited pids", spec: types.Resources{PidsLimit: -1}, check: func(r Resources) bool { return r.PidsMax == -1 }},
+		{name: "negative pids", spec: types.Resources{PidsLimit: -2}, wantErr: "invalid pids_limit"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := ParseResources(tt.spec)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParseResources() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseResources() error = %v", err)
+			}
+			if !tt.check(r) {
+				t.Fatalf("ParseResources() = %+v", r)
+			}
+		})
+	}
+}
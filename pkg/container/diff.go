@@ -0,0 +1,135 @@
+package container
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/urizennnn/boxify/pkg/archive"
+	"github.com/urizennnn/boxify/pkg/daemon/types"
+)
+
+// RootfsDir is the extracted Alpine rootfs the default image is imported
+// from. Containers without image layers use it as their only lower layer.
+const RootfsDir = "/var/lib/boxify/boxify-rootfs"
+
+// OldRootDir is created by boxify itself for pivot_root and is never reported
+// as a change or committed.
+const OldRootDir = "/.oldroot"
+
+// UpperDir holds everything a container wrote on top of its image.
+func UpperDir(containerID string) string {
+	return ContainerRootDir + "/" + containerID + "/upper"
+}
+
+// Changes compares the container's upper layer with its lower layers, given
+// top first. Paths in exclude, such as volume mount points, and everything
+// below them are skipped.
+func Changes(containerID string, lowers []string, exclude []string) ([]types.Change, error) {
+	if len(lowers) == 0 {
+		lowers = []string{RootfsDir}
+	}
+	upper := UpperDir(containerID)
+	skip := map[string]bool{OldRootDir: true}
+	for _, p := range exclude {
+		skip[filepath.Clean(p)] = true
+	}
+
+	changes := []types.Change{}
+	if _, err := os.Stat(upper); os.IsNotExist(err) {
+		// The container was never started.
+		return changes, nil
+	}
+
+	err := filepath.Walk(upper, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(upper, p)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		name := "/" + rel
+
+		if skip[name] {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if archive.IsWhiteout(info) {
+			changes = append(changes, types.Change{Path: name, Kind: types.ChangeDeleted})
+			return nil
+		}
+
+		if !lowerExists(lowers, rel) {
+			changes = append(changes, types.Change{Path: name, Kind: types.ChangeAdded})
+			return nil
+		}
+		changes = append(changes, types.Change{Path: name, Kind: types.ChangeModified})
+
+		if info.IsDir() && archive.IsOpaque(p) {
+			deleted, err := hiddenLowerEntries(p, lowers, rel, name)
+			if err != nil {
+				return err
+			}
+			changes = append(changes, deleted...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes, nil
+}
+
+// hiddenLowerEntries lists the lower entries an opaque upper directory hides
+// as deletions. Entries recreated in the upper directory are reported by the
+// walk itself.
+func hiddenLowerEntries(upperDir string, lowers []string, rel, name string) ([]types.Change, error) {
+	seen := map[string]bool{}
+	var changes []types.Change
+	for _, lower := range lowers {
+		entries, err := os.ReadDir(filepath.Join(lower, rel))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+
+		for _, entry := range entries {
+			if seen[entry.Name()] {
+				continue
+			}
+			seen[entry.Name()] = true
+
+			entryRel := filepath.Join(rel, entry.Name())
+			if _, err := os.Lstat(filepath.Join(upperDir, entry.Name())); err == nil || !lowerExists(lowers, entryRel) {
+				continue
+			}
+			changes = append(changes, types.Change{Path: filepath.Join(name, entry.Name()), Kind: types.ChangeDeleted})
+		}
+	}
+	return changes, nil
+}
+
+// lowerExists reports whether rel is visible in the union of the lower
+// layers, honouring whiteouts and opaque directories in upper layers.
+func lowerExists(lowers []string, rel string) bool {
+	for _, lower := range lowers {
+		if info, err := os.Lstat(filepath.Join(lower, rel)); err == nil {
+			return !archive.IsWhiteout(info)
+		}
+		// An opaque ancestor in this layer hides the layers below it.
+		for dir := filepath.Dir(rel); dir != "." && dir != "/"; dir = filepath.Dir(dir) {
+			if archive.IsOpaque(filepath.Join(lower, dir)) {
+				return false
+			}
+		}
+	}
+	return false
+}
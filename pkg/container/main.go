@@ -4,8 +4,8 @@ import (
 	"log"
 )
 
-func InitContainer(containerID string) (error,string){
-	err,containerID := CreateOverlayFS(containerID)
+func InitContainer(containerID string, lowers []string) (error,string){
+	err,containerID := CreateOverlayFS(containerID, lowers)
 	if err != nil {
 		log.Printf("Error: failed to create overlay %v\n", err)
 		return err,""
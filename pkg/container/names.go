@@ -0,0 +1,39 @@
+package container
+
+import (
+	"fmt"
+	"math/rand"
+	"regexp"
+)
+
+var validName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+var nameAdjectives = []string{
+	"admiring", "bold", "brave", "calm", "clever", "cool", "dazzling",
+	"eager", "elastic", "festive", "focused", "gallant", "gifted", "happy",
+	"hopeful", "jolly", "keen", "kind", "lucid", "modest", "nifty",
+	"optimistic", "peaceful", "quirky", "relaxed", "serene", "sharp",
+	"stoic", "tender", "trusting", "vibrant", "wizardly", "zealous",
+}
+
+var nameNouns = []string{
+	"albattani", "babbage", "bohr", "curie", "darwin", "dijkstra",
+	"einstein", "euler", "faraday", "feynman", "galileo", "gauss",
+	"hopper", "hypatia", "kepler", "knuth", "lamarr", "lovelace",
+	"maxwell", "newton", "noether", "pascal", "ritchie", "shannon",
+	"tesla", "thompson", "torvalds", "turing", "wozniak", "yonath",
+}
+
+// GenerateName returns a random memorable name such as "brave_turing".
+func GenerateName() string {
+	adjective := nameAdjectives[rand.Intn(len(nameAdjectives))]
+	noun := nameNouns[rand.Intn(len(nameNouns))]
+	return adjective + "_" + noun
+}
+
+func ValidateName(name string) error {
+	if !validName.MatchString(name) {
+		return fmt.Errorf("invalid container name %q, only [a-zA-Z0-9][a-zA-Z0-9_.-] are allowed", name)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package container
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// JoinNamespace names an existing namespace by path. Type is the clone flag
+// of its namespace type, such as syscall.CLONE_NEWNET.
+type JoinNamespace struct {
+	Type uintptr
+	Path string
+}
+
+// ProcessNamespace is the namespace of type nsType, "net", "ipc", "uts" and
+// so on, that the process pid belongs to.
+func ProcessNamespace(pid int, nsType string, flag uintptr) JoinNamespace {
+	return JoinNamespace{Type: flag, Path: fmt.Sprintf("/proc/%d/ns/%s", pid, nsType)}
+}
+
+// StartIn starts cmd after joining the namespaces of join, so the process
+// inherits them instead of the caller's. Namespaces belong to the calling
+// thread, so the work happens on a locked thread that is discarded
+// afterwards rather than handed back to the scheduler in the joined
+// namespaces.
+func StartIn(cmd *exec.Cmd, join []JoinNamespace) error {
+	if len(join) == 0 {
+		return cmd.Start()
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+		result <- func() error {
+			for _, ns := range join {
+				if ns.Type == syscall.CLONE_NEWNS {
+					// A thread that shares its filesystem information with
+					// others cannot change its mount namespace.
+					if err := unix.Unshare(unix.CLONE_FS); err != nil {
+						return err
+					}
+				}
+			}
+			for _, ns := range join {
+				file, err := os.Open(ns.Path)
+				if err != nil {
+					return fmt.Errorf("opening namespace: %w", err)
+				}
+				err = unix.Setns(int(file.Fd()), int(ns.Type))
+				file.Close()
+				if err != nil {
+					return fmt.Errorf("joining namespace %s: %w", ns.Path, err)
+				}
+			}
+			return cmd.Start()
+		}()
+	}()
+	return <-result
+}
@@ -3,13 +3,23 @@ package container
 import (
 	"log"
 	"os"
+	"strings"
 	"syscall"
 )
 
-func CreateOverlayFS(containerID string) (error, string) {
-	upperDir := "/var/lib/boxify/boxify-container/" + containerID + "/upper"
-	workDir := "/var/lib/boxify/boxify-container/" + containerID + "/work"
-	mergedDir := "/var/lib/boxify/boxify-container/" + containerID + "/merged"
+const ContainerRootDir = "/var/lib/boxify/boxify-container"
+
+// MergedDir is the container's root filesystem as seen from the host.
+func MergedDir(containerID string) string {
+	return ContainerRootDir + "/" + containerID + "/merged"
+}
+
+// CreateOverlayFS mounts the container's root filesystem with lowers, the
+// image layer directories top first, below its upper directory.
+func CreateOverlayFS(containerID string, lowers []string) (error, string) {
+	upperDir := UpperDir(containerID)
+	workDir := ContainerRootDir + "/" + containerID + "/work"
+	mergedDir := ContainerRootDir + "/" + containerID + "/merged"
 	log.Printf("Creating overlay FS for container %s\n", containerID)
 
 	err := os.MkdirAll(upperDir, 0o755)
@@ -29,7 +39,15 @@ func CreateOverlayFS(containerID string) (error, string) {
 		log.Printf("Error: error creating directory for mergedDir %v\n", err)
 		return err, ""
 	}
-	opts := "lowerdir=/var/lib/boxify/boxify-rootfs,upperdir=" + upperDir + ",workdir=" + workDir
+	if IsMounted(mergedDir) {
+		log.Printf("%v is already mounted\n", mergedDir)
+		return nil, mergedDir
+	}
+
+	if len(lowers) == 0 {
+		lowers = []string{RootfsDir}
+	}
+	opts := "lowerdir=" + strings.Join(lowers, ":") + ",upperdir=" + upperDir + ",workdir=" + workDir
 	log.Printf("mounting %v\n", mergedDir)
 	err = syscall.Mount("overlay", mergedDir, "overlay", 0, opts)
 	if err != nil {
@@ -48,3 +66,51 @@ func CreateOverlayFS(containerID string) (error, string) {
 
 	return nil, mergedDir
 }
+
+// RemoveOverlayFS unmounts the container's merged view and deletes all of its
+// overlay directories.
+func RemoveOverlayFS(containerID string) error {
+	containerDir := ContainerRootDir + "/" + containerID
+	mergedDir := containerDir + "/merged"
+
+	log.Printf("unmounting %v\n", mergedDir)
+	if err := syscall.Unmount(mergedDir, syscall.MNT_DETACH); err != nil && err != syscall.EINVAL && !os.IsNotExist(err) {
+		log.Printf("Error: failed to unmount %v\n", err)
+		return err
+	}
+
+	if err := os.RemoveAll(containerDir); err != nil {
+		log.Printf("Error: failed to remove container directory %v\n", err)
+		return err
+	}
+	return nil
+}
+
+// IsMounted reports whether path is a mount point in the current mount
+// namespace.
+func IsMounted(path string) bool {
+	data, err := os.ReadFile("/proc/self/mountinfo")
+	if err != nil {
+		return false
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 4 && fields[4] == path {
+			return true
+		}
+	}
+	return false
+}
+
+// RootFS returns the container's merged root filesystem, mounting the
+// overlay first if it is not mounted in this mount namespace, which is the
+// case for stopped containers after a daemon restart.
+func RootFS(containerID string, lowers []string) (string, error) {
+	mergedDir := MergedDir(containerID)
+	if IsMounted(mergedDir) {
+		return mergedDir, nil
+	}
+	err, mergedDir := CreateOverlayFS(containerID, lowers)
+	return mergedDir, err
+}
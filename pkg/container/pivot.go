@@ -0,0 +1,44 @@
+package container
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// PivotRoot makes newRoot the root filesystem of the calling process's mount
+// namespace and detaches the old root. newRoot is bind mounted onto itself
+// first, since pivot_root needs a mount point.
+func PivotRoot(newRoot string) error {
+	log.Printf("pivoting root to %s\n", newRoot)
+
+	putOld := filepath.Join(newRoot, ".pivot_root")
+	if err := os.MkdirAll(putOld, 0700); err != nil {
+		return err
+	}
+
+	if err := syscall.Mount(newRoot, newRoot, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+		return err
+	}
+
+	if err := syscall.PivotRoot(newRoot, putOld); err != nil {
+		return err
+	}
+
+	if err := os.Chdir("/"); err != nil {
+		return err
+	}
+
+	putOld = "/.pivot_root"
+	if err := syscall.Unmount(putOld, syscall.MNT_DETACH); err != nil {
+		return err
+	}
+
+	if err := os.RemoveAll(putOld); err != nil {
+		return err
+	}
+
+	log.Printf("successfully pivoted root\n")
+	return nil
+}
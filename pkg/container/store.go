@@ -0,0 +1,98 @@
+package container
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/urizennnn/boxify/pkg/daemon/types"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	StateDir     = "/var/lib/boxify/containers"
+	DefaultImage = "alpine:3.19"
+)
+
+func stateFile(containerID string) string {
+	return filepath.Join(StateDir, containerID, "state.yaml")
+}
+
+// LogPath is where the container's stdout and stderr are written.
+func LogPath(containerID string) string {
+	return filepath.Join(StateDir, containerID, "container.log")
+}
+
+// OpenLogFile opens the container's log file for appending, creating the
+// state directory if needed.
+func OpenLogFile(containerID string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Join(StateDir, containerID), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create state directory: %w", err)
+	}
+	return os.OpenFile(LogPath(containerID), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+}
+
+// SaveState persists the container record, replacing any previous copy.
+func SaveState(c *types.Container) error {
+	dir := filepath.Join(StateDir, c.ID)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("failed to marshal container state: %w", err)
+	}
+
+	tmp := stateFile(c.ID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write container state: %w", err)
+	}
+	if err := os.Rename(tmp, stateFile(c.ID)); err != nil {
+		return fmt.Errorf("failed to replace container state: %w", err)
+	}
+
+	return nil
+}
+
+// LoadStates reads every persisted container record. Records that cannot be
+// parsed are logged and skipped.
+func LoadStates() ([]*types.Container, error) {
+	entries, err := os.ReadDir(StateDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read state directory: %w", err)
+	}
+
+	var containers []*types.Container
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		data, err := os.ReadFile(stateFile(entry.Name()))
+		if err != nil {
+			log.Printf("Skipping container %s: %v\n", entry.Name(), err)
+			continue
+		}
+
+		var c types.Container
+		if err := yaml.Unmarshal(data, &c); err != nil {
+			log.Printf("Skipping container %s: %v\n", entry.Name(), err)
+			continue
+		}
+		containers = append(containers, &c)
+	}
+
+	return containers, nil
+}
+
+func RemoveState(containerID string) error {
+	if err := os.RemoveAll(filepath.Join(StateDir, containerID)); err != nil {
+		return fmt.Errorf("failed to remove container state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package container
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// SyncFd is the descriptor boxify-init inherits its end of the sync socket
+// on, the first of the daemon's extra files.
+const SyncFd = 3
+
+// Messages of the handshake between the daemon and boxify-init. After the
+// clone, init waits for "go" while the daemon sets up the container's cgroup
+// and network. Init then pivots into the container's root and answers
+// "ready", or "error" with the stage that failed. The container stays
+// created until the daemon sends "start": init then execs the command,
+// which closes the socket, or answers "started" when it idles without one.
+const (
+	SyncGo      = "go"
+	SyncReady   = "ready"
+	SyncStart   = "start"
+	SyncStarted = "started"
+	SyncError   = "error"
+)
+
+// SyncMessage is one message on the sync socket.
+type SyncMessage struct {
+	Type  string `json:"type"`
+	Stage string `json:"stage,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// SetupError is a failure boxify-init reported while setting up the
+// container.
+type SetupError struct {
+	Stage string
+	Err   string
+}
+
+func (e *SetupError) Error() string {
+	return fmt.Sprintf("container init failed to %s: %s", e.Stage, e.Err)
+}
+
+// Sync is one end of the sync socket.
+type Sync struct {
+	file    *os.File
+	decoder *json.Decoder
+}
+
+// NewSyncPair returns the daemon's end of a new sync socket and the end to
+// pass to boxify-init.
+func NewSyncPair() (*Sync, *os.File, error) {
+	fds, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_STREAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	return newSync(os.NewFile(uintptr(fds[0]), "sync")), os.NewFile(uintptr(fds[1]), "sync-init"), nil
+}
+
+// InheritedSync returns boxify-init's end of the sync socket. It is closed
+// on exec so the daemon sees the socket close once the command runs.
+func InheritedSync() *Sync {
+	unix.CloseOnExec(SyncFd)
+	return newSync(os.NewFile(SyncFd, "sync"))
+}
+
+func newSync(file *os.File) *Sync {
+	return &Sync{file: file, decoder: json.NewDecoder(file)}
+}
+
+// Send writes a message of the given type.
+func (s *Sync) Send(msgType string) error {
+	return json.NewEncoder(s.file).Encode(SyncMessage{Type: msgType})
+}
+
+// SendError reports a failure at stage to the other end.
+func (s *Sync) SendError(stage string, err error) error {
+	return json.NewEncoder(s.file).Encode(SyncMessage{Type: SyncError, Stage: stage, Error: err.Error()})
+}
+
+// Receive reads the next message. It returns io.EOF once the other end
+// closed the socket, and the reported failure as a *SetupError.
+func (s *Sync) Receive() (SyncMessage, error) {
+	var msg SyncMessage
+	if err := s.decoder.Decode(&msg); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		return msg, err
+	}
+	if msg.Type == SyncError {
+		return msg, &SetupError{Stage: msg.Stage, Err: msg.Error}
+	}
+	return msg, nil
+}
+
+// Expect reads the next message and fails unless it has type want.
+func (s *Sync) Expect(want string) error {
+	msg, err := s.Receive()
+	if err != nil {
+		return err
+	}
+	if msg.Type != want {
+		return fmt.Errorf("unexpected %q message on the sync socket, expected %q", msg.Type, want)
+	}
+	return nil
+}
+
+// Close closes this end of the socket.
+func (s *Sync) Close() error {
+	return s.file.Close()
+}
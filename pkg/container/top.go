@@ -0,0 +1,107 @@
+package container
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/urizennnn/boxify/pkg/daemon/types"
+)
+
+// clockTicks is USER_HZ, the unit of the CPU times in /proc/<pid>/stat. It
+// is 100 on every architecture Linux supports.
+const clockTicks = 100
+
+// ReadProcess collects what /proc knows about a host PID. User names are
+// looked up in the container's own /etc/passwd.
+func ReadProcess(containerID string, pid int) (types.Process, error) {
+	p := types.Process{PID: pid}
+	procDir := filepath.Join("/proc", strconv.Itoa(pid))
+
+	stat, err := os.ReadFile(filepath.Join(procDir, "stat"))
+	if err != nil {
+		return p, err
+	}
+	// The command name is in parentheses and may contain spaces, so the
+	// remaining fields start after the last ')'.
+	end := strings.LastIndexByte(string(stat), ')')
+	if end < 0 {
+		return p, fmt.Errorf("malformed stat for pid %d", pid)
+	}
+	comm := string(stat[strings.IndexByte(string(stat), '(')+1 : end])
+	fields := strings.Fields(string(stat[end+1:]))
+	if len(fields) < 22 {
+		return p, fmt.Errorf("malformed stat for pid %d", pid)
+	}
+
+	p.State = fields[0]
+	p.PPID, _ = strconv.Atoi(fields[1])
+	utime, _ := strconv.ParseInt(fields[11], 10, 64)
+	stime, _ := strconv.ParseInt(fields[12], 10, 64)
+	p.CPUTime = time.Duration(utime+stime) * time.Second / clockTicks
+	rssPages, _ := strconv.ParseInt(fields[21], 10, 64)
+	p.RSS = rssPages * int64(os.Getpagesize())
+
+	if err := readProcessStatus(procDir, &p); err != nil {
+		return p, err
+	}
+	p.User = lookupUser(containerID, p.UID)
+
+	cmdline, err := os.ReadFile(filepath.Join(procDir, "cmdline"))
+	if err != nil {
+		return p, err
+	}
+	p.Command = strings.TrimSpace(strings.ReplaceAll(string(cmdline), "\x00", " "))
+	if p.Command == "" {
+		p.Command = "[" + comm + "]"
+	}
+
+	return p, nil
+}
+
+// readProcessStatus fills in the real UID and the PID in the innermost PID
+// namespace, the last entry of NSpid.
+func readProcessStatus(procDir string, p *types.Process) error {
+	file, err := os.Open(filepath.Join(procDir, "status"))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(value)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch key {
+		case "Uid":
+			p.UID, _ = strconv.Atoi(fields[0])
+		case "NSpid":
+			p.ContainerPID, _ = strconv.Atoi(fields[len(fields)-1])
+		}
+	}
+	return scanner.Err()
+}
+
+func lookupUser(containerID string, uid int) string {
+	passwd, err := os.ReadFile(filepath.Join(MergedDir(containerID), "etc", "passwd"))
+	if err == nil {
+		for _, line := range strings.Split(string(passwd), "\n") {
+			fields := strings.Split(line, ":")
+			if len(fields) > 2 && fields[2] == strconv.Itoa(uid) {
+				return fields[0]
+			}
+		}
+	}
+	return strconv.Itoa(uid)
+}
@@ -0,0 +1,101 @@
+package container
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/urizennnn/boxify/pkg/archive"
+)
+
+// User is the identity a container's processes run as.
+type User struct {
+	UID int
+	GID int
+	// Groups are the groups to set with setgroups, GID included. Without
+	// a group in the spec they hold the user's memberships in /etc/group.
+	Groups []int
+	// Home is the user's home directory from /etc/passwd, if it is listed.
+	Home string
+}
+
+// LookupUser resolves spec against the root filesystem of the container.
+func LookupUser(containerID, spec string) (*User, error) {
+	return ResolveUser(MergedDir(containerID), spec)
+}
+
+// ResolveUser resolves spec, "user[:group]" as names or numeric IDs, against
+// the /etc/passwd and /etc/group files under root. An empty spec is root.
+func ResolveUser(root, spec string) (*User, error) {
+	if spec == "" {
+		return &User{}, nil
+	}
+	userPart, groupPart, hasGroup := strings.Cut(spec, ":")
+
+	u := &User{UID: -1}
+	name := ""
+	if id, err := strconv.Atoi(userPart); err == nil {
+		u.UID = id
+	}
+	for _, fields := range readDatabase(root, "/etc/passwd", 7) {
+		if fields[0] == userPart || fields[2] == userPart {
+			u.UID, _ = strconv.Atoi(fields[2])
+			u.GID, _ = strconv.Atoi(fields[3])
+			u.Home = fields[5]
+			name = fields[0]
+			break
+		}
+	}
+	if u.UID < 0 {
+		return nil, fmt.Errorf("no such user %q", userPart)
+	}
+
+	if hasGroup {
+		u.GID = -1
+		if id, err := strconv.Atoi(groupPart); err == nil {
+			u.GID = id
+		}
+		for _, fields := range readDatabase(root, "/etc/group", 4) {
+			if fields[0] == groupPart {
+				u.GID, _ = strconv.Atoi(fields[2])
+				break
+			}
+		}
+		if u.GID < 0 {
+			return nil, fmt.Errorf("no such group %q", groupPart)
+		}
+	} else if name != "" {
+		for _, fields := range readDatabase(root, "/etc/group", 4) {
+			for _, member := range strings.Split(fields[3], ",") {
+				if id, err := strconv.Atoi(fields[2]); err == nil && member == name {
+					u.Groups = append(u.Groups, id)
+				}
+			}
+		}
+	}
+
+	u.Groups = append(u.Groups, u.GID)
+	return u, nil
+}
+
+// readDatabase returns the records of a colon separated file such as
+// /etc/passwd under root that have at least minFields fields.
+func readDatabase(root, name string, minFields int) [][]string {
+	path, err := archive.SecureJoin(root, name)
+	if err != nil {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+
+	var records [][]string
+	for _, line := range strings.Split(string(data), "\n") {
+		if fields := strings.Split(line, ":"); len(fields) >= minFields {
+			records = append(records, fields)
+		}
+	}
+	return records
+}
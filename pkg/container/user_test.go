@@ -0,0 +1,73 @@
+package container
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestResolveUser(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "etc"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	passwd := "root:x:0:0:root:/root:/bin/sh\n" +
+		"app:x:1000:1000:App:/home/app:/bin/sh\n" +
+		"short:x:1001:1001\n"
+	group := "root:x:0:\n" +
+		"app:x:1000:\n" +
+		"audio:x:29:app,other\n" +
+		"video:x:44:other,app\n" +
+		"staff:x:50:other\n"
+	os.WriteFile(filepath.Join(root, "etc/passwd"), []byte(passwd), 0o644)
+	os.WriteFile(filepath.Join(root, "etc/group"), []byte(group), 0o644)
+
+	tests := []struct {
+		spec    string
+		want    *User
+		wantErr string
+	}{
+		{spec: "", want: &User{}},
+		{spec: "root", want: &User{UID: 0, GID: 0, Groups: []int{0}, Home: "/root"}},
+		{spec: "app", want: &User{UID: 1000, GID: 1000, Groups: []int{29, 44, 1000}, Home: "/home/app"}},
+		{spec: "1000", want: &User{UID: 1000, GID: 1000, Groups: []int{29, 44, 1000}, Home: "/home/app"}},
+		{spec: "app:staff", want: &User{UID: 1000, GID: 50, Groups: []int{50}, Home: "/home/app"}},
+		{spec: "app:7", want: &User{UID: 1000, GID: 7, Groups: []int{7}, Home: "/home/app"}},
+		{spec: "4242", want: &User{UID: 4242, GID: 0, Groups: []int{0}}},
+		{spec: "4242:4343", want: &User{UID: 4242, GID: 4343, Groups: []int{4343}}},
+		{spec: "short", wantErr: `no such user "short"`},
+		{spec: "nobody", wantErr: `no such user "nobody"`},
+		{spec: "app:nogroup", wantErr: `no such group "nogroup"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := ResolveUser(root, tt.spec)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ResolveUser(%q) error = %v, want %q", tt.spec, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ResolveUser(%q) error = %v", tt.spec, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("ResolveUser(%q) = %+v, want %+v", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveUserStaysInRoot(t *testing.T) {
+	root := t.TempDir()
+	// /etc is a link to the host's /etc, which must be read as <root>/etc.
+	if err := os.Symlink("/etc", filepath.Join(root, "etc")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ResolveUser(root, "root"); err == nil {
+		t.Fatalf("ResolveUser read the host's /etc/passwd through a symlink")
+	}
+}
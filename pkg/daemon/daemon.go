@@ -1,24 +1,138 @@
 package daemon
 
 import (
-	"errors"
-	"net/http"
+	"log"
+	"sort"
+	"sync"
 
-	"github.com/urizennnn/boxify/pkg/daemon/handlers"
+	"github.com/urizennnn/boxify/pkg/container"
 	"github.com/urizennnn/boxify/pkg/daemon/types"
+	"github.com/urizennnn/boxify/pkg/events"
+	"github.com/urizennnn/boxify/pkg/image"
+	"github.com/urizennnn/boxify/pkg/network"
 )
 
-func (m *Daemon) GetContainer(id string) (*types.Container, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+type Daemon struct {
+	containers map[string]*types.Container
+	names      map[string]string
+	pods       map[string]*types.Pod
+	mu         sync.RWMutex
+	networkMgr *network.NetworkManager
+	events     *events.Bus
+}
+
+// New sets up the daemon with the default network configured by netOpts.
+func New(netOpts network.Options) *Daemon {
+	networkMgr, err := network.NewNetworkManager(netOpts)
+	if err != nil {
+		log.Fatalf("failed to initialize network manager: %v", err)
+	}
+
+	d := &Daemon{
+		containers: make(map[string]*types.Container),
+		names:      make(map[string]string),
+		pods:       make(map[string]*types.Pod),
+		networkMgr: networkMgr,
+		events:     events.NewBus(events.DefaultBufferSize),
+	}
+	if err := image.EnsureBase(container.DefaultImage, container.RootfsDir); err != nil {
+		log.Printf("Warning: couldn't import the default image: %v", err)
+	}
+	d.restoreContainers()
+	d.restorePods()
+
+	return d
+}
+
+// restoreContainers loads persisted container records and marks the ones
+// whose init process is gone as exited.
+func (d *Daemon) restoreContainers() {
+	states, err := container.LoadStates()
+	if err != nil {
+		log.Printf("Warning: couldn't load container state: %v", err)
+		return
+	}
+
+	for _, c := range states {
+		if c.Active() && !container.ProcessAlive(c.PID) {
+			log.Printf("Container %s (PID %d) is no longer running", c.ID, c.PID)
+			c.Status = "exited"
+			if err := container.SaveState(c); err != nil {
+				log.Printf("Error saving container state: %v", err)
+			}
+		}
+		d.containers[c.ID] = c
+		if c.Name != "" {
+			d.names[c.Name] = c.ID
+		}
+	}
+	log.Printf("Restored %d containers from state", len(states))
+}
+
+func (d *Daemon) AddContainer(container *types.Container) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.containers[container.ID] = container
+}
+
+// RemoveContainer forgets the container and frees its name.
+func (d *Daemon) RemoveContainer(id string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 
-	container, exists := m.containers[id]
+	if c, exists := d.containers[id]; exists {
+		delete(d.names, c.Name)
+		delete(d.containers, id)
+	}
+}
+
+// ListContainers returns a snapshot of all known containers, newest first.
+func (d *Daemon) ListContainers() []*types.Container {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	list := make([]*types.Container, 0, len(d.containers))
+	for _, c := range d.containers {
+		snapshot := *c
+		list = append(list, &snapshot)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt.After(list[j].CreatedAt)
+	})
+	return list
+}
+
+func (d *Daemon) SetContainerStatus(id, status string) {
+	if err := d.UpdateContainer(id, func(c *types.Container) {
+		c.Status = status
+	}); err != nil {
+		log.Printf("Error updating container %s: %v", id, err)
+	}
+}
+
+// UpdateContainer applies update to the container under the daemon lock and
+// persists the result.
+func (d *Daemon) UpdateContainer(id string, update func(c *types.Container)) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	c, exists := d.containers[id]
 	if !exists {
-		return nil, errors.New("container not found")
+		return types.ErrContainerNotFound
 	}
-	return container, nil
+	update(c)
+
+	if err := container.SaveState(c); err != nil {
+		log.Printf("Error saving container state: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (d *Daemon) NetworkManager() *network.NetworkManager {
+	return d.networkMgr
 }
 
-func (d *Daemon) HandleCreateRequest(w http.ResponseWriter, r *http.Request) {
-	handlers.HandleCreate(d, w, r)
+func (d *Daemon) Events() *events.Bus {
+	return d.events
 }
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/urizennnn/boxify/pkg/archive"
+	"github.com/urizennnn/boxify/pkg/container"
+)
+
+// HandleArchiveGet streams a tar archive of a path inside the container. A
+// HEAD request only returns the path's stat header.
+func HandleArchiveGet(d DaemonInterface, w http.ResponseWriter, r *http.Request) {
+	root, target, ok := resolveArchivePath(d, w, r)
+	if !ok {
+		return
+	}
+
+	stat, err := archive.Stat(target)
+	if err != nil {
+		writeArchiveError(w, err)
+		return
+	}
+	if err := setPathStatHeader(w, stat); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if r.Method == http.MethodHead {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-tar")
+	if err := archive.Tar(w, target, stat.Name); err != nil {
+		// The status line is already sent, all we can do is log.
+		log.Printf("Error archiving %s from %s: %v", target, root, err)
+	}
+}
+
+// HandleExport streams the container's whole filesystem, the merged view of
+// its image layers and its own changes, as a flat tar archive. Volumes are
+// bind-mounted into that view and left out, like commit does.
+func HandleExport(d DaemonInterface, w http.ResponseWriter, r *http.Request) {
+	c, err := d.ResolveContainer(r.PathValue("id"))
+	if err != nil {
+		writeContainerError(w, err)
+		return
+	}
+
+	root, err := container.RootFS(c.ID, imageLayers(c))
+	if err != nil {
+		http.Error(w, "Failed to mount container filesystem: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	exclude := []string{container.OldRootDir}
+	for _, m := range c.Mounts {
+		exclude = append(exclude, m.Destination)
+	}
+
+	log.Printf("Exporting container %s", c.ID)
+	w.Header().Set("Content-Type", "application/x-tar")
+	if err := archive.TarFilesystem(w, root, exclude); err != nil {
+		// The status line is already sent, all we can do is log.
+		log.Printf("Error exporting container %s: %v", c.ID, err)
+		return
+	}
+	emitContainerEvent(d, c, "export", nil)
+}
+
+// HandleArchivePut extracts a tar archive into a directory of the container.
+func HandleArchivePut(d DaemonInterface, w http.ResponseWriter, r *http.Request) {
+	root, target, ok := resolveArchivePath(d, w, r)
+	if !ok {
+		return
+	}
+
+	// The destination directory may itself be a symlink, follow it within
+	// the container.
+	dir, err := archive.SecureJoin(root, strings.TrimPrefix(target, root))
+	if err != nil {
+		writeArchiveError(w, err)
+		return
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		writeArchiveError(w, err)
+		return
+	}
+	if !info.IsDir() {
+		http.Error(w, "Destination "+r.URL.Query().Get("path")+" is not a directory", http.StatusBadRequest)
+		return
+	}
+
+	rel, err := filepath.Rel(root, dir)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := archive.Untar(r.Body, root, rel); err != nil {
+		log.Printf("Error extracting archive into %s: %v", dir, err)
+		http.Error(w, "Failed to extract archive: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// resolveArchivePath maps the "path" query parameter onto the host. Every
+// directory on the way is resolved within the container's root; the final
+// component is not followed so a symlink can be copied as a link.
+func resolveArchivePath(d DaemonInterface, w http.ResponseWriter, r *http.Request) (string, string, bool) {
+	c, err := d.ResolveContainer(r.PathValue("id"))
+	if err != nil {
+		writeContainerError(w, err)
+		return "", "", false
+	}
+
+	requested := r.URL.Query().Get("path")
+	if requested == "" {
+		http.Error(w, "path is required", http.StatusBadRequest)
+		return "", "", false
+	}
+
+	root, err := container.RootFS(c.ID, imageLayers(c))
+	if err != nil {
+		http.Error(w, "Failed to mount container filesystem: "+err.Error(), http.StatusInternalServerError)
+		return "", "", false
+	}
+
+	cleaned := path.Clean("/" + requested)
+	parent, err := archive.SecureJoin(root, path.Dir(cleaned))
+	if err != nil {
+		writeArchiveError(w, err)
+		return "", "", false
+	}
+	return root, filepath.Join(parent, path.Base(cleaned)), true
+}
+
+func setPathStatHeader(w http.ResponseWriter, stat archive.PathStat) error {
+	data, err := json.Marshal(stat)
+	if err != nil {
+		return err
+	}
+	w.Header().Set(archive.PathStatHeader, base64.StdEncoding.EncodeToString(data))
+	return nil
+}
+
+func writeArchiveError(w http.ResponseWriter, err error) {
+	if os.IsNotExist(err) {
+		http.Error(w, "No such file or directory in container", http.StatusNotFound)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
@@ -0,0 +1,557 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/urizennnn/boxify/pkg/archive"
+	"github.com/urizennnn/boxify/pkg/build"
+	"github.com/urizennnn/boxify/pkg/container"
+	"github.com/urizennnn/boxify/pkg/daemon/types"
+	"github.com/urizennnn/boxify/pkg/image"
+)
+
+// BuildDir holds the extracted context and scratch files of running builds.
+const BuildDir = "/var/lib/boxify/builds"
+
+// buildMessage is one line of the JSON stream a build responds with. The
+// last line carries either the image ID or an error.
+type buildMessage struct {
+	Stream string `json:"stream,omitempty"`
+	Error  string `json:"error,omitempty"`
+	ID     string `json:"id,omitempty"`
+}
+
+// buildOutput streams build progress to the client as it happens.
+type buildOutput struct {
+	encoder *json.Encoder
+	flusher http.Flusher
+}
+
+func (o *buildOutput) send(message buildMessage) {
+	o.encoder.Encode(message)
+	if o.flusher != nil {
+		o.flusher.Flush()
+	}
+}
+
+func (o *buildOutput) Printf(format string, args ...interface{}) {
+	o.send(buildMessage{Stream: fmt.Sprintf(format, args...)})
+}
+
+// Write passes the output of RUN steps through.
+func (o *buildOutput) Write(p []byte) (int, error) {
+	o.send(buildMessage{Stream: string(p)})
+	return len(p), nil
+}
+
+// builder runs the instructions of a Boxfile. Every instruction produces an
+// image on top of the previous one, cached by the instruction and its
+// inputs.
+type builder struct {
+	d          DaemonInterface
+	out        *buildOutput
+	contextDir string
+	scratchDir string
+	noCache    bool
+
+	parent   *image.Image
+	parentID string
+}
+
+// HandleBuild builds an image from the tar build context in the request
+// body. The Boxfile is read from the context, at "Boxfile" unless the
+// "boxfile" parameter names another path.
+func HandleBuild(d DaemonInterface, w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	ref := query.Get("t")
+	if ref != "" {
+		if _, err := image.NormalizeReference(ref); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	boxfile := query.Get("boxfile")
+	if boxfile == "" {
+		boxfile = build.DefaultBoxfile
+	}
+	noCache, _ := strconv.ParseBool(query.Get("nocache"))
+
+	if err := os.MkdirAll(BuildDir, 0o755); err != nil {
+		http.Error(w, "Failed to create build directory: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	buildDir, err := os.MkdirTemp(BuildDir, "build-")
+	if err != nil {
+		http.Error(w, "Failed to create build directory: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer os.RemoveAll(buildDir)
+
+	contextDir := filepath.Join(buildDir, "context")
+	if err := os.Mkdir(contextDir, 0o755); err != nil {
+		http.Error(w, "Failed to create build directory: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := archive.Untar(r.Body, contextDir, "/"); err != nil {
+		http.Error(w, "Failed to read build context: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	boxfilePath, err := archive.SecureJoin(contextDir, boxfile)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	file, err := os.Open(boxfilePath)
+	if err != nil {
+		http.Error(w, "Cannot locate "+boxfile+" in the build context", http.StatusBadRequest)
+		return
+	}
+	instructions, err := build.Parse(file)
+	file.Close()
+	if err != nil {
+		http.Error(w, boxfile+": "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	out := &buildOutput{encoder: json.NewEncoder(w), flusher: flusher}
+
+	b := &builder{
+		d:          d,
+		out:        out,
+		contextDir: contextDir,
+		scratchDir: buildDir,
+		noCache:    noCache,
+	}
+	for i, instruction := range instructions {
+		out.Printf("Step %d/%d : %s\n", i+1, len(instructions), instruction)
+		if err := b.step(instruction); err != nil {
+			log.Printf("Build failed at %s line %d: %v", boxfile, instruction.Line, err)
+			out.send(buildMessage{Error: err.Error()})
+			return
+		}
+		out.Printf(" ---> %s\n", image.ShortID(b.parentID))
+	}
+
+	if ref != "" {
+		if err := image.Tag(b.parentID, ref); err != nil {
+			out.send(buildMessage{Error: err.Error()})
+			return
+		}
+	}
+	out.Printf("Successfully built %s\n", image.ShortID(b.parentID))
+	if ref != "" {
+		normalized, _ := image.NormalizeReference(ref)
+		out.Printf("Successfully tagged %s\n", normalized)
+	}
+	out.send(buildMessage{ID: b.parentID})
+}
+
+func (b *builder) step(instruction build.Instruction) error {
+	switch instruction.Command {
+	case "FROM":
+		return b.from(instruction.Value)
+
+	case "ENV", "LABEL", "WORKDIR", "USER", "EXPOSE":
+		change := instruction.Command + " " + build.Expand(instruction.Value, b.parent.Config.Env)
+		return b.commit(instruction, change, func(cfg *image.Config) (string, error) {
+			return "", image.ApplyChanges(cfg, []string{change})
+		})
+
+	case "ENTRYPOINT", "CMD":
+		return b.commit(instruction, "", func(cfg *image.Config) (string, error) {
+			return "", image.ApplyChanges(cfg, []string{instruction.String()})
+		})
+
+	case "RUN":
+		return b.commit(instruction, "", func(cfg *image.Config) (string, error) {
+			return b.run(image.ParseCommand(instruction.Value), cfg)
+		})
+
+	case "COPY", "ADD":
+		sources, dest, err := instruction.Sources()
+		if err != nil {
+			return err
+		}
+		paths, err := b.contextPaths(sources)
+		if err != nil {
+			return err
+		}
+		checksum, err := build.Checksum(paths)
+		if err != nil {
+			return err
+		}
+		dest = build.Expand(dest, b.parent.Config.Env)
+		return b.commit(instruction, checksum, func(cfg *image.Config) (string, error) {
+			return b.copy(paths, dest, len(sources) > 1, instruction.Command == "ADD", cfg)
+		})
+	}
+	return fmt.Errorf("unsupported instruction %s", instruction.Command)
+}
+
+// from sets the base image. "scratch" is an empty image without layers.
+func (b *builder) from(ref string) error {
+	if ref == "scratch" {
+		b.parent = &image.Image{
+			Architecture: runtime.GOARCH,
+			OS:           "linux",
+			RootFS:       image.RootFS{Type: "layers"},
+		}
+		b.parentID = ""
+		return nil
+	}
+
+	img, id, err := image.Get(ref)
+	if err != nil {
+		return err
+	}
+	b.parent, b.parentID = img, id
+	return nil
+}
+
+// commit turns the result of a step into a new image on top of the current
+// one. produce changes the config and returns the diff ID of the step's
+// layer, if it has one. A cached image for the same parent, instruction and
+// inputs is reused unless caching is disabled.
+func (b *builder) commit(instruction build.Instruction, inputs string, produce func(cfg *image.Config) (string, error)) error {
+	key := image.CacheKey(b.parentID, instruction.String(), inputs)
+	if !b.noCache {
+		if id, ok := image.CacheLookup(key); ok {
+			if img, _, err := image.Get(id); err == nil {
+				b.out.Printf(" ---> Using cache\n")
+				b.parent, b.parentID = img, id
+				return nil
+			}
+		}
+	}
+
+	cfg := b.parent.Config
+	diffID, err := produce(&cfg)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	diffIDs := append([]string{}, b.parent.RootFS.DiffIDs...)
+	if diffID != "" {
+		diffIDs = append(diffIDs, diffID)
+	}
+	child := &image.Image{
+		Created:      now,
+		Architecture: b.parent.Architecture,
+		OS:           b.parent.OS,
+		Config:       cfg,
+		RootFS:       image.RootFS{Type: "layers", DiffIDs: diffIDs},
+		History: append(append([]image.History{}, b.parent.History...), image.History{
+			Created:    now,
+			CreatedBy:  instruction.String(),
+			EmptyLayer: diffID == "",
+		}),
+	}
+
+	id, err := image.Create(child, b.parentID, "")
+	if err != nil {
+		return err
+	}
+	if err := image.CacheStore(key, id); err != nil {
+		log.Printf("Error storing build cache entry: %v", err)
+	}
+	b.parent, b.parentID = child, id
+	return nil
+}
+
+// run executes a RUN step in a throwaway container of the current image and
+// returns its upper layer as the step's layer. The container idles while the
+// command runs in its namespaces, so the command cannot finish before the
+// container's network and cgroup are set up.
+func (b *builder) run(command []string, cfg *image.Config) (string, error) {
+	if len(b.parent.RootFS.DiffIDs) == 0 {
+		return "", errors.New("RUN needs a base image with a filesystem, not scratch")
+	}
+
+	containerID := uuid.New().String()
+	name, err := reserveContainerName(b.d, "", containerID)
+	if err != nil {
+		return "", err
+	}
+	networkInfo, err := allocateNetwork(b.d, containerID, "")
+	if err != nil {
+		b.d.ReleaseName(name)
+		return "", err
+	}
+
+	c := &types.Container{
+		ID:          containerID,
+		Name:        name,
+		Image:       b.parentID,
+		ImageID:     b.parentID,
+		Env:         cfg.Env,
+		WorkingDir:  cfg.WorkingDir,
+		User:        cfg.User,
+		Labels:      map[string]string{"boxify.build": "true"},
+		NetworkInfo: networkInfo,
+		CreatedAt:   time.Now(),
+		Status:      "created",
+	}
+	b.d.AddContainer(c)
+	if err := container.SaveState(c); err != nil {
+		log.Printf("Error saving container info: %v\n", err)
+	}
+	emitContainerEvent(b.d, c, "create", nil)
+	defer cleanupContainer(b.d, containerID, name)
+
+	started, err := startContainer(b.d, containerID)
+	if err != nil {
+		return "", err
+	}
+
+	b.out.Printf(" ---> Running in %s\n", containerID[:12])
+	code, err := b.execStep(started, command, cfg)
+	if err != nil {
+		return "", err
+	}
+	if code != 0 {
+		return "", fmt.Errorf("the command '%s' returned a non-zero code: %d", strings.Join(command, " "), code)
+	}
+
+	// Stop whatever the command left running before its files are read.
+	if err := stopContainer(b.d, started, 0); err != nil {
+		return "", err
+	}
+	return addUpperLayer(containerID, nil)
+}
+
+// execStep runs command inside the container with the image's environment
+// and user, streaming its output to the client.
+func (b *builder) execStep(c *types.Container, command []string, cfg *image.Config) (int, error) {
+	user, err := container.LookupUser(c.ID, cfg.User)
+	if err != nil {
+		return 0, err
+	}
+
+	step := nsenterCommand(context.Background(), c.PID, user, command)
+	step.Env = execEnv(cfg.Env, user, false)
+	step.Stdout = b.out
+	step.Stderr = b.out
+	step.SysProcAttr.Setpgid = true
+
+	if err := step.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return exitCode(err), nil
+		}
+		return 0, err
+	}
+	return 0, nil
+}
+
+// copy builds the layer of a COPY or ADD step from the context paths. The
+// files are assembled in a staging directory laid out like the image's root.
+func (b *builder) copy(paths []string, dest string, multiple, extract bool, cfg *image.Config) (string, error) {
+	intoDir := multiple || strings.HasSuffix(dest, "/")
+	if !path.IsAbs(dest) {
+		dest = path.Join("/", cfg.WorkingDir, dest)
+	}
+	dest = path.Clean(dest)
+
+	staging, err := os.MkdirTemp(b.scratchDir, "step-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(staging)
+
+	lowers := image.LayerDirs(b.parent)
+	for _, src := range paths {
+		info, err := os.Lstat(src)
+		if err != nil {
+			return "", err
+		}
+
+		targetDir, name := dest, path.Base(src)
+		switch {
+		case info.IsDir():
+			name = "."
+		case extract && isArchive(src):
+			name = ""
+		case !intoDir && !isImageDir(lowers, dest):
+			targetDir, name = path.Dir(dest), path.Base(dest)
+		}
+
+		if err := mkdirLike(staging, targetDir, lowers); err != nil {
+			return "", err
+		}
+		if name == "" {
+			err = extractArchive(src, staging, targetDir)
+		} else {
+			err = copyInto(src, staging, targetDir, name)
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return addLayer(func(w io.Writer) error {
+		return archive.Tar(w, staging, ".")
+	})
+}
+
+// contextPaths resolves COPY and ADD sources, which may be glob patterns,
+// inside the build context.
+func (b *builder) contextPaths(sources []string) ([]string, error) {
+	var paths []string
+	for _, src := range sources {
+		if strings.Contains(src, "://") {
+			return nil, fmt.Errorf("remote sources are not supported: %s", src)
+		}
+
+		resolved, err := archive.SecureJoin(b.contextDir, src)
+		if err != nil {
+			return nil, err
+		}
+		matches, err := filepath.Glob(resolved)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("%s: no such file or directory in the build context", src)
+		}
+		for _, match := range matches {
+			if rel, err := filepath.Rel(b.contextDir, match); err != nil || strings.HasPrefix(rel, "..") {
+				return nil, fmt.Errorf("%s is outside of the build context", src)
+			}
+		}
+		paths = append(paths, matches...)
+	}
+	return paths, nil
+}
+
+// mkdirLike creates dir below root, giving directories that exist in the
+// image the image's permissions and ownership so the layer does not change
+// them, for example the sticky bit of /tmp.
+func mkdirLike(root, dir string, lowers []string) error {
+	current := ""
+	for _, part := range strings.Split(strings.Trim(dir, "/"), "/") {
+		if part == "" {
+			continue
+		}
+		current = path.Join(current, part)
+		target := filepath.Join(root, current)
+		if _, err := os.Lstat(target); err == nil {
+			continue
+		}
+
+		mode, uid, gid := os.FileMode(0o755), 0, 0
+		for _, lower := range lowers {
+			if info, err := os.Stat(filepath.Join(lower, current)); err == nil && info.IsDir() {
+				mode = info.Mode() & (os.ModePerm | os.ModeSticky | os.ModeSetgid)
+				if st, ok := info.Sys().(*syscall.Stat_t); ok {
+					uid, gid = int(st.Uid), int(st.Gid)
+				}
+				break
+			}
+		}
+		if err := os.Mkdir(target, mode); err != nil {
+			return err
+		}
+		if err := os.Chmod(target, mode); err != nil {
+			return err
+		}
+		if err := os.Lchown(target, uid, gid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isImageDir reports whether dir is a directory in the topmost layer that
+// has it.
+func isImageDir(lowers []string, dir string) bool {
+	for _, lower := range lowers {
+		if info, err := os.Lstat(filepath.Join(lower, dir)); err == nil {
+			return info.IsDir()
+		}
+	}
+	return false
+}
+
+// copyInto copies src to dir/name below root. A directory's contents are
+// copied when name is ".".
+func copyInto(src, root, dir, name string) error {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(archive.Tar(pw, src, name))
+	}()
+	defer pr.Close()
+	return archive.Untar(pr, root, dir)
+}
+
+// isArchive reports whether path is a tar archive, optionally gzip
+// compressed, which ADD extracts instead of copying.
+func isArchive(path string) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	r, err := archive.Decompress(file)
+	if err != nil {
+		return false
+	}
+	header := make([]byte, 512)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return false
+	}
+	return string(header[257:262]) == "ustar"
+}
+
+func extractArchive(src, root, dir string) error {
+	file, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	r, err := archive.Decompress(file)
+	if err != nil {
+		return err
+	}
+	return archive.Untar(r, root, dir)
+}
+
+// addUpperLayer stores a container's upper directory as a layer, leaving out
+// exclude and boxify's own mount points.
+func addUpperLayer(containerID string, exclude []string) (string, error) {
+	exclude = append(exclude, container.OldRootDir)
+	return addLayer(func(w io.Writer) error {
+		return archive.TarLayer(w, container.UpperDir(containerID), exclude)
+	})
+}
+
+func addLayer(write func(w io.Writer) error) (string, error) {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(write(pw))
+	}()
+	defer pr.Close()
+	return image.AddLayer(pr)
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/urizennnn/boxify/pkg/container"
+)
+
+// HandleChanges reports what a container added, changed or deleted on top of
+// its image, leaving out volume mount points.
+func HandleChanges(d DaemonInterface, w http.ResponseWriter, r *http.Request) {
+	c, err := d.ResolveContainer(r.PathValue("id"))
+	if err != nil {
+		writeContainerError(w, err)
+		return
+	}
+
+	var mountPoints []string
+	for _, m := range c.Mounts {
+		mountPoints = append(mountPoints, m.Destination)
+	}
+
+	changes, err := container.Changes(c.ID, imageLayers(c), mountPoints)
+	if err != nil {
+		log.Printf("Error computing changes of container %s: %v", c.ID, err)
+		http.Error(w, "Failed to compute changes: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, changes)
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/urizennnn/boxify/pkg/cgroup"
+	"github.com/urizennnn/boxify/pkg/container"
+	"github.com/urizennnn/boxify/pkg/daemon/requests"
+	"github.com/urizennnn/boxify/pkg/image"
+)
+
+// HandleCommit snapshots a container's upper layer into a new image on top
+// of the container's image. The new config starts from the container's
+// command, environment, working directory and user with the requested
+// changes applied.
+func HandleCommit(d DaemonInterface, w http.ResponseWriter, r *http.Request) {
+	c, err := d.ResolveContainer(r.PathValue("id"))
+	if err != nil {
+		writeContainerError(w, err)
+		return
+	}
+
+	var request requests.CommitRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if request.Reference != "" {
+		if _, err := image.NormalizeReference(request.Reference); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	parent, parentID, err := containerImage(c)
+	if err != nil {
+		writeImageError(w, err)
+		return
+	}
+
+	cfg := parent.Config
+	cfg.Env = c.Env
+	cfg.WorkingDir = c.WorkingDir
+	cfg.User = c.User
+	if strings.Join(c.Command, "\x00") != strings.Join(parent.Command(), "\x00") {
+		cfg.Entrypoint = nil
+		cfg.Cmd = c.Command
+	}
+	if err := image.ApplyChanges(&cfg, request.Changes); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	upperDir := container.UpperDir(c.ID)
+	if _, err := os.Stat(upperDir); err != nil {
+		http.Error(w, "Container "+c.Name+" has no filesystem to commit", http.StatusConflict)
+		return
+	}
+
+	if request.Pause && c.Status == "running" && container.ProcessAlive(c.PID) {
+		log.Printf("Pausing container %s for commit", c.ID)
+		if err := cgroup.Freeze(c.ID); err != nil {
+			cgroup.Thaw(c.ID)
+			http.Error(w, "Failed to pause container: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer func() {
+			if err := cgroup.Thaw(c.ID); err != nil {
+				log.Printf("Error thawing container %s after commit: %v", c.ID, err)
+			}
+		}()
+	}
+
+	var exclude []string
+	for _, m := range c.Mounts {
+		exclude = append(exclude, m.Destination)
+	}
+
+	log.Printf("Committing container %s", c.ID)
+	diffID, err := addUpperLayer(c.ID, exclude)
+	if err != nil {
+		log.Printf("Error committing container %s: %v", c.ID, err)
+		http.Error(w, "Failed to commit container: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now().UTC()
+	img := &image.Image{
+		Created:      now,
+		Author:       request.Author,
+		Architecture: parent.Architecture,
+		OS:           parent.OS,
+		Config:       cfg,
+		RootFS: image.RootFS{
+			Type:    "layers",
+			DiffIDs: append(append([]string{}, parent.RootFS.DiffIDs...), diffID),
+		},
+		History: append(append([]image.History{}, parent.History...), image.History{
+			Created:   now,
+			CreatedBy: strings.Join(c.Command, " "),
+			Author:    request.Author,
+			Comment:   request.Comment,
+		}),
+	}
+
+	id, err := image.Create(img, parentID, request.Reference)
+	if err != nil {
+		log.Printf("Error creating image from container %s: %v", c.ID, err)
+		http.Error(w, "Failed to create image: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	emitContainerEvent(d, c, "commit", map[string]string{
+		"imageID":     id,
+		"parentImage": parentID,
+		"reference":   request.Reference,
+	})
+	writeJSON(w, http.StatusCreated, map[string]string{"id": id})
+}
@@ -3,10 +3,14 @@ package handlers
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
 	"os/exec"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -16,14 +20,31 @@ import (
 	"github.com/urizennnn/boxify/pkg/container"
 	"github.com/urizennnn/boxify/pkg/daemon/requests"
 	"github.com/urizennnn/boxify/pkg/daemon/types"
+	"github.com/urizennnn/boxify/pkg/events"
+	"github.com/urizennnn/boxify/pkg/image"
 	"github.com/urizennnn/boxify/pkg/network"
-	"gopkg.in/yaml.v3"
+	"github.com/urizennnn/boxify/pkg/volume"
 )
 
 type DaemonInterface interface {
 	AddContainer(container *types.Container)
 	GetContainer(id string) (*types.Container, error)
+	ResolveContainer(ref string) (*types.Container, error)
+	ListContainers() []*types.Container
+	SetContainerStatus(id, status string)
+	UpdateContainer(id string, update func(c *types.Container)) error
+	ReserveName(name, id string) error
+	ReleaseName(name string)
+	RenameContainer(id, newName string) error
+	RemoveContainer(id string)
+	AddPod(p *types.Pod) error
+	GetPod(id string) (*types.Pod, error)
+	ResolvePod(ref string) (*types.Pod, error)
+	ListPods() []*types.Pod
+	UpdatePod(id string, update func(p *types.Pod)) error
+	RemovePod(id string)
 	NetworkManager() *network.NetworkManager
+	Events() *events.Bus
 }
 
 func HandleCreate(d DaemonInterface, w http.ResponseWriter, r *http.Request) {
@@ -33,26 +54,149 @@ func HandleCreate(d DaemonInterface, w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request payload", http.StatusBadRequest)
 		return
 	}
-	memory := request.MemoryLimit
-	cpu := request.CpuLimit
+
+	restartPolicy, err := parseRestartPolicy(request.RestartPolicy)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	healthCheck, err := parseHealthCheck(request.HealthCheck)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// The request and the stored spec share their fields, the cgroup values
+	// are derived again on every start.
+	resources := types.Resources(request.Resources)
+	if _, err := cgroup.ParseResources(resources); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	imageRef := request.Image
+	if imageRef == "" {
+		imageRef = container.DefaultImage
+	}
+	img, imageID, err := image.Get(imageRef)
+	if err != nil {
+		writeImageError(w, err)
+		return
+	}
+	command := request.Command
+	if len(command) == 0 {
+		command = img.Command()
+	}
+
+	mounts, err := prepareVolumes(request.Volumes)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var pod *types.Pod
+	if request.Pod != "" {
+		if pod, err = d.ResolvePod(request.Pod); err != nil {
+			writePodError(w, err)
+			return
+		}
+	}
+
+	userNetwork, err := requestedNetwork(&request)
+	if err != nil {
+		writeNetworkError(w, err)
+		return
+	}
+	namespaces, err := parseNamespaceModes(d, request)
+	if err != nil {
+		writeContainerError(w, err)
+		return
+	}
 
 	containerID := uuid.New().String()
-	networkMgr := d.NetworkManager()
-	hostVeth, containerVeth, err := networkMgr.VethManager.CreateVethPairAndAttachToHostBridge(containerID, networkMgr.BridgeManager)
-	log.Printf("Created veth pair: host=%s, container=%s\n", hostVeth, containerVeth)
+	name, err := reserveContainerName(d, request.Name, containerID)
 	if err != nil {
-		log.Printf("Error creating veth pair: %v\n", err)
+		writeContainerError(w, err)
 		return
 	}
 
-	pid, cmd, err := parent(d, containerID, memory, cpu, containerVeth, hostVeth, networkMgr)
+	// Pod members and containers sharing another container's network use
+	// its address, they have no interface of their own. Containers in the
+	// host's network or without networking have no address at all.
+	var networkInfo *types.NetworkInfo
+	switch {
+	case request.IPv6Address != "" && (pod != nil || namespaces.Network != namespacePrivate):
+		d.ReleaseName(name)
+		http.Error(w, "an IPv6 address needs a network namespace of the container's own", http.StatusBadRequest)
+		return
+	case pod != nil:
+		networkInfo = sharedNetworkInfo(pod.NetworkInfo)
+	case namespaces.Network == namespaceHost, namespaces.Network == networkNone:
+	case strings.HasPrefix(namespaces.Network, namespaceContainerPrefix):
+		target, err := namespaceContainer(d, namespaces.Network)
+		if err != nil {
+			d.ReleaseName(name)
+			writeContainerError(w, err)
+			return
+		}
+		networkInfo = sharedNetworkInfo(target.NetworkInfo)
+	case userNetwork != nil:
+		if networkInfo, err = allocateNetworkIP(userNetwork, containerID, request.IPv6Address); err != nil {
+			d.ReleaseName(name)
+			writeAllocationError(w, err)
+			return
+		}
+	default:
+		if networkInfo, err = allocateNetwork(d, containerID, request.IPv6Address); err != nil {
+			d.ReleaseName(name)
+			writeAllocationError(w, err)
+			return
+		}
+	}
+
+	containerInfo := &types.Container{
+		ID:            containerID,
+		Name:          name,
+		Image:         imageRef,
+		ImageID:       imageID,
+		Command:       command,
+		Env:           img.Config.Env,
+		WorkingDir:    img.Config.WorkingDir,
+		User:          img.Config.User,
+		Labels:        request.Labels,
+		Annotations:   request.Annotations,
+		Mounts:        mounts,
+		Resources:     resources,
+		RestartPolicy: restartPolicy,
+		HealthCheck:   healthCheck,
+		NetworkInfo:   networkInfo,
+		Namespaces:    namespaces,
+		CreatedAt:     time.Now(),
+		Status:        "created",
+	}
+	if pod != nil {
+		containerInfo.Pod = pod.ID
+	}
+	d.AddContainer(containerInfo)
+
+	if err = container.SaveState(containerInfo); err != nil {
+		log.Printf("Error saving container info: %v\n", err)
+	}
+	emitContainerEvent(d, containerInfo, "create", nil)
+
+	created, err := createProcess(d, containerID)
 	if err != nil {
-		http.Error(w, "Failed to create container", http.StatusInternalServerError)
+		cleanupContainer(d, containerID, name)
+		http.Error(w, "Failed to create container: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 	response := map[string]interface{}{
-		"pid": pid,
-		"cmd": cmd.String(),
+		"id":      containerID,
+		"name":    name,
+		"pid":     created.PID,
+		"cmd":     created.Cmd.String(),
+		"command": created.Command,
 	}
 	jsonBytes, err := json.Marshal(response)
 	if err != nil {
@@ -67,106 +211,408 @@ func HandleCreate(d DaemonInterface, w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func parent(d DaemonInterface, containerID, memory, cpu string, containerVeth, hostVeth string, networkMgr *network.NetworkManager) (int, *exec.Cmd, error) {
-	gateway := networkMgr.IpManager.GetGateway()
-	bridgeCIDR := networkMgr.IpManager.BridgeCIDR
-	nextIP := networkMgr.IpManager.GetNextIP() + bridgeCIDR
-	err, mergedDir := container.InitContainer(containerID)
+// reserveContainerName claims the requested name, or a generated one when the
+// request does not carry a name.
+func reserveContainerName(d DaemonInterface, requested, containerID string) (string, error) {
+	if requested != "" {
+		if err := container.ValidateName(requested); err != nil {
+			return "", err
+		}
+		if err := d.ReserveName(requested, containerID); err != nil {
+			return "", err
+		}
+		return requested, nil
+	}
+
+	for attempt := 0; attempt < 10; attempt++ {
+		name := container.GenerateName()
+		if d.ReserveName(name, containerID) == nil {
+			return name, nil
+		}
+	}
+
+	name := container.GenerateName() + "_" + containerID[:8]
+	return name, d.ReserveName(name, containerID)
+}
+
+// allocateNetwork reserves an address on the default bridge for a new
+// container and, when the bridge has IPv6, an IPv6 address: ip6 if it is
+// set, the next free one otherwise.
+func allocateNetwork(d DaemonInterface, containerID, ip6 string) (*types.NetworkInfo, error) {
+	networkMgr := d.NetworkManager()
+	ipAddr, err := networkMgr.IpManager.AllocateIP(containerID)
+	if err != nil {
+		log.Printf("Error allocating IP: %v\n", err)
+		return nil, err
+	}
+	ipv6Addr, err := networkMgr.IpManager.AllocateIP6(containerID, ip6)
+	if err != nil {
+		log.Printf("Error allocating IPv6 address: %v\n", err)
+		if err := networkMgr.IpManager.ReleaseIP(containerID); err != nil {
+			log.Printf("Error releasing IP: %v\n", err)
+		}
+		return nil, err
+	}
+
+	info := &types.NetworkInfo{
+		IP:      ipAddr + networkMgr.IpManager.BridgeCIDR,
+		Gateway: networkMgr.IpManager.GetGateway(),
+		Bridge:  networkMgr.BridgeManager.ReturnBridgeDetails().DefaultBridge,
+	}
+	if ipv6Addr != "" {
+		info.IPv6 = ipv6Addr
+		info.IPv6Gateway = networkMgr.IpManager.Gateway6.String()
+	}
+	return info, nil
+}
+
+// allocateNetworkIP reserves an address on a macvlan or ipvlan network for a
+// new container, with an IPv6 address on dual-stack networks.
+func allocateNetworkIP(n *config.NetworkStorage, containerID, ip6 string) (*types.NetworkInfo, error) {
+	ip, ipv6Addr, err := network.AllocateNetworkIP(n.Name, containerID, ip6)
 	if err != nil {
-		log.Fatalf("Error: failed in creating overlay FS %v\n", err)
+		log.Printf("Error allocating IP on %s: %v\n", n.Name, err)
+		return nil, err
+	}
+	info := &types.NetworkInfo{
+		IP:      ip,
+		Gateway: n.Ipam.Gateway,
+		Network: n.Name,
+		Driver:  n.Driver,
 	}
+	if ipv6Addr != "" {
+		info.IPv6 = ipv6Addr
+		info.IPv6Gateway = n.Ipam.Gateway6
+	}
+	return info, nil
+}
 
-	cmd := exec.Command("/usr/local/bin/boxify-init", containerID, memory, cpu, mergedDir)
-	cmd.SysProcAttr = &syscall.SysProcAttr{
-		Cloneflags: syscall.CLONE_NEWUTS |
-			syscall.CLONE_NEWPID |
-			syscall.CLONE_NEWIPC |
-			syscall.CLONE_NEWNET |
-			syscall.CLONE_NEWNS,
+// writeAllocationError reports a failed address allocation, a conflict
+// when the requested address is taken.
+func writeAllocationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, network.ErrInvalidAddress):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, network.ErrAddressInUse):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, "Failed to allocate IP address: "+err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// sharedNetworkInfo is the network of a container that uses the address of
+// info's owner, without the owner's veth pair.
+func sharedNetworkInfo(info *types.NetworkInfo) *types.NetworkInfo {
+	if info == nil {
+		return nil
+	}
+	return &types.NetworkInfo{
+		IP:          info.IP,
+		Gateway:     info.Gateway,
+		IPv6:        info.IPv6,
+		IPv6Gateway: info.IPv6Gateway,
+		Bridge:      info.Bridge,
+		Network:     info.Network,
+		Driver:      info.Driver,
+	}
+}
 
+// pendingStarts holds the sync socket of every created container whose
+// boxify-init waits for start, by container ID.
+var pendingStarts sync.Map
+
+// startContainer creates the container's process and starts it. It is used
+// for restarts and for containers that are started again after they exited,
+// which reuse the same overlay, IP and cgroup.
+func startContainer(d DaemonInterface, containerID string) (*types.Container, error) {
+	if _, err := createProcess(d, containerID); err != nil {
+		return nil, err
+	}
+	return startProcess(d, containerID)
+}
+
+// createProcess launches boxify-init for a container that already has an
+// IP address and mounts recorded. Init waits while its cgroup and network
+// are set up, prepares the container's root and then waits again for
+// startProcess. A setup failure is returned as the error init reported.
+func createProcess(d DaemonInterface, containerID string) (*types.Container, error) {
+	c, err := d.GetContainer(containerID)
+	if err != nil {
+		return nil, err
+	}
+	networkMgr := d.NetworkManager()
+
+	err, mergedDir := container.InitContainer(containerID, imageLayers(c))
+	if err != nil {
+		log.Printf("Error: failed in creating overlay FS %v\n", err)
+		return nil, err
+	}
+
+	if err := mountVolumes(c.Mounts, mergedDir); err != nil {
+		log.Printf("Error mounting volumes: %v\n", err)
+		return nil, err
+	}
+
+	cloneflags, join, err := containerNamespaces(d, c)
+	if err != nil {
+		log.Printf("Error preparing namespaces: %v\n", err)
+		return nil, err
+	}
+
+	// Members of a pod join the network, IPC and UTS namespaces of the pod's
+	// infra process, which owns the pod's veth pair.
+	if c.Pod != "" {
+		infraPID, err := ensurePodInfra(d, c.Pod)
+		if err != nil {
+			log.Printf("Error starting pod %s: %v\n", c.Pod, err)
+			return nil, err
+		}
+		for _, ns := range podNamespaceTypes {
+			cloneflags &^= ns.flag
+		}
+		join = append(join, podNamespaces(infraPID)...)
+	}
+
+	var hostVeth, containerVeth string
+	if ownsNetwork(c) && !onSubInterface(c) {
+		hostVeth, containerVeth, err = networkMgr.VethManager.CreateVethPairAndAttachToHostBridge(containerID, networkMgr.BridgeManager)
+		log.Printf("Created veth pair: host=%s, container=%s\n", hostVeth, containerVeth)
+		if err != nil {
+			log.Printf("Error creating veth pair: %v\n", err)
+			return nil, err
+		}
+	}
+
+	logFile, err := container.OpenLogFile(containerID)
+	if err != nil {
+		log.Printf("Error opening container log: %v\n", err)
+		return nil, err
+	}
+	defer logFile.Close()
+
+	sock, initSock, err := container.NewSyncPair()
+	if err != nil {
+		log.Printf("Error creating sync socket: %v\n", err)
+		return nil, err
+	}
+
+	args := append([]string{containerID, c.Resources.MemoryLimit, c.Resources.CpuLimit, mergedDir}, c.Command...)
+	cmd := exec.Command("/usr/local/bin/boxify-init", args...)
+	cmd.Env = append([]string{"BOXIFY_WORKDIR=" + c.WorkingDir, "BOXIFY_USER=" + c.User}, c.Env...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags:   cloneflags,
 		Unshareflags: syscall.CLONE_NEWNS,
 	}
-	cmd.Stderr = os.Stderr
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	cmd.ExtraFiles = []*os.File{initSock}
 
-	if err := cmd.Start(); err != nil {
+	err = container.StartIn(cmd, join)
+	// Only init may hold its end, so that the socket reports init's exit.
+	initSock.Close()
+	if err != nil {
+		sock.Close()
 		log.Printf("Error starting container: %v\n", err)
-		return 0, nil, err
+		return nil, err
 	}
 	pid := cmd.Process.Pid
 
-	containerInfo := &types.Container{
-		ID:      containerID,
-		PID:     pid,
-		Image:   "",
-		Command: []string{"/bin/sh"},
-		NetworkInfo: &types.NetworkInfo{
-			IP:            nextIP,
-			Gateway:       gateway,
-			Bridge:        networkMgr.BridgeManager.ReturnBridgeDetails().DefaultBridge,
-			HostVeth:      hostVeth,
-			ContainerVeth: containerVeth,
-		},
-		CreatedAt: time.Now(),
-		Status:    "running",
-		Cmd:       cmd,
+	err = d.UpdateContainer(containerID, func(c *types.Container) {
+		if c.NetworkInfo != nil {
+			networkInfo := *c.NetworkInfo
+			networkInfo.HostVeth = hostVeth
+			networkInfo.ContainerVeth = containerVeth
+			c.NetworkInfo = &networkInfo
+		}
+
+		c.PID = pid
+		c.Cmd = cmd
+		c.Status = "created"
+		c.ManuallyStopped = false
+		c.OOMKilled = false
+	})
+	if err != nil {
+		log.Printf("Error saving container info: %v\n", err)
 	}
 
-	d.AddContainer(containerInfo)
+	// Until init reports ready a failure kills it here, before the
+	// supervisor could apply the restart policy to a container that never
+	// ran.
+	abort := func(err error) (*types.Container, error) {
+		sock.Close()
+		syscall.Kill(pid, syscall.SIGKILL)
+		cmd.Wait()
+		return nil, err
+	}
 
-	go func() {
-		if err := cmd.Wait(); err != nil {
-			log.Printf("Container %s (PID %d) exited with error: %v", containerID, pid, err)
-		} else {
-			log.Printf("Container %s (PID %d) exited successfully", containerID, pid)
+	if onSubInterface(c) {
+		if err := networkMgr.SetupSubInterface(containerID, d); err != nil {
+			log.Printf("Error setting up %s interface: %v\n", c.NetworkInfo.Driver, err)
+			return abort(err)
 		}
-		if container, err := d.GetContainer(containerID); err == nil {
-			container.Status = "exited"
+	} else if ownsNetwork(c) {
+		log.Printf("Setting up container interface for container %s\n", containerID)
+		if err := networkMgr.SetupContainerInterface(containerID, d, containerVeth); err != nil {
+			log.Printf("Error setting up container interface: %v\n", err)
+			return abort(err)
+		}
+	} else if c.Namespaces.Network == networkNone {
+		if err := networkMgr.SetupLoopbackOnly(containerID, d); err != nil {
+			log.Printf("Error setting up loopback: %v\n", err)
+			return abort(err)
 		}
-	}()
-
-	log.Printf("Setting up container interface for container %s\n", containerID)
-	if err := networkMgr.SetupContainerInterface(containerID, d, containerVeth); err != nil {
-		log.Printf("Error setting up container interface: %v\n", err)
-		return 0, cmd, err
 	}
 
-	err = cgroup.SetupCgroupsV2(pid, memory, cpu)
+	limits, err := cgroup.ParseResources(c.Resources)
+	if err == nil {
+		err = cgroup.SetupCgroupsV2(containerID, c.Pod, pid, limits)
+	}
 	if err != nil {
 		log.Printf("Error setting up cgroups: %v\n", err)
-		return 0, cmd, err
+		return abort(err)
 	}
+	// Counters of an earlier run of a reused cgroup are not this run's, and
+	// an OOM kill right after start must already count.
+	resetOOMBaseline(containerID)
 
-	if err = saveContainerToDefaultConfig(containerInfo); err != nil {
-		log.Printf("Error saving container info: %v\n", err)
+	if err := sock.Send(container.SyncGo); err != nil {
+		log.Printf("Error signalling container init: %v\n", err)
+		return abort(err)
+	}
+	if err := sock.Expect(container.SyncReady); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = errors.New("container init exited during setup, see the container's logs")
+		}
+		log.Printf("Error setting up container %s: %v\n", containerID, err)
+		return abort(err)
 	}
 
-	return pid, cmd, nil
+	pendingStarts.Store(containerID, sock)
+
+	go superviseContainer(d, containerID, cmd)
+	return d.GetContainer(containerID)
 }
 
-func saveContainerToDefaultConfig(container *types.Container) error {
-	filename := "/var/lib/boxify/networks/default.yaml"
-	yamlFile, err := os.ReadFile(filename)
+// startProcess lets the command of a created container run.
+func startProcess(d DaemonInterface, containerID string) (*types.Container, error) {
+	value, ok := pendingStarts.LoadAndDelete(containerID)
+	if !ok {
+		return nil, fmt.Errorf("container %s has no process waiting to start", containerID)
+	}
+	sock := value.(*container.Sync)
+	defer sock.Close()
+
+	c, err := d.GetContainer(containerID)
 	if err != nil {
-		log.Printf("Error reading default network config: %v\n", err)
-		return err
+		return nil, err
 	}
 
-	var configStructure config.NetworkStorage
-	if err := yaml.Unmarshal(yamlFile, &configStructure); err != nil {
-		log.Printf("Error unmarshaling YAML: %v\n", err)
-		return err
+	// Init execs the command, which closes its end of the socket, or
+	// reports that it idles.
+	err = sock.Send(container.SyncStart)
+	if err == nil {
+		if _, err = sock.Receive(); errors.Is(err, io.EOF) {
+			err = nil
+		}
+	}
+	if err != nil {
+		// The supervisor records the exit once init is gone.
+		log.Printf("Error starting container %s: %v\n", containerID, err)
+		syscall.Kill(c.PID, syscall.SIGKILL)
+		return nil, err
 	}
 
-	configStructure.Containers = append(configStructure.Containers, container)
-	updatedData, err := yaml.Marshal(&configStructure)
+	err = d.UpdateContainer(containerID, func(c *types.Container) {
+		c.Status = "running"
+		c.StartedAt = time.Now()
+	})
 	if err != nil {
-		log.Printf("Error marshaling updated YAML: %v\n", err)
-		return err
+		log.Printf("Error saving container info: %v\n", err)
+	}
+
+	go watchOOM(d, containerID, c.PID)
+	go monitorHealth(d, containerID, c.PID)
+
+	started, err := d.GetContainer(containerID)
+	if err != nil {
+		return nil, err
+	}
+	emitNetworkEvent(d, started, "connect")
+	emitContainerEvent(d, started, "start", nil)
+	return started, nil
+}
+
+// discardPendingStart drops the sync socket of a created container whose
+// process is going away.
+func discardPendingStart(containerID string) {
+	if value, ok := pendingStarts.LoadAndDelete(containerID); ok {
+		value.(*container.Sync).Close()
 	}
+}
+
+// prepareVolumes parses the requested volume specs and creates the named
+// volumes that do not exist yet.
+func prepareVolumes(specs []string) ([]types.Mount, error) {
+	var mounts []types.Mount
+	for _, spec := range specs {
+		name, destination, readOnly, err := volume.ParseSpec(spec)
+		if err != nil {
+			return nil, err
+		}
+
+		v, err := volume.Create(name, nil, nil)
+		if err != nil {
+			return nil, err
+		}
 
-	if err := os.WriteFile(filename, updatedData, 0o644); err != nil {
-		return errors.New("failed to write container info: " + err.Error())
+		mounts = append(mounts, types.Mount{
+			Name:        v.Name,
+			Source:      v.Mountpoint,
+			Destination: destination,
+			ReadOnly:    readOnly,
+		})
 	}
+	return mounts, nil
+}
 
+// mountVolumes bind mounts the container's volumes into its root unless they
+// are mounted already. On failure every mount made so far is undone.
+func mountVolumes(mounts []types.Mount, mergedDir string) error {
+	var mounted []types.Mount
+	for _, m := range mounts {
+		if container.IsMounted(mergedDir + m.Destination) {
+			continue
+		}
+
+		v, err := volume.Get(m.Name)
+		if err == nil {
+			err = volume.Mount(v, mergedDir, m.Destination, m.ReadOnly)
+		}
+		if err != nil {
+			unmountVolumes(mounted, mergedDir)
+			return err
+		}
+		mounted = append(mounted, m)
+	}
 	return nil
 }
+
+func unmountVolumes(mounts []types.Mount, mergedDir string) {
+	for _, m := range mounts {
+		if err := volume.Unmount(mergedDir, m.Destination); err != nil {
+			log.Printf("Error unmounting volume %s: %v", m.Name, err)
+		}
+	}
+}
+
+// writeContainerError maps lookup and naming errors to HTTP status codes.
+func writeContainerError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, types.ErrContainerNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, types.ErrNameInUse):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/urizennnn/boxify/pkg/daemon/types"
+	"github.com/urizennnn/boxify/pkg/events"
+)
+
+var eventFilterKeys = map[string]bool{
+	"type":      true,
+	"event":     true,
+	"container": true,
+	"label":     true,
+}
+
+// emitContainerEvent publishes a container event carrying the container's
+// name, image and labels as attributes. Labels cannot hide the name or
+// image, and label filters only see the labels.
+func emitContainerEvent(d DaemonInterface, c *types.Container, action string, extra map[string]string) {
+	attributes := map[string]string{}
+	for key, value := range extra {
+		attributes[key] = value
+	}
+	attributes["name"] = c.Name
+	attributes["image"] = c.Image
+	d.Events().Publish(events.NewLabelled("container", action, c.ID, c.Labels, attributes))
+}
+
+func emitNetworkEvent(d DaemonInterface, c *types.Container, action string) {
+	if c.NetworkInfo == nil {
+		return
+	}
+	name := c.NetworkInfo.Bridge
+	if c.NetworkInfo.Network != "" {
+		name = c.NetworkInfo.Network
+	}
+	d.Events().Publish(events.New("network", action, name, map[string]string{
+		"name":      name,
+		"container": c.ID,
+	}))
+}
+
+func emitVolumeEvent(d DaemonInterface, name, action string, labels map[string]string) {
+	d.Events().Publish(events.NewLabelled("volume", action, name, labels, map[string]string{"driver": "local"}))
+}
+
+func emitImageEvent(d DaemonInterface, action, id, name string) {
+	attributes := map[string]string{}
+	if name != "" {
+		attributes["name"] = name
+	}
+	d.Events().Publish(events.New("image", action, id, attributes))
+}
+
+// HandleEvents replays buffered events newer than "since" and then streams
+// new events as JSON lines until "until" passes or the client goes away.
+func HandleEvents(d DaemonInterface, w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	now := time.Now()
+
+	since, err := parseEventTime(query.Get("since"), now)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	until, err := parseEventTime(query.Get("until"), now)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	filters, err := parseFilters(query.Get("filters"), eventFilterKeys)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	history, stream, cancel := d.Events().Subscribe()
+	defer cancel()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	encoder := json.NewEncoder(w)
+
+	send := func(e events.Event) bool {
+		if !matchEvent(filters, e) {
+			return true
+		}
+		if err := encoder.Encode(e); err != nil {
+			return false
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return true
+	}
+
+	if !since.IsZero() {
+		for _, e := range history {
+			eventTime := time.Unix(0, e.TimeNano)
+			if eventTime.Before(since) || (!until.IsZero() && eventTime.After(until)) {
+				continue
+			}
+			if !send(e) {
+				return
+			}
+		}
+	}
+
+	if !until.IsZero() && !until.After(now) {
+		return
+	}
+
+	var deadline <-chan time.Time
+	if !until.IsZero() {
+		timer := time.NewTimer(until.Sub(now))
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-deadline:
+			return
+		case e := <-stream:
+			if !send(e) {
+				return
+			}
+		}
+	}
+}
+
+// parseEventTime accepts Unix timestamps (with optional fractional seconds),
+// RFC 3339 times and Go durations, which are taken relative to now.
+func parseEventTime(raw string, now time.Time) (time.Time, error) {
+	if raw == "" {
+		return time.Time{}, nil
+	}
+
+	if seconds, err := strconv.ParseFloat(raw, 64); err == nil {
+		return time.Unix(0, int64(seconds*float64(time.Second))), nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, raw); err == nil {
+		return t, nil
+	}
+	if duration, err := time.ParseDuration(raw); err == nil {
+		return now.Add(-duration), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, expected a Unix timestamp, RFC 3339 time or duration", raw)
+}
+
+func matchEvent(f Filters, e events.Event) bool {
+	if !f.matchAny("type", func(v string) bool { return e.Type == v }) {
+		return false
+	}
+
+	if !f.matchAny("event", func(v string) bool {
+		return e.Action == v || strings.HasPrefix(e.Action, v+":")
+	}) {
+		return false
+	}
+
+	if !f.matchAny("container", func(v string) bool {
+		if e.Type == "container" {
+			return strings.HasPrefix(e.Actor.ID, v) || e.Actor.Attributes["name"] == v
+		}
+		return strings.HasPrefix(e.Actor.Attributes["container"], v)
+	}) {
+		return false
+	}
+
+	return f.matchLabels(e.Actor.Labels)
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/urizennnn/boxify/pkg/events"
+)
+
+func TestMatchEvent(t *testing.T) {
+	web := events.NewLabelled("container", "start", "3f2a9c1e5b7d", map[string]string{
+		"team": "payments",
+		"name": "label-name",
+	}, map[string]string{"name": "web", "image": "shop:latest"})
+	volume := events.NewLabelled("volume", "create", "data", nil, map[string]string{"driver": "local"})
+
+	tests := []struct {
+		name    string
+		filters Filters
+		event   events.Event
+		want    bool
+	}{
+		{name: "no filters", filters: Filters{}, event: web, want: true},
+		{name: "label key", filters: Filters{"label": {"team"}}, event: web, want: true},
+		{name: "label value", filters: Filters{"label": {"team=payments"}}, event: web, want: true},
+		{name: "other label value", filters: Filters{"label": {"team=search"}}, event: web, want: false},
+		{name: "label named like an attribute", filters: Filters{"label": {"name=label-name"}}, event: web, want: true},
+		{name: "attribute is not a label", filters: Filters{"label": {"name=web"}}, event: web, want: false},
+		{name: "attribute key is not a label", filters: Filters{"label": {"image"}}, event: web, want: false},
+		{name: "driver is not a label", filters: Filters{"label": {"driver=local"}}, event: volume, want: false},
+		{name: "container by name", filters: Filters{"container": {"web"}}, event: web, want: true},
+		{name: "container by ID prefix", filters: Filters{"container": {"3f2a"}}, event: web, want: true},
+		{name: "event and type", filters: Filters{"type": {"container"}, "event": {"start"}}, event: web, want: true},
+		{name: "other type", filters: Filters{"type": {"volume"}}, event: web, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchEvent(tt.filters, tt.event); got != tt.want {
+				t.Fatalf("matchEvent(%v) = %v, want %v", tt.filters, got, tt.want)
+			}
+		})
+	}
+
+	if web.Actor.Attributes["name"] != "web" || web.Actor.Attributes["team"] != "payments" {
+		t.Fatalf("attributes %v, want the labels and the container's name", web.Actor.Attributes)
+	}
+}
@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+
+	"github.com/urizennnn/boxify/pkg/cgroup"
+	"github.com/urizennnn/boxify/pkg/container"
+	"github.com/urizennnn/boxify/pkg/daemon/requests"
+	"github.com/urizennnn/boxify/pkg/daemon/types"
+	"golang.org/x/sys/unix"
+)
+
+// HandleExec runs a command in a running container. The daemon starts the
+// process in the container's cgroup and namespaces with its environment,
+// user and working directory, so limits, pause and top cover it like the
+// container's own processes.
+//
+// After the 200 response the connection is taken over: the client passes
+// the files the process uses as stdin, stdout and stderr over the socket,
+// and the daemon answers with a requests.ExecResult once the process is
+// gone. Closing the connection kills the process.
+func HandleExec(d DaemonInterface, w http.ResponseWriter, r *http.Request) {
+	c, err := d.ResolveContainer(r.PathValue("id"))
+	if err != nil {
+		writeContainerError(w, err)
+		return
+	}
+
+	var request requests.ExecRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if len(request.Command) == 0 {
+		http.Error(w, "No command given", http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case c.Status == "paused":
+		// A process started in the frozen cgroup would hang until unpause.
+		http.Error(w, "Container "+c.Name+" is paused, unpause it first", http.StatusConflict)
+		return
+	case c.Status != "running" || !container.ProcessAlive(c.PID):
+		http.Error(w, "Container "+c.Name+" is not running", http.StatusConflict)
+		return
+	}
+
+	user, err := container.LookupUser(c.ID, c.User)
+	if err != nil {
+		http.Error(w, "Failed to resolve user: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	cgroupDir, err := os.Open(cgroup.Path(c.ID))
+	if err != nil {
+		http.Error(w, "Failed to open container cgroup: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer cgroupDir.Close()
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "Connection does not support exec", http.StatusInternalServerError)
+		return
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		log.Printf("Error taking over exec connection: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		log.Printf("Exec connection is not a unix socket")
+		return
+	}
+	rw.WriteString("HTTP/1.1 200 OK\r\nContent-Type: application/vnd.boxify.exec\r\nConnection: close\r\n\r\n")
+	if err := rw.Flush(); err != nil {
+		return
+	}
+
+	stdio, err := receiveStdio(unixConn)
+	if err != nil {
+		log.Printf("Error receiving exec stdio for %s: %v", c.ID, err)
+		return
+	}
+
+	result := requests.ExecResult{}
+	result.ExitCode, err = runExec(c, request, user, cgroupDir, stdio, unixConn)
+	if err != nil {
+		log.Printf("Error running exec in %s: %v", c.ID, err)
+		result.Error = err.Error()
+	}
+	json.NewEncoder(conn).Encode(result)
+}
+
+// receiveStdio reads the stdin, stdout and stderr files the client sends
+// with SCM_RIGHTS.
+func receiveStdio(conn *net.UnixConn) ([]*os.File, error) {
+	buf := make([]byte, 1)
+	oob := make([]byte, unix.CmsgSpace(3*4))
+	_, oobn, _, _, err := conn.ReadMsgUnix(buf, oob)
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := unix.ParseSocketControlMessage(oob[:oobn])
+	if err != nil {
+		return nil, err
+	}
+	var fds []int
+	for _, message := range messages {
+		rights, err := unix.ParseUnixRights(&message)
+		if err != nil {
+			continue
+		}
+		fds = append(fds, rights...)
+	}
+	if len(fds) != 3 {
+		for _, fd := range fds {
+			unix.Close(fd)
+		}
+		return nil, fmt.Errorf("expected 3 file descriptors, got %d", len(fds))
+	}
+
+	files := make([]*os.File, len(fds))
+	for i, fd := range fds {
+		files[i] = os.NewFile(uintptr(fd), "exec-stdio-"+strconv.Itoa(i))
+	}
+	return files, nil
+}
+
+// runExec starts the command with nsenter directly in the container's
+// cgroup and waits for it. The process is killed when the client goes away
+// first.
+func runExec(c *types.Container, request requests.ExecRequest, user *container.User, cgroupDir *os.File, stdio []*os.File, conn *net.UnixConn) (int, error) {
+	cmd := nsenterCommand(context.Background(), c.PID, user, request.Command)
+	cmd.Env = execEnv(c.Env, user, request.Tty)
+	cmd.Stdin = stdio[0]
+	cmd.Stdout = stdio[1]
+	cmd.Stderr = stdio[2]
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = int(cgroupDir.Fd())
+	if request.Tty {
+		cmd.SysProcAttr.Setsid = true
+		cmd.SysProcAttr.Setctty = true
+		cmd.SysProcAttr.Ctty = 0
+	} else {
+		cmd.SysProcAttr.Setpgid = true
+	}
+
+	err := cmd.Start()
+	// The process holds its own copies now.
+	for _, f := range stdio {
+		f.Close()
+	}
+	if err != nil {
+		return -1, fmt.Errorf("failed to start %s: %w", request.Command[0], err)
+	}
+	log.Printf("Exec %v in container %s (PID %d)", request.Command, c.ID, cmd.Process.Pid)
+
+	exited := make(chan struct{})
+	go func() {
+		// The client sends nothing more, so the read only returns once the
+		// connection is closed.
+		conn.Read(make([]byte, 1))
+		select {
+		case <-exited:
+		default:
+			log.Printf("Exec client of container %s went away, killing PID %d", c.ID, cmd.Process.Pid)
+			syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		}
+	}()
+
+	err = cmd.Wait()
+	close(exited)
+
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return -1, err
+	}
+	return exitCode(err), nil
+}
+
+// nsenterCommand builds the command that runs command as user in the
+// namespaces of the process pid. It starts in the working directory of that
+// process, which boxify-init created and changed to. nsenter's -G would
+// drop the supplementary groups, so nsenter starts with the user's groups
+// and, still root to enter the namespaces, switches only the user ID.
+func nsenterCommand(ctx context.Context, pid int, user *container.User, command []string) *exec.Cmd {
+	args := append([]string{
+		"-t", strconv.Itoa(pid),
+		"-u", "-i", "-p", "-n", "-m",
+		"--wd",
+		"-S", strconv.Itoa(user.UID),
+		"--",
+	}, command...)
+
+	groups := make([]uint32, len(user.Groups))
+	for i, group := range user.Groups {
+		groups[i] = uint32(group)
+	}
+	cmd := exec.CommandContext(ctx, "/usr/bin/nsenter", args...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Credential: &syscall.Credential{Uid: 0, Gid: uint32(user.GID), Groups: groups},
+	}
+	return cmd
+}
+
+// execEnv is the environment of an exec'd process: the defaults boxify-init
+// gives the container's command, overridden by the container's own env and
+// the user's home directory, like boxify-init does.
+func execEnv(env []string, user *container.User, tty bool) []string {
+	defaults := []string{
+		"PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin",
+		"HOME=/root",
+		"HOSTNAME=container",
+	}
+	if tty {
+		defaults = append(defaults, "TERM=xterm")
+	}
+	defaults = append(defaults, env...)
+	if user.Home != "" {
+		defaults = append(defaults, "HOME="+user.Home)
+	}
+	return defaults
+}
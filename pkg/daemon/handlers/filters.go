@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/urizennnn/boxify/pkg/daemon/types"
+)
+
+// Filters maps a filter key to the accepted values. Values for the same key
+// are OR'ed together, different keys are AND'ed.
+type Filters map[string][]string
+
+var containerFilterKeys = map[string]bool{
+	"status":   true,
+	"name":     true,
+	"label":    true,
+	"network":  true,
+	"ancestor": true,
+	"health":   true,
+}
+
+func parseFilters(raw string, allowed map[string]bool) (Filters, error) {
+	filters := Filters{}
+	if raw == "" {
+		return filters, nil
+	}
+
+	if err := json.Unmarshal([]byte(raw), &filters); err != nil {
+		return nil, fmt.Errorf("invalid filters: %w", err)
+	}
+
+	for key := range filters {
+		if !allowed[key] {
+			return nil, fmt.Errorf("invalid filter %q", key)
+		}
+	}
+
+	return filters, nil
+}
+
+func (f Filters) has(key string) bool {
+	return len(f[key]) > 0
+}
+
+func (f Filters) matchAny(key string, match func(value string) bool) bool {
+	values := f[key]
+	if len(values) == 0 {
+		return true
+	}
+	for _, value := range values {
+		if match(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchLabels reports whether labels satisfy every label filter. A filter is
+// either "key" (the label must be present) or "key=value".
+func (f Filters) matchLabels(labels map[string]string) bool {
+	for _, filter := range f["label"] {
+		key, value, hasValue := strings.Cut(filter, "=")
+		got, exists := labels[key]
+		if !exists || (hasValue && got != value) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchContainer(f Filters, c *types.Container) bool {
+	if !f.matchAny("status", func(v string) bool { return c.Status == v }) {
+		return false
+	}
+
+	if !f.matchAny("name", func(v string) bool { return strings.Contains(c.Name, v) }) {
+		return false
+	}
+
+	if !f.matchAny("network", func(v string) bool {
+		if v == namespaceHost || v == networkNone {
+			return c.Namespaces.Network == v
+		}
+		return onNetwork(c, v, v)
+	}) {
+		return false
+	}
+
+	if !f.matchAny("ancestor", func(v string) bool { return matchImage(c.Image, v) }) {
+		return false
+	}
+
+	if !f.matchAny("health", func(v string) bool {
+		if c.Health == nil {
+			return v == "none"
+		}
+		return c.Health.Status == v
+	}) {
+		return false
+	}
+
+	return f.matchLabels(c.Labels)
+}
+
+// matchImage compares an image reference with an ancestor filter, treating a
+// filter without a tag as matching every tag of that repository.
+func matchImage(image, filter string) bool {
+	if image == filter {
+		return true
+	}
+	if strings.Contains(filter, ":") {
+		return false
+	}
+	repo, _, _ := strings.Cut(image, ":")
+	return repo == filter
+}
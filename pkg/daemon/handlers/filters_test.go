@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/urizennnn/boxify/pkg/daemon/types"
+)
+
+func TestParseFilters(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    Filters
+		wantErr string
+	}{
+		{raw: "", want: Filters{}},
+		{raw: `{}`, want: Filters{}},
+		{raw: `{"status":["running","paused"],"label":["team=payments"]}`, want: Filters{"status": {"running", "paused"}, "label": {"team=payments"}}},
+		{raw: `{"volume":["data"]}`, wantErr: `invalid filter "volume"`},
+		{raw: `{"status":"running"}`, wantErr: "invalid filters"},
+		{raw: `status=running`, wantErr: "invalid filters"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, err := parseFilters(tt.raw, containerFilterKeys)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseFilters() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseFilters() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("parseFilters() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchContainer(t *testing.T) {
+	web := &types.Container{
+		Name:        "shop_web",
+		Status:      "running",
+		Image:       "shop:1.2",
+		Labels:      map[string]string{"team": "payments", "tier": ""},
+		NetworkInfo: &types.NetworkInfo{Bridge: "boxify0"},
+		Health:      &types.Health{Status: "healthy"},
+	}
+	lan := &types.Container{
+		Name:        "printer",
+		Status:      "exited",
+		Image:       "alpine",
+		NetworkInfo: &types.NetworkInfo{Network: "lan"},
+	}
+	host := &types.Container{Name: "probe", Status: "paused", Namespaces: types.NamespaceModes{Network: "host"}}
+
+	tests := []struct {
+		name    string
+		filters Filters
+		want    []*types.Container
+	}{
+		{name: "none", filters: Filters{}, want: []*types.Container{web, lan, host}},
+		{name: "status OR", filters: Filters{"status": {"running", "paused"}}, want: []*types.Container{web, host}},
+		{name: "name substring", filters: Filters{"name": {"web"}}, want: []*types.Container{web}},
+		{name: "label key", filters: Filters{"label": {"tier"}}, want: []*types.Container{web}},
+		{name: "label value", filters: Filters{"label": {"team=payments"}}, want: []*types.Container{web}},
+		{name: "labels AND", filters: Filters{"label": {"team=payments", "tier=web"}}, want: nil},
+		{name: "network by name", filters: Filters{"network": {"lan"}}, want: []*types.Container{lan}},
+		{name: "network by bridge", filters: Filters{"network": {"boxify0"}}, want: []*types.Container{web}},
+		{name: "host network", filters: Filters{"network": {"host"}}, want: []*types.Container{host}},
+		{name: "ancestor repository", filters: Filters{"ancestor": {"shop"}}, want: []*types.Container{web}},
+		{name: "ancestor tag", filters: Filters{"ancestor": {"shop:1.3"}}, want: nil},
+		{name: "health", filters: Filters{"health": {"healthy"}}, want: []*types.Container{web}},
+		{name: "no health check", filters: Filters{"health": {"none"}}, want: []*types.Container{lan, host}},
+		{name: "keys AND", filters: Filters{"status": {"running"}, "network": {"lan"}}, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []*types.Container
+			for _, c := range []*types.Container{web, lan, host} {
+				if matchContainer(tt.filters, c) {
+					got = append(got, c)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				names := func(cs []*types.Container) []string {
+					var out []string
+					for _, c := range cs {
+						out = append(out, c.Name)
+					}
+					return out
+				}
+				t.Fatalf("matched %v, want %v", names(got), names(tt.want))
+			}
+		})
+	}
+}
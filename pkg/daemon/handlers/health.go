@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/urizennnn/boxify/pkg/cgroup"
+	"github.com/urizennnn/boxify/pkg/container"
+	"github.com/urizennnn/boxify/pkg/daemon/requests"
+	"github.com/urizennnn/boxify/pkg/daemon/types"
+)
+
+const (
+	defaultHealthInterval = 30 * time.Second
+	defaultHealthTimeout  = 30 * time.Second
+	defaultHealthRetries  = 3
+
+	// Number of probe results kept on the container.
+	healthLogSize = 5
+	// Probe output beyond this many bytes is dropped.
+	healthOutputLimit = 4096
+)
+
+// parseHealthCheck validates the request's health check and fills in
+// defaults. A nil request or a "NONE" test disables health checking.
+func parseHealthCheck(request *requests.HealthCheck) (*types.HealthConfig, error) {
+	if request == nil || len(request.Test) == 0 || request.Test[0] == "NONE" {
+		return nil, nil
+	}
+
+	switch request.Test[0] {
+	case "CMD":
+		if len(request.Test) < 2 {
+			return nil, errors.New("healthcheck test CMD needs a command")
+		}
+	case "CMD-SHELL":
+		if len(request.Test) != 2 {
+			return nil, errors.New("healthcheck test CMD-SHELL takes exactly one command string")
+		}
+	default:
+		return nil, fmt.Errorf("healthcheck test must start with CMD, CMD-SHELL or NONE, got %q", request.Test[0])
+	}
+
+	config := &types.HealthConfig{
+		Test:               request.Test,
+		Interval:           defaultHealthInterval,
+		Timeout:            defaultHealthTimeout,
+		Retries:            defaultHealthRetries,
+		RestartOnUnhealthy: request.RestartOnUnhealthy,
+	}
+
+	var err error
+	if config.Interval, err = parseHealthDuration("interval", request.Interval, defaultHealthInterval); err != nil {
+		return nil, err
+	}
+	if config.Timeout, err = parseHealthDuration("timeout", request.Timeout, defaultHealthTimeout); err != nil {
+		return nil, err
+	}
+	if config.StartPeriod, err = parseHealthDuration("start_period", request.StartPeriod, 0); err != nil {
+		return nil, err
+	}
+
+	if request.Retries < 0 {
+		return nil, errors.New("healthcheck retries must not be negative")
+	}
+	if request.Retries > 0 {
+		config.Retries = request.Retries
+	}
+
+	return config, nil
+}
+
+func parseHealthDuration(field, raw string, fallback time.Duration) (time.Duration, error) {
+	if raw == "" {
+		return fallback, nil
+	}
+
+	duration, err := time.ParseDuration(raw)
+	if err != nil {
+		return 0, fmt.Errorf("invalid healthcheck %s %q: %w", field, raw, err)
+	}
+	if duration < 0 || (duration == 0 && field != "start_period") {
+		return 0, fmt.Errorf("healthcheck %s must be positive, got %q", field, raw)
+	}
+	return duration, nil
+}
+
+// monitorHealth probes the container on its configured interval for as long
+// as the process with the given PID is the container's main process.
+func monitorHealth(d DaemonInterface, containerID string, pid int) {
+	c, err := d.GetContainer(containerID)
+	if err != nil || c.HealthCheck == nil {
+		return
+	}
+	healthCheck := c.HealthCheck
+
+	d.UpdateContainer(containerID, func(c *types.Container) {
+		c.Health = &types.Health{Status: "starting"}
+	})
+
+	startedAt := time.Now()
+	ticker := time.NewTicker(healthCheck.Interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		c, err := d.GetContainer(containerID)
+		if err != nil || c.PID != pid || !c.Active() {
+			return
+		}
+		// A frozen container cannot answer a probe, so none runs until it
+		// is unpaused.
+		if c.Status == "paused" {
+			continue
+		}
+
+		result := runHealthProbe(c, healthCheck)
+		inStartPeriod := time.Since(startedAt) < healthCheck.StartPeriod
+		recordHealthResult(d, c, result, inStartPeriod)
+	}
+}
+
+// runHealthProbe executes the test inside the container's namespaces and
+// cgroup, with its environment and user like exec. On timeout the probe's
+// whole process group is killed: nsenter forks the test into the
+// container's PID namespace, and killing nsenter alone would leave it
+// running with the output pipe open.
+func runHealthProbe(c *types.Container, healthCheck *types.HealthConfig) types.HealthResult {
+	result := types.HealthResult{Start: time.Now()}
+	fail := func(err error) types.HealthResult {
+		result.End = time.Now()
+		result.ExitCode = -1
+		result.Output = err.Error()
+		return result
+	}
+
+	user, err := container.LookupUser(c.ID, c.User)
+	if err != nil {
+		return fail(fmt.Errorf("failed to resolve user: %w", err))
+	}
+	cgroupDir, err := os.Open(cgroup.Path(c.ID))
+	if err != nil {
+		return fail(fmt.Errorf("failed to open container cgroup: %w", err))
+	}
+	defer cgroupDir.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), healthCheck.Timeout)
+	defer cancel()
+
+	command := healthCheck.Test[1:]
+	if healthCheck.Test[0] == "CMD-SHELL" {
+		command = []string{"/bin/sh", "-c", healthCheck.Test[1]}
+	}
+
+	var output bytes.Buffer
+	probe := nsenterCommand(ctx, c.PID, user, command)
+	probe.Env = execEnv(c.Env, user, false)
+	probe.Stdout = &output
+	probe.Stderr = &output
+	probe.SysProcAttr.Setpgid = true
+	probe.SysProcAttr.UseCgroupFD = true
+	probe.SysProcAttr.CgroupFD = int(cgroupDir.Fd())
+	probe.Cancel = func() error {
+		return syscall.Kill(-probe.Process.Pid, syscall.SIGKILL)
+	}
+	// Output of processes the test left in the background does not hold
+	// the probe up either.
+	probe.WaitDelay = time.Second
+
+	err = probe.Run()
+	result.End = time.Now()
+
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		result.ExitCode = -1
+		output.WriteString(fmt.Sprintf("\nhealth check exceeded timeout of %s", healthCheck.Timeout))
+	case err != nil:
+		result.ExitCode = exitCode(err)
+	}
+
+	result.Output = output.String()
+	if len(result.Output) > healthOutputLimit {
+		result.Output = result.Output[:healthOutputLimit]
+	}
+	return result
+}
+
+func recordHealthResult(d DaemonInterface, c *types.Container, result types.HealthResult, inStartPeriod bool) {
+	previous := "starting"
+	if c.Health != nil {
+		previous = c.Health.Status
+	}
+
+	var current string
+	d.UpdateContainer(c.ID, func(c *types.Container) {
+		health := types.Health{Status: previous}
+		if c.Health != nil {
+			health = *c.Health
+		}
+
+		health.Log = append(append([]types.HealthResult{}, health.Log...), result)
+		if len(health.Log) > healthLogSize {
+			health.Log = health.Log[len(health.Log)-healthLogSize:]
+		}
+
+		if result.ExitCode == 0 {
+			health.FailingStreak = 0
+			health.Status = "healthy"
+		} else if !inStartPeriod {
+			health.FailingStreak++
+			if health.FailingStreak >= c.HealthCheck.Retries {
+				health.Status = "unhealthy"
+			}
+		}
+
+		c.Health = &health
+		current = health.Status
+	})
+
+	if current == previous {
+		return
+	}
+	log.Printf("Container %s health status changed: %s -> %s", c.ID, previous, current)
+	emitContainerEvent(d, c, "health_status: "+current, nil)
+
+	if current == "unhealthy" && c.HealthCheck.RestartOnUnhealthy && c.RestartPolicy.Name != "no" {
+		log.Printf("Killing unhealthy container %s so its restart policy applies", c.ID)
+		if err := syscall.Kill(c.PID, syscall.SIGKILL); err != nil {
+			log.Printf("Error killing unhealthy container %s: %v", c.ID, err)
+		}
+	}
+}
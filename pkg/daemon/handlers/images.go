@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/urizennnn/boxify/pkg/daemon/types"
+	"github.com/urizennnn/boxify/pkg/events"
+	"github.com/urizennnn/boxify/pkg/image"
+)
+
+// containerImage looks up the image a container was created from. Containers
+// created before images were recorded only carry the reference.
+func containerImage(c *types.Container) (*image.Image, string, error) {
+	ref := c.ImageID
+	if ref == "" {
+		ref = c.Image
+	}
+	return image.Get(ref)
+}
+
+// imageLayers returns the container's image layer directories, top first.
+// Without an image the overlay falls back to the plain rootfs.
+func imageLayers(c *types.Container) []string {
+	img, _, err := containerImage(c)
+	if err != nil {
+		log.Printf("Error looking up image of container %s: %v", c.ID, err)
+		return nil
+	}
+	return image.LayerDirs(img)
+}
+
+// writeImageError maps image lookup errors to HTTP status codes.
+func writeImageError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, image.ErrImageNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, image.ErrAmbiguousImage):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, image.ErrImageConflict):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// imageUsers returns a lookup of the container using each image. It is built
+// before the image store is locked, since resolving the image of an older
+// container needs the store.
+func imageUsers(d DaemonInterface) image.InUse {
+	users := make(map[string]string)
+	for _, c := range d.ListContainers() {
+		id := c.ImageID
+		if id == "" {
+			if _, resolved, err := containerImage(c); err == nil {
+				id = resolved
+			}
+		}
+		if _, ok := users[id]; !ok && id != "" {
+			users[id] = c.Name
+		}
+	}
+	return func(id string) string { return users[id] }
+}
+
+func HandleImageList(d DaemonInterface, w http.ResponseWriter, r *http.Request) {
+	all, _ := strconv.ParseBool(r.URL.Query().Get("all"))
+	summaries, err := image.List(all)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if summaries == nil {
+		summaries = []image.Summary{}
+	}
+	writeJSON(w, http.StatusOK, summaries)
+}
+
+// HandleImageInspect returns an image's ID together with its config.
+func HandleImageInspect(d DaemonInterface, w http.ResponseWriter, r *http.Request) {
+	img, id, err := image.Get(r.PathValue("name"))
+	if err != nil {
+		writeImageError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, struct {
+		ID string `json:"id"`
+		*image.Image
+	}{id, img})
+}
+
+func HandleImageHistory(d DaemonInterface, w http.ResponseWriter, r *http.Request) {
+	history, err := image.ImageHistory(r.PathValue("name"))
+	if err != nil {
+		writeImageError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, history)
+}
+
+// HandleImageTag points the reference in the "ref" query parameter at an
+// image.
+func HandleImageTag(d DaemonInterface, w http.ResponseWriter, r *http.Request) {
+	_, id, err := image.Get(r.PathValue("name"))
+	if err != nil {
+		writeImageError(w, err)
+		return
+	}
+
+	ref, err := image.NormalizeReference(r.URL.Query().Get("ref"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := image.Tag(id, ref); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	emitImageEvent(d, "tag", id, ref)
+	w.WriteHeader(http.StatusCreated)
+}
+
+// HandleImageRemove untags and deletes an image. Without force it refuses
+// images that containers use or that several tags name.
+func HandleImageRemove(d DaemonInterface, w http.ResponseWriter, r *http.Request) {
+	force, _ := strconv.ParseBool(r.URL.Query().Get("force"))
+
+	deletions, err := image.Remove(r.PathValue("name"), force, imageUsers(d))
+	emitImageDeletions(d, deletions)
+	if err != nil {
+		writeImageError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, deletions)
+}
+
+// HandleImagePrune removes unused images and then the layers no image
+// references any more.
+func HandleImagePrune(d DaemonInterface, w http.ResponseWriter, r *http.Request) {
+	all, _ := strconv.ParseBool(r.URL.Query().Get("all"))
+
+	report, err := image.Prune(all, imageUsers(d))
+	if report != nil {
+		emitImageDeletions(d, report.ImagesDeleted)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if report.ImagesDeleted == nil {
+		report.ImagesDeleted = []image.Deletion{}
+	}
+	if report.LayersDeleted == nil {
+		report.LayersDeleted = []string{}
+	}
+	d.Events().Publish(events.New("image", "prune", "", map[string]string{
+		"reclaimed": strconv.FormatInt(report.SpaceReclaimed, 10),
+	}))
+	writeJSON(w, http.StatusOK, report)
+}
+
+// HandleImageSave streams the images named by the "names" query parameters
+// as a tar archive in the format given by "format".
+func HandleImageSave(d DaemonInterface, w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	names := query["names"]
+	if len(names) == 0 {
+		http.Error(w, "No images to save", http.StatusBadRequest)
+		return
+	}
+	format := query.Get("format")
+	if format != "" && format != image.FormatDocker && format != image.FormatOCI {
+		http.Error(w, "Unsupported format "+format+", expected "+image.FormatDocker+" or "+image.FormatOCI, http.StatusBadRequest)
+		return
+	}
+	ids := make([]string, len(names))
+	for i, name := range names {
+		_, id, err := image.Get(name)
+		if err != nil {
+			writeImageError(w, err)
+			return
+		}
+		ids[i] = id
+	}
+
+	w.Header().Set("Content-Type", "application/x-tar")
+	if err := image.Save(w, names, format); err != nil {
+		// The archive has already started, so the client sees a
+		// truncated stream rather than an error status.
+		log.Printf("Error saving images %v: %v", names, err)
+		return
+	}
+	for i, name := range names {
+		emitImageEvent(d, "save", ids[i], name)
+	}
+}
+
+// HandleImageLoad imports the images of a docker-archive or OCI layout tar
+// archive sent as the request body.
+func HandleImageLoad(d DaemonInterface, w http.ResponseWriter, r *http.Request) {
+	loaded, err := image.Load(r.Body)
+	for _, name := range loaded {
+		emitImageEvent(d, "load", name, name)
+	}
+	if err != nil {
+		log.Printf("Error loading images: %v", err)
+		http.Error(w, "Failed to load images: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string][]string{"loaded": loaded})
+}
+
+// HandleImageImport creates a single layer image from the root filesystem
+// tar archive sent as the request body. The "ref", "message" and "changes"
+// query parameters tag the image, comment its history entry and adjust its
+// config; "source" names the archive in the history.
+func HandleImageImport(d DaemonInterface, w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	source := query.Get("source")
+	if source == "" {
+		source = "-"
+	}
+
+	id, err := image.Import(r.Body, query.Get("ref"), source, query.Get("message"), query["changes"])
+	if err != nil {
+		log.Printf("Error importing image: %v", err)
+		http.Error(w, "Failed to import image: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	emitImageEvent(d, "import", id, query.Get("ref"))
+	writeJSON(w, http.StatusCreated, map[string]string{"id": id})
+}
+
+func emitImageDeletions(d DaemonInterface, deletions []image.Deletion) {
+	for _, deletion := range deletions {
+		if deletion.Untagged != "" {
+			emitImageEvent(d, "untag", deletion.Untagged, deletion.Untagged)
+		}
+		if deletion.Deleted != "" {
+			emitImageEvent(d, "delete", deletion.Deleted, "")
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+func HandleInspect(d DaemonInterface, w http.ResponseWriter, r *http.Request) {
+	c, err := d.ResolveContainer(r.PathValue("id"))
+	if err != nil {
+		writeContainerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(c); err != nil {
+		http.Error(w, "Failed to write response", http.StatusInternalServerError)
+		return
+	}
+}
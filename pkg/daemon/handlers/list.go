@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/urizennnn/boxify/pkg/daemon/types"
+)
+
+func HandleList(d DaemonInterface, w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	all := false
+	if raw := query.Get("all"); raw != "" {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			http.Error(w, "Invalid value for all", http.StatusBadRequest)
+			return
+		}
+		all = parsed
+	}
+
+	filters, err := parseFilters(query.Get("filters"), containerFilterKeys)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// An explicit status filter decides visibility on its own.
+	if filters.has("status") {
+		all = true
+	}
+
+	result := []*types.Container{}
+	for _, c := range d.ListContainers() {
+		if !all && c.Status == "exited" {
+			continue
+		}
+		if !matchContainer(filters, c) {
+			continue
+		}
+		result = append(result, c)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		http.Error(w, "Failed to write response", http.StatusInternalServerError)
+		return
+	}
+}
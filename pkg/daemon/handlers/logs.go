@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/urizennnn/boxify/pkg/container"
+)
+
+func HandleLogs(d DaemonInterface, w http.ResponseWriter, r *http.Request) {
+	c, err := d.ResolveContainer(r.PathValue("id"))
+	if err != nil {
+		writeContainerError(w, err)
+		return
+	}
+
+	follow, _ := strconv.ParseBool(r.URL.Query().Get("follow"))
+
+	logFile, err := os.Open(container.LogPath(c.ID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		http.Error(w, "Failed to open container log: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer logFile.Close()
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	flusher, _ := w.(http.Flusher)
+
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := logFile.Read(buf)
+		if n > 0 {
+			if _, err := w.Write(buf[:n]); err != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+			continue
+		}
+		if err != nil && err != io.EOF {
+			return
+		}
+
+		if !follow {
+			return
+		}
+		current, err := d.GetContainer(c.ID)
+		if err != nil || !current.Active() {
+			// Pick up anything written between the last read and exit.
+			io.Copy(w, logFile)
+			return
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(250 * time.Millisecond):
+		}
+	}
+}
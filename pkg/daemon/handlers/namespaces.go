@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"syscall"
+
+	"github.com/urizennnn/boxify/config"
+	"github.com/urizennnn/boxify/pkg/container"
+	"github.com/urizennnn/boxify/pkg/daemon/requests"
+	"github.com/urizennnn/boxify/pkg/daemon/types"
+	"github.com/urizennnn/boxify/pkg/network"
+)
+
+// Namespace modes of a container's net, pid, ipc and uts namespaces.
+// networkNone only applies to the network namespace: a namespace of the
+// container's own with nothing but loopback.
+const (
+	namespacePrivate         = "private"
+	namespaceHost            = "host"
+	namespaceContainerPrefix = "container:"
+	networkNone              = "none"
+)
+
+// namespaceKind is one of the namespaces whose mode can be chosen.
+type namespaceKind struct {
+	name string
+	flag uintptr
+	mode func(m *types.NamespaceModes) *string
+}
+
+var namespaceKinds = []namespaceKind{
+	{"net", syscall.CLONE_NEWNET, func(m *types.NamespaceModes) *string { return &m.Network }},
+	{"pid", syscall.CLONE_NEWPID, func(m *types.NamespaceModes) *string { return &m.PID }},
+	{"ipc", syscall.CLONE_NEWIPC, func(m *types.NamespaceModes) *string { return &m.IPC }},
+	{"uts", syscall.CLONE_NEWUTS, func(m *types.NamespaceModes) *string { return &m.UTS }},
+}
+
+// parseNamespaceModes validates the namespace modes of a create request.
+// Containers are referenced by ID, ID prefix or name and stored by full ID;
+// they must be running, since their namespaces only exist while their
+// process does.
+func parseNamespaceModes(d DaemonInterface, request requests.InitContainerRequest) (types.NamespaceModes, error) {
+	modes := types.NamespaceModes{
+		Network: request.NetworkMode,
+		PID:     request.PidMode,
+		IPC:     request.IpcMode,
+		UTS:     request.UtsMode,
+	}
+
+	for _, kind := range namespaceKinds {
+		mode := kind.mode(&modes)
+		switch {
+		case *mode == "" || *mode == namespacePrivate:
+			*mode = namespacePrivate
+		case *mode == namespaceHost:
+		case *mode == networkNone && kind.flag == syscall.CLONE_NEWNET:
+		case strings.HasPrefix(*mode, namespaceContainerPrefix):
+			target, err := d.ResolveContainer(strings.TrimPrefix(*mode, namespaceContainerPrefix))
+			if err != nil {
+				return modes, fmt.Errorf("%s namespace: %w", kind.name, err)
+			}
+			if !target.Active() {
+				return modes, fmt.Errorf("%s namespace: container %s is not running", kind.name, target.Name)
+			}
+			*mode = namespaceContainerPrefix + target.ID
+		case kind.flag == syscall.CLONE_NEWNET:
+			return modes, fmt.Errorf("invalid network mode %q, use private, host, none, container:<id> or a network name", *mode)
+		default:
+			return modes, fmt.Errorf("invalid %s namespace mode %q, use private, host or container:<id>", kind.name, *mode)
+		}
+
+		if request.Pod != "" && kind.flag != syscall.CLONE_NEWPID && *mode != namespacePrivate {
+			return modes, fmt.Errorf("containers of a pod share the pod's network, IPC and UTS namespaces, %s cannot be %s", kind.name, *mode)
+		}
+	}
+	return modes, nil
+}
+
+// requestedNetwork resolves a network mode that names a network rather than
+// a namespace mode. The container then gets a network namespace of its own
+// with an address on that network, so the mode becomes private. It returns
+// nil for the default bridge network and for namespace modes.
+func requestedNetwork(request *requests.InitContainerRequest) (*config.NetworkStorage, error) {
+	mode := request.NetworkMode
+	switch {
+	case mode == "", mode == namespacePrivate, mode == namespaceHost, mode == networkNone,
+		strings.HasPrefix(mode, namespaceContainerPrefix):
+		return nil, nil
+	}
+
+	n, err := network.FindNetwork(mode)
+	if err != nil {
+		return nil, err
+	}
+	request.NetworkMode = namespacePrivate
+	if n.Driver == network.DriverBridge {
+		return nil, nil
+	}
+	if request.Pod != "" {
+		return nil, fmt.Errorf("containers of a pod share the pod's network, they cannot join network %s", n.Name)
+	}
+	return n, nil
+}
+
+// namespaceContainer returns the container whose namespace mode refers to,
+// or nil for private and host namespaces.
+func namespaceContainer(d DaemonInterface, mode string) (*types.Container, error) {
+	id, ok := strings.CutPrefix(mode, namespaceContainerPrefix)
+	if !ok {
+		return nil, nil
+	}
+	return d.GetContainer(id)
+}
+
+// containerNamespaces returns the namespaces a container's init is cloned
+// with and the existing ones it joins, from its namespace modes. A joined
+// container must be running.
+func containerNamespaces(d DaemonInterface, c *types.Container) (uintptr, []container.JoinNamespace, error) {
+	cloneflags := uintptr(syscall.CLONE_NEWNS)
+	var join []container.JoinNamespace
+
+	modes := c.Namespaces
+	for _, kind := range namespaceKinds {
+		mode := *kind.mode(&modes)
+		switch {
+		case mode == namespaceHost:
+		case strings.HasPrefix(mode, namespaceContainerPrefix):
+			target, err := namespaceContainer(d, mode)
+			if err != nil {
+				return 0, nil, fmt.Errorf("%s namespace: %w", kind.name, err)
+			}
+			if !target.Active() || !container.ProcessAlive(target.PID) {
+				return 0, nil, fmt.Errorf("%s namespace: container %s is not running", kind.name, target.Name)
+			}
+			join = append(join, container.ProcessNamespace(target.PID, kind.name, kind.flag))
+		default:
+			cloneflags |= kind.flag
+		}
+	}
+	return cloneflags, join, nil
+}
+
+// ownsNetwork reports whether the container has a network namespace of its
+// own with its own veth pair and address. Pod members, containers in the
+// host's or another container's network namespace and containers without
+// networking do not.
+func ownsNetwork(c *types.Container) bool {
+	mode := c.Namespaces.Network
+	return c.Pod == "" && (mode == "" || mode == namespacePrivate)
+}
+
+// onSubInterface reports whether the container owns a macvlan or ipvlan
+// interface rather than a veth pair on the bridge.
+func onSubInterface(c *types.Container) bool {
+	return ownsNetwork(c) && c.NetworkInfo != nil && c.NetworkInfo.Network != ""
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/urizennnn/boxify/config"
+	"github.com/urizennnn/boxify/pkg/daemon/requests"
+	"github.com/urizennnn/boxify/pkg/daemon/types"
+	"github.com/urizennnn/boxify/pkg/events"
+	"github.com/urizennnn/boxify/pkg/network"
+)
+
+var networkFilterKeys = map[string]bool{
+	"id":     true,
+	"name":   true,
+	"label":  true,
+	"driver": true,
+}
+
+// HandleNetworkCreate creates a macvlan or ipvlan network. The default
+// bridge network is the only bridge network.
+func HandleNetworkCreate(d DaemonInterface, w http.ResponseWriter, r *http.Request) {
+	var request requests.CreateNetworkRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	n, err := network.CreateNetwork(network.CreateOptions{
+		Name:     request.Name,
+		Driver:   request.Driver,
+		Parent:   request.Parent,
+		Subnet:   request.Subnet,
+		Gateway:  request.Gateway,
+		Mode:     request.Mode,
+		IPv6:     request.IPv6,
+		Subnet6:  request.Subnet6,
+		Gateway6: request.Gateway6,
+		Labels:   request.Labels,
+	})
+	if err != nil {
+		writeNetworkError(w, err)
+		return
+	}
+	emitNetworkLifecycleEvent(d, n, "create")
+	writeJSON(w, http.StatusCreated, n)
+}
+
+// HandleNetworkRemove removes a network no container is attached to.
+func HandleNetworkRemove(d DaemonInterface, w http.ResponseWriter, r *http.Request) {
+	n, err := network.FindNetwork(r.PathValue("id"))
+	if err != nil {
+		writeNetworkError(w, err)
+		return
+	}
+	if n.Driver == network.DriverBridge {
+		http.Error(w, "the default bridge network cannot be removed", http.StatusForbidden)
+		return
+	}
+	if attached := networkContainers(d, n); len(attached) > 0 {
+		http.Error(w, "network "+n.Name+" is in use by container "+attached[0].Name, http.StatusConflict)
+		return
+	}
+
+	if err := network.RemoveNetwork(n.Name); err != nil {
+		writeNetworkError(w, err)
+		return
+	}
+	emitNetworkLifecycleEvent(d, n, "destroy")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func HandleNetworkList(d DaemonInterface, w http.ResponseWriter, r *http.Request) {
+	filters, err := parseFilters(r.URL.Query().Get("filters"), networkFilterKeys)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	networks, err := network.ListNetworkConfigs()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	matched := []*config.NetworkStorage{}
+	for _, n := range networks {
+		if !filters.matchAny("id", func(v string) bool { return n.Id == v }) {
+			continue
+		}
+		if !filters.matchAny("name", func(v string) bool { return n.Name == v }) {
+			continue
+		}
+		if !filters.matchAny("driver", func(v string) bool { return n.Driver == v }) {
+			continue
+		}
+		if !filters.matchLabels(n.Labels) {
+			continue
+		}
+		n.Containers = networkContainers(d, n)
+		matched = append(matched, n)
+	}
+	writeJSON(w, http.StatusOK, matched)
+}
+
+func HandleNetworkInspect(d DaemonInterface, w http.ResponseWriter, r *http.Request) {
+	ref := r.PathValue("id")
+
+	networks, err := network.ListNetworkConfigs()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	for _, n := range networks {
+		if n.Id == ref || n.Name == ref {
+			n.Containers = networkContainers(d, n)
+			writeJSON(w, http.StatusOK, n)
+			return
+		}
+	}
+	http.Error(w, "network not found", http.StatusNotFound)
+}
+
+// networkContainers lists the containers attached to the network.
+func networkContainers(d DaemonInterface, n *config.NetworkStorage) []*types.Container {
+	attached := []*types.Container{}
+	for _, c := range d.ListContainers() {
+		if onNetwork(c, n.Name, n.Bridge.Name) {
+			attached = append(attached, c)
+		}
+	}
+	return attached
+}
+
+// onNetwork reports whether the container has an address on the network
+// named name, or on the default network when bridge is its bridge.
+func onNetwork(c *types.Container, name, bridge string) bool {
+	if c.NetworkInfo == nil {
+		return false
+	}
+	if c.NetworkInfo.Network != "" {
+		return c.NetworkInfo.Network == name
+	}
+	return bridge != "" && c.NetworkInfo.Bridge == bridge
+}
+
+func emitNetworkLifecycleEvent(d DaemonInterface, n *config.NetworkStorage, action string) {
+	attributes := map[string]string{"name": n.Name, "driver": n.Driver}
+	d.Events().Publish(events.NewLabelled("network", action, n.Id, n.Labels, attributes))
+}
+
+func writeNetworkError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, network.ErrNetworkNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, network.ErrNetworkExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
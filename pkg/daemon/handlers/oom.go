@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"log"
+	"strconv"
+	"sync"
+
+	"github.com/urizennnn/boxify/pkg/cgroup"
+	"github.com/urizennnn/boxify/pkg/daemon/types"
+)
+
+var (
+	oomMu sync.Mutex
+	// oomBaselines holds the last memory.events counters seen per container,
+	// so only increments are reported.
+	oomBaselines = map[string]cgroup.MemoryEvents{}
+)
+
+func resetOOMBaseline(containerID string) {
+	events, err := cgroup.ReadMemoryEvents(containerID)
+	if err != nil {
+		log.Printf("Error reading memory events for container %s: %v", containerID, err)
+		return
+	}
+
+	oomMu.Lock()
+	defer oomMu.Unlock()
+	oomBaselines[containerID] = events
+}
+
+func forgetOOMBaseline(containerID string) {
+	oomMu.Lock()
+	defer oomMu.Unlock()
+	delete(oomBaselines, containerID)
+}
+
+// watchOOM reports OOM events for as long as the process with the given PID
+// is the container's main process.
+func watchOOM(d DaemonInterface, containerID string, pid int) {
+	done := func() bool {
+		c, err := d.GetContainer(containerID)
+		return err != nil || c.PID != pid || !c.Active()
+	}
+
+	err := cgroup.WatchMemoryEvents(containerID, done, func() {
+		recordOOMEvents(d, containerID)
+	})
+	if err != nil {
+		log.Printf("Error watching memory events for container %s: %v", containerID, err)
+	}
+}
+
+// recordOOMEvents compares memory.events with the last seen counters, marks
+// the container OOMKilled when the kernel killed one of its processes and
+// emits an oom event.
+func recordOOMEvents(d DaemonInterface, containerID string) {
+	current, err := cgroup.ReadMemoryEvents(containerID)
+	if err != nil {
+		return
+	}
+
+	oomMu.Lock()
+	previous, ok := oomBaselines[containerID]
+	oomBaselines[containerID] = current
+	oomMu.Unlock()
+
+	if !ok || (current.OOM <= previous.OOM && current.OOMKill <= previous.OOMKill) {
+		return
+	}
+
+	kills := 0
+	if current.OOMKill > previous.OOMKill {
+		kills = int(current.OOMKill - previous.OOMKill)
+	}
+
+	if kills > 0 {
+		err = d.UpdateContainer(containerID, func(c *types.Container) {
+			c.OOMKilled = true
+			c.OOMKillCount += kills
+		})
+		if err != nil {
+			return
+		}
+	}
+
+	c, err := d.GetContainer(containerID)
+	if err != nil {
+		return
+	}
+	log.Printf("Container %s ran out of memory (%d processes killed, %d in total)", containerID, kills, c.OOMKillCount)
+	emitContainerEvent(d, c, "oom", map[string]string{
+		"oomKillCount": strconv.Itoa(c.OOMKillCount),
+	})
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/urizennnn/boxify/pkg/cgroup"
+	"github.com/urizennnn/boxify/pkg/container"
+)
+
+// HandlePause freezes every process of a running container.
+func HandlePause(d DaemonInterface, w http.ResponseWriter, r *http.Request) {
+	c, err := d.ResolveContainer(r.PathValue("id"))
+	if err != nil {
+		writeContainerError(w, err)
+		return
+	}
+
+	switch {
+	case c.Status == "paused":
+		http.Error(w, "Container "+c.Name+" is already paused", http.StatusConflict)
+		return
+	case c.Status != "running" || !container.ProcessAlive(c.PID):
+		http.Error(w, "Container "+c.Name+" is not running", http.StatusConflict)
+		return
+	}
+
+	log.Printf("Pausing container %s", c.ID)
+	if err := cgroup.Freeze(c.ID); err != nil {
+		log.Printf("Error freezing container %s: %v", c.ID, err)
+		// Don't leave the container half frozen.
+		cgroup.Thaw(c.ID)
+		http.Error(w, "Failed to pause container: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	d.SetContainerStatus(c.ID, "paused")
+	emitContainerEvent(d, c, "pause", nil)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleUnpause resumes a paused container.
+func HandleUnpause(d DaemonInterface, w http.ResponseWriter, r *http.Request) {
+	c, err := d.ResolveContainer(r.PathValue("id"))
+	if err != nil {
+		writeContainerError(w, err)
+		return
+	}
+
+	if c.Status != "paused" {
+		http.Error(w, "Container "+c.Name+" is not paused", http.StatusConflict)
+		return
+	}
+
+	log.Printf("Unpausing container %s", c.ID)
+	if err := cgroup.Thaw(c.ID); err != nil {
+		log.Printf("Error thawing container %s: %v", c.ID, err)
+		http.Error(w, "Failed to unpause container: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	d.SetContainerStatus(c.ID, "running")
+	emitContainerEvent(d, c, "unpause", nil)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,565 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/urizennnn/boxify/pkg/cgroup"
+	"github.com/urizennnn/boxify/pkg/container"
+	"github.com/urizennnn/boxify/pkg/daemon/requests"
+	"github.com/urizennnn/boxify/pkg/daemon/types"
+	"github.com/urizennnn/boxify/pkg/events"
+	"github.com/urizennnn/boxify/pkg/pod"
+)
+
+// podNamespaceTypes are the namespaces a pod's members share with its infra
+// process.
+var podNamespaceTypes = []struct {
+	name string
+	flag uintptr
+}{
+	{"net", syscall.CLONE_NEWNET},
+	{"ipc", syscall.CLONE_NEWIPC},
+	{"uts", syscall.CLONE_NEWUTS},
+}
+
+var podFilterKeys = map[string]bool{
+	"id":    true,
+	"name":  true,
+	"label": true,
+}
+
+// podInfraMu serializes starting and stopping infra processes, so members
+// that start at the same time join the same one.
+var podInfraMu sync.Mutex
+
+// infraGetter presents pod infra processes to the network setup, which looks
+// the process and its interface up by ID.
+type infraGetter struct {
+	d DaemonInterface
+}
+
+func (g infraGetter) GetContainer(id string) (*types.Container, error) {
+	p, err := g.d.GetPod(id)
+	if err != nil {
+		return nil, err
+	}
+	return &types.Container{ID: p.ID, Name: p.Name, PID: p.InfraPID, NetworkInfo: p.NetworkInfo}, nil
+}
+
+// HandlePodCreate creates a pod and starts its infra process, which holds
+// the namespaces, address and cgroup its containers share.
+func HandlePodCreate(d DaemonInterface, w http.ResponseWriter, r *http.Request) {
+	var request requests.CreatePodRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	resources := types.Resources(request.Resources)
+	if _, err := podLimits(resources); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if request.Name != "" && container.ValidateName(request.Name) != nil {
+		http.Error(w, fmt.Sprintf("invalid pod name %q, only [a-zA-Z0-9][a-zA-Z0-9_.-] are allowed", request.Name), http.StatusBadRequest)
+		return
+	}
+
+	podID := uuid.New().String()
+	networkInfo, err := allocateNetwork(d, podID, "")
+	if err != nil {
+		http.Error(w, "Failed to allocate IP address: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	p := &types.Pod{
+		ID:          podID,
+		Name:        request.Name,
+		Hostname:    request.Hostname,
+		Labels:      request.Labels,
+		Resources:   resources,
+		NetworkInfo: networkInfo,
+		CreatedAt:   time.Now(),
+		Status:      "created",
+	}
+	if err := addPod(d, p); err != nil {
+		d.NetworkManager().IpManager.ReleaseIP(podID)
+		writePodError(w, err)
+		return
+	}
+	if err := pod.SaveState(p); err != nil {
+		log.Printf("Error saving pod state: %v", err)
+	}
+	emitPodEvent(d, p, "create")
+
+	if _, err := ensurePodInfra(d, podID); err != nil {
+		cleanupPod(d, podID)
+		http.Error(w, "Failed to start pod: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]string{"id": p.ID, "name": p.Name})
+}
+
+// addPod registers the pod under its requested name, or a generated one
+// when the request does not carry a name. The hostname defaults to the
+// name.
+func addPod(d DaemonInterface, p *types.Pod) error {
+	hostname := p.Hostname
+	register := func(name string) error {
+		p.Name = name
+		p.Hostname = hostname
+		if hostname == "" {
+			p.Hostname = name
+		}
+		return d.AddPod(p)
+	}
+
+	if p.Name != "" {
+		return register(p.Name)
+	}
+	for attempt := 0; attempt < 10; attempt++ {
+		if register(container.GenerateName()) == nil {
+			return nil
+		}
+	}
+	return register(container.GenerateName() + "_" + p.ID[:8])
+}
+
+func HandlePodList(d DaemonInterface, w http.ResponseWriter, r *http.Request) {
+	filters, err := parseFilters(r.URL.Query().Get("filters"), podFilterKeys)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	matched := []*types.Pod{}
+	for _, p := range d.ListPods() {
+		if !filters.matchAny("id", func(v string) bool { return strings.HasPrefix(p.ID, v) }) {
+			continue
+		}
+		if !filters.matchAny("name", func(v string) bool { return p.Name == v }) {
+			continue
+		}
+		if !filters.matchLabels(p.Labels) {
+			continue
+		}
+		p.Containers = podMemberIDs(d, p.ID)
+		matched = append(matched, p)
+	}
+	writeJSON(w, http.StatusOK, matched)
+}
+
+func HandlePodInspect(d DaemonInterface, w http.ResponseWriter, r *http.Request) {
+	p, err := d.ResolvePod(r.PathValue("id"))
+	if err != nil {
+		writePodError(w, err)
+		return
+	}
+	p.Containers = podMemberIDs(d, p.ID)
+	writeJSON(w, http.StatusOK, p)
+}
+
+// HandlePodStart starts the pod's infra process if needed and then every
+// member that is not running.
+func HandlePodStart(d DaemonInterface, w http.ResponseWriter, r *http.Request) {
+	p, err := d.ResolvePod(r.PathValue("id"))
+	if err != nil {
+		writePodError(w, err)
+		return
+	}
+
+	if _, err := ensurePodInfra(d, p.ID); err != nil {
+		http.Error(w, "Failed to start pod: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var failed []string
+	for _, c := range podMembers(d, p.ID) {
+		if (c.Active() && container.ProcessAlive(c.PID)) || c.Status == "restarting" {
+			continue
+		}
+		if err := launchContainer(d, c); err != nil {
+			log.Printf("Error starting container %s of pod %s: %v", c.ID, p.ID, err)
+			failed = append(failed, c.Name+": "+err.Error())
+		}
+	}
+	if len(failed) > 0 {
+		http.Error(w, "Failed to start containers of pod "+p.Name+": "+strings.Join(failed, "; "), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandlePodStop stops every member of the pod and then its infra process.
+func HandlePodStop(d DaemonInterface, w http.ResponseWriter, r *http.Request) {
+	p, err := d.ResolvePod(r.PathValue("id"))
+	if err != nil {
+		writePodError(w, err)
+		return
+	}
+
+	timeout := defaultStopTimeout
+	if raw := r.URL.Query().Get("t"); raw != "" {
+		seconds, err := strconv.Atoi(raw)
+		if err != nil || seconds < 0 {
+			http.Error(w, "Invalid value for t", http.StatusBadRequest)
+			return
+		}
+		timeout = time.Duration(seconds) * time.Second
+	}
+
+	if err := stopPod(d, p, timeout); err != nil {
+		http.Error(w, "Failed to stop pod: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandlePodUpdate changes the pod's limits. A running pod's cgroup is
+// updated in place, and the limits are kept for later starts.
+func HandlePodUpdate(d DaemonInterface, w http.ResponseWriter, r *http.Request) {
+	p, err := d.ResolvePod(r.PathValue("id"))
+	if err != nil {
+		writePodError(w, err)
+		return
+	}
+
+	var request requests.UpdatePodRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	resources := mergeResources(p.Resources, types.Resources(request.Resources))
+	limits, err := podLimits(resources)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if p.Status == "running" && container.ProcessAlive(p.InfraPID) {
+		log.Printf("Updating resources of pod %s", p.ID)
+		if err := cgroup.UpdatePod(p.ID, limits); err != nil {
+			log.Printf("Error updating cgroup of pod %s: %v", p.ID, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := d.UpdatePod(p.ID, func(p *types.Pod) {
+		p.Resources = resources
+	}); err != nil {
+		writePodError(w, err)
+		return
+	}
+
+	updated, err := d.GetPod(p.ID)
+	if err != nil {
+		writePodError(w, err)
+		return
+	}
+	emitPodEvent(d, updated, "update")
+	updated.Containers = podMemberIDs(d, updated.ID)
+	writeJSON(w, http.StatusOK, updated)
+}
+
+// HandlePodRemove removes the pod together with its containers. A running
+// pod is refused unless force is set.
+func HandlePodRemove(d DaemonInterface, w http.ResponseWriter, r *http.Request) {
+	p, err := d.ResolvePod(r.PathValue("id"))
+	if err != nil {
+		writePodError(w, err)
+		return
+	}
+
+	force, _ := strconv.ParseBool(r.URL.Query().Get("force"))
+	if !force && podRunning(d, p) {
+		http.Error(w, "Pod "+p.Name+" is running: stop it first or use --force", http.StatusConflict)
+		return
+	}
+
+	if err := stopPod(d, p, 0); err != nil {
+		http.Error(w, "Failed to kill pod: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for _, c := range podMembers(d, p.ID) {
+		cleanupContainer(d, c.ID, c.Name)
+	}
+	cleanupPod(d, p.ID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// podRunning reports whether the pod's infra process or any of its members
+// is alive.
+func podRunning(d DaemonInterface, p *types.Pod) bool {
+	if container.ProcessAlive(p.InfraPID) {
+		return true
+	}
+	for _, c := range podMembers(d, p.ID) {
+		if c.Active() && container.ProcessAlive(c.PID) {
+			return true
+		}
+	}
+	return false
+}
+
+// podLimits converts the pod's resource spec to cgroup values. Members keep
+// their own default pids limit, so the pod has none unless one is given.
+func podLimits(spec types.Resources) (cgroup.Resources, error) {
+	limits, err := cgroup.ParseResources(spec)
+	if err == nil && spec.PidsLimit == 0 {
+		limits.PidsMax = -1
+	}
+	return limits, err
+}
+
+// podMembers lists the containers of the pod.
+func podMembers(d DaemonInterface, podID string) []*types.Container {
+	var members []*types.Container
+	for _, c := range d.ListContainers() {
+		if c.Pod == podID {
+			members = append(members, c)
+		}
+	}
+	return members
+}
+
+func podMemberIDs(d DaemonInterface, podID string) []string {
+	ids := []string{}
+	for _, c := range podMembers(d, podID) {
+		ids = append(ids, c.ID)
+	}
+	return ids
+}
+
+// podNamespaces are the namespaces of the infra process infraPID that the
+// pod's members join.
+func podNamespaces(infraPID int) []container.JoinNamespace {
+	var join []container.JoinNamespace
+	for _, ns := range podNamespaceTypes {
+		join = append(join, container.ProcessNamespace(infraPID, ns.name, ns.flag))
+	}
+	return join
+}
+
+// ensurePodInfra returns the PID of the pod's infra process, starting it
+// first when the pod is not running.
+func ensurePodInfra(d DaemonInterface, podID string) (int, error) {
+	podInfraMu.Lock()
+	defer podInfraMu.Unlock()
+
+	p, err := d.GetPod(podID)
+	if err != nil {
+		return 0, fmt.Errorf("pod %s: %w", podID, err)
+	}
+	if p.Status == "running" && container.ProcessAlive(p.InfraPID) {
+		return p.InfraPID, nil
+	}
+	return startPodInfra(d, p)
+}
+
+// startPodInfra launches the pod's infra process in new network, IPC and
+// UTS namespaces, connects it to the bridge with the pod's address and moves
+// it into the pod's cgroup, which carries the pod's limits.
+func startPodInfra(d DaemonInterface, p *types.Pod) (int, error) {
+	networkMgr := d.NetworkManager()
+
+	limits, err := podLimits(p.Resources)
+	if err != nil {
+		return 0, err
+	}
+
+	hostVeth, containerVeth, err := networkMgr.VethManager.CreateVethPairAndAttachToHostBridge(p.ID, networkMgr.BridgeManager)
+	if err != nil {
+		log.Printf("Error creating veth pair for pod %s: %v\n", p.ID, err)
+		return 0, err
+	}
+
+	cmd := exec.Command("/usr/local/bin/boxify-init", "pause", p.Hostname)
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags: syscall.CLONE_NEWNET | syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS,
+	}
+	if err := cmd.Start(); err != nil {
+		log.Printf("Error starting infra process of pod %s: %v\n", p.ID, err)
+		return 0, err
+	}
+	pid := cmd.Process.Pid
+
+	err = d.UpdatePod(p.ID, func(p *types.Pod) {
+		networkInfo := *p.NetworkInfo
+		networkInfo.HostVeth = hostVeth
+		networkInfo.ContainerVeth = containerVeth
+
+		p.InfraPID = pid
+		p.NetworkInfo = &networkInfo
+		p.Status = "running"
+	})
+	if err != nil {
+		log.Printf("Error saving pod state: %v\n", err)
+	}
+
+	abort := func(err error) (int, error) {
+		syscall.Kill(pid, syscall.SIGKILL)
+		cmd.Wait()
+		d.UpdatePod(p.ID, func(p *types.Pod) {
+			p.Status = "exited"
+		})
+		return 0, err
+	}
+
+	log.Printf("Setting up interface for pod %s\n", p.ID)
+	if err := networkMgr.SetupContainerInterface(p.ID, infraGetter{d}, containerVeth); err != nil {
+		log.Printf("Error setting up pod interface: %v\n", err)
+		return abort(err)
+	}
+	if err := cgroup.SetupPod(p.ID, pid, limits); err != nil {
+		log.Printf("Error setting up pod cgroup: %v\n", err)
+		return abort(err)
+	}
+
+	go supervisePodInfra(d, p.ID, cmd)
+	emitPodEvent(d, p, "start")
+	return pid, nil
+}
+
+// supervisePodInfra marks the pod exited once its infra process is gone.
+func supervisePodInfra(d DaemonInterface, podID string, cmd *exec.Cmd) {
+	pid := cmd.Process.Pid
+	if err := cmd.Wait(); err != nil {
+		log.Printf("Infra process of pod %s (PID %d) exited: %v", podID, pid, err)
+	}
+	podInfraExited(d, podID, pid)
+}
+
+// watchAdoptedPodInfra follows an infra process started by a previous
+// daemon instance.
+func watchAdoptedPodInfra(d DaemonInterface, podID string, pid int) {
+	for container.ProcessAlive(pid) {
+		time.Sleep(time.Second)
+	}
+	log.Printf("Infra process of pod %s (PID %d) exited", podID, pid)
+	podInfraExited(d, podID, pid)
+}
+
+func podInfraExited(d DaemonInterface, podID string, pid int) {
+	exited := false
+	d.UpdatePod(podID, func(p *types.Pod) {
+		// The pod may have a new infra process by now.
+		if p.InfraPID == pid && p.Status == "running" {
+			p.Status = "exited"
+			exited = true
+		}
+	})
+	if p, err := d.GetPod(podID); err == nil && exited {
+		emitPodEvent(d, p, "die")
+	}
+}
+
+// RecoverPods is called once when the daemon starts, before
+// RecoverContainers, and watches the infra processes that are still
+// running.
+func RecoverPods(d DaemonInterface) {
+	for _, p := range d.ListPods() {
+		if p.Status == "running" && container.ProcessAlive(p.InfraPID) {
+			go watchAdoptedPodInfra(d, p.ID, p.InfraPID)
+		}
+	}
+}
+
+// stopPod stops the pod's members with timeout and then its infra process.
+func stopPod(d DaemonInterface, p *types.Pod, timeout time.Duration) error {
+	for _, c := range podMembers(d, p.ID) {
+		if err := stopContainer(d, c, timeout); err != nil {
+			return fmt.Errorf("stopping container %s: %w", c.Name, err)
+		}
+	}
+
+	podInfraMu.Lock()
+	defer podInfraMu.Unlock()
+
+	p, err := d.GetPod(p.ID)
+	if err != nil {
+		return err
+	}
+	if container.ProcessAlive(p.InfraPID) {
+		log.Printf("Stopping infra process of pod %s (PID %d)", p.ID, p.InfraPID)
+		if err := syscall.Kill(p.InfraPID, syscall.SIGTERM); err != nil && err != syscall.ESRCH {
+			return err
+		}
+		if !waitForExit(p.InfraPID, 5*time.Second) {
+			if err := syscall.Kill(p.InfraPID, syscall.SIGKILL); err != nil && err != syscall.ESRCH {
+				return err
+			}
+			if !waitForExit(p.InfraPID, 5*time.Second) {
+				return errors.New("pod infra process did not exit after SIGKILL")
+			}
+		}
+	}
+
+	wasRunning := p.Status == "running"
+	d.UpdatePod(p.ID, func(p *types.Pod) {
+		p.Status = "exited"
+	})
+	if wasRunning {
+		emitPodEvent(d, p, "stop")
+	}
+	return nil
+}
+
+// cleanupPod releases the pod's veth pair, address, cgroup and state. Its
+// members must be removed first.
+func cleanupPod(d DaemonInterface, podID string) {
+	log.Printf("Removing pod %s", podID)
+
+	p, _ := d.GetPod(podID)
+	if p != nil && container.ProcessAlive(p.InfraPID) {
+		syscall.Kill(p.InfraPID, syscall.SIGKILL)
+		waitForExit(p.InfraPID, 5*time.Second)
+	}
+
+	networkMgr := d.NetworkManager()
+	if err := networkMgr.VethManager.DeleteVethPair(podID); err != nil {
+		log.Printf("Error deleting veth pair: %v", err)
+	}
+	if err := networkMgr.IpManager.ReleaseIP(podID); err != nil {
+		log.Printf("Error releasing IP: %v", err)
+	}
+	if err := cgroup.RemovePod(podID); err != nil {
+		log.Printf("Error removing pod cgroup: %v", err)
+	}
+	if err := pod.RemoveState(podID); err != nil {
+		log.Printf("Error removing pod state: %v", err)
+	}
+
+	d.RemovePod(podID)
+	if p != nil {
+		emitPodEvent(d, p, "remove")
+	}
+}
+
+func emitPodEvent(d DaemonInterface, p *types.Pod, action string) {
+	d.Events().Publish(events.NewLabelled("pod", action, p.ID, p.Labels, map[string]string{"name": p.Name}))
+}
+
+// writePodError maps pod lookup and naming errors to HTTP status codes.
+func writePodError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, types.ErrPodNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, types.ErrPodNameInUse):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/urizennnn/boxify/pkg/cgroup"
+	"github.com/urizennnn/boxify/pkg/container"
+	"github.com/urizennnn/boxify/pkg/network"
+)
+
+func HandleRemove(d DaemonInterface, w http.ResponseWriter, r *http.Request) {
+	c, err := d.ResolveContainer(r.PathValue("id"))
+	if err != nil {
+		writeContainerError(w, err)
+		return
+	}
+
+	force, _ := strconv.ParseBool(r.URL.Query().Get("force"))
+	if c.Active() && container.ProcessAlive(c.PID) {
+		if !force {
+			http.Error(w, "Container "+c.Name+" is running: stop it first or use --force", http.StatusConflict)
+			return
+		}
+		if err := stopContainer(d, c, 0); err != nil {
+			http.Error(w, "Failed to kill container: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	cleanupContainer(d, c.ID, c.Name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+var pruneFilterKeys = map[string]bool{
+	"label": true,
+}
+
+// HandlePrune removes every exited container matching the filters.
+func HandlePrune(d DaemonInterface, w http.ResponseWriter, r *http.Request) {
+	filters, err := parseFilters(r.URL.Query().Get("filters"), pruneFilterKeys)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	deleted := []string{}
+	for _, c := range d.ListContainers() {
+		if c.Status != "exited" || !filters.matchLabels(c.Labels) {
+			continue
+		}
+		cleanupContainer(d, c.ID, c.Name)
+		deleted = append(deleted, c.ID)
+	}
+	writeJSON(w, http.StatusOK, map[string][]string{"deleted": deleted})
+}
+
+// cleanupContainer releases everything the daemon holds for a container:
+// its process, volume mounts, cgroup, overlay, veth pair, IP, state and
+// name. Errors are logged so a half-created container can still be torn
+// down.
+func cleanupContainer(d DaemonInterface, containerID, name string) {
+	log.Printf("Removing container %s", containerID)
+
+	c, err := d.GetContainer(containerID)
+	if err == nil {
+		if container.ProcessAlive(c.PID) {
+			if err := stopContainer(d, c, 0); err != nil {
+				log.Printf("Error killing container %s: %v", containerID, err)
+			}
+		}
+		unmountVolumes(c.Mounts, container.MergedDir(containerID))
+		emitNetworkEvent(d, c, "disconnect")
+	}
+
+	// A macvlan or ipvlan interface goes away with the container's network
+	// namespace. Pod members and containers sharing a network namespace use
+	// the veth pair and address of its owner.
+	if c != nil && onSubInterface(c) {
+		if err := network.ReleaseNetworkIP(c.NetworkInfo.Network, containerID); err != nil {
+			log.Printf("Error releasing IP: %v", err)
+		}
+	} else if c == nil || ownsNetwork(c) {
+		networkMgr := d.NetworkManager()
+		if err := networkMgr.VethManager.DeleteVethPair(containerID); err != nil {
+			log.Printf("Error deleting veth pair: %v", err)
+		}
+		if err := networkMgr.IpManager.ReleaseIP(containerID); err != nil {
+			log.Printf("Error releasing IP: %v", err)
+		}
+	}
+	if err := cgroup.RemoveCgroup(containerID); err != nil {
+		log.Printf("Error removing cgroup: %v", err)
+	}
+	forgetOOMBaseline(containerID)
+	if err := container.RemoveOverlayFS(containerID); err != nil {
+		log.Printf("Error removing overlay: %v", err)
+	}
+	if err := container.RemoveState(containerID); err != nil {
+		log.Printf("Error removing state: %v", err)
+	}
+
+	d.RemoveContainer(containerID)
+	d.ReleaseName(name)
+	if c != nil {
+		emitContainerEvent(d, c, "destroy", nil)
+	}
+}
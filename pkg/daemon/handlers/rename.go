@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/urizennnn/boxify/pkg/container"
+)
+
+func HandleRename(d DaemonInterface, w http.ResponseWriter, r *http.Request) {
+	c, err := d.ResolveContainer(r.PathValue("id"))
+	if err != nil {
+		writeContainerError(w, err)
+		return
+	}
+
+	newName := r.URL.Query().Get("name")
+	if err := container.ValidateName(newName); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := d.RenameContainer(c.ID, newName); err != nil {
+		writeContainerError(w, err)
+		return
+	}
+	oldName := c.Name
+	c.Name = newName
+	emitContainerEvent(d, c, "rename", map[string]string{"oldName": oldName})
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/urizennnn/boxify/pkg/container"
+	"github.com/urizennnn/boxify/pkg/daemon/types"
+)
+
+const (
+	initialRestartDelay = 100 * time.Millisecond
+	maxRestartDelay     = time.Minute
+
+	// A container that stayed up this long is considered healthy again and
+	// its backoff starts over.
+	restartResetAfter = 10 * time.Second
+)
+
+// restartDelays remembers the last backoff delay per container ID.
+var restartDelays sync.Map
+
+// parseRestartPolicy parses "no", "always", "unless-stopped" and
+// "on-failure[:N]".
+func parseRestartPolicy(raw string) (types.RestartPolicy, error) {
+	name, count, hasCount := strings.Cut(raw, ":")
+	switch name {
+	case "", "no":
+		if hasCount {
+			break
+		}
+		return types.RestartPolicy{Name: "no"}, nil
+	case "always", "unless-stopped":
+		if hasCount {
+			break
+		}
+		return types.RestartPolicy{Name: name}, nil
+	case "on-failure":
+		policy := types.RestartPolicy{Name: name}
+		if hasCount {
+			retries, err := strconv.Atoi(count)
+			if err != nil || retries < 0 {
+				return types.RestartPolicy{}, fmt.Errorf("invalid restart policy %q: maximum retry count must be a non-negative integer", raw)
+			}
+			policy.MaximumRetryCount = retries
+		}
+		return policy, nil
+	}
+	return types.RestartPolicy{}, fmt.Errorf("invalid restart policy %q, expected no, always, unless-stopped or on-failure[:N]", raw)
+}
+
+// superviseContainer waits for the container's process and applies its
+// restart policy once it exits.
+func superviseContainer(d DaemonInterface, containerID string, cmd *exec.Cmd) {
+	pid := cmd.Process.Pid
+	err := cmd.Wait()
+	if err != nil {
+		log.Printf("Container %s (PID %d) exited with error: %v", containerID, pid, err)
+	} else {
+		log.Printf("Container %s (PID %d) exited successfully", containerID, pid)
+	}
+	handleContainerExit(d, containerID, exitCode(err))
+}
+
+// watchAdoptedContainer follows a container whose process was started by a
+// previous daemon instance. It is not our child, so its exit is detected by
+// polling and the exit code is unknown.
+func watchAdoptedContainer(d DaemonInterface, containerID string, pid int) {
+	for container.ProcessAlive(pid) {
+		time.Sleep(time.Second)
+	}
+	log.Printf("Container %s (PID %d) exited", containerID, pid)
+	handleContainerExit(d, containerID, -1)
+}
+
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return -1
+	}
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+	return exitErr.ExitCode()
+}
+
+func handleContainerExit(d DaemonInterface, containerID string, code int) {
+	// Pick up an OOM kill before the watcher does so the die event and exit
+	// state already reflect it.
+	recordOOMEvents(d, containerID)
+
+	neverStarted := false
+	err := d.UpdateContainer(containerID, func(c *types.Container) {
+		neverStarted = c.Status == "created"
+		c.ExitCode = code
+		c.FinishedAt = time.Now()
+		c.Status = "exited"
+		c.Cmd = nil
+	})
+	if err != nil {
+		// The container was removed while it was running.
+		restartDelays.Delete(containerID)
+		return
+	}
+
+	c, err := d.GetContainer(containerID)
+	if err != nil {
+		restartDelays.Delete(containerID)
+		return
+	}
+	emitContainerEvent(d, c, "die", map[string]string{
+		"exitCode":  strconv.Itoa(code),
+		"oomKilled": strconv.FormatBool(c.OOMKilled),
+	})
+
+	if neverStarted || !shouldRestart(c) {
+		restartDelays.Delete(containerID)
+		return
+	}
+
+	delay := nextRestartDelay(c)
+	log.Printf("Restarting container %s in %s (policy %s, restart count %d)", containerID, delay, c.RestartPolicy.Name, c.RestartCount)
+	d.SetContainerStatus(containerID, "restarting")
+	time.Sleep(delay)
+
+	c, err = d.GetContainer(containerID)
+	if err != nil {
+		return
+	}
+	if c.ManuallyStopped || c.Status != "restarting" {
+		log.Printf("Container %s was stopped while waiting to restart", containerID)
+		d.SetContainerStatus(containerID, "exited")
+		return
+	}
+
+	d.UpdateContainer(containerID, func(c *types.Container) {
+		c.RestartCount++
+	})
+	emitContainerEvent(d, c, "restart", nil)
+	if _, err := startContainer(d, containerID); err != nil {
+		log.Printf("Error restarting container %s: %v", containerID, err)
+		d.SetContainerStatus(containerID, "exited")
+	}
+}
+
+func shouldRestart(c *types.Container) bool {
+	if c.ManuallyStopped {
+		return false
+	}
+
+	switch c.RestartPolicy.Name {
+	case "always", "unless-stopped":
+		return true
+	case "on-failure":
+		if c.ExitCode == 0 {
+			return false
+		}
+		max := c.RestartPolicy.MaximumRetryCount
+		return max == 0 || c.RestartCount < max
+	default:
+		return false
+	}
+}
+
+// nextRestartDelay doubles the previous delay up to maxRestartDelay, starting
+// over when the container ran long enough.
+func nextRestartDelay(c *types.Container) time.Duration {
+	delay := initialRestartDelay
+	if previous, ok := restartDelays.Load(c.ID); ok && c.FinishedAt.Sub(c.StartedAt) < restartResetAfter {
+		delay = min(previous.(time.Duration)*2, maxRestartDelay)
+	}
+	restartDelays.Store(c.ID, delay)
+	return delay
+}
+
+// RecoverContainers is called once when the daemon starts. Containers that
+// are still running are watched again, and exited containers whose policy
+// asks for it are started.
+func RecoverContainers(d DaemonInterface) {
+	for _, c := range d.ListContainers() {
+		if c.Active() && container.ProcessAlive(c.PID) {
+			go watchAdoptedContainer(d, c.ID, c.PID)
+			resetOOMBaseline(c.ID)
+			go watchOOM(d, c.ID, c.PID)
+			go monitorHealth(d, c.ID, c.PID)
+			continue
+		}
+
+		// A created container's init exits with the daemon that waited on
+		// it; the container stays created until it is started.
+		if c.Status == "created" {
+			continue
+		}
+
+		restart := false
+		switch c.RestartPolicy.Name {
+		case "always":
+			restart = true
+		case "unless-stopped":
+			restart = !c.ManuallyStopped
+		}
+		if !restart {
+			continue
+		}
+
+		log.Printf("Starting container %s (restart policy %s)", c.ID, c.RestartPolicy.Name)
+		if _, err := startContainer(d, c.ID); err != nil {
+			log.Printf("Error starting container %s: %v", c.ID, err)
+		}
+	}
+}
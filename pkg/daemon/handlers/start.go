@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"syscall"
+
+	"github.com/urizennnn/boxify/pkg/container"
+	"github.com/urizennnn/boxify/pkg/daemon/types"
+)
+
+// HandleStart runs a created container's command. A container that exited
+// is set up again and started, like a restart.
+func HandleStart(d DaemonInterface, w http.ResponseWriter, r *http.Request) {
+	c, err := d.ResolveContainer(r.PathValue("id"))
+	if err != nil {
+		writeContainerError(w, err)
+		return
+	}
+
+	switch {
+	case c.Active() && container.ProcessAlive(c.PID):
+		http.Error(w, "Container "+c.Name+" is already running", http.StatusConflict)
+		return
+	case c.Status == "restarting":
+		http.Error(w, "Container "+c.Name+" is restarting", http.StatusConflict)
+		return
+	}
+
+	if err := launchContainer(d, c); err != nil {
+		http.Error(w, "Failed to start container: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// launchContainer runs a container that is not running: a created one whose
+// init waits is let go, anything else is set up again and started.
+func launchContainer(d DaemonInterface, c *types.Container) error {
+	if _, ok := pendingStarts.Load(c.ID); ok {
+		_, err := startProcess(d, c.ID)
+		return err
+	}
+
+	// A created container whose init no longer waits, for example after a
+	// daemon restart, is set up from scratch.
+	if c.Status == "created" && container.ProcessAlive(c.PID) {
+		syscall.Kill(c.PID, syscall.SIGKILL)
+		waitForExit(c.PID, defaultStopTimeout)
+	}
+
+	log.Printf("Starting container %s", c.ID)
+	d.UpdateContainer(c.ID, func(c *types.Container) {
+		c.ManuallyStopped = false
+	})
+	if _, err := startContainer(d, c.ID); err != nil {
+		d.SetContainerStatus(c.ID, "exited")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/urizennnn/boxify/pkg/cgroup"
+	"github.com/urizennnn/boxify/pkg/container"
+	"github.com/urizennnn/boxify/pkg/daemon/types"
+)
+
+const defaultStopTimeout = 10 * time.Second
+
+func HandleStop(d DaemonInterface, w http.ResponseWriter, r *http.Request) {
+	c, err := d.ResolveContainer(r.PathValue("id"))
+	if err != nil {
+		writeContainerError(w, err)
+		return
+	}
+
+	timeout := defaultStopTimeout
+	if raw := r.URL.Query().Get("t"); raw != "" {
+		seconds, err := strconv.Atoi(raw)
+		if err != nil || seconds < 0 {
+			http.Error(w, "Invalid value for t", http.StatusBadRequest)
+			return
+		}
+		timeout = time.Duration(seconds) * time.Second
+	}
+
+	if err := stopContainer(d, c, timeout); err != nil {
+		http.Error(w, "Failed to stop container: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// stopContainer sends SIGTERM to the container's init process and falls back
+// to SIGKILL once the timeout expires.
+func stopContainer(d DaemonInterface, c *types.Container, timeout time.Duration) error {
+	// Mark the stop first so the supervisor does not restart the container.
+	d.UpdateContainer(c.ID, func(c *types.Container) {
+		c.ManuallyStopped = true
+	})
+
+	if c.Status == "created" && container.ProcessAlive(c.PID) {
+		// Init still waits for start, the command never ran.
+		log.Printf("Killing created container %s (PID %d)", c.ID, c.PID)
+		discardPendingStart(c.ID)
+		if err := syscall.Kill(c.PID, syscall.SIGKILL); err != nil && err != syscall.ESRCH {
+			return err
+		}
+		if !waitForExit(c.PID, 5*time.Second) {
+			return errors.New("container init did not exit after SIGKILL")
+		}
+		d.SetContainerStatus(c.ID, "exited")
+		return nil
+	}
+
+	if !c.Active() || !container.ProcessAlive(c.PID) {
+		d.SetContainerStatus(c.ID, "exited")
+		return nil
+	}
+
+	// Frozen processes cannot act on SIGTERM.
+	if c.Status == "paused" {
+		if err := cgroup.Thaw(c.ID); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("Stopping container %s (PID %d)", c.ID, c.PID)
+	if err := syscall.Kill(c.PID, syscall.SIGTERM); err != nil && err != syscall.ESRCH {
+		return err
+	}
+
+	emitContainerEvent(d, c, "kill", map[string]string{"signal": "15"})
+
+	if !waitForExit(c.PID, timeout) {
+		log.Printf("Container %s did not stop within %s, killing it", c.ID, timeout)
+		emitContainerEvent(d, c, "kill", map[string]string{"signal": "9"})
+		if err := syscall.Kill(c.PID, syscall.SIGKILL); err != nil && err != syscall.ESRCH {
+			return err
+		}
+		if !waitForExit(c.PID, 5*time.Second) {
+			return errors.New("container did not exit after SIGKILL")
+		}
+	}
+
+	d.SetContainerStatus(c.ID, "exited")
+	emitContainerEvent(d, c, "stop", nil)
+	return nil
+}
+
+func waitForExit(pid int, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		if !container.ProcessAlive(pid) {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
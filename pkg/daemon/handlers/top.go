@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/urizennnn/boxify/pkg/cgroup"
+	"github.com/urizennnn/boxify/pkg/container"
+	"github.com/urizennnn/boxify/pkg/daemon/types"
+)
+
+// HandleTop lists the processes in a container's cgroup.
+func HandleTop(d DaemonInterface, w http.ResponseWriter, r *http.Request) {
+	c, err := d.ResolveContainer(r.PathValue("id"))
+	if err != nil {
+		writeContainerError(w, err)
+		return
+	}
+
+	if !c.Active() {
+		http.Error(w, "Container "+c.Name+" is not running", http.StatusConflict)
+		return
+	}
+
+	pids, err := cgroup.Procs(c.ID)
+	if err != nil {
+		http.Error(w, "Failed to list processes: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	processes := []types.Process{}
+	for _, pid := range pids {
+		p, err := container.ReadProcess(c.ID, pid)
+		if err != nil {
+			// The process exited while we were reading it.
+			log.Printf("Skipping pid %d of container %s: %v", pid, c.ID, err)
+			continue
+		}
+		processes = append(processes, p)
+	}
+	writeJSON(w, http.StatusOK, processes)
+}
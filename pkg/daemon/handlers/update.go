@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/urizennnn/boxify/pkg/cgroup"
+	"github.com/urizennnn/boxify/pkg/container"
+	"github.com/urizennnn/boxify/pkg/daemon/requests"
+	"github.com/urizennnn/boxify/pkg/daemon/types"
+)
+
+// HandleUpdate changes a container's resource limits and restart policy. A
+// running container's cgroup is updated in place; the new spec is persisted
+// either way and used on every later start.
+func HandleUpdate(d DaemonInterface, w http.ResponseWriter, r *http.Request) {
+	c, err := d.ResolveContainer(r.PathValue("id"))
+	if err != nil {
+		writeContainerError(w, err)
+		return
+	}
+
+	var request requests.UpdateContainerRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	resources := mergeResources(c.Resources, types.Resources(request.Resources))
+	limits, err := cgroup.ParseResources(resources)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	restartPolicy := c.RestartPolicy
+	if request.RestartPolicy != "" {
+		if restartPolicy, err = parseRestartPolicy(request.RestartPolicy); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if c.Active() && container.ProcessAlive(c.PID) {
+		if !request.Force {
+			if err := checkResourceUsage(c.ID, limits); err != nil {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			}
+		}
+
+		log.Printf("Updating resources of container %s", c.ID)
+		if err := cgroup.Update(c.ID, limits); err != nil {
+			log.Printf("Error updating cgroup of container %s: %v", c.ID, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	err = d.UpdateContainer(c.ID, func(c *types.Container) {
+		c.Resources = resources
+		c.RestartPolicy = restartPolicy
+	})
+	if err != nil {
+		writeContainerError(w, err)
+		return
+	}
+
+	updated, err := d.GetContainer(c.ID)
+	if err != nil {
+		writeContainerError(w, err)
+		return
+	}
+	emitContainerEvent(d, updated, "update", nil)
+	writeJSON(w, http.StatusOK, updated)
+}
+
+// mergeResources applies the fields set in update on top of current.
+func mergeResources(current, update types.Resources) types.Resources {
+	merged := current
+
+	setString := func(target *string, value string) {
+		if value != "" {
+			*target = value
+		}
+	}
+	setInt := func(target *int, value int) {
+		if value != 0 {
+			*target = value
+		}
+	}
+	setList := func(target *[]string, value []string) {
+		if value != nil {
+			*target = value
+		}
+	}
+
+	setString(&merged.MemoryLimit, update.MemoryLimit)
+	setString(&merged.MemoryReservation, update.MemoryReservation)
+	setString(&merged.MemorySwap, update.MemorySwap)
+
+	// cpus, cpu_quota and cpu_limit are alternatives, setting one replaces
+	// the others.
+	if update.Cpus != "" || update.CpuQuota != 0 || update.CpuLimit != "" {
+		merged.Cpus = update.Cpus
+		merged.CpuQuota = update.CpuQuota
+		merged.CpuLimit = update.CpuLimit
+	}
+	setInt(&merged.CpuShares, update.CpuShares)
+	setInt(&merged.CpuPeriod, update.CpuPeriod)
+	setString(&merged.CpusetCpus, update.CpusetCpus)
+	setString(&merged.CpusetMems, update.CpusetMems)
+
+	setInt(&merged.IOWeight, update.IOWeight)
+	setList(&merged.DeviceReadBps, update.DeviceReadBps)
+	setList(&merged.DeviceWriteBps, update.DeviceWriteBps)
+	setList(&merged.DeviceReadIOps, update.DeviceReadIOps)
+	setList(&merged.DeviceWriteIOps, update.DeviceWriteIOps)
+
+	setInt(&merged.PidsLimit, update.PidsLimit)
+	return merged
+}
+
+// checkResourceUsage refuses limits the container already exceeds, which
+// would make the kernel reclaim or OOM-kill it straight away.
+func checkResourceUsage(containerID string, limits cgroup.Resources) error {
+	if limits.MemoryMax > 0 {
+		current, err := cgroup.MemoryCurrent(containerID)
+		if err != nil {
+			return err
+		}
+		if current > limits.MemoryMax {
+			return fmt.Errorf("memory limit of %d bytes is below the current usage of %d bytes, use force to apply it anyway", limits.MemoryMax, current)
+		}
+	}
+
+	if limits.PidsMax > 0 {
+		current, err := cgroup.PidsCurrent(containerID)
+		if err != nil {
+			return err
+		}
+		if current > limits.PidsMax {
+			return fmt.Errorf("pids limit of %d is below the current %d processes, use force to apply it anyway", limits.PidsMax, current)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/urizennnn/boxify/pkg/daemon/requests"
+	"github.com/urizennnn/boxify/pkg/volume"
+)
+
+var volumeFilterKeys = map[string]bool{
+	"name":     true,
+	"label":    true,
+	"dangling": true,
+}
+
+func HandleVolumeCreate(d DaemonInterface, w http.ResponseWriter, r *http.Request) {
+	var request requests.CreateVolumeRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	v, err := volume.Create(request.Name, request.Labels, request.Annotations)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	emitVolumeEvent(d, v.Name, "create", v.Labels)
+	writeJSON(w, http.StatusCreated, v)
+}
+
+func HandleVolumeList(d DaemonInterface, w http.ResponseWriter, r *http.Request) {
+	filters, err := parseFilters(r.URL.Query().Get("filters"), volumeFilterKeys)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	matched, err := matchingVolumes(d, filters)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, matched)
+}
+
+func HandleVolumeInspect(d DaemonInterface, w http.ResponseWriter, r *http.Request) {
+	v, err := volume.Get(r.PathValue("name"))
+	if err != nil {
+		writeVolumeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, v)
+}
+
+func HandleVolumeRemove(d DaemonInterface, w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	force, _ := strconv.ParseBool(r.URL.Query().Get("force"))
+
+	if users := volumeUsers(d)[name]; len(users) > 0 && !force {
+		http.Error(w, volume.ErrVolumeInUse.Error()+" by container "+users[0], http.StatusConflict)
+		return
+	}
+
+	v, err := volume.Get(name)
+	if err != nil {
+		writeVolumeError(w, err)
+		return
+	}
+	if err := volume.Remove(name); err != nil {
+		writeVolumeError(w, err)
+		return
+	}
+	emitVolumeEvent(d, name, "destroy", v.Labels)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleVolumePrune removes every volume that no container references and
+// that matches the filters.
+func HandleVolumePrune(d DaemonInterface, w http.ResponseWriter, r *http.Request) {
+	filters, err := parseFilters(r.URL.Query().Get("filters"), volumeFilterKeys)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filters["dangling"] = []string{"true"}
+
+	matched, err := matchingVolumes(d, filters)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	deleted := []string{}
+	for _, v := range matched {
+		if err := volume.Remove(v.Name); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		emitVolumeEvent(d, v.Name, "destroy", v.Labels)
+		deleted = append(deleted, v.Name)
+	}
+	writeJSON(w, http.StatusOK, map[string][]string{"deleted": deleted})
+}
+
+func matchingVolumes(d DaemonInterface, filters Filters) ([]*volume.Volume, error) {
+	volumes, err := volume.List()
+	if err != nil {
+		return nil, err
+	}
+
+	users := volumeUsers(d)
+	matched := []*volume.Volume{}
+	for _, v := range volumes {
+		if !filters.matchAny("name", func(value string) bool { return v.Name == value }) {
+			continue
+		}
+		if !filters.matchAny("dangling", func(value string) bool {
+			dangling, err := strconv.ParseBool(value)
+			return err == nil && dangling == (len(users[v.Name]) == 0)
+		}) {
+			continue
+		}
+		if !filters.matchLabels(v.Labels) {
+			continue
+		}
+		matched = append(matched, v)
+	}
+	return matched, nil
+}
+
+// volumeUsers maps each volume name to the containers that mount it.
+func volumeUsers(d DaemonInterface) map[string][]string {
+	users := make(map[string][]string)
+	for _, c := range d.ListContainers() {
+		for _, m := range c.Mounts {
+			users[m.Name] = append(users[m.Name], c.Name)
+		}
+	}
+	return users
+}
+
+func writeVolumeError(w http.ResponseWriter, err error) {
+	if errors.Is(err, volume.ErrVolumeNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		http.Error(w, "Failed to write response", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,96 @@
+package daemon
+
+import (
+	"log"
+	"strings"
+
+	"github.com/urizennnn/boxify/pkg/container"
+	"github.com/urizennnn/boxify/pkg/daemon/types"
+)
+
+// ReserveName claims name for the container with the given ID. Names are
+// unique across all containers the daemon knows about, running or not.
+func (d *Daemon) ReserveName(name, id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if owner, taken := d.names[name]; taken && owner != id {
+		return types.ErrNameInUse
+	}
+	d.names[name] = id
+	return nil
+}
+
+func (d *Daemon) ReleaseName(name string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.names, name)
+}
+
+// ResolveContainer looks a container up by full ID, name or unique ID prefix,
+// in that order, and returns a snapshot of it.
+func (d *Daemon) ResolveContainer(ref string) (*types.Container, error) {
+	c, err := d.resolveContainer(ref)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := *c
+	return &snapshot, nil
+}
+
+func (d *Daemon) resolveContainer(ref string) (*types.Container, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if ref == "" {
+		return nil, types.ErrContainerNotFound
+	}
+
+	if c, exists := d.containers[ref]; exists {
+		return c, nil
+	}
+
+	if id, exists := d.names[ref]; exists {
+		if c, exists := d.containers[id]; exists {
+			return c, nil
+		}
+	}
+
+	var match *types.Container
+	for id, c := range d.containers {
+		if !strings.HasPrefix(id, ref) {
+			continue
+		}
+		if match != nil {
+			return nil, types.ErrAmbiguousContainer
+		}
+		match = c
+	}
+	if match == nil {
+		return nil, types.ErrContainerNotFound
+	}
+	return match, nil
+}
+
+func (d *Daemon) RenameContainer(id, newName string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	c, exists := d.containers[id]
+	if !exists {
+		return types.ErrContainerNotFound
+	}
+	if owner, taken := d.names[newName]; taken && owner != id {
+		return types.ErrNameInUse
+	}
+
+	delete(d.names, c.Name)
+	d.names[newName] = id
+	c.Name = newName
+
+	if err := container.SaveState(c); err != nil {
+		log.Printf("Error saving container state: %v", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package daemon
+
+import (
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/urizennnn/boxify/pkg/container"
+	"github.com/urizennnn/boxify/pkg/daemon/types"
+	"github.com/urizennnn/boxify/pkg/pod"
+)
+
+// restorePods loads persisted pod records and marks the pods whose infra
+// process is gone as exited.
+func (d *Daemon) restorePods() {
+	states, err := pod.LoadStates()
+	if err != nil {
+		log.Printf("Warning: couldn't load pod state: %v", err)
+		return
+	}
+
+	for _, p := range states {
+		if p.Status == "running" && !container.ProcessAlive(p.InfraPID) {
+			log.Printf("Pod %s (infra PID %d) is no longer running", p.ID, p.InfraPID)
+			p.Status = "exited"
+			if err := pod.SaveState(p); err != nil {
+				log.Printf("Error saving pod state: %v", err)
+			}
+		}
+		d.pods[p.ID] = p
+	}
+	log.Printf("Restored %d pods from state", len(states))
+}
+
+// AddPod registers a new pod. Pod names are unique among pods.
+func (d *Daemon) AddPod(p *types.Pod) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, existing := range d.pods {
+		if existing.Name == p.Name {
+			return types.ErrPodNameInUse
+		}
+	}
+	d.pods[p.ID] = p
+	return nil
+}
+
+// GetPod returns a snapshot of the pod with the given ID.
+func (d *Daemon) GetPod(id string) (*types.Pod, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	p, exists := d.pods[id]
+	if !exists {
+		return nil, types.ErrPodNotFound
+	}
+	snapshot := *p
+	return &snapshot, nil
+}
+
+// ResolvePod looks a pod up by full ID, name or unique ID prefix, in that
+// order, and returns a snapshot of it.
+func (d *Daemon) ResolvePod(ref string) (*types.Pod, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if ref == "" {
+		return nil, types.ErrPodNotFound
+	}
+	if p, exists := d.pods[ref]; exists {
+		snapshot := *p
+		return &snapshot, nil
+	}
+	for _, p := range d.pods {
+		if p.Name == ref {
+			snapshot := *p
+			return &snapshot, nil
+		}
+	}
+
+	var match *types.Pod
+	for id, p := range d.pods {
+		if !strings.HasPrefix(id, ref) {
+			continue
+		}
+		if match != nil {
+			return nil, types.ErrAmbiguousPod
+		}
+		match = p
+	}
+	if match == nil {
+		return nil, types.ErrPodNotFound
+	}
+	snapshot := *match
+	return &snapshot, nil
+}
+
+// ListPods returns a snapshot of all pods, newest first.
+func (d *Daemon) ListPods() []*types.Pod {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	list := make([]*types.Pod, 0, len(d.pods))
+	for _, p := range d.pods {
+		snapshot := *p
+		list = append(list, &snapshot)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt.After(list[j].CreatedAt)
+	})
+	return list
+}
+
+// UpdatePod applies update to the pod under the daemon lock and persists
+// the result.
+func (d *Daemon) UpdatePod(id string, update func(p *types.Pod)) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	p, exists := d.pods[id]
+	if !exists {
+		return types.ErrPodNotFound
+	}
+	update(p)
+
+	if err := pod.SaveState(p); err != nil {
+		log.Printf("Error saving pod state: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (d *Daemon) RemovePod(id string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.pods, id)
+}
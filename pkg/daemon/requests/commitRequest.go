@@ -0,0 +1,12 @@
+package requests
+
+// CommitRequest creates an image from a container's changes. Changes are
+// Boxfile instructions applied to the new image's config, such as
+// "ENV DEBUG=1" or "CMD [\"nginx\"]".
+type CommitRequest struct {
+	Reference string   `json:"reference"`
+	Comment   string   `json:"comment"`
+	Author    string   `json:"author"`
+	Changes   []string `json:"changes"`
+	Pause     bool     `json:"pause"`
+}
@@ -1,8 +1,52 @@
 package requests
 
 type InitContainerRequest struct {
-	Name         string `json:"name"`
-	OriginFolder string `json:"origin_folder"`
-	MemoryLimit  string `json:"memory_limit"`
-	CpuLimit     string `json:"cpu_limit"`
+	Resources
+	Name          string            `json:"name"`
+	Image         string            `json:"image"`
+	OriginFolder  string            `json:"origin_folder"`
+	Command       []string          `json:"command"`
+	Labels        map[string]string `json:"labels"`
+	Annotations   map[string]string `json:"annotations"`
+	Volumes       []string          `json:"volumes"`
+	RestartPolicy string            `json:"restart_policy"`
+	HealthCheck   *HealthCheck      `json:"healthcheck"`
+	Pod           string            `json:"pod"`
+	NetworkMode   string            `json:"network_mode"`
+	PidMode       string            `json:"pid_mode"`
+	IpcMode       string            `json:"ipc_mode"`
+	UtsMode       string            `json:"uts_mode"`
+	IPv6Address   string            `json:"ipv6_address"`
+}
+
+// HealthCheck carries durations as strings such as "30s", they are parsed
+// and validated by the daemon.
+type HealthCheck struct {
+	Test               []string `json:"test"`
+	Interval           string   `json:"interval"`
+	Timeout            string   `json:"timeout"`
+	Retries            int      `json:"retries"`
+	StartPeriod        string   `json:"start_period"`
+	RestartOnUnhealthy bool     `json:"restart_on_unhealthy"`
+}
+
+// Resources are the resource limits of a container, using the same names as
+// the settings section of boxify.yaml.
+type Resources struct {
+	MemoryLimit       string   `json:"memory_limit"`
+	MemoryReservation string   `json:"memory_reservation"`
+	MemorySwap        string   `json:"memory_swap"`
+	CpuLimit          string   `json:"cpu_limit"`
+	Cpus              string   `json:"cpus"`
+	CpuShares         int      `json:"cpu_shares"`
+	CpuPeriod         int      `json:"cpu_period"`
+	CpuQuota          int      `json:"cpu_quota"`
+	CpusetCpus        string   `json:"cpuset_cpus"`
+	CpusetMems        string   `json:"cpuset_mems"`
+	IOWeight          int      `json:"io_weight"`
+	DeviceReadBps     []string `json:"device_read_bps"`
+	DeviceWriteBps    []string `json:"device_write_bps"`
+	DeviceReadIOps    []string `json:"device_read_iops"`
+	DeviceWriteIOps   []string `json:"device_write_iops"`
+	PidsLimit         int      `json:"pids_limit"`
 }
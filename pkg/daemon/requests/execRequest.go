@@ -0,0 +1,16 @@
+package requests
+
+// ExecRequest runs Command in a running container. With Tty the stdio the
+// client passes is a pty slave, which becomes the process's controlling
+// terminal.
+type ExecRequest struct {
+	Command []string `json:"command"`
+	Tty     bool     `json:"tty"`
+}
+
+// ExecResult is sent once the exec'd process is gone. Error is set when it
+// could not be started.
+type ExecResult struct {
+	ExitCode int    `json:"exit_code"`
+	Error    string `json:"error,omitempty"`
+}
@@ -0,0 +1,17 @@
+package requests
+
+// CreateNetworkRequest creates a macvlan or ipvlan network on the Parent
+// interface. Mode is the driver's mode, bridge for macvlan and l2 or l3 for
+// ipvlan. IPv6 or a Subnet6 make the network dual-stack.
+type CreateNetworkRequest struct {
+	Name     string            `json:"name"`
+	Driver   string            `json:"driver"`
+	Parent   string            `json:"parent"`
+	Subnet   string            `json:"subnet"`
+	Gateway  string            `json:"gateway"`
+	Mode     string            `json:"mode"`
+	IPv6     bool              `json:"ipv6"`
+	Subnet6  string            `json:"subnet6"`
+	Gateway6 string            `json:"gateway6"`
+	Labels   map[string]string `json:"labels"`
+}
@@ -0,0 +1,16 @@
+package requests
+
+// CreatePodRequest creates a pod. Its resource limits apply to all of the
+// pod's containers together. Hostname defaults to the pod's name.
+type CreatePodRequest struct {
+	Resources
+	Name     string            `json:"name"`
+	Hostname string            `json:"hostname"`
+	Labels   map[string]string `json:"labels"`
+}
+
+// UpdatePodRequest changes the limits of a pod. Empty fields keep their
+// current value.
+type UpdatePodRequest struct {
+	Resources
+}
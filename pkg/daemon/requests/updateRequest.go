@@ -0,0 +1,9 @@
+package requests
+
+// UpdateContainerRequest changes the limits and restart policy of an existing
+// container. Empty fields keep their current value.
+type UpdateContainerRequest struct {
+	Resources
+	RestartPolicy string `json:"restart_policy"`
+	Force         bool   `json:"force"`
+}
@@ -0,0 +1,7 @@
+package requests
+
+type CreateVolumeRequest struct {
+	Name        string            `json:"name"`
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+}
@@ -6,6 +6,8 @@ import (
 	"net/http"
 	"os"
 	"strconv"
+
+	"github.com/urizennnn/boxify/pkg/daemon/handlers"
 )
 
 func (d *Daemon) Init() {
@@ -30,13 +32,11 @@ func (d *Daemon) Init() {
 		log.Fatalf("Failed to set permissions: %v", err)
 	}
 
-	mux := http.NewServeMux()
-	mux.HandleFunc("/containers/create", d.HandleCreateRequest)
-	// mux.HandleFunc("/containers/start", handleStart)
-	// mux.HandleFunc("/containers/stop", handleStop)
-	// mux.HandleFunc("/containers/list", handleList)
-	// mux.HandleFunc("/containers/remove", handleRemove)
-	//
+	mux := d.routes()
+
+	handlers.RecoverPods(d)
+	go handlers.RecoverContainers(d)
+
 	log.Println("Boxify daemon started, listening on /var/run/boxify.sock")
 
 	if err := http.Serve(listener, mux); err != nil {
@@ -44,6 +44,58 @@ func (d *Daemon) Init() {
 	}
 }
 
+func (d *Daemon) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /containers/create", d.HandleCreateRequest)
+	mux.HandleFunc("GET /containers/json", d.HandleListRequest)
+	mux.HandleFunc("GET /containers/{id}/json", d.HandleInspectRequest)
+	mux.HandleFunc("POST /containers/{id}/start", d.HandleStartRequest)
+	mux.HandleFunc("POST /containers/{id}/stop", d.HandleStopRequest)
+	mux.HandleFunc("GET /containers/{id}/logs", d.HandleLogsRequest)
+	mux.HandleFunc("GET /containers/{id}/top", d.HandleTopRequest)
+	mux.HandleFunc("POST /containers/{id}/exec", d.HandleExecRequest)
+	mux.HandleFunc("GET /containers/{id}/changes", d.HandleChangesRequest)
+	mux.HandleFunc("GET /containers/{id}/archive", d.HandleArchiveGetRequest)
+	mux.HandleFunc("PUT /containers/{id}/archive", d.HandleArchivePutRequest)
+	mux.HandleFunc("GET /containers/{id}/export", d.HandleExportRequest)
+	mux.HandleFunc("POST /containers/{id}/commit", d.HandleCommitRequest)
+	mux.HandleFunc("POST /containers/{id}/rename", d.HandleRenameRequest)
+	mux.HandleFunc("POST /containers/{id}/update", d.HandleUpdateRequest)
+	mux.HandleFunc("POST /containers/{id}/pause", d.HandlePauseRequest)
+	mux.HandleFunc("POST /containers/{id}/unpause", d.HandleUnpauseRequest)
+	mux.HandleFunc("DELETE /containers/{id}", d.HandleRemoveRequest)
+	mux.HandleFunc("POST /containers/prune", d.HandlePruneRequest)
+	mux.HandleFunc("POST /volumes/create", d.HandleVolumeCreateRequest)
+	mux.HandleFunc("GET /volumes", d.HandleVolumeListRequest)
+	mux.HandleFunc("GET /volumes/{name}", d.HandleVolumeInspectRequest)
+	mux.HandleFunc("DELETE /volumes/{name}", d.HandleVolumeRemoveRequest)
+	mux.HandleFunc("POST /volumes/prune", d.HandleVolumePruneRequest)
+	mux.HandleFunc("POST /pods/create", d.HandlePodCreateRequest)
+	mux.HandleFunc("GET /pods/json", d.HandlePodListRequest)
+	mux.HandleFunc("GET /pods/{id}/json", d.HandlePodInspectRequest)
+	mux.HandleFunc("POST /pods/{id}/start", d.HandlePodStartRequest)
+	mux.HandleFunc("POST /pods/{id}/stop", d.HandlePodStopRequest)
+	mux.HandleFunc("POST /pods/{id}/update", d.HandlePodUpdateRequest)
+	mux.HandleFunc("DELETE /pods/{id}", d.HandlePodRemoveRequest)
+	mux.HandleFunc("GET /networks", d.HandleNetworkListRequest)
+	mux.HandleFunc("POST /networks/create", d.HandleNetworkCreateRequest)
+	mux.HandleFunc("GET /networks/{id}", d.HandleNetworkInspectRequest)
+	mux.HandleFunc("DELETE /networks/{id}", d.HandleNetworkRemoveRequest)
+	mux.HandleFunc("GET /images", d.HandleImageListRequest)
+	mux.HandleFunc("GET /images/{name}/json", d.HandleImageInspectRequest)
+	mux.HandleFunc("GET /images/{name}/history", d.HandleImageHistoryRequest)
+	mux.HandleFunc("POST /images/{name}/tag", d.HandleImageTagRequest)
+	mux.HandleFunc("DELETE /images/{name}", d.HandleImageRemoveRequest)
+	mux.HandleFunc("GET /images/save", d.HandleImageSaveRequest)
+	mux.HandleFunc("POST /images/load", d.HandleImageLoadRequest)
+	mux.HandleFunc("POST /images/import", d.HandleImageImportRequest)
+	mux.HandleFunc("POST /images/prune", d.HandleImagePruneRequest)
+	mux.HandleFunc("POST /build", d.HandleBuildRequest)
+	mux.HandleFunc("GET /events", d.HandleEventsRequest)
+
+	return mux
+}
+
 func setupLogging() {
 	if os.Getenv("INVOCATION_ID") != "" {
 		log.SetOutput(os.Stdout)
@@ -1,37 +1,206 @@
 package daemon
 
 import (
-	"log"
-	"sync"
+	"net/http"
 
+	"github.com/urizennnn/boxify/pkg/daemon/handlers"
 	"github.com/urizennnn/boxify/pkg/daemon/types"
-	"github.com/urizennnn/boxify/pkg/network"
 )
 
-type Daemon struct {
-	containers map[string]*types.Container
-	mu         sync.RWMutex
-	networkMgr *network.NetworkManager
-}
+// GetContainer returns a snapshot of the container with the given ID. Changes
+// go through the daemon's setters so they are persisted.
+func (m *Daemon) GetContainer(id string) (*types.Container, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 
-func New() *Daemon {
-	networkMgr, err := network.NewNetworkManager()
-	if err != nil {
-		log.Fatalf("failed to initialize network manager: %v", err)
+	container, exists := m.containers[id]
+	if !exists {
+		return nil, types.ErrContainerNotFound
 	}
+	snapshot := *container
+	return &snapshot, nil
+}
 
-	return &Daemon{
-		containers: make(map[string]*types.Container),
-		networkMgr: networkMgr,
-	}
+func (d *Daemon) HandleCreateRequest(w http.ResponseWriter, r *http.Request) {
+	handlers.HandleCreate(d, w, r)
+}
+
+func (d *Daemon) HandleListRequest(w http.ResponseWriter, r *http.Request) {
+	handlers.HandleList(d, w, r)
+}
+
+func (d *Daemon) HandleInspectRequest(w http.ResponseWriter, r *http.Request) {
+	handlers.HandleInspect(d, w, r)
+}
+
+func (d *Daemon) HandleStartRequest(w http.ResponseWriter, r *http.Request) {
+	handlers.HandleStart(d, w, r)
+}
+
+func (d *Daemon) HandleStopRequest(w http.ResponseWriter, r *http.Request) {
+	handlers.HandleStop(d, w, r)
+}
+
+func (d *Daemon) HandleRemoveRequest(w http.ResponseWriter, r *http.Request) {
+	handlers.HandleRemove(d, w, r)
+}
+
+func (d *Daemon) HandleLogsRequest(w http.ResponseWriter, r *http.Request) {
+	handlers.HandleLogs(d, w, r)
+}
+
+func (d *Daemon) HandleRenameRequest(w http.ResponseWriter, r *http.Request) {
+	handlers.HandleRename(d, w, r)
+}
+
+func (d *Daemon) HandleUpdateRequest(w http.ResponseWriter, r *http.Request) {
+	handlers.HandleUpdate(d, w, r)
+}
+
+func (d *Daemon) HandlePauseRequest(w http.ResponseWriter, r *http.Request) {
+	handlers.HandlePause(d, w, r)
+}
+
+func (d *Daemon) HandleUnpauseRequest(w http.ResponseWriter, r *http.Request) {
+	handlers.HandleUnpause(d, w, r)
+}
+
+func (d *Daemon) HandleTopRequest(w http.ResponseWriter, r *http.Request) {
+	handlers.HandleTop(d, w, r)
+}
+
+func (d *Daemon) HandleExecRequest(w http.ResponseWriter, r *http.Request) {
+	handlers.HandleExec(d, w, r)
+}
+
+func (d *Daemon) HandleArchiveGetRequest(w http.ResponseWriter, r *http.Request) {
+	handlers.HandleArchiveGet(d, w, r)
+}
+
+func (d *Daemon) HandleArchivePutRequest(w http.ResponseWriter, r *http.Request) {
+	handlers.HandleArchivePut(d, w, r)
+}
+
+func (d *Daemon) HandleChangesRequest(w http.ResponseWriter, r *http.Request) {
+	handlers.HandleChanges(d, w, r)
+}
+
+func (d *Daemon) HandleExportRequest(w http.ResponseWriter, r *http.Request) {
+	handlers.HandleExport(d, w, r)
+}
+
+func (d *Daemon) HandleImageImportRequest(w http.ResponseWriter, r *http.Request) {
+	handlers.HandleImageImport(d, w, r)
+}
+
+func (d *Daemon) HandleImageListRequest(w http.ResponseWriter, r *http.Request) {
+	handlers.HandleImageList(d, w, r)
+}
+
+func (d *Daemon) HandleImageInspectRequest(w http.ResponseWriter, r *http.Request) {
+	handlers.HandleImageInspect(d, w, r)
+}
+
+func (d *Daemon) HandleImageHistoryRequest(w http.ResponseWriter, r *http.Request) {
+	handlers.HandleImageHistory(d, w, r)
+}
+
+func (d *Daemon) HandleImageTagRequest(w http.ResponseWriter, r *http.Request) {
+	handlers.HandleImageTag(d, w, r)
+}
+
+func (d *Daemon) HandleImageRemoveRequest(w http.ResponseWriter, r *http.Request) {
+	handlers.HandleImageRemove(d, w, r)
+}
+
+func (d *Daemon) HandleImageSaveRequest(w http.ResponseWriter, r *http.Request) {
+	handlers.HandleImageSave(d, w, r)
+}
+
+func (d *Daemon) HandleImageLoadRequest(w http.ResponseWriter, r *http.Request) {
+	handlers.HandleImageLoad(d, w, r)
+}
+
+func (d *Daemon) HandleImagePruneRequest(w http.ResponseWriter, r *http.Request) {
+	handlers.HandleImagePrune(d, w, r)
+}
+
+func (d *Daemon) HandleBuildRequest(w http.ResponseWriter, r *http.Request) {
+	handlers.HandleBuild(d, w, r)
+}
+
+func (d *Daemon) HandleCommitRequest(w http.ResponseWriter, r *http.Request) {
+	handlers.HandleCommit(d, w, r)
+}
+
+func (d *Daemon) HandlePruneRequest(w http.ResponseWriter, r *http.Request) {
+	handlers.HandlePrune(d, w, r)
+}
+
+func (d *Daemon) HandleVolumeCreateRequest(w http.ResponseWriter, r *http.Request) {
+	handlers.HandleVolumeCreate(d, w, r)
+}
+
+func (d *Daemon) HandleVolumeListRequest(w http.ResponseWriter, r *http.Request) {
+	handlers.HandleVolumeList(d, w, r)
+}
+
+func (d *Daemon) HandleVolumeInspectRequest(w http.ResponseWriter, r *http.Request) {
+	handlers.HandleVolumeInspect(d, w, r)
+}
+
+func (d *Daemon) HandleVolumeRemoveRequest(w http.ResponseWriter, r *http.Request) {
+	handlers.HandleVolumeRemove(d, w, r)
+}
+
+func (d *Daemon) HandleVolumePruneRequest(w http.ResponseWriter, r *http.Request) {
+	handlers.HandleVolumePrune(d, w, r)
+}
+
+func (d *Daemon) HandleNetworkListRequest(w http.ResponseWriter, r *http.Request) {
+	handlers.HandleNetworkList(d, w, r)
+}
+
+func (d *Daemon) HandleNetworkInspectRequest(w http.ResponseWriter, r *http.Request) {
+	handlers.HandleNetworkInspect(d, w, r)
+}
+
+func (d *Daemon) HandleNetworkCreateRequest(w http.ResponseWriter, r *http.Request) {
+	handlers.HandleNetworkCreate(d, w, r)
+}
+
+func (d *Daemon) HandleNetworkRemoveRequest(w http.ResponseWriter, r *http.Request) {
+	handlers.HandleNetworkRemove(d, w, r)
+}
+
+func (d *Daemon) HandleEventsRequest(w http.ResponseWriter, r *http.Request) {
+	handlers.HandleEvents(d, w, r)
+}
+
+func (d *Daemon) HandlePodCreateRequest(w http.ResponseWriter, r *http.Request) {
+	handlers.HandlePodCreate(d, w, r)
+}
+
+func (d *Daemon) HandlePodListRequest(w http.ResponseWriter, r *http.Request) {
+	handlers.HandlePodList(d, w, r)
+}
+
+func (d *Daemon) HandlePodInspectRequest(w http.ResponseWriter, r *http.Request) {
+	handlers.HandlePodInspect(d, w, r)
+}
+
+func (d *Daemon) HandlePodStartRequest(w http.ResponseWriter, r *http.Request) {
+	handlers.HandlePodStart(d, w, r)
+}
+
+func (d *Daemon) HandlePodStopRequest(w http.ResponseWriter, r *http.Request) {
+	handlers.HandlePodStop(d, w, r)
 }
 
-func (d *Daemon) AddContainer(container *types.Container) {
-	d.mu.Lock()
-	defer d.mu.Unlock()
-	d.containers[container.ID] = container
+func (d *Daemon) HandlePodUpdateRequest(w http.ResponseWriter, r *http.Request) {
+	handlers.HandlePodUpdate(d, w, r)
 }
 
-func (d *Daemon) NetworkManager() *network.NetworkManager {
-	return d.networkMgr
+func (d *Daemon) HandlePodRemoveRequest(w http.ResponseWriter, r *http.Request) {
+	handlers.HandlePodRemove(d, w, r)
 }
@@ -0,0 +1,12 @@
+package types
+
+import "errors"
+
+var (
+	ErrContainerNotFound  = errors.New("container not found")
+	ErrAmbiguousContainer = errors.New("container reference matches multiple containers")
+	ErrNameInUse          = errors.New("container name already in use")
+	ErrPodNotFound        = errors.New("pod not found")
+	ErrAmbiguousPod       = errors.New("pod reference matches multiple pods")
+	ErrPodNameInUse       = errors.New("pod name already in use")
+)
@@ -6,20 +6,170 @@ import (
 )
 
 type Container struct {
+	ID              string
+	Name            string
+	PID             int
+	Image           string
+	ImageID         string
+	Command         []string
+	Env             []string
+	WorkingDir      string
+	User            string
+	Labels          map[string]string
+	Annotations     map[string]string
+	Mounts          []Mount
+	Resources       Resources
+	RestartPolicy   RestartPolicy
+	RestartCount    int
+	ExitCode        int
+	OOMKilled       bool
+	OOMKillCount    int
+	HealthCheck     *HealthConfig
+	Health          *Health
+	NetworkInfo     *NetworkInfo
+	Namespaces      NamespaceModes
+	Pod             string
+	CreatedAt       time.Time
+	StartedAt       time.Time
+	FinishedAt      time.Time
+	Status          string
+	ManuallyStopped bool
+	Cmd             *exec.Cmd `yaml:"-" json:"-"`
+}
+
+// Active reports whether the container's main process should be alive,
+// which is the case while it is running or paused.
+func (c *Container) Active() bool {
+	return c.Status == "running" || c.Status == "paused"
+}
+
+// Pod is a group of containers that share the network, IPC and UTS
+// namespaces of the pod's infra process, and whose cgroups are nested in the
+// pod's cgroup so the pod's limits apply to all of them together. Status is
+// "running" while the infra process is alive and "exited" otherwise.
+type Pod struct {
 	ID          string
-	PID         int
-	Image       string
-	Command     []string
+	Name        string
+	Hostname    string
+	InfraPID    int
+	Labels      map[string]string
+	Resources   Resources
 	NetworkInfo *NetworkInfo
 	CreatedAt   time.Time
 	Status      string
-	Cmd         *exec.Cmd `yaml:"-"`
+	Containers  []string `yaml:"-"`
+}
+
+// NamespaceModes says where each of a container's namespaces comes from:
+// "private" for a namespace of its own, "host" for the daemon's or
+// "container:<id>" for the namespace of another container. Network may also
+// be "none", a namespace of the container's own with only loopback.
+// Containers created before modes existed have them empty, which means
+// private.
+type NamespaceModes struct {
+	Network string
+	PID     string
+	IPC     string
+	UTS     string
 }
 
+// NetworkInfo is a container's address on its network. Network and Driver
+// are empty for containers on the default bridge network, IPv6 and
+// IPv6Gateway for containers on networks without IPv6.
 type NetworkInfo struct {
 	IP            string
 	Gateway       string
+	IPv6          string
+	IPv6Gateway   string
 	Bridge        string
+	Network       string
+	Driver        string
 	HostVeth      string
 	ContainerVeth string
 }
+
+type Mount struct {
+	Name        string
+	Source      string
+	Destination string
+	ReadOnly    bool
+}
+
+// Resources is the resource spec as the user gave it. cgroup.ParseResources
+// validates it and converts it to cgroup v2 values.
+type Resources struct {
+	MemoryLimit       string
+	MemoryReservation string
+	MemorySwap        string
+	CpuLimit          string
+	Cpus              string
+	CpuShares         int
+	CpuPeriod         int
+	CpuQuota          int
+	CpusetCpus        string
+	CpusetMems        string
+	IOWeight          int
+	DeviceReadBps     []string
+	DeviceWriteBps    []string
+	DeviceReadIOps    []string
+	DeviceWriteIOps   []string
+	PidsLimit         int
+}
+
+// RestartPolicy is one of "no", "on-failure", "always" or "unless-stopped".
+// MaximumRetryCount only applies to "on-failure", zero meaning unlimited.
+type RestartPolicy struct {
+	Name              string
+	MaximumRetryCount int
+}
+
+type HealthConfig struct {
+	Test               []string
+	Interval           time.Duration
+	Timeout            time.Duration
+	Retries            int
+	StartPeriod        time.Duration
+	RestartOnUnhealthy bool
+}
+
+// Health is the current health state of a container together with the most
+// recent probe results. Status is "starting", "healthy" or "unhealthy".
+type Health struct {
+	Status        string
+	FailingStreak int
+	Log           []HealthResult
+}
+
+type HealthResult struct {
+	Start    time.Time
+	End      time.Time
+	ExitCode int
+	Output   string
+}
+
+// Process is one process of a container as seen from the host. PID is the
+// host PID and ContainerPID the PID inside the container's namespace.
+type Process struct {
+	PID          int
+	ContainerPID int
+	PPID         int
+	UID          int
+	User         string
+	State        string
+	CPUTime      time.Duration
+	RSS          int64
+	Command      string
+}
+
+const (
+	ChangeModified = "C"
+	ChangeAdded    = "A"
+	ChangeDeleted  = "D"
+)
+
+// Change is a path a container added, modified or deleted relative to its
+// image.
+type Change struct {
+	Path string
+	Kind string
+}
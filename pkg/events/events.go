@@ -0,0 +1,122 @@
+package events
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultBufferSize is how many past events are kept for replay.
+	DefaultBufferSize = 1024
+
+	subscriberBufferSize = 256
+)
+
+type Event struct {
+	Type     string
+	Action   string
+	Actor    Actor
+	Time     int64
+	TimeNano int64
+}
+
+type Actor struct {
+	ID         string
+	Attributes map[string]string
+	// Labels are the actor's labels, which are also among its attributes.
+	// Label filters match only these.
+	Labels map[string]string `json:"-"`
+}
+
+// New stamps an event with the current time.
+func New(eventType, action, id string, attributes map[string]string) Event {
+	now := time.Now()
+	return Event{
+		Type:     eventType,
+		Action:   action,
+		Actor:    Actor{ID: id, Attributes: attributes},
+		Time:     now.Unix(),
+		TimeNano: now.UnixNano(),
+	}
+}
+
+// NewLabelled is New for an actor with labels. The labels are listed
+// among the attributes, where the other attributes win on conflicts, and
+// kept apart for label filters.
+func NewLabelled(eventType, action, id string, labels, attributes map[string]string) Event {
+	merged := make(map[string]string, len(labels)+len(attributes))
+	copied := make(map[string]string, len(labels))
+	for key, value := range labels {
+		merged[key] = value
+		copied[key] = value
+	}
+	for key, value := range attributes {
+		merged[key] = value
+	}
+	e := New(eventType, action, id, merged)
+	e.Actor.Labels = copied
+	return e
+}
+
+// Bus fans events out to subscribers and keeps the most recent ones in a
+// ring buffer so new subscribers can replay them.
+type Bus struct {
+	mu          sync.Mutex
+	ring        []Event
+	next        int
+	full        bool
+	subscribers map[chan Event]struct{}
+}
+
+func NewBus(size int) *Bus {
+	return &Bus{
+		ring:        make([]Event, size),
+		subscribers: make(map[chan Event]struct{}),
+	}
+}
+
+// Publish records the event and delivers it to every subscriber. Slow
+// subscribers whose buffer is full miss the event rather than blocking the
+// publisher.
+func (b *Bus) Publish(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.ring[b.next] = e
+	b.next = (b.next + 1) % len(b.ring)
+	if b.next == 0 {
+		b.full = true
+	}
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- e:
+		default:
+			log.Printf("Dropping %s %s event for slow subscriber", e.Type, e.Action)
+		}
+	}
+}
+
+// Subscribe returns the buffered history, oldest first, and a channel that
+// receives every event published afterwards. cancel must be called to stop
+// the subscription.
+func (b *Bus) Subscribe() (history []Event, events <-chan Event, cancel func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.full {
+		history = append(history, b.ring[b.next:]...)
+	}
+	history = append(history, b.ring[:b.next]...)
+
+	ch := make(chan Event, subscriberBufferSize)
+	b.subscribers[ch] = struct{}{}
+
+	cancel = func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subscribers, ch)
+	}
+	return history, ch, cancel
+}
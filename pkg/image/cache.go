@@ -0,0 +1,53 @@
+package image
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func cacheFile(key string) string {
+	return filepath.Join(ImageDir, "cache", key)
+}
+
+// CacheKey derives the build cache key of a step from the image it runs on
+// and everything that determines its result.
+func CacheKey(parentID string, inputs ...string) string {
+	hash := sha256.New()
+	hash.Write([]byte(parentID))
+	for _, input := range inputs {
+		hash.Write([]byte{0})
+		hash.Write([]byte(input))
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// CacheLookup returns the image a build step with key produced before, if
+// that image still exists.
+func CacheLookup(key string) (string, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	data, err := os.ReadFile(cacheFile(key))
+	if err != nil {
+		return "", false
+	}
+	id := strings.TrimSpace(string(data))
+	if _, err := os.Stat(configFile(strings.TrimPrefix(id, digestPrefix))); err != nil {
+		return "", false
+	}
+	return id, true
+}
+
+// CacheStore records id as the result of the build step with key.
+func CacheStore(key, id string) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(cacheFile(key)), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(cacheFile(key), []byte(id+"\n"), 0o644)
+}
@@ -0,0 +1,157 @@
+package image
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// ApplyChanges applies Boxfile style instructions such as "ENV DEBUG=1" or
+// `CMD ["nginx", "-g", "daemon off;"]` to cfg. ENV, LABEL, WORKDIR, USER,
+// EXPOSE, ENTRYPOINT and CMD are supported.
+func ApplyChanges(cfg *Config, changes []string) error {
+	// cfg is usually a copy of a parent image's config, which must not see
+	// the changes through shared maps.
+	cfg.Labels = cloneMap(cfg.Labels)
+	if cfg.ExposedPorts != nil {
+		ports := make(map[string]struct{}, len(cfg.ExposedPorts))
+		for port := range cfg.ExposedPorts {
+			ports[port] = struct{}{}
+		}
+		cfg.ExposedPorts = ports
+	}
+
+	for _, change := range changes {
+		if err := applyChange(cfg, change); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyChange(cfg *Config, change string) error {
+	instruction, value, _ := strings.Cut(strings.TrimSpace(change), " ")
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return fmt.Errorf("invalid change %q, expected INSTRUCTION VALUE", change)
+	}
+
+	switch strings.ToUpper(instruction) {
+	case "ENV":
+		pairs, err := parsePairs(value)
+		if err != nil {
+			return fmt.Errorf("ENV: %w", err)
+		}
+		for _, pair := range pairs {
+			cfg.Env = setEnv(cfg.Env, pair[0], pair[1])
+		}
+
+	case "LABEL":
+		pairs, err := parsePairs(value)
+		if err != nil {
+			return fmt.Errorf("LABEL: %w", err)
+		}
+		if cfg.Labels == nil {
+			cfg.Labels = map[string]string{}
+		}
+		for _, pair := range pairs {
+			cfg.Labels[pair[0]] = pair[1]
+		}
+
+	case "WORKDIR":
+		// Relative paths are relative to the previous working directory.
+		if !path.IsAbs(value) {
+			value = path.Join("/", cfg.WorkingDir, value)
+		}
+		cfg.WorkingDir = path.Clean(value)
+
+	case "USER":
+		cfg.User = value
+
+	case "EXPOSE":
+		if cfg.ExposedPorts == nil {
+			cfg.ExposedPorts = map[string]struct{}{}
+		}
+		for _, port := range strings.Fields(value) {
+			if !strings.Contains(port, "/") {
+				port += "/tcp"
+			}
+			cfg.ExposedPorts[port] = struct{}{}
+		}
+
+	case "ENTRYPOINT":
+		cfg.Entrypoint = ParseCommand(value)
+
+	case "CMD":
+		cfg.Cmd = ParseCommand(value)
+
+	default:
+		return fmt.Errorf("unsupported change instruction %q", instruction)
+	}
+	return nil
+}
+
+// ParseCommand accepts the JSON exec form and wraps anything else in
+// "/bin/sh -c" like the shell form of a Boxfile.
+func ParseCommand(value string) []string {
+	var args []string
+	if strings.HasPrefix(value, "[") && json.Unmarshal([]byte(value), &args) == nil {
+		return args
+	}
+	return []string{"/bin/sh", "-c", value}
+}
+
+// parsePairs parses "KEY=VALUE ..." pairs, where values may be double
+// quoted, or the legacy "KEY VALUE" form with a single pair.
+func parsePairs(value string) ([][2]string, error) {
+	key, rest, _ := strings.Cut(value, " ")
+	if !strings.Contains(key, "=") {
+		return [][2]string{{key, strings.TrimSpace(rest)}}, nil
+	}
+
+	var pairs [][2]string
+	for value != "" {
+		key, rest, ok := strings.Cut(value, "=")
+		if !ok || key == "" || strings.ContainsAny(key, " \t") {
+			return nil, fmt.Errorf("invalid KEY=VALUE pair in %q", value)
+		}
+
+		var val string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quote in %q", value)
+			}
+			val, rest = rest[1:end+1], rest[end+2:]
+		} else {
+			val, rest, _ = strings.Cut(rest, " ")
+		}
+		pairs = append(pairs, [2]string{key, val})
+		value = strings.TrimLeft(rest, " \t")
+	}
+	return pairs, nil
+}
+
+// setEnv returns a copy of env with key set to value.
+func setEnv(env []string, key, value string) []string {
+	env = append([]string(nil), env...)
+	for i, entry := range env {
+		if name, _, _ := strings.Cut(entry, "="); name == key {
+			env[i] = key + "=" + value
+			return env
+		}
+	}
+	return append(env, key+"="+value)
+}
+
+func cloneMap(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	clone := make(map[string]string, len(m))
+	for k, v := range m {
+		clone[k] = v
+	}
+	return clone
+}
@@ -0,0 +1,56 @@
+// Package image stores container images: a stack of layers together with an
+// OCI image configuration, addressed by the digest of that configuration.
+package image
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrImageNotFound  = errors.New("image not found")
+	ErrAmbiguousImage = errors.New("image reference matches multiple images")
+)
+
+// Image is the OCI image configuration. Its sha256 digest is the image ID.
+type Image struct {
+	Created      time.Time `json:"created"`
+	Author       string    `json:"author,omitempty"`
+	Architecture string    `json:"architecture"`
+	OS           string    `json:"os"`
+	Config       Config    `json:"config"`
+	RootFS       RootFS    `json:"rootfs"`
+	History      []History `json:"history,omitempty"`
+}
+
+// Config holds the defaults a container created from the image starts with.
+type Config struct {
+	User         string              `json:"User,omitempty"`
+	ExposedPorts map[string]struct{} `json:"ExposedPorts,omitempty"`
+	Env          []string            `json:"Env,omitempty"`
+	Entrypoint   []string            `json:"Entrypoint,omitempty"`
+	Cmd          []string            `json:"Cmd,omitempty"`
+	WorkingDir   string              `json:"WorkingDir,omitempty"`
+	Labels       map[string]string   `json:"Labels,omitempty"`
+}
+
+// RootFS lists the layers by the digest of their uncompressed tar, bottom
+// first.
+type RootFS struct {
+	Type    string   `json:"type"`
+	DiffIDs []string `json:"diff_ids"`
+}
+
+type History struct {
+	Created    time.Time `json:"created"`
+	CreatedBy  string    `json:"created_by,omitempty"`
+	Author     string    `json:"author,omitempty"`
+	Comment    string    `json:"comment,omitempty"`
+	EmptyLayer bool      `json:"empty_layer,omitempty"`
+}
+
+// Command is what a container created from the image runs when no command
+// is given.
+func (img *Image) Command() []string {
+	return append(append([]string{}, img.Config.Entrypoint...), img.Config.Cmd...)
+}
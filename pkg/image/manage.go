@@ -0,0 +1,406 @@
+package image
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrImageConflict is returned when an image cannot be removed as asked,
+// for example because a container still uses it.
+var ErrImageConflict = errors.New("conflict")
+
+// staleAfter is how old a temporary file in the layer store has to be
+// before prune assumes the layer it belonged to was abandoned.
+const staleAfter = time.Hour
+
+// Summary describes an image in listings.
+type Summary struct {
+	ID       string
+	ParentID string
+	RepoTags []string
+	Created  time.Time
+	Size     int64
+}
+
+// HistoryEntry is one step of an image's history together with the size of
+// the layer it added. ID is set for steps that produced an image in this
+// store.
+type HistoryEntry struct {
+	ID        string
+	Created   time.Time
+	CreatedBy string
+	Comment   string
+	Size      int64
+	Tags      []string
+}
+
+// Deletion records one change made while removing images.
+type Deletion struct {
+	Untagged string `json:",omitempty"`
+	Deleted  string `json:",omitempty"`
+}
+
+// PruneReport lists what a prune removed.
+type PruneReport struct {
+	ImagesDeleted  []Deletion
+	LayersDeleted  []string
+	SpaceReclaimed int64
+}
+
+// InUse reports the container that uses the image with the given ID, or ""
+// when none does.
+type InUse func(id string) string
+
+// index is a snapshot of every image in the store.
+type index struct {
+	repos    map[string]string
+	images   map[string]*Image
+	parents  map[string]string
+	children map[string]int
+}
+
+func loadIndex() (*index, error) {
+	repos, err := loadRepositories()
+	if err != nil {
+		return nil, err
+	}
+	idx := &index{
+		repos:    repos,
+		images:   map[string]*Image{},
+		parents:  map[string]string{},
+		children: map[string]int{},
+	}
+
+	entries, err := os.ReadDir(filepath.Join(ImageDir, "configs"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, entry := range entries {
+		id := digestPrefix + entry.Name()
+		img, err := load(id)
+		if err != nil {
+			log.Printf("Skipping image %s: %v", ShortID(id), err)
+			continue
+		}
+		idx.images[id] = img
+	}
+	for id := range idx.images {
+		data, err := os.ReadFile(parentFile(strings.TrimPrefix(id, digestPrefix)))
+		if err != nil {
+			continue
+		}
+		parent := strings.TrimSpace(string(data))
+		if _, ok := idx.images[parent]; ok {
+			idx.parents[id] = parent
+			idx.children[parent]++
+		}
+	}
+	return idx, nil
+}
+
+// remove deletes the image's configuration and tags. Its layers stay until
+// a prune finds them unreferenced.
+func (idx *index) remove(id string) error {
+	hexDigest := strings.TrimPrefix(id, digestPrefix)
+	if err := os.Remove(configFile(hexDigest)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove image config: %w", err)
+	}
+	os.Remove(parentFile(hexDigest))
+
+	if parent, ok := idx.parents[id]; ok {
+		idx.children[parent]--
+		delete(idx.parents, id)
+	}
+	delete(idx.images, id)
+	delete(idx.children, id)
+	for ref, target := range idx.repos {
+		if target == id {
+			delete(idx.repos, ref)
+		}
+	}
+	log.Printf("Deleted image %s", ShortID(id))
+	return nil
+}
+
+// layerSize is the size of the layer's uncompressed tar.
+func layerSize(diffID string) int64 {
+	info, err := os.Stat(blobFile(strings.TrimPrefix(diffID, digestPrefix)))
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// Size is the combined size of the image's layers.
+func Size(img *Image) int64 {
+	var size int64
+	for _, diffID := range img.RootFS.DiffIDs {
+		size += layerSize(diffID)
+	}
+	return size
+}
+
+// List returns the images in the store, newest first. Untagged images that
+// other images are built on, such as the steps of a build, are only listed
+// when all is set.
+func List(all bool) ([]Summary, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	idx, err := loadIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	var summaries []Summary
+	for id, img := range idx.images {
+		refs := references(idx.repos, id)
+		if !all && len(refs) == 0 && idx.children[id] > 0 {
+			continue
+		}
+		summaries = append(summaries, Summary{
+			ID:       id,
+			ParentID: idx.parents[id],
+			RepoTags: refs,
+			Created:  img.Created,
+			Size:     Size(img),
+		})
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].Created.After(summaries[j].Created)
+	})
+	return summaries, nil
+}
+
+// ImageHistory returns the steps that built the image, newest first.
+func ImageHistory(ref string) ([]HistoryEntry, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	id, err := resolve(ref)
+	if err != nil {
+		return nil, err
+	}
+	idx, err := loadIndex()
+	if err != nil {
+		return nil, err
+	}
+	img, ok := idx.images[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrImageNotFound, ref)
+	}
+
+	entries := make([]HistoryEntry, len(img.History))
+	layer := 0
+	for i, h := range img.History {
+		entries[i] = HistoryEntry{
+			Created:   h.Created,
+			CreatedBy: h.CreatedBy,
+			Comment:   h.Comment,
+		}
+		if !h.EmptyLayer && layer < len(img.RootFS.DiffIDs) {
+			entries[i].Size = layerSize(img.RootFS.DiffIDs[layer])
+			layer++
+		}
+	}
+
+	// Walk up the parents to name the steps that produced local images.
+	// Each image's history extends its parent's by one or more steps.
+	for current := id; current != ""; current = idx.parents[current] {
+		parent, ok := idx.images[current]
+		if !ok || len(parent.History) == 0 || len(parent.History) > len(entries) {
+			break
+		}
+		entry := &entries[len(parent.History)-1]
+		entry.ID = current
+		entry.Tags = references(idx.repos, current)
+	}
+
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	return entries, nil
+}
+
+// Remove deletes the image ref names. A reference to an image with other
+// tags only removes that tag. An image used by a container or named by
+// several tags is only removed when force is set; a forced removal of an
+// image in use drops its tags but keeps the image for the container.
+// Untagged parents left without children are removed as well.
+func Remove(ref string, force bool, inUse InUse) ([]Deletion, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	idx, err := loadIndex()
+	if err != nil {
+		return nil, err
+	}
+	id, err := resolve(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	refs := references(idx.repos, id)
+	byTag := ""
+	if normalized, err := NormalizeReference(ref); err == nil && idx.repos[normalized] == id {
+		byTag = normalized
+	}
+
+	var deletions []Deletion
+	untag := func(refs ...string) error {
+		for _, r := range refs {
+			delete(idx.repos, r)
+			deletions = append(deletions, Deletion{Untagged: r})
+		}
+		return saveRepositories(idx.repos)
+	}
+
+	if byTag != "" && len(refs) > 1 {
+		return deletions, untag(byTag)
+	}
+	if byTag == "" && len(refs) > 1 && !force {
+		return nil, fmt.Errorf("%w: image %s is referenced by %d tags, remove them by name or force the removal", ErrImageConflict, ShortID(id), len(refs))
+	}
+
+	if container := inUse(id); container != "" {
+		if !force || len(refs) == 0 {
+			return nil, fmt.Errorf("%w: image %s is being used by container %s", ErrImageConflict, ShortID(id), container)
+		}
+		return deletions, untag(refs...)
+	}
+	if idx.children[id] > 0 {
+		if len(refs) == 0 {
+			return nil, fmt.Errorf("%w: image %s has dependent child images", ErrImageConflict, ShortID(id))
+		}
+		return deletions, untag(refs...)
+	}
+
+	if err := untag(refs...); err != nil {
+		return deletions, err
+	}
+	for current := id; current != ""; {
+		parent := idx.parents[current]
+		if err := idx.remove(current); err != nil {
+			return deletions, err
+		}
+		deletions = append(deletions, Deletion{Deleted: current})
+
+		if parent == "" || idx.children[parent] > 0 || len(references(idx.repos, parent)) > 0 || inUse(parent) != "" {
+			break
+		}
+		current = parent
+	}
+	return deletions, nil
+}
+
+// Prune removes untagged images that no container uses and no other image
+// is built on, or with all every such image whether tagged or not. It then
+// deletes the layers and build cache entries no remaining image references.
+func Prune(all bool, inUse InUse) (*PruneReport, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	idx, err := loadIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &PruneReport{}
+	for removed := true; removed; {
+		removed = false
+		for id := range idx.images {
+			refs := references(idx.repos, id)
+			if (len(refs) > 0 && !all) || idx.children[id] > 0 || inUse(id) != "" {
+				continue
+			}
+			for _, ref := range refs {
+				report.ImagesDeleted = append(report.ImagesDeleted, Deletion{Untagged: ref})
+			}
+			if err := idx.remove(id); err != nil {
+				return report, err
+			}
+			report.ImagesDeleted = append(report.ImagesDeleted, Deletion{Deleted: id})
+			removed = true
+		}
+	}
+	if err := saveRepositories(idx.repos); err != nil {
+		return report, err
+	}
+
+	referenced := map[string]bool{}
+	for diffID := range pending {
+		referenced[strings.TrimPrefix(diffID, digestPrefix)] = true
+	}
+	for _, img := range idx.images {
+		for _, diffID := range img.RootFS.DiffIDs {
+			referenced[strings.TrimPrefix(diffID, digestPrefix)] = true
+		}
+	}
+
+	for _, dir := range []string{filepath.Join(ImageDir, "blobs", "sha256"), filepath.Join(ImageDir, "layers")} {
+		entries, err := os.ReadDir(dir)
+		if err != nil && !os.IsNotExist(err) {
+			return report, err
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if referenced[name] {
+				continue
+			}
+			path := filepath.Join(dir, name)
+			info, err := os.Lstat(path)
+			if err != nil {
+				continue
+			}
+			if strings.HasPrefix(name, ".") && time.Since(info.ModTime()) < staleAfter {
+				continue
+			}
+
+			size := diskUsage(path)
+			if err := os.RemoveAll(path); err != nil {
+				log.Printf("Error removing layer %s: %v", path, err)
+				continue
+			}
+			report.SpaceReclaimed += size
+			if dir == filepath.Join(ImageDir, "layers") && !strings.HasPrefix(name, ".") {
+				report.LayersDeleted = append(report.LayersDeleted, digestPrefix+name)
+				log.Printf("Deleted layer %s", ShortID(name))
+			}
+		}
+	}
+
+	entries, err := os.ReadDir(filepath.Join(ImageDir, "cache"))
+	if err != nil && !os.IsNotExist(err) {
+		return report, err
+	}
+	for _, entry := range entries {
+		data, err := os.ReadFile(cacheFile(entry.Name()))
+		if err != nil {
+			continue
+		}
+		if _, ok := idx.images[strings.TrimSpace(string(data))]; !ok {
+			os.Remove(cacheFile(entry.Name()))
+		}
+	}
+
+	sort.Strings(report.LayersDeleted)
+	return report, nil
+}
+
+// diskUsage is the apparent size of the files below path.
+func diskUsage(path string) int64 {
+	var size int64
+	filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
@@ -0,0 +1,431 @@
+package image
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/urizennnn/boxify/pkg/archive"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	ImageDir = "/var/lib/boxify/images"
+
+	digestPrefix = "sha256:"
+)
+
+var validReference = regexp.MustCompile(`^[a-z0-9]+([._/-][a-z0-9]+)*(:[a-zA-Z0-9_][a-zA-Z0-9_.-]{0,127})?$`)
+
+// mu serialises changes to the tag file and the layer store.
+var mu sync.Mutex
+
+// pending holds the diff IDs of layers added since the daemon started that
+// no image references yet.
+var pending = map[string]bool{}
+
+func configFile(hexDigest string) string {
+	return filepath.Join(ImageDir, "configs", hexDigest)
+}
+
+func parentFile(hexDigest string) string {
+	return filepath.Join(ImageDir, "parents", hexDigest)
+}
+
+func blobFile(hexDigest string) string {
+	return filepath.Join(ImageDir, "blobs", "sha256", hexDigest)
+}
+
+// LayerDir is where the layer with the given diff ID is extracted, in the
+// format overlayfs expects for a lower directory.
+func LayerDir(diffID string) string {
+	return filepath.Join(ImageDir, "layers", strings.TrimPrefix(diffID, digestPrefix))
+}
+
+func repositoriesFile() string {
+	return filepath.Join(ImageDir, "repositories.yaml")
+}
+
+// ShortID is the abbreviated form of an image ID shown to users.
+func ShortID(id string) string {
+	id = strings.TrimPrefix(id, digestPrefix)
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+// NormalizeReference validates a repository reference and adds the "latest"
+// tag when it has none.
+func NormalizeReference(ref string) (string, error) {
+	if !validReference.MatchString(ref) {
+		return "", fmt.Errorf("invalid reference format %q: repository names must be lowercase, tags may use [A-Za-z0-9_.-]", ref)
+	}
+	if !strings.Contains(ref, ":") {
+		ref += ":latest"
+	}
+	return ref, nil
+}
+
+func loadRepositories() (map[string]string, error) {
+	repos := map[string]string{}
+	data, err := os.ReadFile(repositoriesFile())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return repos, nil
+		}
+		return nil, fmt.Errorf("failed to read image tags: %w", err)
+	}
+	if err := yaml.Unmarshal(data, &repos); err != nil {
+		return nil, fmt.Errorf("failed to parse image tags: %w", err)
+	}
+	return repos, nil
+}
+
+func saveRepositories(repos map[string]string) error {
+	data, err := yaml.Marshal(repos)
+	if err != nil {
+		return fmt.Errorf("failed to marshal image tags: %w", err)
+	}
+	tmp := repositoriesFile() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write image tags: %w", err)
+	}
+	return os.Rename(tmp, repositoriesFile())
+}
+
+// Get resolves ref, a repository reference, an image ID or an unambiguous
+// prefix of one, and returns the image with its ID.
+func Get(ref string) (*Image, string, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	id, err := resolve(ref)
+	if err != nil {
+		return nil, "", err
+	}
+	img, err := load(id)
+	return img, id, err
+}
+
+func resolve(ref string) (string, error) {
+	repos, err := loadRepositories()
+	if err != nil {
+		return "", err
+	}
+	if normalized, err := NormalizeReference(ref); err == nil {
+		if id, ok := repos[normalized]; ok {
+			return id, nil
+		}
+	}
+
+	prefix := strings.TrimPrefix(ref, digestPrefix)
+	if len(prefix) == 0 || strings.Trim(prefix, "0123456789abcdef") != "" {
+		return "", fmt.Errorf("%w: %s", ErrImageNotFound, ref)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(ImageDir, "configs"))
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	var matches []string
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), prefix) {
+			matches = append(matches, digestPrefix+entry.Name())
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("%w: %s", ErrImageNotFound, ref)
+	case 1:
+		return matches[0], nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrAmbiguousImage, ref)
+	}
+}
+
+func load(id string) (*Image, error) {
+	data, err := os.ReadFile(configFile(strings.TrimPrefix(id, digestPrefix)))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: %s", ErrImageNotFound, id)
+		}
+		return nil, fmt.Errorf("failed to read image config: %w", err)
+	}
+
+	var img Image
+	if err := json.Unmarshal(data, &img); err != nil {
+		return nil, fmt.Errorf("failed to parse image config: %w", err)
+	}
+	return &img, nil
+}
+
+// Create stores the image configuration and, when ref is set, tags it.
+// parent is the image img was built on, if any; images with children are
+// kept when their parent is pruned. The returned ID is the digest of the
+// configuration.
+func Create(img *Image, parent, ref string) (string, error) {
+	data, err := json.Marshal(img)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal image config: %w", err)
+	}
+	var refs []string
+	if ref != "" {
+		refs = append(refs, ref)
+	}
+	return createConfig(data, parent, refs)
+}
+
+// createConfig stores the raw configuration as is, so that loaded images
+// keep the ID they had where they were saved.
+func createConfig(data []byte, parent string, refs []string) (string, error) {
+	var img Image
+	if err := json.Unmarshal(data, &img); err != nil {
+		return "", fmt.Errorf("failed to parse image config: %w", err)
+	}
+	for i, ref := range refs {
+		normalized, err := NormalizeReference(ref)
+		if err != nil {
+			return "", err
+		}
+		refs[i] = normalized
+	}
+	for _, diffID := range img.RootFS.DiffIDs {
+		if _, err := os.Stat(LayerDir(diffID)); err != nil {
+			return "", fmt.Errorf("layer %s is not in the store: %w", diffID, err)
+		}
+	}
+
+	sum := sha256.Sum256(data)
+	hexDigest := hex.EncodeToString(sum[:])
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(configFile(hexDigest)), 0o755); err != nil {
+		return "", fmt.Errorf("failed to create image directory: %w", err)
+	}
+	if err := os.WriteFile(configFile(hexDigest), data, 0o644); err != nil {
+		return "", fmt.Errorf("failed to write image config: %w", err)
+	}
+	if parent != "" {
+		if err := os.MkdirAll(filepath.Dir(parentFile(hexDigest)), 0o755); err != nil {
+			return "", fmt.Errorf("failed to create image directory: %w", err)
+		}
+		if err := os.WriteFile(parentFile(hexDigest), []byte(parent+"\n"), 0o644); err != nil {
+			return "", fmt.Errorf("failed to record parent image: %w", err)
+		}
+	}
+	for _, diffID := range img.RootFS.DiffIDs {
+		delete(pending, diffID)
+	}
+
+	id := digestPrefix + hexDigest
+	for _, ref := range refs {
+		if err := tag(id, ref); err != nil {
+			return "", err
+		}
+	}
+	log.Printf("Created image %s %s", ShortID(id), strings.Join(refs, " "))
+	return id, nil
+}
+
+// Tag points ref at the image with the given ID, moving it away from any
+// image it named before.
+func Tag(id, ref string) error {
+	normalized, err := NormalizeReference(ref)
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	return tag(id, normalized)
+}
+
+func tag(id, ref string) error {
+	repos, err := loadRepositories()
+	if err != nil {
+		return err
+	}
+	repos[ref] = id
+	return saveRepositories(repos)
+}
+
+// References lists the tags pointing at the image with the given ID.
+func References(id string) ([]string, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	repos, err := loadRepositories()
+	if err != nil {
+		return nil, err
+	}
+	return references(repos, id), nil
+}
+
+func references(repos map[string]string, id string) []string {
+	var refs []string
+	for ref, target := range repos {
+		if target == id {
+			refs = append(refs, ref)
+		}
+	}
+	sort.Strings(refs)
+	return refs
+}
+
+// AddLayer stores the uncompressed layer tar read from r and extracts it for
+// use as an overlay lower directory. It returns the layer's diff ID. Adding
+// a layer that is already stored is a no-op.
+func AddLayer(r io.Reader) (string, error) {
+	blobDir := filepath.Join(ImageDir, "blobs", "sha256")
+	if err := os.MkdirAll(blobDir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create blob directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(blobDir, ".layer-")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, hash), r); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to write layer: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	hexDigest := hex.EncodeToString(hash.Sum(nil))
+	diffID := digestPrefix + hexDigest
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	// Until an image references the layer, pending keeps prune away from it.
+	pending[diffID] = true
+	if _, err := os.Stat(LayerDir(diffID)); err == nil {
+		return diffID, nil
+	}
+	if err := os.Rename(tmp.Name(), blobFile(hexDigest)); err != nil {
+		return "", fmt.Errorf("failed to store layer: %w", err)
+	}
+
+	// Extract next to the final directory and rename, so that a layer
+	// directory is either complete or missing.
+	if err := os.MkdirAll(filepath.Dir(LayerDir(diffID)), 0o755); err != nil {
+		return "", fmt.Errorf("failed to create layer directory: %w", err)
+	}
+	extractDir, err := os.MkdirTemp(filepath.Dir(LayerDir(diffID)), ".extract-")
+	if err != nil {
+		return "", err
+	}
+	blob, err := os.Open(blobFile(hexDigest))
+	if err != nil {
+		os.RemoveAll(extractDir)
+		return "", err
+	}
+	defer blob.Close()
+
+	if err := archive.UntarLayer(blob, extractDir); err != nil {
+		os.RemoveAll(extractDir)
+		return "", fmt.Errorf("failed to extract layer: %w", err)
+	}
+	if err := os.Chmod(extractDir, 0o755); err != nil {
+		os.RemoveAll(extractDir)
+		return "", err
+	}
+	if err := os.Rename(extractDir, LayerDir(diffID)); err != nil {
+		os.RemoveAll(extractDir)
+		return "", fmt.Errorf("failed to store layer: %w", err)
+	}
+
+	log.Printf("Added layer %s", ShortID(diffID))
+	return diffID, nil
+}
+
+// LayerDirs returns the extracted layer directories of img, top layer first
+// as overlayfs expects them.
+func LayerDirs(img *Image) []string {
+	dirs := make([]string, 0, len(img.RootFS.DiffIDs))
+	for i := len(img.RootFS.DiffIDs) - 1; i >= 0; i-- {
+		dirs = append(dirs, LayerDir(img.RootFS.DiffIDs[i]))
+	}
+	return dirs
+}
+
+// Import creates a single layer image from a root filesystem tar archive,
+// optionally gzip compressed, and tags it as ref when set. source is
+// recorded in the image history; changes are Boxfile instructions applied
+// to the default config, as for a commit.
+func Import(r io.Reader, ref, source, comment string, changes []string) (string, error) {
+	if ref != "" {
+		if _, err := NormalizeReference(ref); err != nil {
+			return "", err
+		}
+	}
+	cfg := Config{
+		Env: []string{"PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"},
+	}
+	if err := ApplyChanges(&cfg, changes); err != nil {
+		return "", err
+	}
+
+	layer, err := archive.Decompress(r)
+	if err != nil {
+		return "", err
+	}
+	diffID, err := AddLayer(layer)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now().UTC()
+	img := &Image{
+		Created:      now,
+		Architecture: runtime.GOARCH,
+		OS:           "linux",
+		Config:       cfg,
+		RootFS:       RootFS{Type: "layers", DiffIDs: []string{diffID}},
+		History: []History{{
+			Created:   now,
+			CreatedBy: "boxify import " + source,
+			Comment:   comment,
+		}},
+	}
+	return Create(img, "", ref)
+}
+
+// EnsureBase imports rootfs as the image ref when the image store is still
+// empty. It turns the rootfs boxify used before images into the default
+// image; from then on it is an image like any other and can be removed.
+func EnsureBase(ref, rootfs string) error {
+	if _, err := os.Stat(repositoriesFile()); err == nil {
+		return nil
+	}
+	if _, err := os.Stat(rootfs); err != nil {
+		return fmt.Errorf("base rootfs unavailable: %w", err)
+	}
+
+	log.Printf("Importing %s as image %s", rootfs, ref)
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(archive.TarFilesystem(pw, rootfs, nil))
+	}()
+	defer pr.Close()
+	_, err := Import(pr, ref, rootfs, "", nil)
+	return err
+}
@@ -0,0 +1,534 @@
+package image
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/urizennnn/boxify/pkg/archive"
+)
+
+// Archive formats understood by Save and Load.
+const (
+	FormatDocker = "docker-archive"
+	FormatOCI    = "oci"
+)
+
+const (
+	mediaTypeManifest = "application/vnd.oci.image.manifest.v1+json"
+	mediaTypeIndex    = "application/vnd.oci.image.index.v1+json"
+	mediaTypeConfig   = "application/vnd.oci.image.config.v1+json"
+	mediaTypeLayer    = "application/vnd.oci.image.layer.v1.tar"
+
+	dockerMediaTypeManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+
+	annotationRefName   = "org.opencontainers.image.ref.name"
+	annotationImageName = "io.containerd.image.name"
+)
+
+// dockerManifest is an entry of the manifest.json of a docker-archive.
+type dockerManifest struct {
+	Config   string
+	RepoTags []string
+	Layers   []string
+}
+
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Platform    *struct {
+		Architecture string `json:"architecture"`
+		OS           string `json:"os"`
+	} `json:"platform,omitempty"`
+}
+
+type ociIndex struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	MediaType     string          `json:"mediaType,omitempty"`
+	Manifests     []ociDescriptor `json:"manifests"`
+}
+
+type ociManifest struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	MediaType     string          `json:"mediaType,omitempty"`
+	Config        ociDescriptor   `json:"config"`
+	Layers        []ociDescriptor `json:"layers"`
+}
+
+// savedImage is an image opened for saving. The files stay readable even if
+// the image is removed while the archive is written.
+type savedImage struct {
+	id     string
+	config []byte
+	refs   []string
+	layers []*os.File
+	diffs  []string
+}
+
+func (s *savedImage) close() {
+	for _, layer := range s.layers {
+		layer.Close()
+	}
+}
+
+// Save writes the images refs name to w as a tar archive in the given
+// format. Images named by a tag are saved with that tag, images named by ID
+// without one.
+func Save(w io.Writer, refs []string, format string) error {
+	if format == "" {
+		format = FormatDocker
+	}
+	if format != FormatDocker && format != FormatOCI {
+		return fmt.Errorf("unsupported archive format %q, expected %s or %s", format, FormatDocker, FormatOCI)
+	}
+
+	images, err := openImages(refs)
+	defer func() {
+		for _, s := range images {
+			s.close()
+		}
+	}()
+	if err != nil {
+		return err
+	}
+
+	tw := tar.NewWriter(w)
+	if format == FormatOCI {
+		err = writeOCI(tw, images)
+	} else {
+		err = writeDocker(tw, images)
+	}
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+func openImages(refs []string) ([]*savedImage, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	repos, err := loadRepositories()
+	if err != nil {
+		return nil, err
+	}
+
+	var images []*savedImage
+	byID := map[string]*savedImage{}
+	for _, ref := range refs {
+		id, err := resolve(ref)
+		if err != nil {
+			return images, err
+		}
+
+		s, ok := byID[id]
+		if !ok {
+			s = &savedImage{id: id}
+			images = append(images, s)
+			byID[id] = s
+
+			if s.config, err = os.ReadFile(configFile(strings.TrimPrefix(id, digestPrefix))); err != nil {
+				return images, fmt.Errorf("failed to read image config: %w", err)
+			}
+			var img Image
+			if err := json.Unmarshal(s.config, &img); err != nil {
+				return images, fmt.Errorf("failed to parse image config: %w", err)
+			}
+			for _, diffID := range img.RootFS.DiffIDs {
+				layer, err := os.Open(blobFile(strings.TrimPrefix(diffID, digestPrefix)))
+				if err != nil {
+					return images, fmt.Errorf("failed to open layer %s: %w", ShortID(diffID), err)
+				}
+				s.layers = append(s.layers, layer)
+				s.diffs = append(s.diffs, diffID)
+			}
+		}
+
+		if normalized, err := NormalizeReference(ref); err == nil && repos[normalized] == id {
+			s.refs = append(s.refs, normalized)
+		}
+	}
+	return images, nil
+}
+
+func writeDocker(tw *tar.Writer, images []*savedImage) error {
+	var manifests []dockerManifest
+	written := map[string]bool{}
+	for _, s := range images {
+		hexDigest := strings.TrimPrefix(s.id, digestPrefix)
+		manifest := dockerManifest{Config: hexDigest + ".json", RepoTags: s.refs}
+		if err := writeTarFile(tw, manifest.Config, s.config); err != nil {
+			return err
+		}
+		for i, layer := range s.layers {
+			name := path.Join(strings.TrimPrefix(s.diffs[i], digestPrefix), "layer.tar")
+			manifest.Layers = append(manifest.Layers, name)
+			if written[name] {
+				continue
+			}
+			if err := writeTarBlob(tw, name, layer); err != nil {
+				return err
+			}
+			written[name] = true
+		}
+		manifests = append(manifests, manifest)
+	}
+
+	data, err := json.Marshal(manifests)
+	if err != nil {
+		return err
+	}
+	return writeTarFile(tw, "manifest.json", data)
+}
+
+func writeOCI(tw *tar.Writer, images []*savedImage) error {
+	if err := writeTarFile(tw, "oci-layout", []byte(`{"imageLayoutVersion":"1.0.0"}`)); err != nil {
+		return err
+	}
+
+	index := ociIndex{SchemaVersion: 2, MediaType: mediaTypeIndex}
+	written := map[string]bool{}
+	writeBlob := func(digest string, write func(name string) error) error {
+		name := path.Join("blobs", "sha256", strings.TrimPrefix(digest, digestPrefix))
+		if written[name] {
+			return nil
+		}
+		written[name] = true
+		return write(name)
+	}
+
+	for _, s := range images {
+		manifest := ociManifest{
+			SchemaVersion: 2,
+			MediaType:     mediaTypeManifest,
+			Config: ociDescriptor{
+				MediaType: mediaTypeConfig,
+				Digest:    s.id,
+				Size:      int64(len(s.config)),
+			},
+		}
+		if err := writeBlob(s.id, func(name string) error {
+			return writeTarFile(tw, name, s.config)
+		}); err != nil {
+			return err
+		}
+
+		for i, layer := range s.layers {
+			info, err := layer.Stat()
+			if err != nil {
+				return err
+			}
+			manifest.Layers = append(manifest.Layers, ociDescriptor{
+				MediaType: mediaTypeLayer,
+				Digest:    s.diffs[i],
+				Size:      info.Size(),
+			})
+			if err := writeBlob(s.diffs[i], func(name string) error {
+				return writeTarBlob(tw, name, layer)
+			}); err != nil {
+				return err
+			}
+		}
+
+		data, err := json.Marshal(manifest)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(data)
+		digest := digestPrefix + hex.EncodeToString(sum[:])
+		if err := writeBlob(digest, func(name string) error {
+			return writeTarFile(tw, name, data)
+		}); err != nil {
+			return err
+		}
+
+		descriptor := ociDescriptor{MediaType: mediaTypeManifest, Digest: digest, Size: int64(len(data))}
+		if len(s.refs) == 0 {
+			index.Manifests = append(index.Manifests, descriptor)
+		}
+		for _, ref := range s.refs {
+			_, tag, _ := strings.Cut(ref[strings.LastIndex(ref, "/")+1:], ":")
+			descriptor.Annotations = map[string]string{
+				annotationImageName: ref,
+				annotationRefName:   tag,
+			}
+			index.Manifests = append(index.Manifests, descriptor)
+		}
+	}
+
+	data, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	return writeTarFile(tw, "index.json", data)
+}
+
+func writeTarFile(tw *tar.Writer, name string, data []byte) error {
+	header := &tar.Header{
+		Name:     name,
+		Mode:     0o644,
+		Size:     int64(len(data)),
+		ModTime:  time.Unix(0, 0),
+		Typeflag: tar.TypeReg,
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+func writeTarBlob(tw *tar.Writer, name string, file *os.File) error {
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	header := &tar.Header{
+		Name:     name,
+		Mode:     0o644,
+		Size:     info.Size(),
+		ModTime:  time.Unix(0, 0),
+		Typeflag: tar.TypeReg,
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, io.NewSectionReader(file, 0, info.Size()))
+	return err
+}
+
+// loadSource is an image found in an archive being loaded.
+type loadSource struct {
+	config []byte
+	layers []string
+	refs   []string
+}
+
+// Load imports the images of a docker-archive or OCI layout tar archive
+// read from r, with their layers and tags. It returns the tags of the loaded
+// images, or the ID of an image loaded without one.
+func Load(r io.Reader) ([]string, error) {
+	if err := os.MkdirAll(ImageDir, 0o755); err != nil {
+		return nil, err
+	}
+	dir, err := os.MkdirTemp(ImageDir, ".load-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	input, err := archive.Decompress(r)
+	if err != nil {
+		return nil, err
+	}
+	if err := archive.Untar(input, dir, "."); err != nil {
+		return nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+
+	var sources []loadSource
+	if _, err := os.Stat(filepath.Join(dir, "manifest.json")); err == nil {
+		sources, err = readDockerArchive(dir)
+		if err != nil {
+			return nil, err
+		}
+	} else if _, err := os.Stat(filepath.Join(dir, "index.json")); err == nil {
+		sources, err = readOCILayout(dir)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		return nil, fmt.Errorf("archive has neither a manifest.json nor an index.json, use boxify import for plain filesystem archives")
+	}
+
+	var loaded []string
+	for _, source := range sources {
+		id, err := loadImage(dir, source)
+		if err != nil {
+			return loaded, err
+		}
+		if len(source.refs) == 0 {
+			loaded = append(loaded, id)
+		}
+		loaded = append(loaded, source.refs...)
+	}
+	return loaded, nil
+}
+
+func readDockerArchive(dir string) ([]loadSource, error) {
+	var manifests []dockerManifest
+	if err := readJSON(dir, "manifest.json", &manifests); err != nil {
+		return nil, err
+	}
+
+	var sources []loadSource
+	for _, manifest := range manifests {
+		source := loadSource{layers: manifest.Layers, refs: manifest.RepoTags}
+		var err error
+		if source.config, err = readArchiveFile(dir, manifest.Config); err != nil {
+			return nil, err
+		}
+		sources = append(sources, source)
+	}
+	return sources, nil
+}
+
+func readOCILayout(dir string) ([]loadSource, error) {
+	var index ociIndex
+	if err := readJSON(dir, "index.json", &index); err != nil {
+		return nil, err
+	}
+
+	var sources []loadSource
+	for _, descriptor := range index.Manifests {
+		manifest, err := readOCIManifest(dir, descriptor)
+		if err != nil {
+			return nil, err
+		}
+
+		source := loadSource{}
+		if source.config, err = readBlob(dir, manifest.Config.Digest); err != nil {
+			return nil, err
+		}
+		for _, layer := range manifest.Layers {
+			source.layers = append(source.layers, blobPath(layer.Digest))
+		}
+		if name := descriptor.Annotations[annotationImageName]; name != "" {
+			name = strings.TrimPrefix(strings.TrimPrefix(name, "docker.io/library/"), "docker.io/")
+			source.refs = append(source.refs, name)
+		} else if name := descriptor.Annotations[annotationRefName]; strings.Contains(name, ":") {
+			source.refs = append(source.refs, name)
+		}
+		sources = append(sources, source)
+	}
+	return sources, nil
+}
+
+// readOCIManifest reads the image manifest descriptor points at. For a
+// multi-platform index it picks the manifest for this machine.
+func readOCIManifest(dir string, descriptor ociDescriptor) (*ociManifest, error) {
+	for depth := 0; depth < 4; depth++ {
+		data, err := readBlob(dir, descriptor.Digest)
+		if err != nil {
+			return nil, err
+		}
+		if descriptor.MediaType != mediaTypeIndex && descriptor.MediaType != dockerMediaTypeManifestList {
+			var manifest ociManifest
+			if err := json.Unmarshal(data, &manifest); err != nil {
+				return nil, fmt.Errorf("failed to parse image manifest: %w", err)
+			}
+			return &manifest, nil
+		}
+
+		var index ociIndex
+		if err := json.Unmarshal(data, &index); err != nil {
+			return nil, fmt.Errorf("failed to parse image index: %w", err)
+		}
+		found := false
+		for _, m := range index.Manifests {
+			if m.Platform == nil || (m.Platform.OS == "linux" && m.Platform.Architecture == runtime.GOARCH) {
+				descriptor, found = m, true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("image index %s has no manifest for linux/%s", ShortID(descriptor.Digest), runtime.GOARCH)
+		}
+	}
+	return nil, fmt.Errorf("image index %s is nested too deeply", ShortID(descriptor.Digest))
+}
+
+// loadImage adds the layers of source and stores its configuration,
+// checking that the layers are the ones the configuration lists.
+func loadImage(dir string, source loadSource) (string, error) {
+	var img Image
+	if err := json.Unmarshal(source.config, &img); err != nil {
+		return "", fmt.Errorf("failed to parse image config: %w", err)
+	}
+	if len(img.RootFS.DiffIDs) != len(source.layers) {
+		return "", fmt.Errorf("image config lists %d layers but the archive has %d", len(img.RootFS.DiffIDs), len(source.layers))
+	}
+
+	for i, name := range source.layers {
+		diffID, err := loadLayer(dir, name)
+		if err != nil {
+			return "", err
+		}
+		if diffID != img.RootFS.DiffIDs[i] {
+			return "", fmt.Errorf("layer %s does not match diff ID %s of the image config", name, img.RootFS.DiffIDs[i])
+		}
+	}
+	return createConfig(source.config, "", source.refs)
+}
+
+func loadLayer(dir, name string) (string, error) {
+	p, err := archive.SecureJoin(dir, name)
+	if err != nil {
+		return "", err
+	}
+	file, err := os.Open(p)
+	if err != nil {
+		return "", fmt.Errorf("failed to open layer %s: %w", name, err)
+	}
+	defer file.Close()
+
+	r, err := archive.Decompress(file)
+	if err != nil {
+		return "", fmt.Errorf("layer %s: %w", name, err)
+	}
+	return AddLayer(r)
+}
+
+func blobPath(digest string) string {
+	algorithm, hexDigest, _ := strings.Cut(digest, ":")
+	return path.Join("blobs", algorithm, hexDigest)
+}
+
+// readBlob reads a blob of an OCI layout and verifies its digest.
+func readBlob(dir, digest string) ([]byte, error) {
+	if !strings.HasPrefix(digest, digestPrefix) {
+		return nil, fmt.Errorf("unsupported digest %q", digest)
+	}
+	data, err := readArchiveFile(dir, blobPath(digest))
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	if digestPrefix+hex.EncodeToString(sum[:]) != digest {
+		return nil, fmt.Errorf("blob %s does not match its digest", ShortID(digest))
+	}
+	return data, nil
+}
+
+func readArchiveFile(dir, name string) ([]byte, error) {
+	p, err := archive.SecureJoin(dir, name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, fmt.Errorf("archive is missing %s: %w", name, err)
+	}
+	return data, nil
+}
+
+func readJSON(dir, name string, v interface{}) error {
+	data, err := readArchiveFile(dir, name)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	return nil
+}
@@ -1,20 +1,16 @@
 package network
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"net"
 	"os"
-	"strconv"
-	"strings"
 
 	"github.com/urizennnn/boxify/config"
 	"gopkg.in/yaml.v3"
 )
 
-// func (m *IPManager) ReleaseIP(containerID string) error
-// func (m *IPManager) GetGateway() string
-// func (m *IPManager) AllocateIP(containerID string) (string, error)
-
 func (m *IPManager) GetNextIP() string {
 	if CheckNetworkConfigExists() {
 		networkConfig, err := ReadNetworkConfig("default")
@@ -37,6 +33,12 @@ func (m *IPManager) GetGateway() string {
 }
 
 func (m *IPManager) GetHostNetworks() ([]*net.IPNet, error) {
+	return hostNetworks()
+}
+
+// hostNetworks returns the IPv4 and IPv6 networks of the host's interfaces,
+// leaving out loopback and IPv6 link-local ones, which every interface has.
+func hostNetworks() ([]*net.IPNet, error) {
 	var networks []*net.IPNet
 
 	ifaces, err := net.Interfaces()
@@ -55,10 +57,8 @@ func (m *IPManager) GetHostNetworks() ([]*net.IPNet, error) {
 		}
 
 		for _, addr := range addrs {
-			if ipnet, ok := addr.(*net.IPNet); ok {
-				if ipnet.IP.To4() != nil { // IPv4 only
-					networks = append(networks, ipnet)
-				}
+			if ipnet, ok := addr.(*net.IPNet); ok && !ipnet.IP.IsLinkLocalUnicast() {
+				networks = append(networks, ipnet)
 			}
 		}
 	}
@@ -66,6 +66,8 @@ func (m *IPManager) GetHostNetworks() ([]*net.IPNet, error) {
 	return networks, nil
 }
 
+// isNetworkConflict reports whether cidr overlaps one of the existing
+// networks. Networks of the other address family never overlap.
 func isNetworkConflict(cidr string, existing []*net.IPNet) bool {
 	_, testNet, err := net.ParseCIDR(cidr)
 	if err != nil {
@@ -138,24 +140,16 @@ func (m *IPManager) InitIPManager() (string, error) {
 	return "", nil
 }
 
+// IncrementIp returns the address after ip, IPv4 or IPv6, and stores it as
+// the next address to hand out.
 func (m *IPManager) IncrementIp(ip string) net.IP {
 	log.Printf("IncrementIp: Input IP: %s", ip)
-	splitString := strings.Split(ip, ".")
-	log.Printf("IncrementIp: Split IP into %d parts: %v", len(splitString), splitString)
-	for i := len(splitString) - 1; i >= 0; i-- {
-		if splitString[i] != "255" {
-			log.Printf("IncrementIp: Incrementing octet at index %d: %s", i, splitString[i])
-			transformedInt, err := strconv.Atoi(splitString[i])
-			if err != nil {
-				log.Printf("IncrementIp: Error converting octet to int: %v", err)
-				return nil
-			}
-
-			splitString[i] = strconv.Itoa(transformedInt + 1)
-			break
-		}
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		log.Printf("IncrementIp: Invalid IP address %q", ip)
+		return nil
 	}
-	result := net.ParseIP(strings.Join(splitString, "."))
+	result := nextAddr(parsed)
 	log.Printf("IncrementIp: Result: %v", result)
 
 	if err := m.persistNextIP(result.String()); err != nil {
@@ -165,6 +159,180 @@ func (m *IPManager) IncrementIp(ip string) net.IP {
 	return result
 }
 
+// AllocateIP reserves the next free address in the bridge subnet for the
+// container and records it in the network config.
+func (m *IPManager) AllocateIP(containerID string) (string, error) {
+	_, subnet, err := net.ParseCIDR(m.Gateway.String() + m.BridgeCIDR)
+	if err != nil {
+		return "", fmt.Errorf("invalid bridge subnet: %w", err)
+	}
+
+	if !CheckNetworkConfigExists() {
+		ip := m.nextFreeIP(subnet, m.NextIP, m.Allocated)
+		if ip == nil {
+			return "", errors.New("no free addresses left in " + subnet.String())
+		}
+		m.Allocated[containerID] = ip
+		m.NextIP = nextAddr(ip)
+		return ip.String(), nil
+	}
+
+	configPath := NetworkStorageDir + "/default.yaml"
+	lock := NewFileLock(configPath)
+	if err := lock.AcquireLock(); err != nil {
+		return "", err
+	}
+	defer lock.ReleaseLock()
+
+	networkConfig, err := ReadNetworkConfig("default")
+	if err != nil {
+		return "", err
+	}
+	if networkConfig.Ipam.AllocatedIPs == nil {
+		networkConfig.Ipam.AllocatedIPs = make(map[string]string)
+	}
+
+	allocated := make(map[string]net.IP)
+	for name, ipStr := range networkConfig.Ipam.AllocatedIPs {
+		allocated[name] = net.ParseIP(ipStr)
+	}
+
+	ip := m.nextFreeIP(subnet, net.ParseIP(networkConfig.Ipam.NextIP), allocated)
+	if ip == nil {
+		return "", errors.New("no free addresses left in " + subnet.String())
+	}
+
+	networkConfig.Ipam.AllocatedIPs[containerID] = ip.String()
+	networkConfig.Ipam.NextIP = nextAddr(ip).String()
+	if err := WriteNetworkConfigWithoutLock(networkConfig); err != nil {
+		return "", err
+	}
+
+	m.Allocated[containerID] = ip
+	m.NextIP = nextAddr(ip)
+	log.Printf("AllocateIP: Allocated %s to %s", ip, containerID)
+	return ip.String(), nil
+}
+
+// ReleaseIP returns the container's address to the pool.
+func (m *IPManager) ReleaseIP(containerID string) error {
+	delete(m.Allocated, containerID)
+
+	if !CheckNetworkConfigExists() {
+		return nil
+	}
+
+	configPath := NetworkStorageDir + "/default.yaml"
+	lock := NewFileLock(configPath)
+	if err := lock.AcquireLock(); err != nil {
+		return err
+	}
+	defer lock.ReleaseLock()
+
+	networkConfig, err := ReadNetworkConfig("default")
+	if err != nil {
+		return err
+	}
+
+	released6 := releaseIPv6(&networkConfig.Ipam, containerID)
+	released, exists := networkConfig.Ipam.AllocatedIPs[containerID]
+	if !exists {
+		if released6 {
+			return WriteNetworkConfigWithoutLock(networkConfig)
+		}
+		return nil
+	}
+	delete(networkConfig.Ipam.AllocatedIPs, containerID)
+
+	// Hand the lowest free address out first so the pool does not drift.
+	if ip := net.ParseIP(released); ip != nil {
+		if next := net.ParseIP(networkConfig.Ipam.NextIP); next == nil || bytesLess(ip, next) {
+			networkConfig.Ipam.NextIP = released
+		}
+	}
+
+	log.Printf("ReleaseIP: Released %s from %s", released, containerID)
+	return WriteNetworkConfigWithoutLock(networkConfig)
+}
+
+// nextFreeIP walks the bridge subnet from start, wrapping around once, and
+// returns the first address that is neither allocated, the gateway nor the
+// network or broadcast address.
+func (m *IPManager) nextFreeIP(subnet *net.IPNet, start net.IP, allocated map[string]net.IP) net.IP {
+	return findFreeIP(subnet, start, allocated, m.Gateway)
+}
+
+// findFreeIP is nextFreeIP for any subnet and gateway. A nil gateway
+// reserves no address.
+func findFreeIP(subnet *net.IPNet, start net.IP, allocated map[string]net.IP, gateway net.IP) net.IP {
+	used := make(map[string]bool)
+	for _, ip := range allocated {
+		if ip != nil {
+			used[ip.String()] = true
+		}
+	}
+	if gateway != nil {
+		used[gateway.String()] = true
+	}
+
+	if start == nil || !subnet.Contains(start) {
+		start = nextAddr(subnet.IP)
+	}
+
+	ones, bits := subnet.Mask.Size()
+	size := 1 << uint(min(bits-ones, 24))
+
+	ip := start
+	for i := 0; i < size; i++ {
+		if !subnet.Contains(ip) {
+			ip = nextAddr(subnet.IP)
+		}
+		if !used[ip.String()] && !ip.Equal(subnet.IP) && !isBroadcast(ip, subnet) {
+			return ip
+		}
+		ip = nextAddr(ip)
+	}
+	return nil
+}
+
+func nextAddr(ip net.IP) net.IP {
+	next := make(net.IP, len(ip))
+	copy(next, ip)
+	if v4 := next.To4(); v4 != nil {
+		next = v4
+	}
+	for i := len(next) - 1; i >= 0; i-- {
+		next[i]++
+		if next[i] != 0 {
+			break
+		}
+	}
+	return next
+}
+
+func isBroadcast(ip net.IP, subnet *net.IPNet) bool {
+	if ip.To4() == nil {
+		return false
+	}
+	ip = ip.To4()
+	for i := range ip {
+		if ip[i]|subnet.Mask[len(subnet.Mask)-len(ip)+i] != 0xff {
+			return false
+		}
+	}
+	return true
+}
+
+func bytesLess(a, b net.IP) bool {
+	a, b = a.To16(), b.To16()
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return false
+}
+
 func (m *IPManager) persistNextIP(nextIP string) error {
 	if !CheckNetworkConfigExists() {
 		return nil
@@ -182,8 +350,13 @@ func (m *IPManager) persistNextIP(nextIP string) error {
 		return err
 	}
 
+	next := net.ParseIP(nextIP)
+	if next.To4() == nil {
+		networkConfig.Ipam.NextIP6 = nextIP
+		return WriteNetworkConfigWithoutLock(networkConfig)
+	}
 	networkConfig.Ipam.NextIP = nextIP
-	m.NextIP = net.ParseIP(nextIP)
+	m.NextIP = next
 
 	return WriteNetworkConfigWithoutLock(networkConfig)
 }
@@ -0,0 +1,320 @@
+package network
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/urizennnn/boxify/config"
+)
+
+func TestNextAddr(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{"10.0.0.1", "10.0.0.2"},
+		{"10.0.0.255", "10.0.1.0"},
+		{"10.0.255.255", "10.1.0.0"},
+		{"10.255.255.255", "11.0.0.0"},
+		{"fd00::1", "fd00::2"},
+		{"fd00::ff", "fd00::100"},
+		{"fd00::ffff", "fd00::1:0"},
+		{"fd00::ffff:ffff:ffff:ffff", "fd00:0:0:1::"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			ip := net.ParseIP(tt.ip)
+			got := nextAddr(ip)
+			if got.String() != tt.want {
+				t.Fatalf("nextAddr(%s) = %s, want %s", tt.ip, got, tt.want)
+			}
+			if ip.String() != tt.ip {
+				t.Fatalf("nextAddr modified its argument to %s", ip)
+			}
+		})
+	}
+}
+
+func TestIncrementIp(t *testing.T) {
+	useTempStorage(t)
+	m := &IPManager{}
+
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{"172.17.0.254", "172.17.0.255"},
+		{"172.17.0.255", "172.17.1.0"},
+		{"fd00::ffff", "fd00::1:0"},
+	}
+	for _, tt := range tests {
+		if got := m.IncrementIp(tt.ip); got.String() != tt.want {
+			t.Errorf("IncrementIp(%s) = %s, want %s", tt.ip, got, tt.want)
+		}
+	}
+	if got := m.IncrementIp("not-an-ip"); got != nil {
+		t.Errorf("IncrementIp(not-an-ip) = %s, want nil", got)
+	}
+}
+
+func TestIncrementIpPersistsNextIP(t *testing.T) {
+	useTempStorage(t)
+	if err := WriteNetworkConfig(&config.NetworkStorage{Name: "default"}); err != nil {
+		t.Fatal(err)
+	}
+
+	m := &IPManager{}
+	m.IncrementIp("172.17.0.255")
+	m.IncrementIp("fd00::ffff")
+
+	stored, err := ReadNetworkConfig("default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Ipam.NextIP != "172.17.1.0" || stored.Ipam.NextIP6 != "fd00::1:0" {
+		t.Fatalf("stored NextIP %q NextIP6 %q, want 172.17.1.0 and fd00::1:0", stored.Ipam.NextIP, stored.Ipam.NextIP6)
+	}
+}
+
+func TestAllocateIP(t *testing.T) {
+	// Without a stored default network the bridge pool lives in memory.
+	useTempStorage(t)
+	m := &IPManager{
+		BridgeCIDR: "/29",
+		Gateway:    net.ParseIP("10.0.0.1"),
+		Allocated:  map[string]net.IP{},
+	}
+
+	// 10.0.0.0/29: .0 is the subnet, .1 the gateway and .7 the broadcast.
+	for i, want := range []string{"10.0.0.2", "10.0.0.3", "10.0.0.4", "10.0.0.5", "10.0.0.6"} {
+		got, err := m.AllocateIP(string(rune('a' + i)))
+		if err != nil || got != want {
+			t.Fatalf("AllocateIP() = %q, %v, want %s", got, err, want)
+		}
+	}
+	if got, err := m.AllocateIP("f"); err == nil || !strings.Contains(err.Error(), "no free addresses") {
+		t.Fatalf("AllocateIP() on a full subnet = %q, %v", got, err)
+	}
+
+	if err := m.ReleaseIP("c"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := m.AllocateIP("f"); err != nil || got != "10.0.0.4" {
+		t.Fatalf("AllocateIP() after release = %q, %v, want 10.0.0.4", got, err)
+	}
+}
+
+func TestSetIPv6Ipam(t *testing.T) {
+	tests := []struct {
+		name        string
+		subnet      string
+		gateway     string
+		noGateway   bool
+		wantSubnet  string
+		wantGateway string
+		wantErr     string
+	}{
+		{name: "default gateway", subnet: "fd00:1::/64", wantSubnet: "fd00:1::/64", wantGateway: "fd00:1::1"},
+		{name: "masked subnet", subnet: "fd00:1::42/64", wantSubnet: "fd00:1::/64", wantGateway: "fd00:1::1"},
+		{name: "given gateway", subnet: "fd00:1::/64", gateway: "fd00:1::fffe", wantSubnet: "fd00:1::/64", wantGateway: "fd00:1::fffe"},
+		{name: "no gateway", subnet: "fd00:1::/64", gateway: "fd00:1::fffe", noGateway: true, wantSubnet: "fd00:1::/64"},
+		{name: "smallest subnet", subnet: "fd00:1::/126", wantSubnet: "fd00:1::/126", wantGateway: "fd00:1::1"},
+		{name: "too small", subnet: "fd00:1::/127", wantErr: "too small"},
+		{name: "IPv4 subnet", subnet: "10.0.0.0/24", wantErr: "IPv4 subnet"},
+		{name: "invalid subnet", subnet: "fd00:1::", wantErr: "invalid IPv6 subnet"},
+		{name: "gateway outside subnet", subnet: "fd00:1::/64", gateway: "fd00:2::1", wantErr: "not in subnet"},
+		{name: "IPv4 gateway", subnet: "fd00:1::/64", gateway: "10.0.0.1", wantErr: "not in subnet"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ipam := &config.NetworkIpam{NextIP6: "fd00:9::9", AllocatedIPs6: map[string]string{"old": "fd00:9::9"}}
+			err := setIPv6Ipam(ipam, tt.subnet, tt.gateway, tt.noGateway)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("setIPv6Ipam() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("setIPv6Ipam() error = %v", err)
+			}
+			if ipam.Subnet6 != tt.wantSubnet || ipam.Gateway6 != tt.wantGateway {
+				t.Fatalf("got subnet %q gateway %q, want %q %q", ipam.Subnet6, ipam.Gateway6, tt.wantSubnet, tt.wantGateway)
+			}
+			if ipam.NextIP6 != "" || len(ipam.AllocatedIPs6) != 0 {
+				t.Fatalf("allocations of the previous subnet were kept: %q %v", ipam.NextIP6, ipam.AllocatedIPs6)
+			}
+		})
+	}
+}
+
+func TestSetIPv6IpamGeneratesSubnet(t *testing.T) {
+	ipam := &config.NetworkIpam{}
+	if err := setIPv6Ipam(ipam, "", "", false); err != nil {
+		t.Fatalf("setIPv6Ipam() error = %v", err)
+	}
+	_, subnet, err := net.ParseCIDR(ipam.Subnet6)
+	if err != nil {
+		t.Fatalf("generated subnet %q: %v", ipam.Subnet6, err)
+	}
+	if ones, _ := subnet.Mask.Size(); ones != 64 || subnet.IP[0] != 0xfd {
+		t.Fatalf("generated subnet %s, want a unique local /64", subnet)
+	}
+	if gw := net.ParseIP(ipam.Gateway6); !gw.Equal(nextAddr(subnet.IP)) {
+		t.Fatalf("gateway %s, want the subnet's first address", ipam.Gateway6)
+	}
+}
+
+func TestAllocateIPv6(t *testing.T) {
+	// fd00::/126 holds fd00::0 to fd00::3; ::0 is the subnet and ::1 the
+	// gateway, leaving two addresses.
+	newIpam := func() *config.NetworkIpam {
+		ipam := &config.NetworkIpam{}
+		if err := setIPv6Ipam(ipam, "fd00::/126", "", false); err != nil {
+			t.Fatal(err)
+		}
+		return ipam
+	}
+
+	type step struct {
+		release   string
+		container string
+		requested string
+		want      string
+		wantErr   error
+		errText   string
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "runs out",
+			steps: []step{
+				{container: "a", want: "fd00::2/126"},
+				{container: "b", want: "fd00::3/126"},
+				{container: "c", errText: "no free addresses"},
+			},
+		},
+		{
+			name: "reuse after release",
+			steps: []step{
+				{container: "a", want: "fd00::2/126"},
+				{container: "b", want: "fd00::3/126"},
+				{release: "a"},
+				{container: "c", want: "fd00::2/126"},
+				{release: "b"},
+				{container: "d", requested: "fd00::3", want: "fd00::3/126"},
+			},
+		},
+		{
+			name: "requested address",
+			steps: []step{
+				{container: "a", requested: "fd00::3", want: "fd00::3/126"},
+				{container: "b", want: "fd00::2/126"},
+			},
+		},
+		{
+			name: "requested address in use",
+			steps: []step{
+				{container: "a", want: "fd00::2/126"},
+				{container: "b", requested: "fd00::2", wantErr: ErrAddressInUse},
+			},
+		},
+		{
+			name: "requested gateway",
+			steps: []step{
+				{container: "a", requested: "fd00::1", wantErr: ErrInvalidAddress, errText: "reserved"},
+			},
+		},
+		{
+			name: "requested subnet address",
+			steps: []step{
+				{container: "a", requested: "fd00::", wantErr: ErrInvalidAddress, errText: "reserved"},
+			},
+		},
+		{
+			name: "requested address outside subnet",
+			steps: []step{
+				{container: "a", requested: "fd00::4", wantErr: ErrInvalidAddress, errText: "not in subnet"},
+			},
+		},
+		{
+			name: "requested IPv4 address",
+			steps: []step{
+				{container: "a", requested: "10.0.0.2", wantErr: ErrInvalidAddress, errText: "not an IPv6 address"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ipam := newIpam()
+			for _, s := range tt.steps {
+				if s.release != "" {
+					if !releaseIPv6(ipam, s.release) {
+						t.Fatalf("releaseIPv6(%s) found no address", s.release)
+					}
+					continue
+				}
+
+				got, err := allocateIPv6(ipam, s.container, s.requested)
+				if s.wantErr != nil || s.errText != "" {
+					if s.wantErr != nil && !errors.Is(err, s.wantErr) {
+						t.Fatalf("allocateIPv6(%s, %q) error = %v, want %v", s.container, s.requested, err, s.wantErr)
+					}
+					if err == nil || !strings.Contains(err.Error(), s.errText) {
+						t.Fatalf("allocateIPv6(%s, %q) error = %v, want it to contain %q", s.container, s.requested, err, s.errText)
+					}
+					if _, ok := ipam.AllocatedIPs6[s.container]; ok {
+						t.Fatalf("failed allocation recorded an address for %s", s.container)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatalf("allocateIPv6(%s, %q) error = %v", s.container, s.requested, err)
+				}
+				if got != s.want {
+					t.Fatalf("allocateIPv6(%s, %q) = %s, want %s", s.container, s.requested, got, s.want)
+				}
+			}
+		})
+	}
+}
+
+func TestReleaseIPv6(t *testing.T) {
+	ipam := &config.NetworkIpam{}
+	if err := setIPv6Ipam(ipam, "fd00::/64", "", false); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"a", "b", "c"} {
+		if _, err := allocateIPv6(ipam, id, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if ipam.NextIP6 != "fd00::5" {
+		t.Fatalf("NextIP6 = %s, want fd00::5", ipam.NextIP6)
+	}
+
+	if releaseIPv6(ipam, "unknown") {
+		t.Fatalf("releaseIPv6 reported an address for an unknown container")
+	}
+	if !releaseIPv6(ipam, "c") || ipam.NextIP6 != "fd00::4" {
+		t.Fatalf("after releasing c NextIP6 = %s, want fd00::4", ipam.NextIP6)
+	}
+	if !releaseIPv6(ipam, "a") || ipam.NextIP6 != "fd00::2" {
+		t.Fatalf("after releasing a NextIP6 = %s, want fd00::2", ipam.NextIP6)
+	}
+	// A higher address does not move NextIP6 back up.
+	ipam.AllocatedIPs6["c"] = "fd00::4"
+	if !releaseIPv6(ipam, "c") || ipam.NextIP6 != "fd00::2" {
+		t.Fatalf("after releasing c again NextIP6 = %s, want fd00::2", ipam.NextIP6)
+	}
+	if _, ok := ipam.AllocatedIPs6["b"]; !ok || len(ipam.AllocatedIPs6) != 1 {
+		t.Fatalf("AllocatedIPs6 = %v, want only b", ipam.AllocatedIPs6)
+	}
+}
@@ -0,0 +1,309 @@
+package network
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+
+	"github.com/urizennnn/boxify/config"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+var (
+	ErrInvalidAddress = errors.New("invalid address")
+	ErrAddressInUse   = errors.New("address already in use")
+)
+
+// parseIPv6Subnet validates an IPv6 subnet. Its address is masked, so
+// fd00::1/64 becomes fd00::/64.
+func parseIPv6Subnet(subnet string) (*net.IPNet, error) {
+	_, ipNet, err := net.ParseCIDR(subnet)
+	if err != nil {
+		return nil, fmt.Errorf("invalid IPv6 subnet %q: %w", subnet, err)
+	}
+	if ipNet.IP.To4() != nil {
+		return nil, fmt.Errorf("invalid IPv6 subnet %q, it is an IPv4 subnet", subnet)
+	}
+	if ones, _ := ipNet.Mask.Size(); ones > 126 {
+		return nil, fmt.Errorf("IPv6 subnet %s is too small, use a /126 or larger", ipNet)
+	}
+	return ipNet, nil
+}
+
+// uniqueLocalSubnet picks a random unique local /64 (RFC 4193) that none of
+// the host's networks overlaps.
+func uniqueLocalSubnet() (*net.IPNet, error) {
+	existing, err := hostNetworks()
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 0; attempt < 10; attempt++ {
+		ip := make(net.IP, net.IPv6len)
+		ip[0] = 0xfd
+		if _, err := rand.Read(ip[1:6]); err != nil {
+			return nil, err
+		}
+		subnet := &net.IPNet{IP: ip, Mask: net.CIDRMask(64, 128)}
+		if !isNetworkConflict(subnet.String(), existing) {
+			return subnet, nil
+		}
+	}
+	return nil, errors.New("could not find a free IPv6 subnet")
+}
+
+// setIPv6Ipam gives ipam an IPv6 subnet and gateway. An empty subnet is
+// replaced by a generated unique local /64 and an empty gateway by the
+// subnet's first address. Networks that route through their parent pass
+// noGateway and get none.
+func setIPv6Ipam(ipam *config.NetworkIpam, subnet, gateway string, noGateway bool) error {
+	var ipNet *net.IPNet
+	var err error
+	if subnet == "" {
+		ipNet, err = uniqueLocalSubnet()
+	} else {
+		ipNet, err = parseIPv6Subnet(subnet)
+	}
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case noGateway:
+		gateway = ""
+	case gateway == "":
+		gateway = nextAddr(ipNet.IP).String()
+	default:
+		gw := net.ParseIP(gateway)
+		if gw == nil || gw.To4() != nil || !ipNet.Contains(gw) {
+			return fmt.Errorf("IPv6 gateway %s is not in subnet %s", gateway, ipNet)
+		}
+		gateway = gw.String()
+	}
+
+	ipam.Subnet6 = ipNet.String()
+	ipam.Gateway6 = gateway
+	ipam.NextIP6 = ""
+	ipam.AllocatedIPs6 = map[string]string{}
+	return nil
+}
+
+// allocateIPv6 reserves an address in the IPv6 subnet of ipam for the
+// container: requested when it is set, the next free one otherwise. It
+// returns the address with the subnet's prefix length, e.g. fd00::2/64.
+func allocateIPv6(ipam *config.NetworkIpam, containerID, requested string) (string, error) {
+	_, subnet, err := net.ParseCIDR(ipam.Subnet6)
+	if err != nil {
+		return "", fmt.Errorf("invalid IPv6 subnet: %w", err)
+	}
+
+	used := make(map[string]net.IP)
+	for id, ipStr := range ipam.AllocatedIPs6 {
+		used[id] = net.ParseIP(ipStr)
+	}
+	gateway := net.ParseIP(ipam.Gateway6)
+
+	var ip net.IP
+	if requested != "" {
+		ip = net.ParseIP(requested)
+		switch {
+		case ip == nil || ip.To4() != nil:
+			return "", fmt.Errorf("%w: %q is not an IPv6 address", ErrInvalidAddress, requested)
+		case !subnet.Contains(ip):
+			return "", fmt.Errorf("%w: %s is not in subnet %s", ErrInvalidAddress, ip, subnet)
+		case ip.Equal(subnet.IP) || ip.Equal(gateway):
+			return "", fmt.Errorf("%w: %s is reserved", ErrInvalidAddress, ip)
+		}
+		for _, u := range used {
+			if ip.Equal(u) {
+				return "", fmt.Errorf("%w: %s", ErrAddressInUse, ip)
+			}
+		}
+	} else {
+		ip = findFreeIP(subnet, net.ParseIP(ipam.NextIP6), used, gateway)
+		if ip == nil {
+			return "", errors.New("no free addresses left in " + subnet.String())
+		}
+		ipam.NextIP6 = nextAddr(ip).String()
+	}
+
+	if ipam.AllocatedIPs6 == nil {
+		ipam.AllocatedIPs6 = make(map[string]string)
+	}
+	ipam.AllocatedIPs6[containerID] = ip.String()
+
+	ones, _ := subnet.Mask.Size()
+	return fmt.Sprintf("%s/%d", ip, ones), nil
+}
+
+// releaseIPv6 returns the container's IPv6 address to the pool of ipam and
+// reports whether it had one.
+func releaseIPv6(ipam *config.NetworkIpam, containerID string) bool {
+	released, exists := ipam.AllocatedIPs6[containerID]
+	if !exists {
+		return false
+	}
+	delete(ipam.AllocatedIPs6, containerID)
+
+	if ip := net.ParseIP(released); ip != nil {
+		if next := net.ParseIP(ipam.NextIP6); next == nil || bytesLess(ip, next) {
+			ipam.NextIP6 = released
+		}
+	}
+	log.Printf("releaseIPv6: Released %s from %s", released, containerID)
+	return true
+}
+
+// EnableIPv6 gives the default network an IPv6 subnet: subnet when it is
+// set, otherwise the one stored with the network or a generated unique
+// local /64. The stored subnet can only be changed while no container has
+// an address from it.
+func (m *IPManager) EnableIPv6(subnet string) error {
+	if !CheckNetworkConfigExists() {
+		return errors.New("the default network config does not exist")
+	}
+	if subnet != "" {
+		ipNet, err := parseIPv6Subnet(subnet)
+		if err != nil {
+			return err
+		}
+		subnet = ipNet.String()
+	}
+
+	configPath := NetworkStorageDir + "/default.yaml"
+	lock := NewFileLock(configPath)
+	if err := lock.AcquireLock(); err != nil {
+		return err
+	}
+	defer lock.ReleaseLock()
+
+	networkConfig, err := ReadNetworkConfig("default")
+	if err != nil {
+		return err
+	}
+	ipam := &networkConfig.Ipam
+
+	switch {
+	case ipam.Subnet6 != "" && (subnet == "" || subnet == ipam.Subnet6):
+		log.Printf("EnableIPv6: Using stored IPv6 subnet %s", ipam.Subnet6)
+	case len(ipam.AllocatedIPs6) > 0:
+		return fmt.Errorf("IPv6 subnet %s is in use, remove its containers before changing it to %s", ipam.Subnet6, subnet)
+	default:
+		if err := setIPv6Ipam(ipam, subnet, "", false); err != nil {
+			return err
+		}
+		if err := WriteNetworkConfigWithoutLock(networkConfig); err != nil {
+			return err
+		}
+		log.Printf("EnableIPv6: Using IPv6 subnet %s", ipam.Subnet6)
+	}
+
+	_, m.Subnet6, _ = net.ParseCIDR(ipam.Subnet6)
+	m.Gateway6 = net.ParseIP(ipam.Gateway6)
+	return nil
+}
+
+// AllocateIP6 reserves an IPv6 address on the default network for the
+// container, requested when it is set. It returns the address with the
+// subnet's prefix length, or "" when the network has no IPv6.
+func (m *IPManager) AllocateIP6(containerID, requested string) (string, error) {
+	if m.Subnet6 == nil {
+		if requested != "" {
+			return "", fmt.Errorf("%w: the default network has no IPv6, start boxifyd with --ipv6", ErrInvalidAddress)
+		}
+		return "", nil
+	}
+
+	configPath := NetworkStorageDir + "/default.yaml"
+	lock := NewFileLock(configPath)
+	if err := lock.AcquireLock(); err != nil {
+		return "", err
+	}
+	defer lock.ReleaseLock()
+
+	networkConfig, err := ReadNetworkConfig("default")
+	if err != nil {
+		return "", err
+	}
+	ip, err := allocateIPv6(&networkConfig.Ipam, containerID, requested)
+	if err != nil {
+		return "", err
+	}
+	if err := WriteNetworkConfigWithoutLock(networkConfig); err != nil {
+		return "", err
+	}
+
+	log.Printf("AllocateIP6: Allocated %s to %s", ip, containerID)
+	return ip, nil
+}
+
+// AddIPv6Gateway enables IPv6 on the bridge and gives it the default
+// network's IPv6 gateway address, dropping addresses of an earlier subnet.
+func (m *BridgeManager) AddIPv6Gateway(ip *IPManager) error {
+	sysctl := "/proc/sys/net/ipv6/conf/" + m.DefaultBridge + "/disable_ipv6"
+	if err := os.WriteFile(sysctl, []byte("0"), 0o644); err != nil {
+		return fmt.Errorf("enabling IPv6 on %s: %w", m.DefaultBridge, err)
+	}
+
+	bridgeLink, err := netlink.LinkByName(m.DefaultBridge)
+	if err != nil {
+		return err
+	}
+	gateway := &net.IPNet{IP: ip.Gateway6, Mask: ip.Subnet6.Mask}
+
+	addrs, err := netlink.AddrList(bridgeLink, netlink.FAMILY_V6)
+	if err != nil {
+		return err
+	}
+	assigned := false
+	for _, addr := range addrs {
+		switch {
+		case addr.IP.IsLinkLocalUnicast():
+		case addr.IPNet.String() == gateway.String():
+			assigned = true
+		default:
+			log.Printf("[IPv6] Removing stale address %s from bridge %s", addr.IPNet, m.DefaultBridge)
+			if err := netlink.AddrDel(bridgeLink, &addr); err != nil {
+				log.Printf("[IPv6] Could not remove %s: %v", addr.IPNet, err)
+			}
+		}
+	}
+	if assigned {
+		log.Printf("[IPv6] Bridge %s already has %s", m.DefaultBridge, gateway)
+		return nil
+	}
+
+	log.Printf("[IPv6] Assigning %s to bridge %s", gateway, m.DefaultBridge)
+	return netlink.AddrAdd(bridgeLink, &netlink.Addr{IPNet: gateway, Flags: unix.IFA_F_NODAD})
+}
+
+// addIPv6 assigns an IPv6 address to a container's interface, which must be
+// up, and routes IPv6 through it, via gateway when there is one. The
+// address skips duplicate address detection, the IPAM made it unique, so
+// it is usable at once.
+func addIPv6(handle *netlink.Handle, link netlink.Link, ipAddr, gateway string) error {
+	addr, err := netlink.ParseAddr(ipAddr)
+	if err != nil {
+		return err
+	}
+	addr.Flags = unix.IFA_F_NODAD
+	if err := handle.AddrAdd(link, addr); err != nil {
+		return fmt.Errorf("assigning %s: %w", ipAddr, err)
+	}
+
+	_, defaultDst, _ := net.ParseCIDR("::/0")
+	route := &netlink.Route{Dst: defaultDst, LinkIndex: link.Attrs().Index}
+	if gateway != "" {
+		route.Gw = net.ParseIP(gateway)
+	} else {
+		route.Scope = netlink.SCOPE_LINK
+	}
+	if err := handle.RouteAdd(route); err != nil {
+		return fmt.Errorf("adding IPv6 default route: %w", err)
+	}
+	return nil
+}
@@ -1,11 +1,22 @@
 package network
 
 import (
+	"fmt"
 	"log"
 	"net"
 )
 
-func NewNetworkManager() (*NetworkManager, error) {
+// Options configure the default bridge network.
+type Options struct {
+	// IPv6 gives the bridge and its containers IPv6 addresses besides
+	// their IPv4 ones.
+	IPv6 bool
+	// IPv6Subnet is the bridge's IPv6 subnet. When it is empty the stored
+	// one is used, or a unique local /64 is generated.
+	IPv6Subnet string
+}
+
+func NewNetworkManager(opts Options) (*NetworkManager, error) {
 	ipManager := &IPManager{
 		Allocated: make(map[string]net.IP),
 	}
@@ -23,6 +34,15 @@ func NewNetworkManager() (*NetworkManager, error) {
 		return nil, nil
 	}
 
+	if opts.IPv6 {
+		if err := ipManager.EnableIPv6(opts.IPv6Subnet); err != nil {
+			return nil, fmt.Errorf("enabling IPv6: %w", err)
+		}
+		if err := bridgeManager.AddIPv6Gateway(ipManager); err != nil {
+			return nil, fmt.Errorf("enabling IPv6: %w", err)
+		}
+	}
+
 	vethManager := &VethManager{
 		veths: make(map[string][2]string),
 	}
@@ -3,6 +3,7 @@ package network
 import (
 	"log"
 	"net"
+	"runtime"
 
 	"github.com/urizennnn/boxify/pkg/daemon/types"
 	"github.com/vishvananda/netlink"
@@ -103,15 +104,14 @@ func (m *NetworkManager) AssignIPToVethInContainerNamespace(containerId string,
 	}
 	log.Printf("[AssignIP] Found veth %s (index: %d)", "eth0", containerVeth.Attrs().Index)
 
-	ipAddr := m.IpManager.GetNextIP()
+	ipAddr := container.NetworkInfo.IP
 	if ipAddr == "" {
 		log.Printf("[AssignIP] Container %v does not have an IP address", containerId)
 		return nil
 	}
 	log.Printf("[AssignIP] Got IP address: %s", ipAddr)
 
-	// FIX: dynamically set subnet mask
-	addr, err := netlink.ParseAddr(ipAddr + "/16")
+	addr, err := netlink.ParseAddr(ipAddr)
 	if err != nil {
 		log.Printf("[AssignIP] Failed to parse addr %s: %v", ipAddr, err)
 		return err
@@ -153,6 +153,14 @@ func (m *NetworkManager) AssignIPToVethInContainerNamespace(containerId string,
 	}
 	log.Printf("[AssignIP] Successfully added default route")
 
+	if ip6 := container.NetworkInfo.IPv6; ip6 != "" {
+		log.Printf("[AssignIP] Adding IPv6 address %s and default route via %s", ip6, container.NetworkInfo.IPv6Gateway)
+		if err := addIPv6(&netlink.Handle{}, containerVeth, ip6, container.NetworkInfo.IPv6Gateway); err != nil {
+			log.Printf("[AssignIP] Could not configure IPv6: %v", err)
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -191,6 +199,49 @@ func (m *NetworkManager) CreateLoopbackInContainerNamespace(containerId string,
 	return nil
 }
 
+// SetupLoopbackOnly brings up the loopback interface of a container whose
+// network namespace has no other interface.
+func (m *NetworkManager) SetupLoopbackOnly(containerId string, damon ContainerGetter) error {
+	container, err := damon.GetContainer(containerId)
+	if err != nil {
+		return err
+	}
+
+	// Namespace switches apply to the OS thread, keep this goroutine on it.
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	origNS, err := GetOriginalNS()
+	if err != nil {
+		log.Printf("[Loopback] Failed to get original namespace: %v", err)
+		return err
+	}
+	defer func() {
+		if err := netns.Set(netns.NsHandle(origNS.Fd())); err != nil {
+			log.Printf("[Loopback] FAILED to restore original namespace: %v", err)
+		}
+		origNS.Close()
+	}()
+
+	containerFD, err := GetNsFD(container.PID)
+	if err != nil {
+		log.Printf("[Loopback] Could not get netns fd for container %s: %v", containerId, err)
+		return err
+	}
+	defer containerFD.Close()
+	if err := netns.Set(netns.NsHandle(containerFD.Fd())); err != nil {
+		log.Printf("[Loopback] Failed to switch to container namespace: %v", err)
+		return err
+	}
+
+	lo, err := netlink.LinkByName("lo")
+	if err != nil {
+		log.Printf("[Loopback] Could not find loopback interface: %v", err)
+		return err
+	}
+	return netlink.LinkSetUp(lo)
+}
+
 func SetupContainerNetworkStandalone(containerID, containerVethName, gateway, ipAddr string) error {
 	containerVeth, err := netlink.LinkByName(containerVethName)
 	if err != nil {
@@ -275,6 +326,10 @@ func SetupContainerNetworkStandalone(containerID, containerVethName, gateway, ip
 func (m *NetworkManager) SetupContainerInterface(containerId string, damon ContainerGetter, containerVeth string) error {
 	log.Printf("[SetupInterface] ========== Starting network setup for container %s ==========", containerId)
 
+	// Namespace switches apply to the OS thread, keep this goroutine on it.
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
 	log.Printf("[SetupInterface] Saving original namespace")
 	origNS, err := GetOriginalNS()
 	if err != nil {
@@ -3,8 +3,13 @@ package network
 // TODO: implement port forwarding rules at a later time
 
 import (
+	"fmt"
 	"log"
+	"os"
 	"os/exec"
+	"strings"
+
+	"github.com/vishvananda/netlink"
 )
 
 func (m *NatManager) enableIPForwarding() error {
@@ -77,8 +82,105 @@ func (m *NatManager) RemoveMasquerading() error {
 	cmd := exec.Command("iptables", "-t", "nat", "-D", "POSTROUTING", "-s", fullCIDR, "!", "-o", bridgeDetails.DefaultBridge, "-j", "MASQUERADE")
 	if err := cmd.Run(); err != nil {
 		log.Printf("Error removing masquerading: %v", err)
+	}
+
+	if ipCidr.Subnet6 != nil {
+		bridge := bridgeDetails.DefaultBridge
+		rules := [][]string{
+			{"-t", "nat", "-D", "POSTROUTING", "-s", ipCidr.Subnet6.String(), "!", "-o", bridge, "-j", "MASQUERADE"},
+			{"-t", "filter", "-D", "FORWARD", "-i", bridge, "-j", "ACCEPT"},
+			{"-t", "filter", "-D", "FORWARD", "-o", bridge, "-m", "conntrack", "--ctstate", "RELATED,ESTABLISHED", "-j", "ACCEPT"},
+		}
+		for _, rule := range rules {
+			if out, err := exec.Command("ip6tables", rule...).CombinedOutput(); err != nil {
+				log.Printf("Error removing IPv6 rule %v: %v, output: %s", rule, err, out)
+			}
+		}
+	}
+	return nil
+}
+
+// setupIPv6 enables IPv6 forwarding and gives the bridge's IPv6 subnet the
+// same ip6tables masquerading and forwarding rules its IPv4 subnet has.
+func (m *NatManager) setupIPv6() error {
+	bridge := m.BridgeManager.ReturnBridgeDetails().DefaultBridge
+
+	// With forwarding on, interfaces with accept_ra 1 ignore router
+	// advertisements and a default route learned through SLAAC expires, so
+	// the uplinks are switched to 2 first.
+	if err := keepAcceptingRA(bridge); err != nil {
+		return err
+	}
+	cmd := exec.Command("sysctl", "-w", "net.ipv6.conf.all.forwarding=1")
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("enabling IPv6 forwarding: %w", err)
+	}
+
+	subnet := m.IpManager.GetIpDetails().Subnet6.String()
+	log.Printf("Setting up IPv6 masquerading and forwarding for %s via bridge %s", subnet, bridge)
+
+	if err := ensureIP6tablesRule("nat", "POSTROUTING", "-s", subnet, "!", "-o", bridge, "-j", "MASQUERADE"); err != nil {
+		return err
+	}
+	if err := ensureIP6tablesRule("filter", "FORWARD", "-i", bridge, "-j", "ACCEPT"); err != nil {
+		return err
+	}
+	return ensureIP6tablesRule("filter", "FORWARD", "-o", bridge, "-m", "conntrack", "--ctstate", "RELATED,ESTABLISHED", "-j", "ACCEPT")
+}
+
+// keepAcceptingRA sets accept_ra from 1 to 2 on the interfaces holding an
+// IPv6 default route other than the bridge, so they keep accepting router
+// advertisements once forwarding is on. Interfaces set to 0 or 2 are left
+// alone.
+func keepAcceptingRA(bridge string) error {
+	routes, err := netlink.RouteList(nil, netlink.FAMILY_V6)
+	if err != nil {
+		return fmt.Errorf("listing IPv6 routes: %w", err)
+	}
+
+	// Multipath routes keep their interfaces in the nexthops.
+	uplinks := map[int]bool{}
+	for _, route := range routes {
+		if route.Dst != nil && route.Dst.String() != "::/0" {
+			continue
+		}
+		uplinks[route.LinkIndex] = true
+		for _, hop := range route.MultiPath {
+			uplinks[hop.LinkIndex] = true
+		}
+	}
+
+	for index := range uplinks {
+		link, err := netlink.LinkByIndex(index)
+		if err != nil || link.Attrs().Name == bridge {
+			continue
+		}
+
+		sysctl := "/proc/sys/net/ipv6/conf/" + link.Attrs().Name + "/accept_ra"
+		current, err := os.ReadFile(sysctl)
+		if err != nil || strings.TrimSpace(string(current)) != "1" {
+			continue
+		}
+		log.Printf("[IPv6] Setting accept_ra to 2 on %s so it keeps its SLAAC default route", link.Attrs().Name)
+		if err := os.WriteFile(sysctl, []byte("2"), 0o644); err != nil {
+			return fmt.Errorf("setting accept_ra on %s: %w", link.Attrs().Name, err)
+		}
+	}
+	return nil
+}
+
+// ensureIP6tablesRule appends rule to chain of table unless it is there.
+func ensureIP6tablesRule(table, chain string, rule ...string) error {
+	args := append([]string{"-t", table, "-C", chain}, rule...)
+	if err := exec.Command("ip6tables", args...).Run(); err == nil {
 		return nil
 	}
+
+	args[2] = "-A"
+	out, err := exec.Command("ip6tables", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("ip6tables %v: %v, output: %s", args, err, out)
+	}
 	return nil
 }
 
@@ -95,5 +197,10 @@ func (m *NatManager) EnableNat() error {
 		log.Printf("Error setting up forwarding rules: %v", err)
 		return nil
 	}
+	if m.IpManager.Subnet6 != nil {
+		if err := m.setupIPv6(); err != nil {
+			log.Printf("Error setting up IPv6 NAT: %v", err)
+		}
+	}
 	return nil
 }
@@ -0,0 +1,305 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/urizennnn/boxify/config"
+	"github.com/vishvananda/netlink"
+	"gopkg.in/yaml.v3"
+)
+
+// Network drivers. The default network is the only bridge network; macvlan
+// and ipvlan networks are created with CreateNetwork.
+const (
+	DriverBridge  = "bridge"
+	DriverMacvlan = "macvlan"
+	DriverIpvlan  = "ipvlan"
+)
+
+// Modes of macvlan and ipvlan networks.
+const (
+	MacvlanModeBridge = "bridge"
+	IpvlanModeL2      = "l2"
+	IpvlanModeL3      = "l3"
+)
+
+var (
+	ErrNetworkNotFound = errors.New("network not found")
+	ErrNetworkExists   = errors.New("network already exists")
+)
+
+var validNetworkName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// reservedNetworkNames are taken by the default network's file or are
+// network modes of containers.
+var reservedNetworkNames = map[string]bool{
+	"default": true,
+	"private": true,
+	"host":    true,
+	"none":    true,
+}
+
+func networkConfigPath(name string) string {
+	return filepath.Join(NetworkStorageDir, name+".yaml")
+}
+
+// FindNetwork returns the network with the given ID or name.
+func FindNetwork(ref string) (*config.NetworkStorage, error) {
+	networks, err := ListNetworkConfigs()
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range networks {
+		if n.Id == ref || n.Name == ref {
+			return n, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrNetworkNotFound, ref)
+}
+
+// CreateOptions describe a macvlan or ipvlan network. Mode is the driver's
+// mode, bridge for macvlan and l2 or l3 for ipvlan. IPv6 makes the network
+// dual-stack, with Subnet6 or a generated unique local /64.
+type CreateOptions struct {
+	Name     string
+	Driver   string
+	Parent   string
+	Subnet   string
+	Gateway  string
+	Mode     string
+	IPv6     bool
+	Subnet6  string
+	Gateway6 string
+	Labels   map[string]string
+}
+
+// CreateNetwork validates and stores a macvlan or ipvlan network on the
+// parent interface. The gateways default to the subnets' first addresses;
+// ipvlan L3 networks route through their parent and take none.
+func CreateNetwork(opts CreateOptions) (*config.NetworkStorage, error) {
+	name, driver, parent, mode := opts.Name, opts.Driver, opts.Parent, opts.Mode
+	subnet, gateway := opts.Subnet, opts.Gateway
+	if !validNetworkName.MatchString(name) || reservedNetworkNames[name] {
+		return nil, fmt.Errorf("invalid network name %q", name)
+	}
+	if _, err := FindNetwork(name); err == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNetworkExists, name)
+	}
+
+	switch driver {
+	case DriverMacvlan:
+		if mode == "" {
+			mode = MacvlanModeBridge
+		}
+		if mode != MacvlanModeBridge {
+			return nil, fmt.Errorf("unsupported macvlan mode %q, only bridge is supported", mode)
+		}
+	case DriverIpvlan:
+		if mode == "" {
+			mode = IpvlanModeL2
+		}
+		if mode != IpvlanModeL2 && mode != IpvlanModeL3 {
+			return nil, fmt.Errorf("unsupported ipvlan mode %q, use l2 or l3", mode)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported network driver %q, use macvlan or ipvlan", driver)
+	}
+
+	if parent == "" {
+		return nil, errors.New("a parent interface is required")
+	}
+	if _, err := netlink.LinkByName(parent); err != nil {
+		return nil, fmt.Errorf("parent interface %s: %w", parent, err)
+	}
+
+	if subnet == "" {
+		return nil, errors.New("a subnet is required")
+	}
+	_, ipNet, err := net.ParseCIDR(subnet)
+	if err != nil {
+		return nil, fmt.Errorf("invalid subnet %q: %w", subnet, err)
+	}
+	if ipNet.IP.To4() == nil {
+		return nil, fmt.Errorf("invalid subnet %q, only IPv4 subnets are supported", subnet)
+	}
+
+	routed := driver == DriverIpvlan && mode == IpvlanModeL3
+	switch {
+	case routed:
+		if gateway != "" || opts.Gateway6 != "" {
+			return nil, errors.New("ipvlan l3 networks route through their parent and take no gateway")
+		}
+	case gateway == "":
+		gateway = nextAddr(ipNet.IP).String()
+	default:
+		gw := net.ParseIP(gateway)
+		if gw == nil || !ipNet.Contains(gw) {
+			return nil, fmt.Errorf("gateway %s is not in subnet %s", gateway, ipNet)
+		}
+		gateway = gw.String()
+	}
+
+	n := &config.NetworkStorage{
+		Id:     uuid.New().String(),
+		Name:   name,
+		Driver: driver,
+		Parent: parent,
+		Mode:   mode,
+		Labels: opts.Labels,
+		Ipam: config.NetworkIpam{
+			Subnet:       ipNet.String(),
+			Gateway:      gateway,
+			AllocatedIPs: map[string]string{},
+		},
+	}
+	if opts.IPv6 || opts.Subnet6 != "" {
+		if err := setIPv6Ipam(&n.Ipam, opts.Subnet6, opts.Gateway6, routed); err != nil {
+			return nil, err
+		}
+	} else if opts.Gateway6 != "" {
+		return nil, errors.New("an IPv6 gateway needs an IPv6 network, use --ipv6")
+	}
+
+	if err := os.MkdirAll(NetworkStorageDir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create network storage directory: %w", err)
+	}
+	lock := NewFileLock(networkConfigPath(name))
+	if err := lock.AcquireLock(); err != nil {
+		return nil, fmt.Errorf("failed to acquire lock: %w", err)
+	}
+	defer lock.ReleaseLock()
+
+	if _, err := os.Stat(networkConfigPath(name)); err == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNetworkExists, name)
+	}
+	n.CreatedAt = time.Now().Format(time.RFC3339)
+	if err := writeNamedNetwork(n); err != nil {
+		return nil, err
+	}
+	log.Printf("CreateNetwork: Created %s network %s on %s (%s %s)", driver, name, parent, ipNet, n.Ipam.Subnet6)
+	return n, nil
+}
+
+// RemoveNetwork deletes a network created with CreateNetwork.
+func RemoveNetwork(name string) error {
+	if err := os.Remove(networkConfigPath(name)); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%w: %s", ErrNetworkNotFound, name)
+		}
+		return err
+	}
+	return nil
+}
+
+// AllocateNetworkIP reserves the next free address of a macvlan or ipvlan
+// network for the container and, on dual-stack networks, an IPv6 address,
+// requested6 when it is set. It returns the addresses with their subnets'
+// prefix lengths, e.g. 192.168.1.10/24; ip6 is empty without IPv6.
+func AllocateNetworkIP(name, containerID, requested6 string) (string, string, error) {
+	var allocated, allocated6 string
+	err := updateNamedNetwork(name, func(n *config.NetworkStorage) error {
+		if n.Ipam.Subnet6 != "" {
+			var err error
+			if allocated6, err = allocateIPv6(&n.Ipam, containerID, requested6); err != nil {
+				return err
+			}
+		} else if requested6 != "" {
+			return fmt.Errorf("%w: network %s has no IPv6", ErrInvalidAddress, name)
+		}
+
+		_, subnet, err := net.ParseCIDR(n.Ipam.Subnet)
+		if err != nil {
+			return fmt.Errorf("invalid subnet of network %s: %w", name, err)
+		}
+
+		used := make(map[string]net.IP)
+		for id, ipStr := range n.Ipam.AllocatedIPs {
+			used[id] = net.ParseIP(ipStr)
+		}
+		ip := findFreeIP(subnet, net.ParseIP(n.Ipam.NextIP), used, net.ParseIP(n.Ipam.Gateway))
+		if ip == nil {
+			return errors.New("no free addresses left in " + subnet.String())
+		}
+
+		if n.Ipam.AllocatedIPs == nil {
+			n.Ipam.AllocatedIPs = make(map[string]string)
+		}
+		n.Ipam.AllocatedIPs[containerID] = ip.String()
+		n.Ipam.NextIP = nextAddr(ip).String()
+
+		ones, _ := subnet.Mask.Size()
+		allocated = fmt.Sprintf("%s/%d", ip, ones)
+		return nil
+	})
+	if err != nil {
+		return "", "", err
+	}
+	log.Printf("AllocateNetworkIP: Allocated %s %s on %s to %s", allocated, allocated6, name, containerID)
+	return allocated, allocated6, nil
+}
+
+// ReleaseNetworkIP returns the container's address to the network's pool.
+func ReleaseNetworkIP(name, containerID string) error {
+	return updateNamedNetwork(name, func(n *config.NetworkStorage) error {
+		releaseIPv6(&n.Ipam, containerID)
+		released, exists := n.Ipam.AllocatedIPs[containerID]
+		if !exists {
+			return nil
+		}
+		delete(n.Ipam.AllocatedIPs, containerID)
+
+		if ip := net.ParseIP(released); ip != nil {
+			if next := net.ParseIP(n.Ipam.NextIP); next == nil || bytesLess(ip, next) {
+				n.Ipam.NextIP = released
+			}
+		}
+		log.Printf("ReleaseNetworkIP: Released %s on %s from %s", released, name, containerID)
+		return nil
+	})
+}
+
+// updateNamedNetwork applies update to the stored network under its lock.
+func updateNamedNetwork(name string, update func(n *config.NetworkStorage) error) error {
+	path := networkConfigPath(name)
+	lock := NewFileLock(path)
+	if err := lock.AcquireLock(); err != nil {
+		return fmt.Errorf("failed to acquire lock: %w", err)
+	}
+	defer lock.ReleaseLock()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%w: %s", ErrNetworkNotFound, name)
+		}
+		return fmt.Errorf("failed to read network config: %w", err)
+	}
+	var n config.NetworkStorage
+	if err := yaml.Unmarshal(data, &n); err != nil {
+		return fmt.Errorf("failed to unmarshal network config: %w", err)
+	}
+
+	if err := update(&n); err != nil {
+		return err
+	}
+	return writeNamedNetwork(&n)
+}
+
+func writeNamedNetwork(n *config.NetworkStorage) error {
+	data, err := yaml.Marshal(n)
+	if err != nil {
+		return fmt.Errorf("failed to marshal network config: %w", err)
+	}
+	if err := os.WriteFile(networkConfigPath(n.Name), data, 0o644); err != nil {
+		return fmt.Errorf("failed to write network config: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,280 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// useTempStorage points the network configs at a fresh directory for the
+// duration of the test.
+func useTempStorage(t *testing.T) {
+	t.Helper()
+	previous := NetworkStorageDir
+	NetworkStorageDir = t.TempDir()
+	t.Cleanup(func() { NetworkStorageDir = previous })
+}
+
+func TestCreateNetworkValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    CreateOptions
+		wantErr string
+	}{
+		{
+			name:    "invalid name",
+			opts:    CreateOptions{Name: "-lan", Driver: DriverMacvlan, Parent: "lo", Subnet: "192.168.50.0/24"},
+			wantErr: "invalid network name",
+		},
+		{
+			name:    "reserved name",
+			opts:    CreateOptions{Name: "host", Driver: DriverMacvlan, Parent: "lo", Subnet: "192.168.50.0/24"},
+			wantErr: "invalid network name",
+		},
+		{
+			name:    "unknown driver",
+			opts:    CreateOptions{Name: "lan", Driver: DriverBridge, Parent: "lo", Subnet: "192.168.50.0/24"},
+			wantErr: "unsupported network driver",
+		},
+		{
+			name:    "macvlan mode",
+			opts:    CreateOptions{Name: "lan", Driver: DriverMacvlan, Mode: "vepa", Parent: "lo", Subnet: "192.168.50.0/24"},
+			wantErr: "unsupported macvlan mode",
+		},
+		{
+			name:    "ipvlan mode",
+			opts:    CreateOptions{Name: "lan", Driver: DriverIpvlan, Mode: "l3s", Parent: "lo", Subnet: "192.168.50.0/24"},
+			wantErr: "unsupported ipvlan mode",
+		},
+		{
+			name:    "missing parent",
+			opts:    CreateOptions{Name: "lan", Driver: DriverMacvlan, Subnet: "192.168.50.0/24"},
+			wantErr: "parent interface is required",
+		},
+		{
+			name:    "unknown parent",
+			opts:    CreateOptions{Name: "lan", Driver: DriverMacvlan, Parent: "boxify-nope0", Subnet: "192.168.50.0/24"},
+			wantErr: "parent interface boxify-nope0",
+		},
+		{
+			name:    "missing subnet",
+			opts:    CreateOptions{Name: "lan", Driver: DriverMacvlan, Parent: "lo"},
+			wantErr: "subnet is required",
+		},
+		{
+			name:    "invalid subnet",
+			opts:    CreateOptions{Name: "lan", Driver: DriverMacvlan, Parent: "lo", Subnet: "192.168.50.0"},
+			wantErr: "invalid subnet",
+		},
+		{
+			name:    "IPv6 subnet",
+			opts:    CreateOptions{Name: "lan", Driver: DriverMacvlan, Parent: "lo", Subnet: "fd00:50::/64"},
+			wantErr: "only IPv4 subnets",
+		},
+		{
+			name:    "gateway outside subnet",
+			opts:    CreateOptions{Name: "lan", Driver: DriverMacvlan, Parent: "lo", Subnet: "192.168.50.0/24", Gateway: "192.168.51.1"},
+			wantErr: "is not in subnet",
+		},
+		{
+			name:    "invalid gateway",
+			opts:    CreateOptions{Name: "lan", Driver: DriverMacvlan, Parent: "lo", Subnet: "192.168.50.0/24", Gateway: "router"},
+			wantErr: "is not in subnet",
+		},
+		{
+			name:    "l3 with gateway",
+			opts:    CreateOptions{Name: "lan", Driver: DriverIpvlan, Mode: IpvlanModeL3, Parent: "lo", Subnet: "192.168.50.0/24", Gateway: "192.168.50.1"},
+			wantErr: "take no gateway",
+		},
+		{
+			name:    "l3 with IPv6 gateway",
+			opts:    CreateOptions{Name: "lan", Driver: DriverIpvlan, Mode: IpvlanModeL3, Parent: "lo", Subnet: "192.168.50.0/24", IPv6: true, Gateway6: "fd00:50::1"},
+			wantErr: "take no gateway",
+		},
+		{
+			name:    "IPv6 gateway without IPv6",
+			opts:    CreateOptions{Name: "lan", Driver: DriverMacvlan, Parent: "lo", Subnet: "192.168.50.0/24", Gateway6: "fd00:50::1"},
+			wantErr: "needs an IPv6 network",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTempStorage(t)
+			_, err := CreateNetwork(tt.opts)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("CreateNetwork() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCreateNetworkDefaults(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        CreateOptions
+		wantMode    string
+		wantSubnet  string
+		wantGateway string
+	}{
+		{
+			name:        "macvlan",
+			opts:        CreateOptions{Name: "lan", Driver: DriverMacvlan, Parent: "lo", Subnet: "192.168.50.7/24"},
+			wantMode:    MacvlanModeBridge,
+			wantSubnet:  "192.168.50.0/24",
+			wantGateway: "192.168.50.1",
+		},
+		{
+			name:        "ipvlan l2 with gateway",
+			opts:        CreateOptions{Name: "lan", Driver: DriverIpvlan, Parent: "lo", Subnet: "10.20.0.0/16", Gateway: "10.20.255.254"},
+			wantMode:    IpvlanModeL2,
+			wantSubnet:  "10.20.0.0/16",
+			wantGateway: "10.20.255.254",
+		},
+		{
+			name:        "ipvlan l3",
+			opts:        CreateOptions{Name: "lan", Driver: DriverIpvlan, Mode: IpvlanModeL3, Parent: "lo", Subnet: "10.30.0.0/24"},
+			wantMode:    IpvlanModeL3,
+			wantSubnet:  "10.30.0.0/24",
+			wantGateway: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTempStorage(t)
+			n, err := CreateNetwork(tt.opts)
+			if err != nil {
+				t.Fatalf("CreateNetwork() error = %v", err)
+			}
+			if n.Mode != tt.wantMode || n.Ipam.Subnet != tt.wantSubnet || n.Ipam.Gateway != tt.wantGateway {
+				t.Fatalf("got mode %q subnet %q gateway %q, want %q %q %q",
+					n.Mode, n.Ipam.Subnet, n.Ipam.Gateway, tt.wantMode, tt.wantSubnet, tt.wantGateway)
+			}
+
+			stored, err := FindNetwork(tt.opts.Name)
+			if err != nil {
+				t.Fatalf("FindNetwork() error = %v", err)
+			}
+			if stored.Id != n.Id || stored.Driver != tt.opts.Driver || stored.Parent != "lo" {
+				t.Fatalf("stored network %+v does not match created %+v", stored, n)
+			}
+
+			if _, err := CreateNetwork(tt.opts); !errors.Is(err, ErrNetworkExists) {
+				t.Fatalf("creating %s twice: error = %v, want ErrNetworkExists", tt.opts.Name, err)
+			}
+		})
+	}
+}
+
+func TestCreateNetworkIPv6(t *testing.T) {
+	useTempStorage(t)
+
+	n, err := CreateNetwork(CreateOptions{Name: "lan", Driver: DriverMacvlan, Parent: "lo", Subnet: "192.168.50.0/24", IPv6: true, Subnet6: "fd00:50::/64"})
+	if err != nil {
+		t.Fatalf("CreateNetwork() error = %v", err)
+	}
+	if n.Ipam.Subnet6 != "fd00:50::/64" || n.Ipam.Gateway6 != "fd00:50::1" {
+		t.Fatalf("got IPv6 subnet %q gateway %q", n.Ipam.Subnet6, n.Ipam.Gateway6)
+	}
+
+	routed, err := CreateNetwork(CreateOptions{Name: "routed", Driver: DriverIpvlan, Mode: IpvlanModeL3, Parent: "lo", Subnet: "10.30.0.0/24", IPv6: true})
+	if err != nil {
+		t.Fatalf("CreateNetwork() error = %v", err)
+	}
+	if !strings.HasPrefix(routed.Ipam.Subnet6, "fd") || routed.Ipam.Gateway6 != "" {
+		t.Fatalf("got IPv6 subnet %q gateway %q, want a generated fd00::/8 subnet without gateway", routed.Ipam.Subnet6, routed.Ipam.Gateway6)
+	}
+}
+
+func TestAllocateNetworkIP(t *testing.T) {
+	useTempStorage(t)
+
+	// A /29 has six host addresses; .1 is the gateway.
+	if _, err := CreateNetwork(CreateOptions{Name: "lan", Driver: DriverMacvlan, Parent: "lo", Subnet: "192.168.50.0/29"}); err != nil {
+		t.Fatalf("CreateNetwork() error = %v", err)
+	}
+
+	for i, want := range []string{"192.168.50.2/29", "192.168.50.3/29", "192.168.50.4/29", "192.168.50.5/29", "192.168.50.6/29"} {
+		ip, ip6, err := AllocateNetworkIP("lan", fmt.Sprintf("c%d", i), "")
+		if err != nil {
+			t.Fatalf("AllocateNetworkIP() error = %v", err)
+		}
+		if ip != want || ip6 != "" {
+			t.Fatalf("AllocateNetworkIP() = %q, %q, want %q", ip, ip6, want)
+		}
+	}
+
+	if _, _, err := AllocateNetworkIP("lan", "c5", ""); err == nil || !strings.Contains(err.Error(), "no free addresses") {
+		t.Fatalf("allocating from a full subnet: error = %v", err)
+	}
+
+	if err := ReleaseNetworkIP("lan", "c1"); err != nil {
+		t.Fatalf("ReleaseNetworkIP() error = %v", err)
+	}
+	if err := ReleaseNetworkIP("lan", "unknown"); err != nil {
+		t.Fatalf("releasing an unknown container: error = %v", err)
+	}
+	ip, _, err := AllocateNetworkIP("lan", "c5", "")
+	if err != nil {
+		t.Fatalf("AllocateNetworkIP() after release error = %v", err)
+	}
+	if ip != "192.168.50.3/29" {
+		t.Fatalf("AllocateNetworkIP() after release = %q, want the released 192.168.50.3/29", ip)
+	}
+
+	if _, _, err := AllocateNetworkIP("lan", "c6", "fd00::5"); !errors.Is(err, ErrInvalidAddress) {
+		t.Fatalf("requesting IPv6 on an IPv4 network: error = %v, want ErrInvalidAddress", err)
+	}
+	if _, _, err := AllocateNetworkIP("wan", "c6", ""); !errors.Is(err, ErrNetworkNotFound) {
+		t.Fatalf("allocating on a missing network: error = %v, want ErrNetworkNotFound", err)
+	}
+	if err := ReleaseNetworkIP("wan", "c6"); !errors.Is(err, ErrNetworkNotFound) {
+		t.Fatalf("releasing on a missing network: error = %v, want ErrNetworkNotFound", err)
+	}
+}
+
+func TestAllocateNetworkIPDualStack(t *testing.T) {
+	useTempStorage(t)
+
+	_, err := CreateNetwork(CreateOptions{Name: "lan", Driver: DriverMacvlan, Parent: "lo", Subnet: "192.168.50.0/24", IPv6: true, Subnet6: "fd00:50::/64"})
+	if err != nil {
+		t.Fatalf("CreateNetwork() error = %v", err)
+	}
+
+	ip, ip6, err := AllocateNetworkIP("lan", "a", "")
+	if err != nil {
+		t.Fatalf("AllocateNetworkIP() error = %v", err)
+	}
+	if ip != "192.168.50.2/24" || ip6 != "fd00:50::2/64" {
+		t.Fatalf("AllocateNetworkIP() = %q, %q", ip, ip6)
+	}
+
+	_, ip6, err = AllocateNetworkIP("lan", "b", "fd00:50::80")
+	if err != nil {
+		t.Fatalf("AllocateNetworkIP() with a requested address error = %v", err)
+	}
+	if ip6 != "fd00:50::80/64" {
+		t.Fatalf("AllocateNetworkIP() = %q, want the requested fd00:50::80/64", ip6)
+	}
+
+	// A failed IPv6 request must not leave an IPv4 address behind.
+	if _, _, err := AllocateNetworkIP("lan", "c", "fd00:50::80"); !errors.Is(err, ErrAddressInUse) {
+		t.Fatalf("requesting a used address: error = %v, want ErrAddressInUse", err)
+	}
+	n, err := FindNetwork("lan")
+	if err != nil {
+		t.Fatalf("FindNetwork() error = %v", err)
+	}
+	if _, ok := n.Ipam.AllocatedIPs["c"]; ok {
+		t.Fatalf("failed allocation left an IPv4 address: %v", n.Ipam.AllocatedIPs)
+	}
+
+	if err := ReleaseNetworkIP("lan", "b"); err != nil {
+		t.Fatalf("ReleaseNetworkIP() error = %v", err)
+	}
+	if n, _ = FindNetwork("lan"); n.Ipam.AllocatedIPs6["b"] != "" || n.Ipam.AllocatedIPs["b"] != "" {
+		t.Fatalf("ReleaseNetworkIP() kept addresses of b: %v %v", n.Ipam.AllocatedIPs, n.Ipam.AllocatedIPs6)
+	}
+}
@@ -12,7 +12,9 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
-const NetworkStorageDir = "/var/lib/boxify/networks"
+// NetworkStorageDir holds the network configs. It is a variable so tests can
+// point it at a temporary directory.
+var NetworkStorageDir = "/var/lib/boxify/networks"
 
 func WriteNetworkConfig(networkStorage *config.NetworkStorage) error {
 	if err := os.MkdirAll(NetworkStorageDir, 0o755); err != nil {
@@ -91,6 +93,41 @@ func ReadNetworkConfig(networkId string) (*config.NetworkStorage, error) {
 	return &networkStorage, nil
 }
 
+// ListNetworkConfigs reads every network stored in NetworkStorageDir.
+func ListNetworkConfigs() ([]*config.NetworkStorage, error) {
+	entries, err := os.ReadDir(NetworkStorageDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read network storage directory: %w", err)
+	}
+
+	var networks []*config.NetworkStorage
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".yaml" {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(NetworkStorageDir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read network config: %w", err)
+		}
+
+		var networkStorage config.NetworkStorage
+		if err := yaml.Unmarshal(data, &networkStorage); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal network config: %w", err)
+		}
+		// The default network was stored before networks had drivers.
+		if networkStorage.Driver == "" {
+			networkStorage.Driver = DriverBridge
+		}
+		networks = append(networks, &networkStorage)
+	}
+
+	return networks, nil
+}
+
 func CheckNetworkConfigExists() bool {
 	configPath := filepath.Join(NetworkStorageDir, "default.yaml")
 	info, err := os.Stat(configPath)
@@ -0,0 +1,123 @@
+package network
+
+import (
+	"fmt"
+	"log"
+	"net"
+
+	"github.com/urizennnn/boxify/pkg/daemon/types"
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+)
+
+// SetupSubInterface gives a container on a macvlan or ipvlan network its
+// interface. The sub-interface of the network's parent is created directly
+// in the container's network namespace, so it never appears on the host,
+// and is configured there as eth0 with the container's address and default
+// route. No bridge, veth pair or NAT is involved.
+func (m *NetworkManager) SetupSubInterface(containerId string, damon ContainerGetter) error {
+	container, err := damon.GetContainer(containerId)
+	if err != nil {
+		return err
+	}
+	info := container.NetworkInfo
+
+	n, err := FindNetwork(info.Network)
+	if err != nil {
+		return err
+	}
+	parent, err := netlink.LinkByName(n.Parent)
+	if err != nil {
+		return fmt.Errorf("parent interface %s of network %s: %w", n.Parent, n.Name, err)
+	}
+
+	nsFD, err := GetNsFD(container.PID)
+	if err != nil {
+		log.Printf("[SubInterface] Could not get netns fd for container %s: %v", containerId, err)
+		return err
+	}
+	defer nsFD.Close()
+
+	attrs := netlink.NewLinkAttrs()
+	attrs.Name = "sub-" + containerId[:8]
+	attrs.ParentIndex = parent.Attrs().Index
+	attrs.Namespace = netlink.NsFd(nsFD.Fd())
+
+	var link netlink.Link
+	switch n.Driver {
+	case DriverMacvlan:
+		link = &netlink.Macvlan{LinkAttrs: attrs, Mode: netlink.MACVLAN_MODE_BRIDGE}
+	case DriverIpvlan:
+		mode := netlink.IPVLAN_MODE_L2
+		if n.Mode == IpvlanModeL3 {
+			mode = netlink.IPVLAN_MODE_L3
+		}
+		link = &netlink.IPVlan{LinkAttrs: attrs, Mode: mode}
+	default:
+		return fmt.Errorf("network %s has no sub-interfaces, its driver is %s", n.Name, n.Driver)
+	}
+
+	log.Printf("[SubInterface] Creating %s interface on %s for container %s", n.Driver, n.Parent, containerId)
+	if err := netlink.LinkAdd(link); err != nil {
+		log.Printf("[SubInterface] Could not create %s interface: %v", n.Driver, err)
+		return err
+	}
+
+	// A handle bound to the container's namespace configures it without
+	// switching the thread's namespace.
+	handle, err := netlink.NewHandleAt(netns.NsHandle(nsFD.Fd()))
+	if err != nil {
+		return err
+	}
+	defer handle.Close()
+
+	return configureInterface(handle, attrs.Name, info)
+}
+
+// configureInterface renames the interface to eth0, assigns the container's
+// addresses, brings it and loopback up and routes everything through it,
+// via the gateways when there are any.
+func configureInterface(handle *netlink.Handle, name string, info *types.NetworkInfo) error {
+	link, err := handle.LinkByName(name)
+	if err != nil {
+		return err
+	}
+	if err := handle.LinkSetName(link, "eth0"); err != nil {
+		return fmt.Errorf("renaming %s to eth0: %w", name, err)
+	}
+
+	addr, err := netlink.ParseAddr(info.IP)
+	if err != nil {
+		return err
+	}
+	if err := handle.AddrAdd(link, addr); err != nil {
+		return fmt.Errorf("assigning %s: %w", info.IP, err)
+	}
+	if err := handle.LinkSetUp(link); err != nil {
+		return err
+	}
+
+	lo, err := handle.LinkByName("lo")
+	if err != nil {
+		return err
+	}
+	if err := handle.LinkSetUp(lo); err != nil {
+		return err
+	}
+
+	_, defaultDst, _ := net.ParseCIDR("0.0.0.0/0")
+	route := &netlink.Route{Dst: defaultDst, LinkIndex: link.Attrs().Index}
+	if info.Gateway != "" {
+		route.Gw = net.ParseIP(info.Gateway)
+	} else {
+		route.Scope = netlink.SCOPE_LINK
+	}
+	if err := handle.RouteAdd(route); err != nil {
+		return fmt.Errorf("adding default route: %w", err)
+	}
+
+	if info.IPv6 != "" {
+		return addIPv6(handle, link, info.IPv6, info.IPv6Gateway)
+	}
+	return nil
+}
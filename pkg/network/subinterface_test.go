@@ -0,0 +1,198 @@
+package network
+
+import (
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"syscall"
+	"testing"
+
+	"github.com/urizennnn/boxify/pkg/daemon/types"
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+)
+
+type fakeContainers map[string]*types.Container
+
+func (f fakeContainers) GetContainer(id string) (*types.Container, error) {
+	c, ok := f[id]
+	if !ok {
+		return nil, types.ErrContainerNotFound
+	}
+	return c, nil
+}
+
+// testNetns moves the rest of the test onto a locked thread in a new
+// network namespace, so the links it creates never reach the host and go
+// away with the namespace even when the run is killed.
+func testNetns(t *testing.T) {
+	t.Helper()
+	if os.Geteuid() != 0 {
+		t.Skip("creating network namespaces needs root")
+	}
+
+	runtime.LockOSThread()
+	host, err := netns.Get()
+	if err != nil {
+		runtime.UnlockOSThread()
+		t.Fatal(err)
+	}
+	ns, err := netns.New()
+	if err != nil {
+		host.Close()
+		runtime.UnlockOSThread()
+		t.Skipf("cannot create a network namespace: %v", err)
+	}
+	t.Cleanup(func() {
+		ns.Close()
+		defer host.Close()
+		if err := netns.Set(host); err != nil {
+			// Left locked, the thread is discarded with the test's goroutine.
+			t.Errorf("returning to the host network namespace: %v", err)
+			return
+		}
+		runtime.UnlockOSThread()
+	})
+}
+
+// dummyParent creates a dummy link to carry the sub-interfaces in a
+// throwaway network namespace the rest of the test runs in. Tests that need
+// one are skipped without root or kernel support.
+func dummyParent(t *testing.T) string {
+	t.Helper()
+	testNetns(t)
+
+	attrs := netlink.NewLinkAttrs()
+	attrs.Name = "boxify-test0"
+	if err := netlink.LinkAdd(&netlink.Dummy{LinkAttrs: attrs}); err != nil {
+		t.Skipf("cannot create a dummy link: %v", err)
+	}
+	link, err := netlink.LinkByName(attrs.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := netlink.LinkSetUp(link); err != nil {
+		t.Fatal(err)
+	}
+	return attrs.Name
+}
+
+// containerProcess starts a process in a new network namespace to stand in
+// for a container's init. It is forked from the test's thread, so after
+// testNetns it starts out in the test's namespace.
+func containerProcess(t *testing.T) int {
+	t.Helper()
+	cmd := exec.Command("sleep", "60")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Cloneflags: syscall.CLONE_NEWNET}
+	if err := cmd.Start(); err != nil {
+		t.Skipf("cannot start a process in a new network namespace: %v", err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+	return cmd.Process.Pid
+}
+
+func TestSetupSubInterface(t *testing.T) {
+	tests := []struct {
+		name   string
+		driver string
+		mode   string
+	}{
+		{name: "macvlan", driver: DriverMacvlan, mode: MacvlanModeBridge},
+		{name: "ipvlan l2", driver: DriverIpvlan, mode: IpvlanModeL2},
+		{name: "ipvlan l3", driver: DriverIpvlan, mode: IpvlanModeL3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parent := dummyParent(t)
+			useTempStorage(t)
+
+			n, err := CreateNetwork(CreateOptions{Name: "lan", Driver: tt.driver, Mode: tt.mode, Parent: parent, Subnet: "192.168.77.0/24"})
+			if err != nil {
+				t.Fatalf("CreateNetwork() error = %v", err)
+			}
+			const containerID = "5b7d4e21-9a0c-4d8e-8f1b-2a373f2a9c1e"
+			ip, _, err := AllocateNetworkIP("lan", containerID, "")
+			if err != nil {
+				t.Fatalf("AllocateNetworkIP() error = %v", err)
+			}
+
+			pid := containerProcess(t)
+			containers := fakeContainers{containerID: {
+				ID:  containerID,
+				PID: pid,
+				NetworkInfo: &types.NetworkInfo{
+					IP:      ip,
+					Gateway: n.Ipam.Gateway,
+					Network: n.Name,
+					Driver:  n.Driver,
+				},
+			}}
+
+			if err := (&NetworkManager{}).SetupSubInterface(containerID, containers); err != nil {
+				if tt.driver == DriverIpvlan && errors.Is(err, syscall.EOPNOTSUPP) {
+					t.Skipf("ipvlan is not supported: %v", err)
+				}
+				t.Fatalf("SetupSubInterface() error = %v", err)
+			}
+
+			if _, err := netlink.LinkByName("sub-" + containerID[:8]); err == nil {
+				t.Fatalf("sub-interface is left in the parent's namespace")
+			}
+
+			ns, err := netns.GetFromPid(pid)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer ns.Close()
+			handle, err := netlink.NewHandleAt(ns)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer handle.Close()
+
+			eth0, err := handle.LinkByName("eth0")
+			if err != nil {
+				t.Fatalf("eth0 missing in the container: %v", err)
+			}
+			if eth0.Type() != tt.driver {
+				t.Fatalf("eth0 is a %s link, want %s", eth0.Type(), tt.driver)
+			}
+			if eth0.Attrs().Flags&net.FlagUp == 0 {
+				t.Fatalf("eth0 is down")
+			}
+
+			addrs, err := handle.AddrList(eth0, netlink.FAMILY_V4)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(addrs) != 1 || addrs[0].IPNet.String() != ip {
+				t.Fatalf("eth0 has addresses %v, want %s", addrs, ip)
+			}
+
+			routes, err := handle.RouteList(eth0, netlink.FAMILY_V4)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var defaultRoute *netlink.Route
+			for i, route := range routes {
+				if route.Dst == nil || route.Dst.String() == "0.0.0.0/0" {
+					defaultRoute = &routes[i]
+				}
+			}
+			switch {
+			case defaultRoute == nil:
+				t.Fatalf("no default route in %v", routes)
+			case n.Ipam.Gateway == "" && (defaultRoute.Gw != nil || defaultRoute.Scope != netlink.SCOPE_LINK):
+				t.Fatalf("default route %v, want a link scope route without gateway", defaultRoute)
+			case n.Ipam.Gateway != "" && !defaultRoute.Gw.Equal(net.ParseIP(n.Ipam.Gateway)):
+				t.Fatalf("default route %v, want it via %s", defaultRoute, n.Ipam.Gateway)
+			}
+		})
+	}
+}
@@ -11,6 +11,10 @@ type IPManager struct {
 	Gateway    net.IP
 	NextIP     net.IP
 	Allocated  map[string]net.IP
+	// Subnet6 and Gateway6 are set when the default network has IPv6. Its
+	// IPv6 addresses are only tracked in the network config.
+	Subnet6  *net.IPNet
+	Gateway6 net.IP
 }
 
 type BridgeManager struct {
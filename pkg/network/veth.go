@@ -57,15 +57,17 @@ func (m *VethManager) CreateVethPairAndAttachToHostBridge(containerID string, br
 func (m *VethManager) DeleteVethPair(containerID string) error {
 	vethNames, exists := m.veths[containerID]
 	if !exists {
-		log.Printf("veth pair not found for container ID: %s", containerID)
-		return nil
+		// The daemon may have restarted since the pair was created.
+		vethNames = [2]string{"veth-" + containerID[:8], "vethc-" + containerID[:8]}
 	}
 
 	for _, vethName := range vethNames {
 		veth, err := netlink.LinkByName(vethName)
 		if err != nil {
-			log.Printf("could not find link by name, %v\n", err)
-			return err
+			// The peer disappears together with the other end or the
+			// container's network namespace.
+			log.Printf("veth %s already gone: %v\n", vethName, err)
+			continue
 		}
 
 		if err := netlink.LinkDel(veth); err != nil {
@@ -0,0 +1,62 @@
+package oci
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// runHooks runs each hook in order with the container's state on standard
+// input and stops at the first one that fails.
+func runHooks(name string, hooks []Hook, state *State) error {
+	if len(hooks) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	for _, hook := range hooks {
+		if err := runHook(hook, data); err != nil {
+			return fmt.Errorf("%s hook %s: %w", name, hook.Path, err)
+		}
+	}
+	return nil
+}
+
+func runHook(hook Hook, state []byte) error {
+	ctx := context.Background()
+	if hook.Timeout != nil {
+		if *hook.Timeout <= 0 {
+			return fmt.Errorf("timeout must be positive")
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(*hook.Timeout)*time.Second)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, hook.Path)
+	if len(hook.Args) > 0 {
+		cmd.Args = hook.Args
+	}
+	cmd.Env = hook.Env
+	cmd.Stdin = bytes.NewReader(state)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("timed out after %ds", *hook.Timeout)
+		}
+		if out := strings.TrimSpace(output.String()); out != "" {
+			return fmt.Errorf("%w: %s", err, out)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package oci
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/urizennnn/boxify/pkg/container"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// File descriptors the runtime passes to the container's init.
+const (
+	syncFd = 3
+	fifoFd = 4
+)
+
+// Init is the container side of Create. It runs in the container's new
+// namespaces with the bundle as its working directory, sets up the
+// container once the runtime says so, waits for Start and then execs the
+// container's process. The caller must be locked to its OS thread. Init only
+// returns on failure, after reporting the error to the runtime.
+func Init() error {
+	sync := os.NewFile(syncFd, "sync")
+	fifo := os.NewFile(fifoFd, "exec.fifo")
+	unix.CloseOnExec(syncFd)
+	unix.CloseOnExec(fifoFd)
+
+	var msg message
+	if err := json.NewDecoder(sync).Decode(&msg); err != nil {
+		return fmt.Errorf("waiting for the runtime: %w", err)
+	}
+	if msg.Type != "go" {
+		return fmt.Errorf("unexpected message %q from the runtime", msg.Type)
+	}
+
+	spec, path, err := setupContainer(msg.State)
+	if err != nil {
+		json.NewEncoder(sync).Encode(message{Type: "error", Message: err.Error()})
+		return err
+	}
+	if err := json.NewEncoder(sync).Encode(message{Type: "created"}); err != nil {
+		return err
+	}
+	sync.Close()
+
+	// Block until Start writes to the FIFO.
+	if _, err := fifo.Read(make([]byte, 1)); err != nil {
+		return fmt.Errorf("waiting for start: %w", err)
+	}
+	fifo.Close()
+
+	if spec.Hooks != nil {
+		state := *msg.State
+		state.Status = StatusRunning
+		if err := runHooks("startContainer", spec.Hooks.StartContainer, &state); err != nil {
+			return err
+		}
+	}
+	if err := setupProcess(spec.Process); err != nil {
+		return err
+	}
+	if err := syscall.Exec(path, spec.Process.Args, spec.Process.Env); err != nil {
+		return fmt.Errorf("exec %s: %w", spec.Process.Args[0], err)
+	}
+	return nil
+}
+
+// setupContainer builds the container's root filesystem from the bundle in
+// the working directory. It returns the bundle's configuration and the path
+// of the executable to run.
+func setupContainer(state *State) (*Spec, string, error) {
+	bundle, err := os.Getwd()
+	if err != nil {
+		return nil, "", err
+	}
+	spec, err := LoadSpec(bundle)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if ns, ok := spec.namespace("cgroup"); ok && ns.Path == "" {
+		// Unshared now that the runtime has moved init into its cgroup,
+		// which becomes the container's cgroup root. A namespace with a
+		// path was joined when init started.
+		if err := unix.Unshare(unix.CLONE_NEWCGROUP); err != nil {
+			return nil, "", fmt.Errorf("creating cgroup namespace: %w", err)
+		}
+	}
+
+	rootfs := spec.rootfs(bundle)
+	if err := setupRootfs(spec, rootfs); err != nil {
+		return nil, "", err
+	}
+	if spec.Hooks != nil {
+		if err := runHooks("createContainer", spec.Hooks.CreateContainer, state); err != nil {
+			return nil, "", err
+		}
+	}
+	if err := container.PivotRoot(rootfs); err != nil {
+		return nil, "", fmt.Errorf("pivoting root: %w", err)
+	}
+	if err := finishRootfs(spec); err != nil {
+		return nil, "", err
+	}
+
+	for key, value := range spec.Linux.Sysctl {
+		file := filepath.Join("/proc/sys", strings.ReplaceAll(key, ".", "/"))
+		if err := os.WriteFile(file, []byte(value), 0o644); err != nil {
+			return nil, "", fmt.Errorf("setting sysctl %s: %w", key, err)
+		}
+	}
+	if ns, ok := spec.namespace("uts"); ok && ns.Path == "" && spec.Hostname != "" {
+		if err := unix.Sethostname([]byte(spec.Hostname)); err != nil {
+			return nil, "", fmt.Errorf("setting hostname: %w", err)
+		}
+	}
+	if ns, ok := spec.namespace("network"); ok && ns.Path == "" {
+		lo, err := netlink.LinkByName("lo")
+		if err != nil {
+			return nil, "", fmt.Errorf("finding loopback: %w", err)
+		}
+		if err := netlink.LinkSetUp(lo); err != nil {
+			return nil, "", fmt.Errorf("bringing up loopback: %w", err)
+		}
+	}
+
+	path, err := lookPath(spec.Process)
+	return spec, path, err
+}
@@ -0,0 +1,311 @@
+package oci
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/urizennnn/boxify/pkg/archive"
+	"golang.org/x/sys/unix"
+)
+
+// mountFlags are the mount options that map to flags. The ones that clear a
+// flag have clear set.
+var mountFlags = map[string]struct {
+	clear bool
+	flag  uintptr
+}{
+	"ro":            {false, unix.MS_RDONLY},
+	"rw":            {true, unix.MS_RDONLY},
+	"nosuid":        {false, unix.MS_NOSUID},
+	"suid":          {true, unix.MS_NOSUID},
+	"nodev":         {false, unix.MS_NODEV},
+	"dev":           {true, unix.MS_NODEV},
+	"noexec":        {false, unix.MS_NOEXEC},
+	"exec":          {true, unix.MS_NOEXEC},
+	"sync":          {false, unix.MS_SYNCHRONOUS},
+	"async":         {true, unix.MS_SYNCHRONOUS},
+	"dirsync":       {false, unix.MS_DIRSYNC},
+	"remount":       {false, unix.MS_REMOUNT},
+	"mand":          {false, unix.MS_MANDLOCK},
+	"nomand":        {true, unix.MS_MANDLOCK},
+	"atime":         {true, unix.MS_NOATIME},
+	"noatime":       {false, unix.MS_NOATIME},
+	"diratime":      {true, unix.MS_NODIRATIME},
+	"nodiratime":    {false, unix.MS_NODIRATIME},
+	"relatime":      {false, unix.MS_RELATIME},
+	"norelatime":    {true, unix.MS_RELATIME},
+	"strictatime":   {false, unix.MS_STRICTATIME},
+	"nostrictatime": {true, unix.MS_STRICTATIME},
+	"bind":          {false, unix.MS_BIND},
+	"rbind":         {false, unix.MS_BIND | unix.MS_REC},
+	"defaults":      {false, 0},
+}
+
+// propagationFlags are the mount options that change a mount's propagation.
+var propagationFlags = map[string]uintptr{
+	"private":     unix.MS_PRIVATE,
+	"rprivate":    unix.MS_PRIVATE | unix.MS_REC,
+	"shared":      unix.MS_SHARED,
+	"rshared":     unix.MS_SHARED | unix.MS_REC,
+	"slave":       unix.MS_SLAVE,
+	"rslave":      unix.MS_SLAVE | unix.MS_REC,
+	"unbindable":  unix.MS_UNBINDABLE,
+	"runbindable": unix.MS_UNBINDABLE | unix.MS_REC,
+}
+
+// defaultDevices are bind mounted from the host into every container's
+// /dev, as the runtime specification requires.
+var defaultDevices = []string{"/dev/null", "/dev/zero", "/dev/full", "/dev/random", "/dev/urandom", "/dev/tty"}
+
+// parseMountOptions splits options into mount flags, propagation flags and
+// the filesystem specific data.
+func parseMountOptions(options []string) (flags uintptr, propagation []uintptr, data string) {
+	var extra []string
+	for _, option := range options {
+		if f, ok := mountFlags[option]; ok {
+			if f.clear {
+				flags &^= f.flag
+			} else {
+				flags |= f.flag
+			}
+			continue
+		}
+		if p, ok := propagationFlags[option]; ok {
+			propagation = append(propagation, p)
+			continue
+		}
+		extra = append(extra, option)
+	}
+	return flags, propagation, strings.Join(extra, ",")
+}
+
+// setupRootfs prepares the container's root filesystem in its new mount
+// namespace: it stops mounts from propagating back to the host and mounts
+// the bundle's mounts and devices below rootfs.
+func setupRootfs(spec *Spec, rootfs string) error {
+	propagation := uintptr(unix.MS_SLAVE | unix.MS_REC)
+	if spec.Linux.RootfsPropagation != "" {
+		p, ok := propagationFlags[spec.Linux.RootfsPropagation]
+		if !ok {
+			return fmt.Errorf("invalid rootfsPropagation %q", spec.Linux.RootfsPropagation)
+		}
+		propagation = p
+	}
+	if err := unix.Mount("", "/", "", propagation, ""); err != nil {
+		return fmt.Errorf("setting root propagation: %w", err)
+	}
+	if err := unix.Mount(rootfs, rootfs, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+		return fmt.Errorf("bind mounting rootfs: %w", err)
+	}
+
+	devTmpfs := false
+	for _, m := range spec.Mounts {
+		if err := mount(rootfs, m); err != nil {
+			return fmt.Errorf("mounting %s: %w", m.Destination, err)
+		}
+		if filepath.Clean(m.Destination) == "/dev" && m.Type == "tmpfs" {
+			devTmpfs = true
+		}
+	}
+
+	if devTmpfs {
+		for _, device := range defaultDevices {
+			if err := bindDevice(rootfs, device); err != nil {
+				return fmt.Errorf("creating %s: %w", device, err)
+			}
+		}
+		if err := devSymlinks(rootfs); err != nil {
+			return err
+		}
+	}
+	for _, device := range spec.Linux.Devices {
+		if err := createDevice(rootfs, device); err != nil {
+			return fmt.Errorf("creating %s: %w", device.Path, err)
+		}
+	}
+	return nil
+}
+
+func mount(rootfs string, m Mount) error {
+	dest, err := archive.SecureJoin(rootfs, m.Destination)
+	if err != nil {
+		return err
+	}
+	flags, propagation, data := parseMountOptions(m.Options)
+	if m.Type == "bind" {
+		flags |= unix.MS_BIND
+	}
+
+	fsType := m.Type
+	if fsType == "cgroup" {
+		// Only the unified hierarchy exists on the hosts boxify supports.
+		fsType = "cgroup2"
+	}
+
+	isFile := false
+	if flags&unix.MS_BIND != 0 {
+		info, err := os.Stat(m.Source)
+		if err != nil {
+			return err
+		}
+		isFile = !info.IsDir()
+	}
+	if err := createMountPoint(dest, isFile); err != nil {
+		return err
+	}
+
+	if flags&unix.MS_BIND == 0 {
+		if err := unix.Mount(m.Source, dest, fsType, flags, data); err != nil {
+			return err
+		}
+	} else {
+		if err := unix.Mount(m.Source, dest, "", flags&(unix.MS_BIND|unix.MS_REC), ""); err != nil {
+			return err
+		}
+		// A bind mount ignores every other flag, so they are applied with a
+		// remount.
+		if flags&^(unix.MS_BIND|unix.MS_REC) != 0 {
+			if err := unix.Mount("", dest, "", flags|unix.MS_REMOUNT, ""); err != nil {
+				return fmt.Errorf("remounting: %w", err)
+			}
+		}
+	}
+	for _, p := range propagation {
+		if err := unix.Mount("", dest, "", p, ""); err != nil {
+			return fmt.Errorf("setting propagation: %w", err)
+		}
+	}
+	return nil
+}
+
+func createMountPoint(dest string, isFile bool) error {
+	if !isFile {
+		return os.MkdirAll(dest, 0o755)
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(dest, os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+func bindDevice(rootfs, device string) error {
+	dest, err := archive.SecureJoin(rootfs, device)
+	if err != nil {
+		return err
+	}
+	if err := createMountPoint(dest, true); err != nil {
+		return err
+	}
+	return unix.Mount(device, dest, "", unix.MS_BIND, "")
+}
+
+// createDevice makes a device node. Inside a user namespace mknod is not
+// permitted and the host's node is bind mounted instead.
+func createDevice(rootfs string, device Device) error {
+	dest, err := archive.SecureJoin(rootfs, device.Path)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return err
+	}
+
+	var mode uint32
+	switch device.Type {
+	case "c", "u":
+		mode = unix.S_IFCHR
+	case "b":
+		mode = unix.S_IFBLK
+	case "p":
+		mode = unix.S_IFIFO
+	default:
+		return fmt.Errorf("unknown device type %q", device.Type)
+	}
+	perm := uint32(0o666)
+	if device.FileMode != nil {
+		perm = uint32(device.FileMode.Perm())
+	}
+
+	os.Remove(dest)
+	err = unix.Mknod(dest, mode|perm, int(unix.Mkdev(uint32(device.Major), uint32(device.Minor))))
+	if err == unix.EPERM {
+		return bindDevice(rootfs, device.Path)
+	}
+	if err != nil {
+		return err
+	}
+	if err := unix.Chmod(dest, perm); err != nil {
+		return err
+	}
+	uid, gid := -1, -1
+	if device.UID != nil {
+		uid = int(*device.UID)
+	}
+	if device.GID != nil {
+		gid = int(*device.GID)
+	}
+	return os.Chown(dest, uid, gid)
+}
+
+func devSymlinks(rootfs string) error {
+	links := [][2]string{
+		{"/proc/self/fd", "/dev/fd"},
+		{"/proc/self/fd/0", "/dev/stdin"},
+		{"/proc/self/fd/1", "/dev/stdout"},
+		{"/proc/self/fd/2", "/dev/stderr"},
+		{"/proc/kcore", "/dev/core"},
+		{"pts/ptmx", "/dev/ptmx"},
+	}
+	for _, link := range links {
+		dest := filepath.Join(rootfs, link[1])
+		if err := os.Symlink(link[0], dest); err != nil && !os.IsExist(err) {
+			return fmt.Errorf("creating %s: %w", link[1], err)
+		}
+	}
+	return nil
+}
+
+// finishRootfs applies the settings that need the container's root to be in
+// place: a read-only root, and the masked and read-only paths.
+func finishRootfs(spec *Spec) error {
+	for _, path := range spec.Linux.ReadonlyPaths {
+		if err := unix.Mount(path, path, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return fmt.Errorf("making %s read-only: %w", path, err)
+		}
+		flags := uintptr(unix.MS_BIND | unix.MS_REMOUNT | unix.MS_RDONLY | unix.MS_NOSUID | unix.MS_NODEV | unix.MS_NOEXEC)
+		if err := unix.Mount("", path, "", flags, ""); err != nil {
+			return fmt.Errorf("making %s read-only: %w", path, err)
+		}
+	}
+
+	for _, path := range spec.Linux.MaskedPaths {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if info.IsDir() {
+			err = unix.Mount("tmpfs", path, "tmpfs", unix.MS_RDONLY, "")
+		} else {
+			err = unix.Mount("/dev/null", path, "", unix.MS_BIND, "")
+		}
+		if err != nil {
+			return fmt.Errorf("masking %s: %w", path, err)
+		}
+	}
+
+	if spec.Root.Readonly {
+		if err := unix.Mount("", "/", "", unix.MS_BIND|unix.MS_REMOUNT|unix.MS_RDONLY, ""); err != nil {
+			return fmt.Errorf("making the root read-only: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package oci
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+var capabilities = map[string]uintptr{
+	"CAP_CHOWN":              0,
+	"CAP_DAC_OVERRIDE":       1,
+	"CAP_DAC_READ_SEARCH":    2,
+	"CAP_FOWNER":             3,
+	"CAP_FSETID":             4,
+	"CAP_KILL":               5,
+	"CAP_SETGID":             6,
+	"CAP_SETUID":             7,
+	"CAP_SETPCAP":            8,
+	"CAP_LINUX_IMMUTABLE":    9,
+	"CAP_NET_BIND_SERVICE":   10,
+	"CAP_NET_BROADCAST":      11,
+	"CAP_NET_ADMIN":          12,
+	"CAP_NET_RAW":            13,
+	"CAP_IPC_LOCK":           14,
+	"CAP_IPC_OWNER":          15,
+	"CAP_SYS_MODULE":         16,
+	"CAP_SYS_RAWIO":          17,
+	"CAP_SYS_CHROOT":         18,
+	"CAP_SYS_PTRACE":         19,
+	"CAP_SYS_PACCT":          20,
+	"CAP_SYS_ADMIN":          21,
+	"CAP_SYS_BOOT":           22,
+	"CAP_SYS_NICE":           23,
+	"CAP_SYS_RESOURCE":       24,
+	"CAP_SYS_TIME":           25,
+	"CAP_SYS_TTY_CONFIG":     26,
+	"CAP_MKNOD":              27,
+	"CAP_LEASE":              28,
+	"CAP_AUDIT_WRITE":        29,
+	"CAP_AUDIT_CONTROL":      30,
+	"CAP_SETFCAP":            31,
+	"CAP_MAC_OVERRIDE":       32,
+	"CAP_MAC_ADMIN":          33,
+	"CAP_SYSLOG":             34,
+	"CAP_WAKE_ALARM":         35,
+	"CAP_BLOCK_SUSPEND":      36,
+	"CAP_AUDIT_READ":         37,
+	"CAP_PERFMON":            38,
+	"CAP_BPF":                39,
+	"CAP_CHECKPOINT_RESTORE": 40,
+}
+
+var rlimits = map[string]int{
+	"RLIMIT_CPU":        unix.RLIMIT_CPU,
+	"RLIMIT_FSIZE":      unix.RLIMIT_FSIZE,
+	"RLIMIT_DATA":       unix.RLIMIT_DATA,
+	"RLIMIT_STACK":      unix.RLIMIT_STACK,
+	"RLIMIT_CORE":       unix.RLIMIT_CORE,
+	"RLIMIT_RSS":        unix.RLIMIT_RSS,
+	"RLIMIT_NPROC":      unix.RLIMIT_NPROC,
+	"RLIMIT_NOFILE":     unix.RLIMIT_NOFILE,
+	"RLIMIT_MEMLOCK":    unix.RLIMIT_MEMLOCK,
+	"RLIMIT_AS":         unix.RLIMIT_AS,
+	"RLIMIT_LOCKS":      unix.RLIMIT_LOCKS,
+	"RLIMIT_SIGPENDING": unix.RLIMIT_SIGPENDING,
+	"RLIMIT_MSGQUEUE":   unix.RLIMIT_MSGQUEUE,
+	"RLIMIT_NICE":       unix.RLIMIT_NICE,
+	"RLIMIT_RTPRIO":     unix.RLIMIT_RTPRIO,
+	"RLIMIT_RTTIME":     unix.RLIMIT_RTTIME,
+}
+
+// capabilitySet is a capability bit mask.
+type capabilitySet [2]uint32
+
+func newCapabilitySet(names []string) capabilitySet {
+	var set capabilitySet
+	for _, name := range names {
+		bit := capabilities[name]
+		set[bit/32] |= 1 << (bit % 32)
+	}
+	return set
+}
+
+func (c capabilitySet) has(bit uintptr) bool {
+	return c[bit/32]&(1<<(bit%32)) != 0
+}
+
+// lastCapability is the highest capability the running kernel knows.
+func lastCapability() uintptr {
+	data, err := os.ReadFile("/proc/sys/kernel/cap_last_cap")
+	if err != nil {
+		return capabilities["CAP_CHECKPOINT_RESTORE"]
+	}
+	last, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return capabilities["CAP_CHECKPOINT_RESTORE"]
+	}
+	return uintptr(last)
+}
+
+// setupProcess applies the process's limits, identity and capabilities to
+// the calling thread, which then execs the process. Capabilities are per
+// thread, so the caller must be locked to its OS thread.
+func setupProcess(p *Process) error {
+	for _, rlimit := range p.Rlimits {
+		limit := &unix.Rlimit{Cur: rlimit.Soft, Max: rlimit.Hard}
+		if err := unix.Setrlimit(rlimits[rlimit.Type], limit); err != nil {
+			return fmt.Errorf("setting %s: %w", rlimit.Type, err)
+		}
+	}
+
+	if p.OOMScoreAdj != nil {
+		if err := os.WriteFile("/proc/self/oom_score_adj", []byte(strconv.Itoa(*p.OOMScoreAdj)), 0o644); err != nil {
+			return fmt.Errorf("setting oom_score_adj: %w", err)
+		}
+	}
+
+	if p.NoNewPrivileges {
+		if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+			return fmt.Errorf("setting no_new_privs: %w", err)
+		}
+	}
+
+	caps := p.Capabilities
+	if caps == nil {
+		caps = &Capabilities{}
+	}
+	bounding := newCapabilitySet(caps.Bounding)
+	for bit := uintptr(0); bit <= lastCapability(); bit++ {
+		if bounding.has(bit) {
+			continue
+		}
+		if err := unix.Prctl(unix.PR_CAPBSET_DROP, bit, 0, 0, 0); err != nil && err != unix.EINVAL {
+			return fmt.Errorf("dropping capability %d from the bounding set: %w", bit, err)
+		}
+	}
+
+	// Keep the permitted set across the change of user so that the
+	// requested capabilities can still be raised afterwards.
+	if err := unix.Prctl(unix.PR_SET_KEEPCAPS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("setting keepcaps: %w", err)
+	}
+	groups := make([]int, 0, len(p.User.AdditionalGids))
+	for _, gid := range p.User.AdditionalGids {
+		groups = append(groups, int(gid))
+	}
+	if err := syscall.Setgroups(groups); err != nil {
+		return fmt.Errorf("setting supplementary groups: %w", err)
+	}
+	if err := syscall.Setgid(int(p.User.GID)); err != nil {
+		return fmt.Errorf("setting gid %d: %w", p.User.GID, err)
+	}
+	if err := syscall.Setuid(int(p.User.UID)); err != nil {
+		return fmt.Errorf("setting uid %d: %w", p.User.UID, err)
+	}
+	if err := unix.Prctl(unix.PR_SET_KEEPCAPS, 0, 0, 0, 0); err != nil {
+		return fmt.Errorf("clearing keepcaps: %w", err)
+	}
+
+	effective := newCapabilitySet(caps.Effective)
+	permitted := newCapabilitySet(caps.Permitted)
+	inheritable := newCapabilitySet(caps.Inheritable)
+	header := unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}
+	var data [2]unix.CapUserData
+	for i := range data {
+		data[i] = unix.CapUserData{
+			Effective:   effective[i],
+			Permitted:   permitted[i],
+			Inheritable: inheritable[i],
+		}
+	}
+	if err := unix.Capset(&header, &data[0]); err != nil {
+		return fmt.Errorf("setting capabilities: %w", err)
+	}
+	for _, name := range caps.Ambient {
+		if err := unix.Prctl(unix.PR_CAP_AMBIENT, unix.PR_CAP_AMBIENT_RAISE, capabilities[name], 0, 0); err != nil {
+			return fmt.Errorf("raising ambient capability %s: %w", name, err)
+		}
+	}
+
+	if p.User.Umask != nil {
+		unix.Umask(int(*p.User.Umask))
+	}
+	if err := os.Chdir(p.Cwd); err != nil {
+		return fmt.Errorf("changing to working directory: %w", err)
+	}
+	return nil
+}
+
+// lookPath resolves the process's executable with the PATH of its own
+// environment, as seen from inside the container.
+func lookPath(p *Process) (string, error) {
+	name := p.Args[0]
+	if strings.Contains(name, "/") {
+		if _, err := os.Stat(name); err != nil {
+			return "", fmt.Errorf("executable %q: %w", name, err)
+		}
+		return name, nil
+	}
+
+	path := "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+	for _, env := range p.Env {
+		if value, ok := strings.CutPrefix(env, "PATH="); ok {
+			path = value
+		}
+	}
+	for _, dir := range strings.Split(path, ":") {
+		candidate := dir + "/" + name
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() && info.Mode()&0o111 != 0 {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("executable %q not found in $PATH", name)
+}
@@ -0,0 +1,86 @@
+package oci
+
+import (
+	"log"
+
+	"github.com/urizennnn/boxify/pkg/cgroup"
+)
+
+const defaultCPUPeriod = 100000
+
+// cgroupResources converts the cgroup v1 flavoured limits of a bundle to
+// the cgroup v2 values boxify writes. Settings with no cgroup v2 equivalent
+// boxify implements are logged and skipped.
+func cgroupResources(spec *Resources) cgroup.Resources {
+	r := cgroup.Resources{CPUPeriod: defaultCPUPeriod}
+	if spec == nil {
+		return r
+	}
+
+	if len(spec.Devices) > 0 {
+		log.Printf("Warning: linux.resources.devices is not enforced, cgroup v2 device control needs eBPF")
+	}
+
+	if m := spec.Memory; m != nil {
+		if m.Limit != nil {
+			r.MemoryMax = *m.Limit
+		}
+		if m.Reservation != nil {
+			r.MemoryLow = *m.Reservation
+		}
+		if m.Swap != nil {
+			// The spec limits memory plus swap, cgroup v2 limits swap alone.
+			swap := int64(-1)
+			if *m.Swap >= 0 && m.Limit != nil && *m.Limit > 0 {
+				swap = max(*m.Swap-*m.Limit, 0)
+			}
+			r.MemorySwap = &swap
+		}
+	}
+
+	if c := spec.CPU; c != nil {
+		if c.Period != nil && *c.Period > 0 {
+			r.CPUPeriod = int64(*c.Period)
+		}
+		if c.Quota != nil {
+			r.CPUQuota = *c.Quota
+		}
+		if c.Shares != nil && *c.Shares >= 2 {
+			shares := min(*c.Shares, 262144)
+			r.CPUWeight = 1 + ((shares-2)*9999)/262142
+		}
+		r.CpusetCpus = c.Cpus
+		r.CpusetMems = c.Mems
+	}
+
+	if spec.Pids != nil && spec.Pids.Limit > 0 {
+		r.PidsMax = spec.Pids.Limit
+	}
+
+	if b := spec.BlockIO; b != nil {
+		if b.Weight != nil && *b.Weight >= 10 {
+			weight := min(uint64(*b.Weight), 1000)
+			r.IOWeight = 1 + (weight-10)*9999/990
+		}
+		limits := map[[2]int64]*cgroup.IOLimit{}
+		throttle := func(devices []ThrottleDevice, set func(*cgroup.IOLimit, uint64)) {
+			for _, d := range devices {
+				key := [2]int64{d.Major, d.Minor}
+				limit, ok := limits[key]
+				if !ok {
+					limit = &cgroup.IOLimit{Major: uint32(d.Major), Minor: uint32(d.Minor)}
+					limits[key] = limit
+				}
+				set(limit, d.Rate)
+			}
+		}
+		throttle(b.ThrottleReadBpsDevice, func(l *cgroup.IOLimit, v uint64) { l.ReadBps = v })
+		throttle(b.ThrottleWriteBpsDevice, func(l *cgroup.IOLimit, v uint64) { l.WriteBps = v })
+		throttle(b.ThrottleReadIOPSDevice, func(l *cgroup.IOLimit, v uint64) { l.ReadIOps = v })
+		throttle(b.ThrottleWriteIOPSDevice, func(l *cgroup.IOLimit, v uint64) { l.WriteIOps = v })
+		for _, limit := range limits {
+			r.IOMax = append(r.IOMax, *limit)
+		}
+	}
+	return r
+}
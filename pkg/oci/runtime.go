@@ -0,0 +1,319 @@
+package oci
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/urizennnn/boxify/pkg/cgroup"
+	"github.com/urizennnn/boxify/pkg/container"
+	"golang.org/x/sys/unix"
+)
+
+// cgroupParent holds the cgroups of bundles whose cgroupsPath is relative
+// or unset.
+const cgroupParent = "boxify-oci"
+
+// cloneFlags are the flags that create each namespace type.
+var cloneFlags = map[string]uintptr{
+	"pid":     syscall.CLONE_NEWPID,
+	"network": syscall.CLONE_NEWNET,
+	"mount":   syscall.CLONE_NEWNS,
+	"ipc":     syscall.CLONE_NEWIPC,
+	"uts":     syscall.CLONE_NEWUTS,
+	"user":    syscall.CLONE_NEWUSER,
+	"cgroup":  syscall.CLONE_NEWCGROUP,
+}
+
+// message is exchanged between the runtime and the container's init over
+// their socket pair. The runtime sends "go" once the cgroup and the hooks
+// that run before the container are done, init answers "created" when it
+// waits for start, or "error".
+type message struct {
+	Type    string `json:"type"`
+	State   *State `json:"state,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// Create sets up the container id from bundle and leaves its init waiting
+// for Start. When pidFile is set the PID of the init is written there.
+func (rt *Runtime) Create(id, bundle, pidFile string) error {
+	if !validID.MatchString(id) {
+		return fmt.Errorf("invalid container ID %q", id)
+	}
+	bundle, err := filepath.Abs(bundle)
+	if err != nil {
+		return err
+	}
+	spec, err := LoadSpec(bundle)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(rt.Root, 0o700); err != nil {
+		return err
+	}
+	dir := rt.dir(id)
+	if err := os.Mkdir(dir, 0o700); err != nil {
+		if os.IsExist(err) {
+			return fmt.Errorf("container %s already exists", id)
+		}
+		return err
+	}
+
+	cgroupsPath := spec.Linux.CgroupsPath
+	if cgroupsPath == "" {
+		cgroupsPath = id
+	}
+	cgroupDir, err := cgroup.Resolve(cgroupsPath, cgroupParent)
+	if err != nil {
+		os.RemoveAll(dir)
+		return err
+	}
+
+	rec := &record{
+		ID:          id,
+		Bundle:      bundle,
+		Cgroup:      cgroupDir,
+		Created:     time.Now().UTC(),
+		Annotations: spec.Annotations,
+	}
+	if err := rt.save(rec); err != nil {
+		os.RemoveAll(dir)
+		return err
+	}
+
+	cmd, err := rt.create(spec, rec)
+	if err != nil {
+		if cmd != nil && cmd.Process != nil {
+			cmd.Process.Kill()
+			cmd.Wait()
+		}
+		cgroup.RemovePath(cgroupDir)
+		os.RemoveAll(dir)
+		return err
+	}
+	// The init outlives the runtime and is reparented once it exits.
+	cmd.Process.Release()
+
+	if pidFile != "" {
+		tmp := pidFile + ".tmp"
+		if err := os.WriteFile(tmp, []byte(strconv.Itoa(rec.Pid)), 0o644); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp, pidFile); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (rt *Runtime) create(spec *Spec, rec *record) (*exec.Cmd, error) {
+	fifoPath := filepath.Join(rt.dir(rec.ID), execFifo)
+	if err := unix.Mkfifo(fifoPath, 0o600); err != nil {
+		return nil, fmt.Errorf("failed to create exec fifo: %w", err)
+	}
+	// Opened read-write so the open does not block. The init holds the only
+	// other reference once this copy is closed, so Start can tell whether
+	// anything waits on the FIFO.
+	fifo, err := os.OpenFile(fifoPath, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer fifo.Close()
+
+	fds, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_STREAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sync socket: %w", err)
+	}
+	parent := os.NewFile(uintptr(fds[0]), "sync-parent")
+	child := os.NewFile(uintptr(fds[1]), "sync-child")
+	defer parent.Close()
+
+	cmd := exec.Command("/proc/self/exe", "init")
+	cmd.Dir = rec.Bundle
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = []*os.File{child, fifo}
+	cmd.SysProcAttr = &syscall.SysProcAttr{}
+
+	var join []container.JoinNamespace
+	for _, ns := range spec.Linux.Namespaces {
+		if ns.Path != "" {
+			join = append(join, container.JoinNamespace{Type: cloneFlags[ns.Type], Path: ns.Path})
+			continue
+		}
+		if ns.Type == "cgroup" {
+			// init unshares it once it is in the container's cgroup.
+			continue
+		}
+		cmd.SysProcAttr.Cloneflags |= cloneFlags[ns.Type]
+	}
+	if _, ok := spec.namespace("user"); ok {
+		for _, m := range spec.Linux.UIDMappings {
+			cmd.SysProcAttr.UidMappings = append(cmd.SysProcAttr.UidMappings, syscall.SysProcIDMap{ContainerID: int(m.ContainerID), HostID: int(m.HostID), Size: int(m.Size)})
+		}
+		for _, m := range spec.Linux.GIDMappings {
+			cmd.SysProcAttr.GidMappings = append(cmd.SysProcAttr.GidMappings, syscall.SysProcIDMap{ContainerID: int(m.ContainerID), HostID: int(m.HostID), Size: int(m.Size)})
+		}
+		cmd.SysProcAttr.GidMappingsEnableSetgroups = true
+		// Become root of the new namespace, the runtime's own IDs are not
+		// mapped into it.
+		cmd.SysProcAttr.Credential = &syscall.Credential{Uid: 0, Gid: 0}
+	}
+
+	err = container.StartIn(cmd, join)
+	child.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start container init: %w", err)
+	}
+
+	rec.Pid = cmd.Process.Pid
+	if rec.StartTime, err = processStartTime(rec.Pid); err != nil {
+		return cmd, err
+	}
+	if err := rt.save(rec); err != nil {
+		return cmd, err
+	}
+
+	unified := map[string]string{}
+	if spec.Linux.Resources != nil {
+		unified = spec.Linux.Resources.Unified
+	}
+	if err := cgroup.SetupPath(rec.Cgroup, rec.Pid, cgroupResources(spec.Linux.Resources), unified); err != nil {
+		return cmd, fmt.Errorf("failed to set up cgroup: %w", err)
+	}
+
+	state := &State{Version: Version, ID: rec.ID, Status: StatusCreating, Pid: rec.Pid, Bundle: rec.Bundle, Annotations: rec.Annotations}
+	if spec.Hooks != nil {
+		if err := runHooks("prestart", spec.Hooks.Prestart, state); err != nil {
+			return cmd, err
+		}
+		if err := runHooks("createRuntime", spec.Hooks.CreateRuntime, state); err != nil {
+			return cmd, err
+		}
+	}
+
+	if err := json.NewEncoder(parent).Encode(message{Type: "go", State: state}); err != nil {
+		return cmd, fmt.Errorf("failed to signal container init: %w", err)
+	}
+	var reply message
+	if err := json.NewDecoder(parent).Decode(&reply); err != nil {
+		return cmd, fmt.Errorf("container init exited during setup")
+	}
+	if reply.Type != "created" {
+		return cmd, fmt.Errorf("container init: %s", reply.Message)
+	}
+	return cmd, nil
+}
+
+// Start lets the created container's process run.
+func (rt *Runtime) Start(id string) error {
+	rec, err := rt.load(id)
+	if err != nil {
+		return err
+	}
+	if status := rt.status(rec); status != StatusCreated {
+		return fmt.Errorf("container %s is %s, not created", id, status)
+	}
+
+	fifoPath := filepath.Join(rt.dir(id), execFifo)
+	fifo, err := os.OpenFile(fifoPath, os.O_WRONLY|unix.O_NONBLOCK, 0)
+	if err != nil {
+		if errors.Is(err, unix.ENXIO) {
+			return fmt.Errorf("container %s has no init waiting to start", id)
+		}
+		return err
+	}
+	_, err = fifo.Write([]byte{0})
+	fifo.Close()
+	if err != nil {
+		return fmt.Errorf("failed to start container %s: %w", id, err)
+	}
+	os.Remove(fifoPath)
+
+	spec, err := LoadSpec(rec.Bundle)
+	if err != nil {
+		return err
+	}
+	if spec.Hooks != nil {
+		if err := runHooks("poststart", spec.Hooks.Poststart, rt.state(rec)); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+	return nil
+}
+
+// Kill sends sig to the container's init, or with all to every process in
+// its cgroup.
+func (rt *Runtime) Kill(id string, sig syscall.Signal, all bool) error {
+	rec, err := rt.load(id)
+	if err != nil {
+		return err
+	}
+	if all {
+		return cgroup.SignalPath(rec.Cgroup, sig)
+	}
+	if status := rt.status(rec); status != StatusCreated && status != StatusRunning {
+		return fmt.Errorf("container %s is %s", id, status)
+	}
+	return syscall.Kill(rec.Pid, sig)
+}
+
+// Delete removes a stopped container. With force a container that still
+// runs is killed first.
+func (rt *Runtime) Delete(id string, force bool) error {
+	rec, err := rt.load(id)
+	if err != nil {
+		return err
+	}
+
+	if status := rt.status(rec); status != StatusStopped {
+		if !force && status != StatusCreating {
+			return fmt.Errorf("container %s is %s, stop it first or force the deletion", id, status)
+		}
+		if err := cgroup.SignalPath(rec.Cgroup, syscall.SIGKILL); err != nil {
+			return err
+		}
+		if rec.Pid != 0 && alive(rec.Pid, rec.StartTime) {
+			syscall.Kill(rec.Pid, syscall.SIGKILL)
+		}
+		if !waitEmpty(rec, 10*time.Second) {
+			return fmt.Errorf("container %s did not exit after SIGKILL", id)
+		}
+	}
+
+	if err := cgroup.RemovePath(rec.Cgroup); err != nil {
+		log.Printf("Warning: failed to remove cgroup %s: %v", rec.Cgroup, err)
+	}
+	if spec, err := LoadSpec(rec.Bundle); err == nil && spec.Hooks != nil {
+		state := rt.state(rec)
+		state.Status = StatusStopped
+		if err := runHooks("poststop", spec.Hooks.Poststop, state); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+	return os.RemoveAll(rt.dir(id))
+}
+
+// waitEmpty waits for the init to exit and the container's cgroup to have
+// no processes left.
+func waitEmpty(rec *record, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		pids, err := cgroup.ProcsPath(rec.Cgroup)
+		if (err != nil || len(pids) == 0) && (rec.Pid == 0 || !alive(rec.Pid, rec.StartTime)) {
+			return true
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return false
+}
@@ -0,0 +1,312 @@
+// Package oci runs containers from OCI runtime bundles: a directory with a
+// config.json and the root filesystem it names. It implements the create,
+// start, state, kill and delete operations of the OCI runtime
+// specification on top of boxify's namespace, pivot_root and cgroup code.
+package oci
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Version is the runtime specification version boxify implements.
+const Version = "1.2.0"
+
+// Spec is the subset of the OCI runtime configuration boxify understands.
+type Spec struct {
+	Version     string            `json:"ociVersion"`
+	Process     *Process          `json:"process,omitempty"`
+	Root        *Root             `json:"root,omitempty"`
+	Hostname    string            `json:"hostname,omitempty"`
+	Mounts      []Mount           `json:"mounts,omitempty"`
+	Hooks       *Hooks            `json:"hooks,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Linux       *Linux            `json:"linux,omitempty"`
+}
+
+// Process is the container's main process.
+type Process struct {
+	Terminal        bool          `json:"terminal,omitempty"`
+	User            User          `json:"user"`
+	Args            []string      `json:"args,omitempty"`
+	Env             []string      `json:"env,omitempty"`
+	Cwd             string        `json:"cwd"`
+	Capabilities    *Capabilities `json:"capabilities,omitempty"`
+	Rlimits         []Rlimit      `json:"rlimits,omitempty"`
+	NoNewPrivileges bool          `json:"noNewPrivileges,omitempty"`
+	OOMScoreAdj     *int          `json:"oomScoreAdj,omitempty"`
+}
+
+// User is the identity the process runs as, in the container's user
+// namespace.
+type User struct {
+	UID            uint32   `json:"uid"`
+	GID            uint32   `json:"gid"`
+	Umask          *uint32  `json:"umask,omitempty"`
+	AdditionalGids []uint32 `json:"additionalGids,omitempty"`
+}
+
+// Capabilities lists capability names such as "CAP_CHOWN" per set.
+type Capabilities struct {
+	Bounding    []string `json:"bounding,omitempty"`
+	Effective   []string `json:"effective,omitempty"`
+	Inheritable []string `json:"inheritable,omitempty"`
+	Permitted   []string `json:"permitted,omitempty"`
+	Ambient     []string `json:"ambient,omitempty"`
+}
+
+// Rlimit is a resource limit such as "RLIMIT_NOFILE".
+type Rlimit struct {
+	Type string `json:"type"`
+	Hard uint64 `json:"hard"`
+	Soft uint64 `json:"soft"`
+}
+
+// Root is the container's root filesystem, relative to the bundle unless
+// absolute.
+type Root struct {
+	Path     string `json:"path"`
+	Readonly bool   `json:"readonly,omitempty"`
+}
+
+// Mount is an additional filesystem mounted into the container.
+type Mount struct {
+	Destination string   `json:"destination"`
+	Type        string   `json:"type,omitempty"`
+	Source      string   `json:"source,omitempty"`
+	Options     []string `json:"options,omitempty"`
+}
+
+// Hooks are run at points of the container's lifecycle with its state on
+// standard input.
+type Hooks struct {
+	Prestart        []Hook `json:"prestart,omitempty"`
+	CreateRuntime   []Hook `json:"createRuntime,omitempty"`
+	CreateContainer []Hook `json:"createContainer,omitempty"`
+	StartContainer  []Hook `json:"startContainer,omitempty"`
+	Poststart       []Hook `json:"poststart,omitempty"`
+	Poststop        []Hook `json:"poststop,omitempty"`
+}
+
+// Hook is a command run for a lifecycle event. Timeout is in seconds.
+type Hook struct {
+	Path    string   `json:"path"`
+	Args    []string `json:"args,omitempty"`
+	Env     []string `json:"env,omitempty"`
+	Timeout *int     `json:"timeout,omitempty"`
+}
+
+// Linux holds the Linux specific configuration.
+type Linux struct {
+	UIDMappings       []IDMapping       `json:"uidMappings,omitempty"`
+	GIDMappings       []IDMapping       `json:"gidMappings,omitempty"`
+	Sysctl            map[string]string `json:"sysctl,omitempty"`
+	Resources         *Resources        `json:"resources,omitempty"`
+	CgroupsPath       string            `json:"cgroupsPath,omitempty"`
+	Namespaces        []Namespace       `json:"namespaces,omitempty"`
+	Devices           []Device          `json:"devices,omitempty"`
+	RootfsPropagation string            `json:"rootfsPropagation,omitempty"`
+	MaskedPaths       []string          `json:"maskedPaths,omitempty"`
+	ReadonlyPaths     []string          `json:"readonlyPaths,omitempty"`
+}
+
+// IDMapping maps a range of IDs in the container's user namespace to the
+// host.
+type IDMapping struct {
+	ContainerID uint32 `json:"containerID"`
+	HostID      uint32 `json:"hostID"`
+	Size        uint32 `json:"size"`
+}
+
+// Namespace is created for the container, or joined when Path is set.
+type Namespace struct {
+	Type string `json:"type"`
+	Path string `json:"path,omitempty"`
+}
+
+// Device is a device node created in the container's /dev.
+type Device struct {
+	Type     string       `json:"type"`
+	Path     string       `json:"path"`
+	Major    int64        `json:"major,omitempty"`
+	Minor    int64        `json:"minor,omitempty"`
+	FileMode *os.FileMode `json:"fileMode,omitempty"`
+	UID      *uint32      `json:"uid,omitempty"`
+	GID      *uint32      `json:"gid,omitempty"`
+}
+
+// Resources are the cgroup limits of the container.
+type Resources struct {
+	Devices []DeviceRule      `json:"devices,omitempty"`
+	Memory  *Memory           `json:"memory,omitempty"`
+	CPU     *CPU              `json:"cpu,omitempty"`
+	Pids    *Pids             `json:"pids,omitempty"`
+	BlockIO *BlockIO          `json:"blockIO,omitempty"`
+	Unified map[string]string `json:"unified,omitempty"`
+}
+
+// DeviceRule allows or denies access to devices.
+type DeviceRule struct {
+	Allow  bool   `json:"allow"`
+	Type   string `json:"type,omitempty"`
+	Major  *int64 `json:"major,omitempty"`
+	Minor  *int64 `json:"minor,omitempty"`
+	Access string `json:"access,omitempty"`
+}
+
+// Memory limits, in bytes. Swap is the limit of memory plus swap.
+type Memory struct {
+	Limit       *int64 `json:"limit,omitempty"`
+	Reservation *int64 `json:"reservation,omitempty"`
+	Swap        *int64 `json:"swap,omitempty"`
+}
+
+// CPU limits. Shares are the cgroup v1 relative weight.
+type CPU struct {
+	Shares *uint64 `json:"shares,omitempty"`
+	Quota  *int64  `json:"quota,omitempty"`
+	Period *uint64 `json:"period,omitempty"`
+	Cpus   string  `json:"cpus,omitempty"`
+	Mems   string  `json:"mems,omitempty"`
+}
+
+// Pids limits the number of processes.
+type Pids struct {
+	Limit int64 `json:"limit"`
+}
+
+// BlockIO weights and throttles block devices. Weight uses the cgroup v1
+// range of 10 to 1000.
+type BlockIO struct {
+	Weight                  *uint16          `json:"weight,omitempty"`
+	ThrottleReadBpsDevice   []ThrottleDevice `json:"throttleReadBpsDevice,omitempty"`
+	ThrottleWriteBpsDevice  []ThrottleDevice `json:"throttleWriteBpsDevice,omitempty"`
+	ThrottleReadIOPSDevice  []ThrottleDevice `json:"throttleReadIOPSDevice,omitempty"`
+	ThrottleWriteIOPSDevice []ThrottleDevice `json:"throttleWriteIOPSDevice,omitempty"`
+}
+
+// ThrottleDevice is a rate limit for one block device.
+type ThrottleDevice struct {
+	Major int64  `json:"major"`
+	Minor int64  `json:"minor"`
+	Rate  uint64 `json:"rate"`
+}
+
+// namespaceTypes are the namespaces boxify can create or join.
+var namespaceTypes = map[string]bool{
+	"pid":     true,
+	"network": true,
+	"mount":   true,
+	"ipc":     true,
+	"uts":     true,
+	"user":    true,
+	"cgroup":  true,
+}
+
+// LoadSpec reads and validates the config.json of bundle.
+func LoadSpec(bundle string) (*Spec, error) {
+	data, err := os.ReadFile(filepath.Join(bundle, "config.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bundle config: %w", err)
+	}
+
+	var spec Spec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("failed to parse bundle config: %w", err)
+	}
+	if err := spec.validate(); err != nil {
+		return nil, err
+	}
+	return &spec, nil
+}
+
+func (s *Spec) validate() error {
+	if !strings.HasPrefix(s.Version, "1.") {
+		return fmt.Errorf("unsupported ociVersion %q", s.Version)
+	}
+	if s.Root == nil || s.Root.Path == "" {
+		return fmt.Errorf("root.path is required")
+	}
+	if s.Process == nil || len(s.Process.Args) == 0 {
+		return fmt.Errorf("process.args is required")
+	}
+	if s.Process.Terminal {
+		return fmt.Errorf("process.terminal is not supported, run the container with inherited stdio")
+	}
+	if !filepath.IsAbs(s.Process.Cwd) {
+		return fmt.Errorf("process.cwd %q must be an absolute path", s.Process.Cwd)
+	}
+	if s.Linux == nil {
+		return fmt.Errorf("linux is required")
+	}
+
+	seen := map[string]bool{}
+	for _, ns := range s.Linux.Namespaces {
+		if !namespaceTypes[ns.Type] {
+			return fmt.Errorf("unsupported namespace type %q", ns.Type)
+		}
+		if seen[ns.Type] {
+			return fmt.Errorf("namespace %q is listed twice", ns.Type)
+		}
+		seen[ns.Type] = true
+		if ns.Type == "user" && ns.Path != "" {
+			return fmt.Errorf("joining an existing user namespace is not supported")
+		}
+	}
+	if !seen["mount"] {
+		return fmt.Errorf("a mount namespace is required")
+	}
+	if seen["user"] && (len(s.Linux.UIDMappings) == 0 || len(s.Linux.GIDMappings) == 0) {
+		return fmt.Errorf("a user namespace needs uidMappings and gidMappings")
+	}
+
+	for _, m := range s.Mounts {
+		if !filepath.IsAbs(m.Destination) {
+			return fmt.Errorf("mount destination %q must be an absolute path", m.Destination)
+		}
+	}
+	for _, capability := range s.capabilityNames() {
+		if _, ok := capabilities[capability]; !ok {
+			return fmt.Errorf("unknown capability %q", capability)
+		}
+	}
+	for _, rlimit := range s.Process.Rlimits {
+		if _, ok := rlimits[rlimit.Type]; !ok {
+			return fmt.Errorf("unknown rlimit %q", rlimit.Type)
+		}
+	}
+	return nil
+}
+
+func (s *Spec) capabilityNames() []string {
+	c := s.Process.Capabilities
+	if c == nil {
+		return nil
+	}
+	var names []string
+	for _, set := range [][]string{c.Bounding, c.Effective, c.Inheritable, c.Permitted, c.Ambient} {
+		names = append(names, set...)
+	}
+	return names
+}
+
+// namespace returns the entry for a namespace type, if the spec has one.
+func (s *Spec) namespace(nsType string) (Namespace, bool) {
+	for _, ns := range s.Linux.Namespaces {
+		if ns.Type == nsType {
+			return ns, true
+		}
+	}
+	return Namespace{}, false
+}
+
+// rootfs is the absolute path of the container's root filesystem.
+func (s *Spec) rootfs(bundle string) string {
+	if filepath.IsAbs(s.Root.Path) {
+		return s.Root.Path
+	}
+	return filepath.Join(bundle, s.Root.Path)
+}
@@ -0,0 +1,177 @@
+package oci
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// DefaultRoot is where the runtime keeps the state of its containers.
+const DefaultRoot = "/run/boxify-oci"
+
+// Container statuses of the runtime specification.
+const (
+	StatusCreating = "creating"
+	StatusCreated  = "created"
+	StatusRunning  = "running"
+	StatusStopped  = "stopped"
+)
+
+// execFifo is the FIFO the container's init blocks on until start.
+const execFifo = "exec.fifo"
+
+// ErrNotFound is returned for a container ID the runtime does not know.
+var ErrNotFound = errors.New("container does not exist")
+
+var validID = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// State is the state of a container as the runtime specification defines
+// it.
+type State struct {
+	Version     string            `json:"ociVersion"`
+	ID          string            `json:"id"`
+	Status      string            `json:"status"`
+	Pid         int               `json:"pid,omitempty"`
+	Bundle      string            `json:"bundle"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// record is what the runtime stores for a container between commands.
+type record struct {
+	ID          string            `json:"id"`
+	Bundle      string            `json:"bundle"`
+	Pid         int               `json:"pid"`
+	StartTime   uint64            `json:"startTime"`
+	Cgroup      string            `json:"cgroup"`
+	Created     time.Time         `json:"created"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// Runtime manages the containers whose state lives below Root.
+type Runtime struct {
+	Root string
+}
+
+func (rt *Runtime) dir(id string) string {
+	return filepath.Join(rt.Root, id)
+}
+
+func (rt *Runtime) load(id string) (*record, error) {
+	if !validID.MatchString(id) {
+		return nil, fmt.Errorf("invalid container ID %q", id)
+	}
+	data, err := os.ReadFile(filepath.Join(rt.dir(id), "state.json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+		}
+		return nil, err
+	}
+	var rec record
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, fmt.Errorf("failed to parse state of %s: %w", id, err)
+	}
+	return &rec, nil
+}
+
+func (rt *Runtime) save(rec *record) error {
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(rt.dir(rec.ID), "state.json")
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// status works out the container's status from its init process. The init
+// waits on the exec FIFO until start, which removes it.
+func (rt *Runtime) status(rec *record) string {
+	if rec.Pid == 0 {
+		return StatusCreating
+	}
+	if !alive(rec.Pid, rec.StartTime) {
+		return StatusStopped
+	}
+	if _, err := os.Stat(filepath.Join(rt.dir(rec.ID), execFifo)); err == nil {
+		return StatusCreated
+	}
+	return StatusRunning
+}
+
+func (rt *Runtime) state(rec *record) *State {
+	s := &State{
+		Version:     Version,
+		ID:          rec.ID,
+		Status:      rt.status(rec),
+		Bundle:      rec.Bundle,
+		Annotations: rec.Annotations,
+	}
+	if s.Status != StatusStopped {
+		s.Pid = rec.Pid
+	}
+	return s
+}
+
+// State returns the state of the container id.
+func (rt *Runtime) State(id string) (*State, error) {
+	rec, err := rt.load(id)
+	if err != nil {
+		return nil, err
+	}
+	return rt.state(rec), nil
+}
+
+// List returns the state of every container, ordered by ID.
+func (rt *Runtime) List() ([]*State, error) {
+	entries, err := os.ReadDir(rt.Root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var states []*State
+	for _, entry := range entries {
+		rec, err := rt.load(entry.Name())
+		if err != nil {
+			continue
+		}
+		states = append(states, rt.state(rec))
+	}
+	return states, nil
+}
+
+// processStartTime is the start time of pid in clock ticks since boot, used
+// to tell the container's init from a later process that reuses its PID.
+func processStartTime(pid int) (uint64, error) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return 0, err
+	}
+	// The command name may contain spaces, the fields after it do not.
+	stat := string(data)
+	fields := strings.Fields(stat[strings.LastIndexByte(stat, ')')+1:])
+	if len(fields) < 20 {
+		return 0, fmt.Errorf("unexpected format of /proc/%d/stat", pid)
+	}
+	if fields[0] == "Z" || fields[0] == "X" {
+		return 0, syscall.ESRCH
+	}
+	return strconv.ParseUint(fields[19], 10, 64)
+}
+
+func alive(pid int, startTime uint64) bool {
+	current, err := processStartTime(pid)
+	return err == nil && current == startTime
+}
@@ -0,0 +1,78 @@
+package pod
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/urizennnn/boxify/pkg/daemon/types"
+	"gopkg.in/yaml.v3"
+)
+
+const StateDir = "/var/lib/boxify/pods"
+
+func stateFile(podID string) string {
+	return filepath.Join(StateDir, podID+".yaml")
+}
+
+// SaveState persists the pod record, replacing any previous copy.
+func SaveState(p *types.Pod) error {
+	if err := os.MkdirAll(StateDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create pod state directory: %w", err)
+	}
+
+	data, err := yaml.Marshal(p)
+	if err != nil {
+		return fmt.Errorf("failed to marshal pod state: %w", err)
+	}
+
+	tmp := stateFile(p.ID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write pod state: %w", err)
+	}
+	if err := os.Rename(tmp, stateFile(p.ID)); err != nil {
+		return fmt.Errorf("failed to replace pod state: %w", err)
+	}
+	return nil
+}
+
+// LoadStates reads every persisted pod record. Records that cannot be
+// parsed are logged and skipped.
+func LoadStates() ([]*types.Pod, error) {
+	entries, err := os.ReadDir(StateDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read pod state directory: %w", err)
+	}
+
+	var pods []*types.Pod
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".yaml" {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(StateDir, entry.Name()))
+		if err != nil {
+			log.Printf("Skipping pod %s: %v\n", entry.Name(), err)
+			continue
+		}
+
+		var p types.Pod
+		if err := yaml.Unmarshal(data, &p); err != nil {
+			log.Printf("Skipping pod %s: %v\n", entry.Name(), err)
+			continue
+		}
+		pods = append(pods, &p)
+	}
+	return pods, nil
+}
+
+func RemoveState(podID string) error {
+	if err := os.Remove(stateFile(podID)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove pod state: %w", err)
+	}
+	return nil
+}
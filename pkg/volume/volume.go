@@ -0,0 +1,211 @@
+package volume
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+)
+
+const VolumeDir = "/var/lib/boxify/volumes"
+
+var (
+	ErrVolumeNotFound = errors.New("volume not found")
+	ErrVolumeInUse    = errors.New("volume is in use")
+)
+
+var validName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// mu serialises changes to the volume directory.
+var mu sync.Mutex
+
+type Volume struct {
+	Name        string
+	Driver      string
+	Mountpoint  string
+	Labels      map[string]string
+	Annotations map[string]string
+	CreatedAt   time.Time
+}
+
+func metadataFile(name string) string {
+	return filepath.Join(VolumeDir, name, "volume.yaml")
+}
+
+func dataDir(name string) string {
+	return filepath.Join(VolumeDir, name, "_data")
+}
+
+// Create makes a new local volume. Creating a volume that already exists
+// returns the existing one unchanged. An empty name gets a random one.
+func Create(name string, labels, annotations map[string]string) (*Volume, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if name == "" {
+		name = strings.ReplaceAll(uuid.New().String(), "-", "")
+	}
+	if !validName.MatchString(name) {
+		return nil, fmt.Errorf("invalid volume name %q, only [a-zA-Z0-9][a-zA-Z0-9_.-] are allowed", name)
+	}
+
+	if existing, err := get(name); err == nil {
+		return existing, nil
+	}
+
+	if err := os.MkdirAll(dataDir(name), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create volume directory: %w", err)
+	}
+
+	v := &Volume{
+		Name:        name,
+		Driver:      "local",
+		Mountpoint:  dataDir(name),
+		Labels:      labels,
+		Annotations: annotations,
+		CreatedAt:   time.Now(),
+	}
+
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal volume: %w", err)
+	}
+	if err := os.WriteFile(metadataFile(name), data, 0o644); err != nil {
+		return nil, fmt.Errorf("failed to write volume metadata: %w", err)
+	}
+
+	log.Printf("Created volume %s", name)
+	return v, nil
+}
+
+func Get(name string) (*Volume, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	return get(name)
+}
+
+func get(name string) (*Volume, error) {
+	data, err := os.ReadFile(metadataFile(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrVolumeNotFound
+		}
+		return nil, fmt.Errorf("failed to read volume metadata: %w", err)
+	}
+
+	var v Volume
+	if err := yaml.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("failed to parse volume metadata: %w", err)
+	}
+	return &v, nil
+}
+
+func List() ([]*Volume, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	entries, err := os.ReadDir(VolumeDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read volume directory: %w", err)
+	}
+
+	var volumes []*Volume
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		v, err := get(entry.Name())
+		if err != nil {
+			log.Printf("Skipping volume %s: %v", entry.Name(), err)
+			continue
+		}
+		volumes = append(volumes, v)
+	}
+	return volumes, nil
+}
+
+// Remove deletes the volume and its data. Callers are responsible for
+// checking that no container still uses it.
+func Remove(name string) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, err := get(name); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(filepath.Join(VolumeDir, name)); err != nil {
+		return fmt.Errorf("failed to remove volume: %w", err)
+	}
+
+	log.Printf("Removed volume %s", name)
+	return nil
+}
+
+// ParseSpec splits a "name:/path[:ro]" volume flag.
+func ParseSpec(spec string) (name, destination string, readOnly bool, err error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return "", "", false, fmt.Errorf("invalid volume %q, expected name:/path[:ro]", spec)
+	}
+
+	name, destination = parts[0], parts[1]
+	if len(parts) == 3 {
+		switch parts[2] {
+		case "ro":
+			readOnly = true
+		case "rw":
+		default:
+			return "", "", false, fmt.Errorf("invalid volume mode %q in %q", parts[2], spec)
+		}
+	}
+
+	if !filepath.IsAbs(destination) {
+		return "", "", false, fmt.Errorf("volume destination %q must be an absolute path", destination)
+	}
+	return name, filepath.Clean(destination), readOnly, nil
+}
+
+// Mount bind mounts the volume onto destination inside rootDir. The mount is
+// made in the caller's mount namespace, so it must happen before the
+// container's namespace is cloned.
+func Mount(v *Volume, rootDir, destination string, readOnly bool) error {
+	target := filepath.Join(rootDir, destination)
+	if err := os.MkdirAll(target, 0o755); err != nil {
+		return fmt.Errorf("failed to create mount point %s: %w", destination, err)
+	}
+
+	if err := syscall.Mount(v.Mountpoint, target, "", syscall.MS_BIND, ""); err != nil {
+		return fmt.Errorf("failed to mount volume %s: %w", v.Name, err)
+	}
+
+	if readOnly {
+		flags := uintptr(syscall.MS_BIND | syscall.MS_REMOUNT | syscall.MS_RDONLY)
+		if err := syscall.Mount("", target, "", flags, ""); err != nil {
+			syscall.Unmount(target, syscall.MNT_DETACH)
+			return fmt.Errorf("failed to make volume %s read-only: %w", v.Name, err)
+		}
+	}
+
+	log.Printf("Mounted volume %s at %s", v.Name, target)
+	return nil
+}
+
+func Unmount(rootDir, destination string) error {
+	target := filepath.Join(rootDir, destination)
+	if err := syscall.Unmount(target, syscall.MNT_DETACH); err != nil && err != syscall.EINVAL && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to unmount %s: %w", target, err)
+	}
+	return nil
+}